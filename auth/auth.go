@@ -1,12 +1,18 @@
 package auth
 
 import (
+	"sync"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
+// PasswordCost is the bcrypt cost of new password hashes. Tests lower it to
+// bcrypt.MinCost.
+var PasswordCost = 14
+
 // HashPassword generates a bcrypt hash of the password.
 func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), PasswordCost)
 	return string(bytes), err
 }
 
@@ -15,3 +21,15 @@ func CheckPasswordHash(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+// dummyHash is a hash no password is checked against successfully, made on first use.
+var dummyHash = sync.OnceValue(func() string {
+	hash, _ := HashPassword("no user has this password")
+	return hash
+})
+
+// CheckUnknownUser takes as long as CheckPasswordHash. Sign in calls it when the
+// username does not exist, so that response times do not tell which usernames do.
+func CheckUnknownUser(password string) {
+	CheckPasswordHash(password, dummyHash())
+}
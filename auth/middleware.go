@@ -1,13 +1,61 @@
 package auth
 
 import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"fmt"
+	"log"
 	"net/http"
+	"strings"
+	"time"
 	"tiny-invoicing/database"
+	"tiny-invoicing/models"
 	"tiny-invoicing/response"
 )
 
-// BasicAuth wraps a handler and provides basic authentication.
-func BasicAuth(next http.HandlerFunc) http.HandlerFunc {
+// UserStore looks up the users that may sign in.
+type UserStore interface {
+	GetUserByUsername(username string) (*database.User, error)
+}
+
+// Store looks up users, their login sessions and their API tokens.
+type Store interface {
+	UserStore
+	GetSession(tokenHash string, now time.Time) (*database.Session, error)
+	UseAPIToken(tokenHash string, now time.Time) (*database.APIToken, error)
+}
+
+// userKey, sessionKey and apiTokenKey are the context keys of the signed-in user,
+// session and API token.
+type userKey struct{}
+type sessionKey struct{}
+type apiTokenKey struct{}
+
+// CurrentUser returns the user signed in for the request, or nil outside the
+// authentication middleware.
+func CurrentUser(r *http.Request) *database.User {
+	user, _ := r.Context().Value(userKey{}).(*database.User)
+	return user
+}
+
+// CurrentSession returns the session the request was authenticated with, or nil if
+// it was not authenticated by session cookie.
+func CurrentSession(r *http.Request) *database.Session {
+	session, _ := r.Context().Value(sessionKey{}).(*database.Session)
+	return session
+}
+
+// CurrentAPIToken returns the API token the request was authenticated with, or nil
+// if it was not authenticated by bearer token.
+func CurrentAPIToken(r *http.Request) *database.APIToken {
+	token, _ := r.Context().Value(apiTokenKey{}).(*database.APIToken)
+	return token
+}
+
+// BasicAuth wraps a handler and provides basic authentication against the users of store.
+// The handler finds the signed-in user with CurrentUser; Require checks what they may do.
+func BasicAuth(users UserStore, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		username, password, ok := r.BasicAuth()
 		if !ok {
@@ -15,16 +63,121 @@ func BasicAuth(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		user, err := database.GetUserByUsername(username)
+		user, err := users.GetUserByUsername(username)
 		if err != nil {
+			CheckUnknownUser(password)
 			response.Error(w, http.StatusUnauthorized, "Invalid credentials")
 			return
 		}
 
-		if CheckPasswordHash(password, user.PasswordHash) && user.IsAdmin {
-			next.ServeHTTP(w, r)
+		if CheckPasswordHash(password, user.PasswordHash) {
+			if !HasRole(w, user) {
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userKey{}, user)))
 		} else {
 			response.Error(w, http.StatusUnauthorized, "Invalid credentials")
 		}
 	}
 }
+
+// Authenticate wraps a handler and signs the user in with an API token in an
+// "Authorization: Bearer" header, the session cookie or, without either, with basic
+// authentication. Requests that change state using the cookie must carry the
+// session's CSRF token in the X-CSRF-Token header.
+func Authenticate(store Store, next http.HandlerFunc) http.HandlerFunc {
+	basic := BasicAuth(store, next)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+			bearerAuth(store, next, w, r, strings.TrimPrefix(header, "Bearer "))
+			return
+		}
+
+		cookie, err := r.Cookie(SessionCookie)
+		if err != nil || cookie.Value == "" {
+			basic(w, r)
+			return
+		}
+
+		session, err := store.GetSession(HashToken(cookie.Value), time.Now())
+		if err != nil && err != sql.ErrNoRows {
+			log.Printf("Error loading session: %v", err)
+			response.Error(w, http.StatusInternalServerError, "Failed to check session")
+			return
+		}
+		if err == sql.ErrNoRows {
+			ClearSessionCookie(w)
+			response.Error(w, http.StatusUnauthorized, "Session expired, please log in again")
+			return
+		}
+		if !safeMethod(r.Method) && subtle.ConstantTimeCompare([]byte(r.Header.Get(CSRFHeader)), []byte(session.CSRFToken)) != 1 {
+			response.Error(w, http.StatusForbidden, "Missing or invalid CSRF token")
+			return
+		}
+		if !HasRole(w, session.User) {
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), userKey{}, session.User)
+		ctx = context.WithValue(ctx, sessionKey{}, session)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+// bearerAuth signs the user in with an API token.
+func bearerAuth(store Store, next http.HandlerFunc, w http.ResponseWriter, r *http.Request, token string) {
+	apiToken, err := store.UseAPIToken(HashToken(token), time.Now())
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error loading API token: %v", err)
+		response.Error(w, http.StatusInternalServerError, "Failed to check API token")
+		return
+	}
+	if err == sql.ErrNoRows {
+		response.Error(w, http.StatusUnauthorized, "Invalid API token")
+		return
+	}
+	if !HasRole(w, apiToken.User) {
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), userKey{}, apiToken.User)
+	ctx = context.WithValue(ctx, apiTokenKey{}, apiToken)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// HasRole reports whether user has been assigned a role, and responds with 403
+// Forbidden if not: users without a role cannot sign in.
+func HasRole(w http.ResponseWriter, user *database.User) bool {
+	if user.Role == models.RoleNone {
+		response.Error(w, http.StatusForbidden, "Your account has no role yet; ask an admin to assign one")
+		return false
+	}
+	return true
+}
+
+// Require wraps a handler behind Authenticate or BasicAuth and lets the request through
+// only if the signed-in user's role has permission and, for requests made with an API
+// token, the token was granted it as a scope.
+func Require(permission models.Permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := CurrentUser(r)
+		if user == nil {
+			response.Error(w, http.StatusUnauthorized, "Authentication required")
+			return
+		}
+		if !user.Role.Can(permission) {
+			response.Error(w, http.StatusForbidden, fmt.Sprintf("The %s role does not have the %s permission", user.Role, permission))
+			return
+		}
+		if token := CurrentAPIToken(r); token != nil && !token.HasScope(string(permission)) {
+			response.Error(w, http.StatusForbidden, fmt.Sprintf("API token lacks the %s scope", permission))
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// safeMethod reports whether requests with method only read.
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
@@ -0,0 +1,297 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"tiny-invoicing/database"
+	"tiny-invoicing/database/memstore"
+	"tiny-invoicing/models"
+)
+
+func TestMain(m *testing.M) {
+	PasswordCost = bcrypt.MinCost
+	os.Exit(m.Run())
+}
+
+// newStore returns a store with the admin "admin" and the viewer "clerk", both with
+// the password "secret", and a session of admin.
+func newStore(t *testing.T) (*memstore.Store, *database.Session, string) {
+	store := memstore.New()
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := store.CreateUser(&database.User{Username: "admin", PasswordHash: string(hash), Role: models.RoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.CreateUser(&database.User{Username: "clerk", PasswordHash: string(hash), Role: models.RoleViewer}); err != nil {
+		t.Fatal(err)
+	}
+
+	session, token, err := NewSession(&database.User{ID: int(id)}, time.Now(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionID, err := store.CreateSession(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.ID = int(sessionID)
+	return store, session, token
+}
+
+func TestAuthenticate(t *testing.T) {
+	store, session, token := newStore(t)
+	var signedIn *database.User
+	handler := Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		signedIn = CurrentUser(r)
+		if CurrentSession(r) != nil && CurrentSession(r).ID != session.ID {
+			t.Errorf("CurrentSession = %+v, want session %d", CurrentSession(r), session.ID)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name     string
+		method   string
+		cookie   string
+		csrf     string
+		username string
+		password string
+		want     int
+	}{
+		{"cookie", "GET", token, "", "", "", http.StatusNoContent},
+		{"cookie with CSRF token", "POST", token, session.CSRFToken, "", "", http.StatusNoContent},
+		{"cookie without CSRF token", "POST", token, "", "", "", http.StatusForbidden},
+		{"cookie with wrong CSRF token", "DELETE", token, "x" + session.CSRFToken, "", "", http.StatusForbidden},
+		{"unknown cookie", "GET", "forged", "", "admin", "secret", http.StatusUnauthorized},
+		{"basic", "POST", "", "", "admin", "secret", http.StatusNoContent},
+		{"basic with wrong password", "GET", "", "", "admin", "wrong", http.StatusUnauthorized},
+		{"nothing", "GET", "", "", "", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		signedIn = nil
+		req := httptest.NewRequest(tt.method, "/api/invoices", nil)
+		if tt.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: SessionCookie, Value: tt.cookie})
+		}
+		if tt.csrf != "" {
+			req.Header.Set(CSRFHeader, tt.csrf)
+		}
+		if tt.username != "" {
+			req.SetBasicAuth(tt.username, tt.password)
+		}
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+
+		if rr.Code != tt.want {
+			t.Errorf("%s: got status %d want %d", tt.name, rr.Code, tt.want)
+		}
+		if tt.want == http.StatusNoContent && (signedIn == nil || signedIn.Username != "admin") {
+			t.Errorf("%s: signed in as %+v, want admin", tt.name, signedIn)
+		}
+	}
+}
+
+func TestAuthenticate_ExpiredSession(t *testing.T) {
+	store, session, token := newStore(t)
+	if err := store.DeleteSession(session.UserID, session.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/invoices", nil)
+	req.AddCookie(&http.Cookie{Name: SessionCookie, Value: token})
+	rr := httptest.NewRecorder()
+	Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler called for a revoked session")
+	})(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("got status %d want %d", rr.Code, http.StatusUnauthorized)
+	}
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != SessionCookie || cookies[0].MaxAge >= 0 {
+		t.Errorf("cookies = %+v, want the session cookie cleared", cookies)
+	}
+}
+
+func TestAuthenticate_APIToken(t *testing.T) {
+	store, _, _ := newStore(t)
+	admin, err := store.GetUserByUsername("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiToken, token, err := NewAPIToken(admin, "script", []string{ScopeRead}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.CreateAPIToken(apiToken); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"token", token, http.StatusNoContent},
+		{"unknown token", APITokenPrefix + "forged", http.StatusUnauthorized},
+	} {
+		var signedIn *database.User
+		req := httptest.NewRequest("POST", "/api/invoices", nil)
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		rr := httptest.NewRecorder()
+		Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+			signedIn = CurrentUser(r)
+			if CurrentAPIToken(r) == nil {
+				t.Errorf("%s: CurrentAPIToken is nil", tt.name)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})(rr, req)
+
+		// Bearer requests need no CSRF token.
+		if rr.Code != tt.want {
+			t.Errorf("%s: got status %d want %d", tt.name, rr.Code, tt.want)
+		}
+		if tt.want == http.StatusNoContent && (signedIn == nil || signedIn.Username != "admin") {
+			t.Errorf("%s: signed in as %+v, want admin", tt.name, signedIn)
+		}
+	}
+
+	tokens, err := store.GetUserAPITokens(admin.ID)
+	if err != nil || len(tokens) != 1 || tokens[0].LastUsedAt == nil {
+		t.Errorf("GetUserAPITokens = %+v, %v; want the token with its last use", tokens, err)
+	}
+}
+
+func TestAuthenticate_NoRole(t *testing.T) {
+	// Users without a role, such as non-admins from before roles, are turned away
+	// however they sign in, even with a session or token from before.
+	store, _, _ := newStore(t)
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := store.CreateUser(&database.User{Username: "former", PasswordHash: string(hash), Role: models.RoleNone})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &database.User{ID: int(id), Username: "former"}
+	session, cookie, err := NewSession(user, time.Now(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.CreateSession(session); err != nil {
+		t.Fatal(err)
+	}
+	apiToken, token, err := NewAPIToken(user, "script", []string{ScopeRead}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.CreateAPIToken(apiToken); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, sign := range map[string]func(*http.Request){
+		"basic":  func(r *http.Request) { r.SetBasicAuth("former", "secret") },
+		"cookie": func(r *http.Request) { r.AddCookie(&http.Cookie{Name: SessionCookie, Value: cookie}) },
+		"token":  func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) },
+	} {
+		req := httptest.NewRequest("GET", "/api/invoices", nil)
+		sign(req)
+		rr := httptest.NewRecorder()
+		Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+			t.Errorf("%s: handler called for a user without a role", name)
+		})(rr, req)
+
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("%s: got status %d want %d", name, rr.Code, http.StatusForbidden)
+		}
+	}
+}
+
+func TestRequire(t *testing.T) {
+	store, _, _ := newStore(t)
+	tokens := make(map[string]string)
+	for _, username := range []string{"admin", "clerk"} {
+		user, err := store.GetUserByUsername(username)
+		if err != nil {
+			t.Fatal(err)
+		}
+		apiToken, token, err := NewAPIToken(user, "script", []string{ScopeRead, ScopeInvoicesWrite}, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.CreateAPIToken(apiToken); err != nil {
+			t.Fatal(err)
+		}
+		tokens[username] = token
+	}
+
+	tests := []struct {
+		name       string
+		username   string
+		bearer     bool
+		permission models.Permission
+		want       int
+	}{
+		{"admin reads", "admin", false, models.PermissionRead, http.StatusNoContent},
+		{"admin changes settings", "admin", false, models.PermissionWriteSettings, http.StatusNoContent},
+		{"viewer reads", "clerk", false, models.PermissionRead, http.StatusNoContent},
+		{"viewer writes invoices", "clerk", false, models.PermissionWriteInvoices, http.StatusForbidden},
+		{"viewer manages users", "clerk", false, models.PermissionManageUsers, http.StatusForbidden},
+		{"token writes invoices", "admin", true, models.PermissionWriteInvoices, http.StatusNoContent},
+		{"token writes customers", "admin", true, models.PermissionWriteCustomers, http.StatusForbidden},
+		{"token changes settings", "admin", true, models.PermissionWriteSettings, http.StatusForbidden},
+		{"token manages its account", "admin", true, models.PermissionAccount, http.StatusForbidden},
+		// A token can do no more than its user's role allows, whatever its scopes.
+		{"viewer's token writes invoices", "clerk", true, models.PermissionWriteInvoices, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/api/invoices", nil)
+		if tt.bearer {
+			req.Header.Set("Authorization", "Bearer "+tokens[tt.username])
+		} else {
+			req.SetBasicAuth(tt.username, "secret")
+		}
+		rr := httptest.NewRecorder()
+		Authenticate(store, Require(tt.permission, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))(rr, req)
+
+		if rr.Code != tt.want {
+			t.Errorf("%s: got status %d want %d", tt.name, rr.Code, tt.want)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	Require(models.PermissionRead, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler called without a signed-in user")
+	})(rr, httptest.NewRequest("GET", "/api/invoices", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("without Authenticate: got status %d want %d", rr.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestCheckUnknownUser(t *testing.T) {
+	// Unknown usernames are checked against a hash as costly as real ones.
+	hash, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	CheckUnknownUser("secret")
+	if cost, err := bcrypt.Cost([]byte(dummyHash())); err != nil || cost != want {
+		t.Errorf("dummy hash cost = %d, %v; want %d", cost, err, want)
+	}
+}
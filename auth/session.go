@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"tiny-invoicing/database"
+)
+
+// SessionCookie is the name of the cookie holding the session token.
+const SessionCookie = "session"
+
+// CSRFHeader carries the session's CSRF token on requests that change state using
+// the session cookie.
+const CSRFHeader = "X-CSRF-Token"
+
+// DefaultSessionTTL is how long a session lasts by default.
+const DefaultSessionTTL = 12 * time.Hour
+
+// NewToken returns a random token of 32 bytes, URL-safe base64 encoded.
+func NewToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the SHA-256 hash under which a token is stored. Tokens are
+// random, so unlike passwords they need no salt or slow hash.
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// NewSession starts a session for user at now, lasting ttl. It returns the session
+// to store and the token for the cookie, which is not stored.
+func NewSession(user *database.User, now time.Time, ttl time.Duration) (*database.Session, string, error) {
+	token, err := NewToken()
+	if err != nil {
+		return nil, "", err
+	}
+	csrf, err := NewToken()
+	if err != nil {
+		return nil, "", err
+	}
+	session := &database.Session{
+		TokenHash: HashToken(token),
+		CSRFToken: csrf,
+		UserID:    user.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+		User:      user,
+	}
+	return session, token, nil
+}
+
+// SetSessionCookie sends the session cookie. It is only sent over HTTPS, is hidden
+// from scripts and is withheld from cross-site subrequests.
+func SetSessionCookie(w http.ResponseWriter, token string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// ClearSessionCookie tells the browser to forget the session cookie.
+func ClearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
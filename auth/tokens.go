@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"time"
+
+	"tiny-invoicing/database"
+	"tiny-invoicing/models"
+)
+
+// The scopes an API token can be granted: the permissions of models.Role that
+// scripts may use. A token can do what its scopes and its user's role both allow.
+const (
+	ScopeRead           = string(models.PermissionRead)
+	ScopeInvoicesWrite  = string(models.PermissionWriteInvoices)
+	ScopeCustomersWrite = string(models.PermissionWriteCustomers)
+)
+
+// Scopes lists the scopes an API token can be granted.
+var Scopes = []string{ScopeRead, ScopeInvoicesWrite, ScopeCustomersWrite}
+
+// APITokenPrefix starts every API token, so that leaked tokens are easy to spot.
+const APITokenPrefix = "tinv_"
+
+// ValidScope reports whether scope is one of Scopes.
+func ValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// NewAPIToken creates an API token named name for user at now. It returns the token
+// to store and the token itself, which is not stored and is shown only once.
+func NewAPIToken(user *database.User, name string, scopes []string, now time.Time) (*database.APIToken, string, error) {
+	random, err := NewToken()
+	if err != nil {
+		return nil, "", err
+	}
+	token := APITokenPrefix + random
+	apiToken := &database.APIToken{
+		UserID:    user.ID,
+		Name:      name,
+		TokenHash: HashToken(token),
+		Scopes:    scopes,
+		CreatedAt: now,
+		User:      user,
+	}
+	return apiToken, token, nil
+}
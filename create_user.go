@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"tiny-invoicing/auth"
+	"tiny-invoicing/database"
+	"tiny-invoicing/models"
+)
+
+const createUserUsage = "usage: tiny-invoicing create-user -username NAME [-role owner|admin|accountant|viewer] < password"
+
+// runCreateUser runs the create-user subcommand, which adds a user with the password
+// read from the first line of stdin. It is how the first owner is created without
+// the setup token, and how access is regained when every owner is locked out.
+func runCreateUser(args []string, stdin io.Reader) error {
+	flags := flag.NewFlagSet("create-user", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	username := flags.String("username", "", "")
+	roleName := flags.String("role", string(models.RoleOwner), "")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 || *username == "" {
+		return errors.New(createUserUsage)
+	}
+	role, err := models.ParseRole(*roleName)
+	if err != nil {
+		return fmt.Errorf("%v\n%s", err, createUserUsage)
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	password = strings.TrimRight(password, "\r\n")
+	if password == "" {
+		return errors.New("a password is required")
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	id, err := database.CreateUser(&database.User{Username: *username, PasswordHash: hash, Role: role})
+	if err != nil {
+		if err == database.ErrUsernameTaken {
+			return fmt.Errorf("username %q is already taken", *username)
+		}
+		return err
+	}
+	log.Printf("Created %s %q (ID %d)", role, *username, id)
+	return nil
+}
@@ -0,0 +1,30 @@
+package database
+
+import "tiny-invoicing/models"
+
+// amounts collects DECIMAL columns as text while a row is scanned, so they can be
+// parsed with the right number of decimal places once the row's currency is known.
+type amounts struct {
+	dest []*models.Money
+	text []*string
+}
+
+// col returns the Scan destination for a column whose amount belongs in m.
+func (a *amounts) col(m *models.Money) interface{} {
+	text := new(string)
+	a.dest = append(a.dest, m)
+	a.text = append(a.text, text)
+	return text
+}
+
+// parse converts the scanned columns into amounts of the given currency.
+func (a *amounts) parse(currency string) error {
+	for k, m := range a.dest {
+		parsed, err := models.ParseMoney(*a.text[k], currency, models.RoundHalfUp)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// APIToken is a token a user has created for scripts to call the API with.
+type APIToken struct {
+	ID     int    `json:"id"`
+	UserID int    `json:"user_id"`
+	Name   string `json:"name"`
+	// TokenHash is the SHA-256 hash of the token, which is only shown when created.
+	TokenHash  string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	// User is the user the token acts for, filled in by UseAPIToken.
+	User *User `json:"-"`
+}
+
+// CreateAPIToken stores a new API token and returns its ID.
+func CreateAPIToken(token *APIToken) (int64, error) {
+	return insert(DB, "INSERT INTO api_tokens (user_id, name, token_hash, scopes, created_at) VALUES (?, ?, ?, ?, ?)",
+		token.UserID, token.Name, token.TokenHash, strings.Join(token.Scopes, " "), token.CreatedAt.UTC())
+}
+
+// UseAPIToken retrieves the API token with the hash, and its user, and records that
+// it was used at now. It returns sql.ErrNoRows if there is no such token.
+func UseAPIToken(tokenHash string, now time.Time) (*APIToken, error) {
+	token := APIToken{TokenHash: tokenHash, User: &User{}}
+	var scopes string
+	err := DB.QueryRow(`SELECT t.id, t.user_id, t.name, t.scopes, t.created_at, u.id, u.username, u.password_hash, COALESCE(u.role, '')
+		FROM api_tokens t JOIN users u ON u.id = t.user_id WHERE t.token_hash = ?`, tokenHash).Scan(
+		&token.ID, &token.UserID, &token.Name, &scopes, &token.CreatedAt,
+		&token.User.ID, &token.User.Username, &token.User.PasswordHash, &token.User.Role)
+	if err != nil {
+		return nil, err
+	}
+	token.Scopes = strings.Fields(scopes)
+
+	if _, err := DB.Exec("UPDATE api_tokens SET last_used_at = ? WHERE id = ?", now.UTC(), token.ID); err != nil {
+		return nil, err
+	}
+	token.LastUsedAt = &now
+	return &token, nil
+}
+
+// GetUserAPITokens lists the API tokens of a user, newest first.
+func GetUserAPITokens(userID int) ([]APIToken, error) {
+	rows, err := DB.Query("SELECT id, user_id, name, scopes, created_at, last_used_at FROM api_tokens WHERE user_id = ? ORDER BY created_at DESC, id DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []APIToken
+	for rows.Next() {
+		var token APIToken
+		var scopes string
+		if err := rows.Scan(&token.ID, &token.UserID, &token.Name, &scopes, &token.CreatedAt, &token.LastUsedAt); err != nil {
+			return nil, err
+		}
+		token.Scopes = strings.Fields(scopes)
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// DeleteAPIToken revokes an API token of a user. It returns sql.ErrNoRows if the
+// user has no token with that ID.
+func DeleteAPIToken(userID, id int) error {
+	result, err := DB.Exec("DELETE FROM api_tokens WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// HasScope reports whether the token was granted scope.
+func (t *APIToken) HasScope(scope string) bool {
+	for _, granted := range t.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
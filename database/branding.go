@@ -0,0 +1,45 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+
+	"tiny-invoicing/models"
+)
+
+// EnsureBranding stores defaults as the branding if none has been saved yet.
+func EnsureBranding(defaults models.Branding) error {
+	_, err := DB.Exec(backend.insertIgnore("INTO branding (id, company_name, company_address, logo_url, color, payment_instructions, footer) VALUES (1, ?, ?, ?, ?, ?, ?)"),
+		defaults.CompanyName, defaults.CompanyAddress, defaults.LogoURL, defaults.Color, defaults.PaymentInstructions, defaults.Footer)
+	return err
+}
+
+// GetBranding returns the saved branding, or empty branding with the default
+// colour if none has been saved.
+func GetBranding() (*models.Branding, error) {
+	var branding models.Branding
+	err := DB.QueryRow("SELECT company_name, company_address, logo_url, color, payment_instructions, footer FROM branding WHERE id = 1").Scan(
+		&branding.CompanyName, &branding.CompanyAddress, &branding.LogoURL, &branding.Color, &branding.PaymentInstructions, &branding.Footer)
+	if err == sql.ErrNoRows {
+		return &models.Branding{Color: models.DefaultBrandColor}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &branding, nil
+}
+
+// brandingColumns are the columns SaveBranding replaces.
+var brandingColumns = []string{"company_name", "company_address", "logo_url", "color", "payment_instructions", "footer"}
+
+// SaveBranding replaces the branding.
+func SaveBranding(branding *models.Branding) error {
+	set := make([]string, len(brandingColumns))
+	for k, column := range brandingColumns {
+		set[k] = column + " = " + backend.excluded(column)
+	}
+	_, err := DB.Exec("INSERT INTO branding (id, "+strings.Join(brandingColumns, ", ")+") VALUES (1, ?, ?, ?, ?, ?, ?)"+
+		backend.onConflict("id", strings.Join(set, ", ")),
+		branding.CompanyName, branding.CompanyAddress, branding.LogoURL, branding.Color, branding.PaymentInstructions, branding.Footer)
+	return err
+}
@@ -0,0 +1,213 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"tiny-invoicing/models"
+)
+
+// creditNoteColumns is the column list read by scanCreditNote.
+const creditNoteColumns = "id, credit_note_number, invoice_id, currency, issue_date, reason, subtotal, tax_total, total, base_currency, exchange_rate, base_total, created_at"
+
+// scanCreditNote reads a row selected with creditNoteColumns.
+func scanCreditNote(row rowScanner, note *models.CreditNote) error {
+	var a amounts
+	var baseCurrency, exchangeRate, baseTotal sql.NullString
+	err := row.Scan(&note.ID, &note.Number, &note.InvoiceID, &note.Currency, &note.IssueDate, &note.Reason,
+		a.col(&note.Subtotal), a.col(&note.TaxTotal), a.col(&note.Total),
+		&baseCurrency, &exchangeRate, &baseTotal, &note.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if err := a.parse(note.Currency); err != nil {
+		return err
+	}
+
+	if baseCurrency.Valid {
+		rate, err := models.ParseRate(exchangeRate.String)
+		if err != nil {
+			return err
+		}
+		total, err := models.ParseMoney(baseTotal.String, baseCurrency.String, models.RoundHalfUp)
+		if err != nil {
+			return err
+		}
+		note.BaseCurrency = baseCurrency.String
+		note.ExchangeRate = rate
+		note.BaseTotal = &total
+	}
+	return nil
+}
+
+// queryCreditNotes runs a query selecting creditNoteColumns and loads the lines of
+// the credit notes found.
+func queryCreditNotes(query string, args ...interface{}) ([]models.CreditNote, error) {
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notes []models.CreditNote
+	for rows.Next() {
+		var note models.CreditNote
+		if err := scanCreditNote(rows, &note); err != nil {
+			return nil, err
+		}
+		notes = append(notes, note)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for k := range notes {
+		if notes[k].Lines, err = getCreditNoteLines(notes[k].ID, notes[k].Currency); err != nil {
+			return nil, err
+		}
+	}
+	return notes, nil
+}
+
+func getCreditNoteLines(noteID int, currency string) ([]models.CreditNoteLine, error) {
+	rows, err := DB.Query("SELECT id, line_item_id, description, quantity, subtotal, tax_amount, total FROM credit_note_lines WHERE credit_note_id = ? ORDER BY id", noteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := []models.CreditNoteLine{}
+	for rows.Next() {
+		var line models.CreditNoteLine
+		var a amounts
+		if err := rows.Scan(&line.ID, &line.LineItemID, &line.Description, &line.Quantity,
+			a.col(&line.Subtotal), a.col(&line.TaxAmount), a.col(&line.Total)); err != nil {
+			return nil, err
+		}
+		if err := a.parse(currency); err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+	return lines, rows.Err()
+}
+
+// GetCreditNotes retrieves a paginated list of credit notes, newest first.
+func GetCreditNotes(limit, offset int) ([]models.CreditNote, error) {
+	return queryCreditNotes("SELECT "+creditNoteColumns+" FROM credit_notes ORDER BY issue_date DESC, id DESC LIMIT ? OFFSET ?", limit, offset)
+}
+
+// GetCreditNoteByID retrieves a single credit note with its lines.
+func GetCreditNoteByID(id int) (*models.CreditNote, error) {
+	notes, err := queryCreditNotes("SELECT "+creditNoteColumns+" FROM credit_notes WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(notes) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &notes[0], nil
+}
+
+// GetInvoiceCreditNotes lists the credit notes of an invoice in the order they were
+// issued. It returns sql.ErrNoRows if the invoice does not exist.
+func GetInvoiceCreditNotes(invoiceID int) ([]models.CreditNote, error) {
+	if _, err := InvoiceCurrency(invoiceID); err != nil {
+		return nil, err
+	}
+	return queryCreditNotes("SELECT "+creditNoteColumns+" FROM credit_notes WHERE invoice_id = ? ORDER BY id", invoiceID)
+}
+
+// CreateCreditNote issues a credit note against note.InvoiceID: it works out the
+// credited amounts, takes the next credit note number and reduces the invoice's
+// balance, all in one transaction. Unless version is 0, the invoice must still be at
+// that version. It returns the credit note's ID and the invoice's new version,
+// sql.ErrNoRows for unknown invoices, ErrVersionMismatch, ErrNumberTaken and the
+// models credit note errors for credits the invoice cannot accept.
+func CreateCreditNote(note *models.CreditNote, version int) (int64, int, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	// Lock the invoice so that concurrent credit notes and payments see each other.
+	var invoice models.Invoice
+	if err := scanInvoice(tx.QueryRow("SELECT "+invoiceColumns+" FROM invoices WHERE id = ?"+backend.forUpdate(), note.InvoiceID), &invoice); err != nil {
+		return 0, 0, err
+	}
+	if version != 0 && version != invoice.Version {
+		return 0, 0, ErrVersionMismatch
+	}
+	if invoice.LineItems, err = getInvoiceItems(tx, invoice.ID); err != nil {
+		return 0, 0, err
+	}
+	credited, err := creditedQuantities(tx, invoice.ID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	now := time.Now()
+	if err := invoice.Credit(note, credited, now); err != nil {
+		return 0, 0, err
+	}
+	if note.Number, err = nextNumber(tx, models.SequenceCreditNote, note.IssueDate); err != nil {
+		return 0, 0, err
+	}
+
+	noteID, err := insert(tx, "INSERT INTO credit_notes (credit_note_number, invoice_id, currency, issue_date, reason, subtotal, tax_total, total, base_currency, exchange_rate, base_total, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		note.Number, note.InvoiceID, note.Currency, note.IssueDate, note.Reason, note.Subtotal, note.TaxTotal, note.Total,
+		nullString(note.BaseCurrency), nullRate(note.ExchangeRate), note.BaseTotal, now)
+	if isDuplicateKey(err) {
+		return 0, 0, ErrNumberTaken
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, line := range note.Lines {
+		_, err := tx.Exec("INSERT INTO credit_note_lines (credit_note_id, line_item_id, description, quantity, subtotal, tax_amount, total) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			noteID, line.LineItemID, line.Description, line.Quantity, line.Subtotal, line.TaxAmount, line.Total)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	_, err = tx.Exec("UPDATE invoices SET status = ?, amount_credited = ?, paid_at = ?, version = version + 1 WHERE id = ?",
+		invoice.Status, invoice.AmountCredited, invoice.PaidAt, invoice.ID)
+	if err != nil {
+		return 0, 0, err
+	}
+	note.CreatedAt = now
+	return noteID, invoice.Version + 1, tx.Commit()
+}
+
+// creditedQuantities returns the quantity of each line item of an invoice that its
+// credit notes have credited so far.
+func creditedQuantities(tx *sql.Tx, invoiceID int) (map[int]int, error) {
+	rows, err := tx.Query(`SELECT l.line_item_id, SUM(l.quantity) FROM credit_note_lines l
+		JOIN credit_notes n ON n.id = l.credit_note_id WHERE n.invoice_id = ? GROUP BY l.line_item_id`, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	credited := make(map[int]int)
+	for rows.Next() {
+		var itemID, quantity int
+		if err := rows.Scan(&itemID, &quantity); err != nil {
+			return nil, err
+		}
+		credited[itemID] = quantity
+	}
+	return credited, rows.Err()
+}
+
+// nullRate stores a missing exchange rate as NULL.
+func nullRate(rate models.Rate) interface{} {
+	if rate == 0 {
+		return nil
+	}
+	return rate
+}
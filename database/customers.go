@@ -0,0 +1,140 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// ErrUnknownCustomer is returned when an invoice references a customer that does not exist.
+var ErrUnknownCustomer = errors.New("unknown customer")
+
+// ErrCustomerInUse is returned when deleting a customer that still has invoices,
+// estimates or recurring profiles.
+var ErrCustomerInUse = errors.New("customer has invoices")
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// queryRower is satisfied by both *sql.DB and *sql.Tx.
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx.
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// GetCustomers retrieves a paginated list of customers ordered by name.
+func GetCustomers(limit, offset int) ([]Customer, error) {
+	rows, err := DB.Query("SELECT id, name, email, address, currency, template FROM customers ORDER BY name, id LIMIT ? OFFSET ?", limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var customers []Customer
+	for rows.Next() {
+		var customer Customer
+		if err := scanCustomer(rows, &customer); err != nil {
+			return nil, err
+		}
+		customers = append(customers, customer)
+	}
+	return customers, rows.Err()
+}
+
+// GetCustomerByID retrieves a single customer. It returns sql.ErrNoRows if the customer does not exist.
+func GetCustomerByID(id int) (*Customer, error) {
+	var customer Customer
+	row := DB.QueryRow("SELECT id, name, email, address, currency, template FROM customers WHERE id = ?", id)
+	if err := scanCustomer(row, &customer); err != nil {
+		return nil, err
+	}
+	return &customer, nil
+}
+
+// scanCustomer reads a customer row, treating NULL email, address and template as empty strings.
+func scanCustomer(row rowScanner, customer *Customer) error {
+	var email, address, template sql.NullString
+	if err := row.Scan(&customer.ID, &customer.Name, &email, &address, &customer.Currency, &template); err != nil {
+		return err
+	}
+	customer.Email = email.String
+	customer.Address = address.String
+	customer.Template = template.String
+	return nil
+}
+
+// CreateCustomer inserts a new customer and returns its ID.
+func CreateCustomer(customer *Customer) (int64, error) {
+	return insert(DB, "INSERT INTO customers (name, email, address, currency, template) VALUES (?, ?, ?, ?, ?)",
+		customer.Name, customer.Email, customer.Address, customer.Currency, nullString(customer.Template))
+}
+
+// UpdateCustomer overwrites an existing customer. It returns sql.ErrNoRows if the customer does not exist.
+func UpdateCustomer(customer *Customer) error {
+	if err := customerExists(DB, customer.ID); err != nil {
+		return err
+	}
+	_, err := DB.Exec("UPDATE customers SET name = ?, email = ?, address = ?, currency = ?, template = ? WHERE id = ?",
+		customer.Name, customer.Email, customer.Address, customer.Currency, nullString(customer.Template), customer.ID)
+	return err
+}
+
+// DeleteCustomer removes a customer. Customers that are referenced by invoices, estimates
+// or recurring profiles are kept and ErrCustomerInUse is returned; unknown IDs yield
+// sql.ErrNoRows.
+func DeleteCustomer(id int) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := customerExists(tx, id); err != nil {
+		return err
+	}
+
+	var invoices int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM invoices WHERE customer_id = ?", id).Scan(&invoices); err != nil {
+		return err
+	}
+	if invoices > 0 {
+		return ErrCustomerInUse
+	}
+
+	var profiles int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM recurring_profiles WHERE customer_id = ?", id).Scan(&profiles); err != nil {
+		return err
+	}
+	if profiles > 0 {
+		return ErrCustomerInUse
+	}
+
+	var estimates int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM estimates WHERE customer_id = ?", id).Scan(&estimates); err != nil {
+		return err
+	}
+	if estimates > 0 {
+		return ErrCustomerInUse
+	}
+
+	if _, err := tx.Exec("DELETE FROM customers WHERE id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// customerExists returns sql.ErrNoRows if no customer has the given ID.
+func customerExists(q queryRower, id int) error {
+	var exists int
+	return q.QueryRow("SELECT 1 FROM customers WHERE id = ?", id).Scan(&exists)
+}
+
+// nullString stores an empty string as NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
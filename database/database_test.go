@@ -1,10 +1,13 @@
 package database
 
 import (
+	"regexp"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+
+	"tiny-invoicing/models"
 )
 
 func TestGetInvoiceByID(t *testing.T) {
@@ -19,36 +22,59 @@ func TestGetInvoiceByID(t *testing.T) {
 	issueDate := time.Now()
 	dueDate := issueDate.Add(14 * 24 * time.Hour)
 
-	// Expectations for Invoice
-	invoiceRows := sqlmock.NewRows([]string{"id", "client_id", "issue_date", "due_date", "status", "total"}).
-		AddRow(1, 1, issueDate, dueDate, "draft", 0.0) // total 0 in DB, should be recalculated
+	// Expectations for Invoice. MySQL returns DECIMAL columns as text.
+	invoiceRows := sqlmock.NewRows([]string{"id", "invoice_number", "customer_id", "currency", "issue_date", "due_date", "status", "sent_at", "paid_at", "voided_at", "prices_include_tax", "discount_percent", "discount_amount", "discount", "discount_total", "subtotal", "tax_total", "total", "amount_paid", "base_currency", "exchange_rate", "base_total", "rounding_mode", "recurring_profile_id", "recurrence_date", "amount_credited", "estimate_id", "notes", "version"}).
+		AddRow(1, nil, 1, "USD", issueDate, dueDate, "draft", nil, nil, nil, false, 0, 0.0, 0.0, 0.0, []byte("25.00"), []byte("5.00"), []byte("30.00"), []byte("10.00"), nil, nil, nil, "half_up", nil, nil, 0.0, nil, nil, 3)
 
-	mock.ExpectQuery("SELECT id, client_id, issue_date, due_date, status, total FROM invoices WHERE id = ?").
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, invoice_number, customer_id, currency, issue_date, due_date, status, sent_at, paid_at, voided_at, prices_include_tax, discount_percent, discount_amount, discount, discount_total, subtotal, tax_total, total, amount_paid, base_currency, exchange_rate, base_total, rounding_mode, recurring_profile_id, recurrence_date, amount_credited, estimate_id, notes, version FROM invoices WHERE id = ?")).
 		WithArgs(1).
 		WillReturnRows(invoiceRows)
 
 	// Expectations for Line Items
-	itemRows := sqlmock.NewRows([]string{"id", "invoice_id", "description", "quantity", "unit_price"}).
-		AddRow(1, 1, "Item 1", 2, 10.0).
-		AddRow(2, 1, "Item 2", 1, 5.0)
+	itemRows := sqlmock.NewRows([]string{"id", "invoice_id", "description", "quantity", "unit_price", "discount_percent", "discount_amount", "discount", "invoice_discount", "tax_codes", "subtotal", "tax_amount", "total"}).
+		AddRow(1, 1, "Item 1", 2, []byte("10.00"), []byte("0.0000"), []byte("0.00"), []byte("0.00"), []byte("0.00"), "VAT", []byte("20.00"), []byte("4.00"), []byte("24.00")).
+		AddRow(2, 1, "Item 2", 1, []byte("5.00"), []byte("0.0000"), []byte("0.00"), []byte("0.00"), []byte("0.00"), "VAT", []byte("5.00"), []byte("1.00"), []byte("6.00"))
 
-	mock.ExpectQuery("SELECT id, invoice_id, description, quantity, unit_price FROM line_items WHERE invoice_id = ?").
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, invoice_id, description, quantity, unit_price, discount_percent, discount_amount, discount, invoice_discount, tax_codes, subtotal, tax_amount, total FROM invoice_items WHERE invoice_id = ? ORDER BY id")).
 		WithArgs(1).
 		WillReturnRows(itemRows)
 
+	taxRows := sqlmock.NewRows([]string{"code", "name", "kind", "rate", "compound", "taxable_amount", "tax_amount"}).
+		AddRow("VAT", "Standard VAT", "vat", []byte("20.0000"), false, []byte("25.00"), []byte("5.00"))
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT code, name, kind, rate, compound, taxable_amount, tax_amount FROM invoice_taxes WHERE invoice_id = ? ORDER BY id")).
+		WithArgs(1).
+		WillReturnRows(taxRows)
+
 	invoice, err := GetInvoiceByID(1)
 	if err != nil {
-		t.Errorf("GetInvoiceByID returned error: %s", err)
+		t.Fatalf("GetInvoiceByID returned error: %s", err)
+	}
+
+	if invoice.Total.String() != "30.00" {
+		t.Errorf("Expected total to be 30.00, but got %s", invoice.Total)
 	}
 
-	if invoice.Total != 25.0 {
-		t.Errorf("Expected recalculated total to be 25.0, but got %f", invoice.Total)
+	if invoice.BalanceDue.String() != "20.00" {
+		t.Errorf("Expected balance due to be 20.00, but got %s", invoice.BalanceDue)
+	}
+
+	if len(invoice.Taxes) != 1 || invoice.Taxes[0].Rate != models.NewPercent(2000) || invoice.Taxes[0].TaxAmount.String() != "5.00" {
+		t.Errorf("Expected a 20%% VAT summary of 5.00, but got %+v", invoice.Taxes)
+	}
+
+	if len(invoice.LineItems[0].TaxCodes) != 1 || invoice.LineItems[0].TaxCodes[0] != "VAT" {
+		t.Errorf("Expected line item tax codes [VAT], but got %v", invoice.LineItems[0].TaxCodes)
 	}
 
 	if len(invoice.LineItems) != 2 {
 		t.Errorf("Expected 2 line items, but got %d", len(invoice.LineItems))
 	}
 
+	if invoice.LineItems[0].UnitPrice.Amount != 1000 {
+		t.Errorf("Expected unit price of 1000 minor units, but got %d", invoice.LineItems[0].UnitPrice.Amount)
+	}
+
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
@@ -0,0 +1,40 @@
+package database
+
+import (
+	"database/sql"
+
+	"tiny-invoicing/models"
+)
+
+// RecordDelivery adds an entry to an invoice's email log and returns its ID.
+func RecordDelivery(delivery *models.Delivery) (int64, error) {
+	return insert(DB, "INSERT INTO invoice_deliveries (invoice_id, recipient, sent_at, message_id, result, error) VALUES (?, ?, ?, ?, ?, ?)",
+		delivery.InvoiceID, delivery.Recipient, delivery.SentAt, delivery.MessageID, delivery.Result, nullString(delivery.Error))
+}
+
+// GetDeliveries lists the attempts to email an invoice, oldest first. It returns
+// sql.ErrNoRows if the invoice does not exist.
+func GetDeliveries(invoiceID int) ([]models.Delivery, error) {
+	var exists int
+	if err := DB.QueryRow("SELECT 1 FROM invoices WHERE id = ?", invoiceID).Scan(&exists); err != nil {
+		return nil, err
+	}
+
+	rows, err := DB.Query("SELECT id, invoice_id, recipient, sent_at, message_id, result, error FROM invoice_deliveries WHERE invoice_id = ? ORDER BY sent_at, id", invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []models.Delivery
+	for rows.Next() {
+		var delivery models.Delivery
+		var errorText sql.NullString
+		if err := rows.Scan(&delivery.ID, &delivery.InvoiceID, &delivery.Recipient, &delivery.SentAt, &delivery.MessageID, &delivery.Result, &errorText); err != nil {
+			return nil, err
+		}
+		delivery.Error = errorText.String
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
@@ -0,0 +1,109 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Backend names a supported database.
+type Backend string
+
+const (
+	MySQL    Backend = "mysql"
+	SQLite   Backend = "sqlite"
+	Postgres Backend = "postgres"
+)
+
+// backend is the database DB is connected to. The queries of this package are
+// written for all of them, with ? placeholders (see rebind for Postgres); the few
+// statements that differ go through the methods below.
+var backend = MySQL
+
+// CurrentBackend returns the database DB is connected to.
+func CurrentBackend() Backend {
+	return backend
+}
+
+// forUpdate is appended to a SELECT whose rows must stay locked until the
+// transaction ends. SQLite has no row locks: its write transactions take the
+// whole database (see Open), which serialises them just the same.
+func (b Backend) forUpdate() string {
+	if b == SQLite {
+		return ""
+	}
+	return " FOR UPDATE"
+}
+
+// insertIgnore turns into, an "INTO table (...) VALUES ..." clause, into an INSERT
+// that skips rows whose key already exists.
+func (b Backend) insertIgnore(into string) string {
+	switch b {
+	case SQLite:
+		return "INSERT OR IGNORE " + into
+	case Postgres:
+		return "INSERT " + into + " ON CONFLICT DO NOTHING"
+	}
+	return "INSERT IGNORE " + into
+}
+
+// onConflict returns the clause that makes an INSERT apply set to the existing row
+// when one with the same key exists. SQLite and Postgres need the key columns
+// spelled out.
+func (b Backend) onConflict(key, set string) string {
+	if b == SQLite || b == Postgres {
+		return " ON CONFLICT (" + key + ") DO UPDATE SET " + set
+	}
+	return " ON DUPLICATE KEY UPDATE " + set
+}
+
+// excluded refers, in the set clause of onConflict, to the value the INSERT tried
+// to store in column.
+func (b Backend) excluded(column string) string {
+	if b == SQLite || b == Postgres {
+		return "excluded." + column
+	}
+	return "VALUES(" + column + ")"
+}
+
+// inserter runs statements on DB or inside a transaction.
+type inserter interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// insert runs an INSERT into a table with an id column and returns the ID of the
+// new row. Postgres drivers do not report it, so it is read back with RETURNING.
+func insert(q inserter, query string, args ...interface{}) (int64, error) {
+	if backend == Postgres {
+		var id int64
+		err := q.QueryRow(query+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+	result, err := q.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// isDuplicateKey reports whether err is a unique constraint violation.
+func isDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1062
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23505"
+	}
+	return false
+}
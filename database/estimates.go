@@ -0,0 +1,213 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"tiny-invoicing/models"
+)
+
+// ErrEstimateConverted is returned by CreateInvoice when the estimate the invoice
+// was converted from already has an invoice.
+var ErrEstimateConverted = errors.New("estimate already converted")
+
+// estimateQuery selects the columns read by scanEstimate, with the invoice the
+// estimate was converted into.
+const estimateQuery = "SELECT e.id, e.estimate_number, e.customer_id, e.currency, e.issue_date, e.expiry_date, e.status, e.sent_at, e.accepted_at, e.declined_at, e.prices_include_tax, e.discount_percent, e.discount_amount, e.discount, e.discount_total, e.subtotal, e.tax_total, e.total, e.rounding_mode, i.id FROM estimates e LEFT JOIN invoices i ON i.estimate_id = e.id"
+
+// scanEstimate reads a row selected with estimateQuery. The reported status is
+// derived from the stored one, so sent estimates past their expiry date read as expired.
+func scanEstimate(row rowScanner, estimate *models.Estimate) error {
+	var a amounts
+	var number sql.NullString
+	var invoiceID sql.NullInt64
+	err := row.Scan(&estimate.ID, &number, &estimate.CustomerID, &estimate.Currency, &estimate.IssueDate, &estimate.ExpiryDate,
+		&estimate.Status, &estimate.SentAt, &estimate.AcceptedAt, &estimate.DeclinedAt, &estimate.PricesIncludeTax,
+		&estimate.DiscountPercent, a.col(&estimate.DiscountAmount), a.col(&estimate.Discount), a.col(&estimate.DiscountTotal),
+		a.col(&estimate.Subtotal), a.col(&estimate.TaxTotal), a.col(&estimate.Total), &estimate.RoundingMode, &invoiceID)
+	if err != nil {
+		return err
+	}
+	if err := a.parse(estimate.Currency); err != nil {
+		return err
+	}
+	estimate.Number = number.String
+	if invoiceID.Valid {
+		id := int(invoiceID.Int64)
+		estimate.InvoiceID = &id
+	}
+
+	estimate.Status = models.DeriveEstimateStatus(estimate.Status, estimate.ExpiryDate, time.Now())
+	return nil
+}
+
+// loadEstimateItems fills in the line items of estimates.
+func loadEstimateItems(estimates []models.Estimate) error {
+	if len(estimates) == 0 {
+		return nil
+	}
+	index := make(map[int]*models.Estimate, len(estimates))
+	ids := make([]interface{}, len(estimates))
+	for k := range estimates {
+		index[estimates[k].ID] = &estimates[k]
+		ids[k] = estimates[k].ID
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	rows, err := DB.Query("SELECT id, estimate_id, description, quantity, unit_price, discount_percent, discount_amount, discount, invoice_discount, tax_codes, subtotal, tax_amount, total FROM estimate_items WHERE estimate_id IN ("+placeholders+") ORDER BY id", ids...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var estimateID int
+		var item models.LineItem
+		var a amounts
+		var taxCodes string
+		if err := rows.Scan(&item.ID, &estimateID, &item.Description, &item.Quantity, a.col(&item.UnitPrice),
+			&item.DiscountPercent, a.col(&item.DiscountAmount), a.col(&item.Discount), a.col(&item.InvoiceDiscount),
+			&taxCodes, a.col(&item.Subtotal), a.col(&item.TaxAmount), a.col(&item.Total)); err != nil {
+			return err
+		}
+		estimate := index[estimateID]
+		if err := a.parse(estimate.Currency); err != nil {
+			return err
+		}
+		if taxCodes != "" {
+			item.TaxCodes = strings.Split(taxCodes, ",")
+		}
+		estimate.LineItems = append(estimate.LineItems, item)
+	}
+	return rows.Err()
+}
+
+// queryEstimates runs estimateQuery with the given conditions and loads the line
+// items of the estimates found.
+func queryEstimates(conditions string, args ...interface{}) ([]models.Estimate, error) {
+	rows, err := DB.Query(estimateQuery+" "+conditions, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var estimates []models.Estimate
+	for rows.Next() {
+		var estimate models.Estimate
+		if err := scanEstimate(rows, &estimate); err != nil {
+			return nil, err
+		}
+		estimates = append(estimates, estimate)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := loadEstimateItems(estimates); err != nil {
+		return nil, err
+	}
+	return estimates, nil
+}
+
+// GetEstimates retrieves a paginated list of estimates, newest first.
+func GetEstimates(limit, offset int) ([]models.Estimate, error) {
+	return queryEstimates("ORDER BY e.issue_date DESC, e.id DESC LIMIT ? OFFSET ?", limit, offset)
+}
+
+// GetEstimateByID retrieves a single estimate with its line items.
+func GetEstimateByID(id int) (*models.Estimate, error) {
+	estimates, err := queryEstimates("WHERE e.id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(estimates) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &estimates[0], nil
+}
+
+// CreateEstimate stores a calculated estimate and its line items as a draft.
+func CreateEstimate(estimate *models.Estimate) (int64, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := customerExists(tx, estimate.CustomerID); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrUnknownCustomer
+		}
+		return 0, err
+	}
+
+	estimate.Status = models.EstimateDraft
+	estimateID, err := insert(tx, "INSERT INTO estimates (customer_id, currency, issue_date, expiry_date, status, prices_include_tax, discount_percent, discount_amount, discount, discount_total, subtotal, tax_total, total, rounding_mode) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		estimate.CustomerID, estimate.Currency, estimate.IssueDate, estimate.ExpiryDate, estimate.Status, estimate.PricesIncludeTax,
+		estimate.DiscountPercent, estimate.DiscountAmount, estimate.Discount, estimate.DiscountTotal,
+		estimate.Subtotal, estimate.TaxTotal, estimate.Total, estimate.RoundingMode)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, item := range estimate.LineItems {
+		_, err := tx.Exec("INSERT INTO estimate_items (estimate_id, description, quantity, unit_price, discount_percent, discount_amount, discount, invoice_discount, tax_codes, subtotal, tax_amount, total) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			estimateID, item.Description, item.Quantity, item.UnitPrice,
+			item.DiscountPercent, item.DiscountAmount, item.Discount, item.InvoiceDiscount,
+			strings.Join(item.TaxCodes, ","), item.Subtotal, item.TaxAmount, item.Total)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return estimateID, tx.Commit()
+}
+
+// TransitionEstimateStatus moves an estimate to a new status inside a transaction,
+// recording when the move happened. Sending an estimate gives it the next number of
+// the estimate sequence. It returns sql.ErrNoRows for unknown estimates, ErrNumberTaken
+// if that number is in use, and wraps
+// models.ErrInvalidEstimateTransition for illegal moves, including answering an
+// expired estimate.
+func TransitionEstimateStatus(id int, status models.EstimateStatus, at time.Time) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the row so concurrent transitions are evaluated one after another.
+	var estimate models.Estimate
+	err = tx.QueryRow("SELECT id, issue_date, expiry_date, status, sent_at, accepted_at, declined_at FROM estimates WHERE id = ?"+backend.forUpdate(), id).Scan(
+		&estimate.ID, &estimate.IssueDate, &estimate.ExpiryDate, &estimate.Status, &estimate.SentAt, &estimate.AcceptedAt, &estimate.DeclinedAt)
+	if err != nil {
+		return err
+	}
+	estimate.Status = models.DeriveEstimateStatus(estimate.Status, estimate.ExpiryDate, at)
+
+	if err := estimate.TransitionTo(status, at); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE estimates SET status = ?, sent_at = ?, accepted_at = ?, declined_at = ? WHERE id = ?",
+		estimate.Status, estimate.SentAt, estimate.AcceptedAt, estimate.DeclinedAt, id)
+	if err != nil {
+		return err
+	}
+
+	if status == models.EstimateSent {
+		if estimate.Number, err = nextNumber(tx, models.SequenceEstimate, estimate.IssueDate); err != nil {
+			return err
+		}
+		_, err := tx.Exec("UPDATE estimates SET estimate_number = ? WHERE id = ?", estimate.Number, id)
+		if isDuplicateKey(err) {
+			return ErrNumberTaken
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
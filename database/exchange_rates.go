@@ -0,0 +1,73 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"tiny-invoicing/models"
+)
+
+// SaveExchangeRates stores rates in one transaction, replacing any rate already
+// recorded for the same currencies and date.
+func SaveExchangeRates(rates []models.ExchangeRate) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, rate := range rates {
+		_, err := tx.Exec("INSERT INTO exchange_rates (base_currency, currency, rate, effective_date) VALUES (?, ?, ?, ?)"+
+			backend.onConflict("base_currency, currency, effective_date", "rate = "+backend.excluded("rate")),
+			rate.Base, rate.Currency, rate.Rate, rate.Date)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetExchangeRates lists the rates against base, newest first. A non-empty currency
+// restricts the list to that currency.
+func GetExchangeRates(base, currency string, limit, offset int) ([]models.ExchangeRate, error) {
+	query := "SELECT base_currency, currency, rate, effective_date FROM exchange_rates WHERE base_currency = ?"
+	args := []interface{}{base}
+	if currency != "" {
+		query += " AND currency = ?"
+		args = append(args, currency)
+	}
+	query += " ORDER BY effective_date DESC, currency LIMIT ? OFFSET ?"
+	args = append(args, limit, offset)
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates []models.ExchangeRate
+	for rows.Next() {
+		var rate models.ExchangeRate
+		if err := rows.Scan(&rate.Base, &rate.Currency, &rate.Rate, &rate.Date); err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+	return rates, rows.Err()
+}
+
+// exchangeRateOn returns the most recent rate against base known on the given date.
+// A currency is always worth exactly one unit of itself.
+func exchangeRateOn(q queryRower, base, currency string, date time.Time) (models.Rate, error) {
+	if currency == base {
+		return models.OneRate, nil
+	}
+	var rate models.Rate
+	err := q.QueryRow("SELECT rate FROM exchange_rates WHERE base_currency = ? AND currency = ? AND effective_date <= ? ORDER BY effective_date DESC LIMIT 1",
+		base, currency, date).Scan(&rate)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("%w for %s against %s on %s", models.ErrNoExchangeRate, currency, base, date.Format("2006-01-02"))
+	}
+	return rate, err
+}
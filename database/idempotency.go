@@ -0,0 +1,67 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// IdempotencyRecord remembers a request made with an Idempotency-Key header and,
+// once it has finished, its response.
+type IdempotencyRecord struct {
+	// Scope separates the keys of different users and endpoints.
+	Scope       string
+	Key         string
+	RequestHash string
+	// StatusCode is 0 while the first request is still being handled.
+	StatusCode int
+	// ETag is the response's ETag header, if it had one.
+	ETag         string
+	ResponseBody []byte
+	CreatedAt    time.Time
+}
+
+// ReserveIdempotencyKey stores record as an unfinished request unless its scope and
+// key are already taken, in which case it returns the record holding them. Records
+// created before expiredBefore are forgotten first, so their keys can be used again.
+func ReserveIdempotencyKey(record *IdempotencyRecord, expiredBefore time.Time) (*IdempotencyRecord, error) {
+	if _, err := DB.Exec("DELETE FROM idempotency_keys WHERE created_at < ?", expiredBefore.UTC()); err != nil {
+		return nil, err
+	}
+
+	result, err := DB.Exec(backend.insertIgnore("INTO idempotency_keys (scope, idempotency_key, request_hash, created_at) VALUES (?, ?, ?, ?)"),
+		record.Scope, record.Key, record.RequestHash, record.CreatedAt.UTC())
+	if err != nil {
+		return nil, err
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if inserted == 1 {
+		return nil, nil
+	}
+
+	existing := IdempotencyRecord{Scope: record.Scope, Key: record.Key}
+	var status sql.NullInt64
+	var etag sql.NullString
+	err = DB.QueryRow("SELECT request_hash, status_code, etag, response_body, created_at FROM idempotency_keys WHERE scope = ? AND idempotency_key = ?", record.Scope, record.Key).Scan(
+		&existing.RequestHash, &status, &etag, &existing.ResponseBody, &existing.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	existing.StatusCode, existing.ETag = int(status.Int64), etag.String
+	return &existing, nil
+}
+
+// SaveIdempotentResponse records the response to a reserved request.
+func SaveIdempotentResponse(record *IdempotencyRecord) error {
+	_, err := DB.Exec("UPDATE idempotency_keys SET status_code = ?, etag = ?, response_body = ? WHERE scope = ? AND idempotency_key = ?",
+		record.StatusCode, record.ETag, string(record.ResponseBody), record.Scope, record.Key)
+	return err
+}
+
+// ReleaseIdempotencyKey forgets a reserved request, so that it can be retried.
+func ReleaseIdempotencyKey(scope, key string) error {
+	_, err := DB.Exec("DELETE FROM idempotency_keys WHERE scope = ? AND idempotency_key = ?", scope, key)
+	return err
+}
@@ -0,0 +1,123 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"tiny-invoicing/models"
+)
+
+// Invitation invites someone to sign up with a role, through a link holding a token.
+type Invitation struct {
+	ID int `json:"id"`
+	// TokenHash is the SHA-256 hash of the token in the link, which is only shown
+	// when the invitation is created.
+	TokenHash string      `json:"-"`
+	Role      models.Role `json:"role"`
+	CreatedBy int         `json:"created_by"`
+	CreatedAt time.Time   `json:"created_at"`
+	ExpiresAt time.Time   `json:"expires_at"`
+	// AcceptedAt and UserID are set once the invitation has been used to sign up.
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	UserID     *int       `json:"user_id,omitempty"`
+}
+
+// CreateInvitation stores a new invitation and returns its ID.
+func CreateInvitation(invitation *Invitation) (int64, error) {
+	return insert(DB, "INSERT INTO invitations (token_hash, role, created_by, created_at, expires_at) VALUES (?, ?, ?, ?, ?)",
+		invitation.TokenHash, invitation.Role, invitation.CreatedBy, invitation.CreatedAt.UTC(), invitation.ExpiresAt.UTC())
+}
+
+// GetInvitations lists the invitations that have been neither accepted nor expired
+// by now, newest first.
+func GetInvitations(now time.Time) ([]Invitation, error) {
+	rows, err := DB.Query("SELECT id, role, created_by, created_at, expires_at FROM invitations WHERE accepted_at IS NULL AND expires_at > ? ORDER BY created_at DESC, id DESC",
+		now.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invitations []Invitation
+	for rows.Next() {
+		var invitation Invitation
+		if err := rows.Scan(&invitation.ID, &invitation.Role, &invitation.CreatedBy, &invitation.CreatedAt, &invitation.ExpiresAt); err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, invitation)
+	}
+	return invitations, rows.Err()
+}
+
+// GetInvitationByToken retrieves the invitation with the token hash if it has been
+// neither accepted nor expired by now. It returns sql.ErrNoRows otherwise.
+func GetInvitationByToken(tokenHash string, now time.Time) (*Invitation, error) {
+	invitation := Invitation{TokenHash: tokenHash}
+	err := DB.QueryRow("SELECT id, role, created_by, created_at, expires_at FROM invitations WHERE token_hash = ? AND accepted_at IS NULL AND expires_at > ?",
+		tokenHash, now.UTC()).Scan(&invitation.ID, &invitation.Role, &invitation.CreatedBy, &invitation.CreatedAt, &invitation.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// GetInvitation retrieves an invitation by ID, whether or not it has been accepted.
+// It returns sql.ErrNoRows if there is no such invitation.
+func GetInvitation(id int) (*Invitation, error) {
+	var invitation Invitation
+	err := DB.QueryRow("SELECT id, role, created_by, created_at, expires_at, accepted_at, user_id FROM invitations WHERE id = ?", id).Scan(
+		&invitation.ID, &invitation.Role, &invitation.CreatedBy, &invitation.CreatedAt, &invitation.ExpiresAt, &invitation.AcceptedAt, &invitation.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// DeleteInvitation withdraws an invitation that has not been accepted. It returns
+// sql.ErrNoRows if there is no such invitation.
+func DeleteInvitation(id int) error {
+	result, err := DB.Exec("DELETE FROM invitations WHERE id = ? AND accepted_at IS NULL", id)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// AcceptInvitation creates user with the role of the invitation with the token hash,
+// and marks the invitation accepted at now so that it cannot be used again. It
+// returns the user's ID, sql.ErrNoRows if the invitation does not exist, has expired
+// or has been used, and ErrUsernameTaken if another user has the username.
+func AcceptInvitation(tokenHash string, user *User, now time.Time) (int64, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var invitationID int
+	err = tx.QueryRow("SELECT id, role FROM invitations WHERE token_hash = ? AND accepted_at IS NULL AND expires_at > ?"+backend.forUpdate(),
+		tokenHash, now.UTC()).Scan(&invitationID, &user.Role)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := insert(tx, "INSERT INTO users (username, password_hash, role) VALUES (?, ?, ?)",
+		user.Username, user.PasswordHash, user.Role)
+	if isDuplicateKey(err) {
+		return 0, ErrUsernameTaken
+	}
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec("UPDATE invitations SET accepted_at = ?, user_id = ? WHERE id = ?", now.UTC(), id, invitationID); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
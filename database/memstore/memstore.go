@@ -0,0 +1,954 @@
+// Package memstore keeps invoices, customers and users in memory. It behaves like
+// the SQL store of package database, errors included, and is meant for tests and demos.
+package memstore
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"tiny-invoicing/database"
+	"tiny-invoicing/models"
+)
+
+// Store is an in-memory implementation of the invoice, customer and user stores.
+// It is safe for concurrent use; the zero value is not, use New.
+type Store struct {
+	mu sync.Mutex
+
+	// lastID holds the last ID handed out per table. Like AUTO_INCREMENT, IDs
+	// are never reused.
+	lastID map[string]int
+
+	customers     map[int]database.Customer
+	invoices      map[int]*models.Invoice
+	deliveries    []models.Delivery
+	users         map[string]database.User
+	sessions      map[int]database.Session
+	apiTokens     map[int]database.APIToken
+	invitations   map[int]database.Invitation
+	taxRates      map[string]models.TaxRate
+	exchangeRates []models.ExchangeRate
+	branding      *models.Branding
+	sequences     map[string]models.NumberSequence
+	// counters holds the last number handed out per sequence and period.
+	counters map[string]int64
+	// idempotency holds the requests made with an Idempotency-Key, by scope and key.
+	idempotency map[[2]string]database.IdempotencyRecord
+}
+
+// New returns an empty store with the default numbering sequences.
+func New() *Store {
+	return &Store{
+		lastID:      make(map[string]int),
+		customers:   make(map[int]database.Customer),
+		invoices:    make(map[int]*models.Invoice),
+		users:       make(map[string]database.User),
+		sessions:    make(map[int]database.Session),
+		apiTokens:   make(map[int]database.APIToken),
+		invitations: make(map[int]database.Invitation),
+		taxRates:    make(map[string]models.TaxRate),
+		sequences: map[string]models.NumberSequence{
+			models.SequenceInvoice:    {Name: models.SequenceInvoice, Format: models.DefaultInvoiceNumberFormat, Reset: models.ResetYearly},
+			models.SequenceCreditNote: {Name: models.SequenceCreditNote, Format: models.DefaultCreditNoteNumberFormat, Reset: models.ResetYearly},
+			models.SequenceEstimate:   {Name: models.SequenceEstimate, Format: models.DefaultEstimateNumberFormat, Reset: models.ResetYearly},
+		},
+		counters:    make(map[string]int64),
+		idempotency: make(map[[2]string]database.IdempotencyRecord),
+	}
+}
+
+// nextID hands out the next ID of a table. The caller holds s.mu.
+func (s *Store) nextID(table string) int {
+	s.lastID[table]++
+	return s.lastID[table]
+}
+
+// page returns the part of n sorted entries that limit and offset select.
+func page(n, limit, offset int) (int, int) {
+	if offset > n {
+		offset = n
+	}
+	end := n
+	if limit >= 0 && offset+limit < n {
+		end = offset + limit
+	}
+	return offset, end
+}
+
+// GetCustomers retrieves a paginated list of customers ordered by name.
+func (s *Store) GetCustomers(limit, offset int) ([]database.Customer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all := make([]database.Customer, 0, len(s.customers))
+	for _, customer := range s.customers {
+		all = append(all, customer)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Name != all[j].Name {
+			return all[i].Name < all[j].Name
+		}
+		return all[i].ID < all[j].ID
+	})
+
+	start, end := page(len(all), limit, offset)
+	var customers []database.Customer
+	return append(customers, all[start:end]...), nil
+}
+
+// GetCustomerByID retrieves a single customer. It returns sql.ErrNoRows if the customer does not exist.
+func (s *Store) GetCustomerByID(id int) (*database.Customer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	customer, ok := s.customers[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &customer, nil
+}
+
+// CreateCustomer adds a new customer and returns its ID.
+func (s *Store) CreateCustomer(customer *database.Customer) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *customer
+	stored.ID = s.nextID("customers")
+	s.customers[stored.ID] = stored
+	return int64(stored.ID), nil
+}
+
+// UpdateCustomer overwrites an existing customer. It returns sql.ErrNoRows if the customer does not exist.
+func (s *Store) UpdateCustomer(customer *database.Customer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.customers[customer.ID]; !ok {
+		return sql.ErrNoRows
+	}
+	s.customers[customer.ID] = *customer
+	return nil
+}
+
+// DeleteCustomer removes a customer. Customers with invoices are kept and
+// database.ErrCustomerInUse is returned; unknown IDs yield sql.ErrNoRows.
+func (s *Store) DeleteCustomer(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.customers[id]; !ok {
+		return sql.ErrNoRows
+	}
+	for _, invoice := range s.invoices {
+		if invoice.CustomerID == id {
+			return database.ErrCustomerInUse
+		}
+	}
+	delete(s.customers, id)
+	return nil
+}
+
+// CreateInvoice stores a draft invoice with its items and tax summary. It returns
+// database.ErrUnknownCustomer for unknown customers, and database.ErrRecurrenceExists
+// or database.ErrEstimateConverted if the recurring profile run or the estimate the
+// invoice is for already has one.
+func (s *Store) CreateInvoice(invoice *models.Invoice) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.customers[invoice.CustomerID]; !ok {
+		return 0, database.ErrUnknownCustomer
+	}
+	for _, other := range s.invoices {
+		if invoice.EstimateID != nil && other.EstimateID != nil && *other.EstimateID == *invoice.EstimateID {
+			return 0, database.ErrEstimateConverted
+		}
+		if invoice.RecurringProfileID != nil && other.RecurringProfileID != nil && *other.RecurringProfileID == *invoice.RecurringProfileID &&
+			invoice.RecurrenceDate != nil && other.RecurrenceDate != nil && other.RecurrenceDate.Equal(*invoice.RecurrenceDate) {
+			return 0, database.ErrRecurrenceExists
+		}
+	}
+
+	// Only what CreateInvoice of package database writes is kept; the rest
+	// starts out empty.
+	stored := &models.Invoice{
+		ID:                 s.nextID("invoices"),
+		CustomerID:         invoice.CustomerID,
+		Currency:           invoice.Currency,
+		IssueDate:          invoice.IssueDate,
+		DueDate:            invoice.DueDate,
+		PricesIncludeTax:   invoice.PricesIncludeTax,
+		DiscountPercent:    invoice.DiscountPercent,
+		DiscountAmount:     invoice.DiscountAmount,
+		Discount:           invoice.Discount,
+		DiscountTotal:      invoice.DiscountTotal,
+		Subtotal:           invoice.Subtotal,
+		TaxTotal:           invoice.TaxTotal,
+		Total:              invoice.Total,
+		AmountPaid:         models.NewMoney(0, invoice.Currency),
+		AmountCredited:     models.NewMoney(0, invoice.Currency),
+		RoundingMode:       invoice.Rounding(),
+		Status:             invoice.Status,
+		RecurringProfileID: copyInt(invoice.RecurringProfileID),
+		RecurrenceDate:     copyTime(invoice.RecurrenceDate),
+		EstimateID:         copyInt(invoice.EstimateID),
+		Notes:              invoice.Notes,
+		Version:            1,
+		Taxes:              append([]models.TaxSummary(nil), invoice.Taxes...),
+	}
+	if stored.Status == "" {
+		stored.Status = models.StatusDraft
+	}
+	stored.LineItems = s.copyLines(stored.ID, invoice.LineItems)
+
+	s.invoices[stored.ID] = stored
+	return int64(stored.ID), nil
+}
+
+// copyLines copies the line items of invoice invoiceID, giving them new IDs.
+func (s *Store) copyLines(invoiceID int, items []models.LineItem) []models.LineItem {
+	var lines []models.LineItem
+	for _, item := range items {
+		item.ID = s.nextID("invoice_items")
+		item.InvoiceID = invoiceID
+		item.TaxCodes = append([]string(nil), item.TaxCodes...)
+		lines = append(lines, item)
+	}
+	return lines
+}
+
+// UpdateInvoice replaces the customer, dates, discounts, notes, line items and totals
+// of a draft invoice, checking its version unless invoice.Version is 0. It returns
+// the errors of UpdateInvoice of package database.
+func (s *Store) UpdateInvoice(invoice *models.Invoice) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.invoices[invoice.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if invoice.Version != 0 && invoice.Version != stored.Version {
+		return database.ErrVersionMismatch
+	}
+	if !stored.Status.Editable() {
+		return models.ErrInvoiceLocked
+	}
+	if _, ok := s.customers[invoice.CustomerID]; !ok {
+		return database.ErrUnknownCustomer
+	}
+
+	stored.CustomerID = invoice.CustomerID
+	stored.Currency = invoice.Currency
+	stored.IssueDate, stored.DueDate = invoice.IssueDate, invoice.DueDate
+	stored.PricesIncludeTax = invoice.PricesIncludeTax
+	stored.DiscountPercent, stored.DiscountAmount = invoice.DiscountPercent, invoice.DiscountAmount
+	stored.Discount, stored.DiscountTotal = invoice.Discount, invoice.DiscountTotal
+	stored.Subtotal, stored.TaxTotal, stored.Total = invoice.Subtotal, invoice.TaxTotal, invoice.Total
+	// Drafts have no payments or credit notes.
+	stored.AmountPaid = models.NewMoney(0, invoice.Currency)
+	stored.AmountCredited = models.NewMoney(0, invoice.Currency)
+	stored.RoundingMode = invoice.Rounding()
+	stored.Notes = invoice.Notes
+	stored.Taxes = append([]models.TaxSummary(nil), invoice.Taxes...)
+	stored.LineItems = s.copyLines(stored.ID, invoice.LineItems)
+	stored.Version++
+	return nil
+}
+
+// GetInvoices retrieves a paginated list of invoices, newest first. The invoices are
+// listed without their line items and tax summaries.
+func (s *Store) GetInvoices(limit, offset int) ([]models.Invoice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all := make([]*models.Invoice, 0, len(s.invoices))
+	for _, invoice := range s.invoices {
+		all = append(all, invoice)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if !all[i].IssueDate.Equal(all[j].IssueDate) {
+			return all[i].IssueDate.After(all[j].IssueDate)
+		}
+		return all[i].ID > all[j].ID
+	})
+
+	start, end := page(len(all), limit, offset)
+	var invoices []models.Invoice
+	for _, stored := range all[start:end] {
+		invoice := read(stored)
+		invoice.LineItems, invoice.Taxes = nil, nil
+		invoices = append(invoices, *invoice)
+	}
+	return invoices, nil
+}
+
+// GetInvoiceByID retrieves a single invoice by its ID, including its items and tax
+// summary. It returns sql.ErrNoRows if the invoice does not exist.
+func (s *Store) GetInvoiceByID(id int) (*models.Invoice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.invoices[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return read(stored), nil
+}
+
+// TransitionInvoiceStatus moves an invoice to a new status. Sending an invoice gives
+// it the next invoice number and snapshots its total in models.BaseCurrency, as
+// TransitionInvoiceStatus of package database does; nothing changes if that fails.
+// Unless version is 0, the invoice must still be at that version.
+// It returns sql.ErrNoRows for unknown invoices, database.ErrVersionMismatch,
+// database.ErrNumberTaken if the next number is in use, wraps
+// models.ErrInvalidTransition for illegal moves and models.ErrNoExchangeRate when
+// no rate is known.
+func (s *Store) TransitionInvoiceStatus(id, version int, status models.InvoiceStatus, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.invoices[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if version != 0 && version != stored.Version {
+		return database.ErrVersionMismatch
+	}
+	invoice := clone(stored)
+	if err := invoice.TransitionTo(status, at); err != nil {
+		return err
+	}
+
+	if status == models.StatusSent {
+		rate, err := s.exchangeRateOn(models.BaseCurrency, invoice.Currency, invoice.IssueDate)
+		if err != nil {
+			return err
+		}
+		invoice.SnapshotBase(models.BaseCurrency, rate)
+		invoice.Number = s.nextNumber(models.SequenceInvoice, invoice.IssueDate)
+		for _, other := range s.invoices {
+			if other.Number == invoice.Number {
+				// Give the number back, as the SQL store's rollback does.
+				sequence := s.sequences[models.SequenceInvoice]
+				s.counters[models.SequenceInvoice+"/"+sequence.Period(invoice.IssueDate)]--
+				return database.ErrNumberTaken
+			}
+		}
+	}
+
+	invoice.Version++
+	s.invoices[id] = invoice
+	return nil
+}
+
+// UpdateNumberSequence changes the format and reset rule of a sequence. When the reset
+// rule changes, the counter of the period in effect as of now continues from the
+// highest number handed out, as UpdateNumberSequence of package database does. It
+// returns sql.ErrNoRows if the sequence does not exist.
+func (s *Store) UpdateNumberSequence(sequence *models.NumberSequence, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.sequences[sequence.Name]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	s.sequences[sequence.Name] = *sequence
+	if stored.Reset != sequence.Reset {
+		var last int64
+		for key, value := range s.counters {
+			if strings.HasPrefix(key, sequence.Name+"/") && value > last {
+				last = value
+			}
+		}
+		if last > 0 {
+			s.counters[sequence.Name+"/"+sequence.Period(now)] = last
+		}
+	}
+	return nil
+}
+
+// nextNumber hands out the next number of a sequence for a document dated date.
+// The caller holds s.mu.
+func (s *Store) nextNumber(name string, date time.Time) string {
+	sequence := s.sequences[name]
+	key := name + "/" + sequence.Period(date)
+	s.counters[key]++
+	return sequence.Number(date, s.counters[key])
+}
+
+// RecordDelivery adds an entry to an invoice's email log and returns its ID.
+func (s *Store) RecordDelivery(delivery *models.Delivery) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.invoices[delivery.InvoiceID]; !ok {
+		return 0, fmt.Errorf("invoice %d does not exist", delivery.InvoiceID)
+	}
+	stored := *delivery
+	stored.ID = s.nextID("invoice_deliveries")
+	s.deliveries = append(s.deliveries, stored)
+	return int64(stored.ID), nil
+}
+
+// GetDeliveries lists the attempts to email an invoice, oldest first. It returns
+// sql.ErrNoRows if the invoice does not exist.
+func (s *Store) GetDeliveries(invoiceID int) ([]models.Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.invoices[invoiceID]; !ok {
+		return nil, sql.ErrNoRows
+	}
+	var deliveries []models.Delivery
+	for _, delivery := range s.deliveries {
+		if delivery.InvoiceID == invoiceID {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sort.SliceStable(deliveries, func(i, j int) bool {
+		return deliveries[i].SentAt.Before(deliveries[j].SentAt)
+	})
+	return deliveries, nil
+}
+
+// CreateTaxRate adds a new tax rate and returns its ID. It returns
+// database.ErrDuplicateTaxCode if the code is already in use.
+func (s *Store) CreateTaxRate(rate *models.TaxRate) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.taxRates[rate.Code]; ok {
+		return 0, database.ErrDuplicateTaxCode
+	}
+	stored := *rate
+	stored.ID = s.nextID("tax_rates")
+	s.taxRates[stored.Code] = stored
+	return int64(stored.ID), nil
+}
+
+// GetTaxRatesByCode retrieves the tax rates with the given codes. Unknown codes are
+// simply missing from the result.
+func (s *Store) GetTaxRatesByCode(codes []string) ([]models.TaxRate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rates []models.TaxRate
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if rate, ok := s.taxRates[code]; ok && !seen[code] {
+			seen[code] = true
+			rates = append(rates, rate)
+		}
+	}
+	return rates, nil
+}
+
+// SaveExchangeRates stores exchange rates, replacing those already known for the
+// same currencies and date.
+func (s *Store) SaveExchangeRates(rates []models.ExchangeRate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+next:
+	for _, rate := range rates {
+		for k, known := range s.exchangeRates {
+			if known.Base == rate.Base && known.Currency == rate.Currency && known.Date.Equal(rate.Date) {
+				s.exchangeRates[k].Rate = rate.Rate
+				continue next
+			}
+		}
+		s.exchangeRates = append(s.exchangeRates, rate)
+	}
+	return nil
+}
+
+// exchangeRateOn returns the units of currency one unit of base bought on date.
+// The caller holds s.mu.
+func (s *Store) exchangeRateOn(base, currency string, date time.Time) (models.Rate, error) {
+	if currency == base {
+		return models.OneRate, nil
+	}
+	var found *models.ExchangeRate
+	for k, rate := range s.exchangeRates {
+		if rate.Base == base && rate.Currency == currency && !rate.Date.After(date) &&
+			(found == nil || rate.Date.After(found.Date)) {
+			found = &s.exchangeRates[k]
+		}
+	}
+	if found == nil {
+		return 0, fmt.Errorf("%w for %s against %s on %s", models.ErrNoExchangeRate, currency, base, date.Format("2006-01-02"))
+	}
+	return found.Rate, nil
+}
+
+// GetBranding returns the saved branding, or empty branding with the default
+// colour if none has been saved.
+func (s *Store) GetBranding() (*models.Branding, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.branding == nil {
+		return &models.Branding{Color: models.DefaultBrandColor}, nil
+	}
+	branding := *s.branding
+	return &branding, nil
+}
+
+// SaveBranding replaces the branding.
+func (s *Store) SaveBranding(branding *models.Branding) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved := *branding
+	s.branding = &saved
+	return nil
+}
+
+// CreateUser adds a new user and returns its ID. It returns database.ErrUsernameTaken
+// if another user has the username.
+func (s *Store) CreateUser(user *database.User) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[user.Username]; ok {
+		return 0, database.ErrUsernameTaken
+	}
+	stored := *user
+	stored.ID = s.nextID("users")
+	s.users[stored.Username] = stored
+	return int64(stored.ID), nil
+}
+
+// GetUserByUsername retrieves a user by their username. It returns sql.ErrNoRows if
+// there is no such user.
+func (s *Store) GetUserByUsername(username string) (*database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[username]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &user, nil
+}
+
+// CreateFirstUser creates the first user, such as the owner set up on a new install.
+// It returns database.ErrUsersExist if there are users already.
+func (s *Store) CreateFirstUser(user *database.User) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.users) > 0 {
+		return 0, database.ErrUsersExist
+	}
+	stored := *user
+	stored.ID = s.nextID("users")
+	s.users[stored.Username] = stored
+	return int64(stored.ID), nil
+}
+
+// CountUsers returns the number of users.
+func (s *Store) CountUsers() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.users), nil
+}
+
+// GetUserByID retrieves a user by ID. It returns sql.ErrNoRows if there is no such user.
+func (s *Store) GetUserByID(id int) (*database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if user.ID == id {
+			return &user, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// GetUsers lists all users by username.
+func (s *Store) GetUsers() ([]database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var users []database.User
+	for _, user := range s.users {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users, nil
+}
+
+// UpdateUserRole gives a user a new role. It returns sql.ErrNoRows if there is no
+// such user, and models.ErrLastOwner if the user is the only owner and would lose
+// the role.
+func (s *Store) UpdateUserRole(id int, role models.Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	owners := 0
+	for _, user := range s.users {
+		if user.Role == models.RoleOwner {
+			owners++
+		}
+	}
+	for username, user := range s.users {
+		if user.ID != id {
+			continue
+		}
+		if user.Role == models.RoleOwner && role != models.RoleOwner && owners <= 1 {
+			return models.ErrLastOwner
+		}
+		user.Role = role
+		s.users[username] = user
+		return nil
+	}
+	return sql.ErrNoRows
+}
+
+// CreateInvitation stores a new invitation and returns its ID.
+func (s *Store) CreateInvitation(invitation *database.Invitation) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *invitation
+	stored.ID = s.nextID("invitations")
+	stored.AcceptedAt, stored.UserID = nil, nil
+	s.invitations[stored.ID] = stored
+	return int64(stored.ID), nil
+}
+
+// GetInvitations lists the invitations that have been neither accepted nor expired
+// by now, newest first.
+func (s *Store) GetInvitations(now time.Time) ([]database.Invitation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var invitations []database.Invitation
+	for _, invitation := range s.invitations {
+		if invitation.AcceptedAt == nil && invitation.ExpiresAt.After(now) {
+			invitation.TokenHash = ""
+			invitations = append(invitations, invitation)
+		}
+	}
+	sort.Slice(invitations, func(i, j int) bool {
+		if !invitations[i].CreatedAt.Equal(invitations[j].CreatedAt) {
+			return invitations[i].CreatedAt.After(invitations[j].CreatedAt)
+		}
+		return invitations[i].ID > invitations[j].ID
+	})
+	return invitations, nil
+}
+
+// GetInvitationByToken retrieves the invitation with the token hash if it has been
+// neither accepted nor expired by now. It returns sql.ErrNoRows otherwise.
+func (s *Store) GetInvitationByToken(tokenHash string, now time.Time) (*database.Invitation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, invitation := range s.invitations {
+		if invitation.TokenHash == tokenHash && invitation.AcceptedAt == nil && invitation.ExpiresAt.After(now) {
+			return &invitation, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// GetInvitation retrieves an invitation by ID, whether or not it has been accepted.
+// It returns sql.ErrNoRows if there is no such invitation.
+func (s *Store) GetInvitation(id int) (*database.Invitation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	invitation, ok := s.invitations[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	invitation.TokenHash = ""
+	return &invitation, nil
+}
+
+// DeleteInvitation withdraws an invitation that has not been accepted. It returns
+// sql.ErrNoRows if there is no such invitation.
+func (s *Store) DeleteInvitation(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if invitation, ok := s.invitations[id]; !ok || invitation.AcceptedAt != nil {
+		return sql.ErrNoRows
+	}
+	delete(s.invitations, id)
+	return nil
+}
+
+// AcceptInvitation creates user with the role of the invitation with the token hash,
+// and marks the invitation accepted at now so that it cannot be used again. It
+// returns the user's ID, sql.ErrNoRows if the invitation does not exist, has expired
+// or has been used, and database.ErrUsernameTaken if another user has the username.
+func (s *Store) AcceptInvitation(tokenHash string, user *database.User, now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, invitation := range s.invitations {
+		if invitation.TokenHash != tokenHash || invitation.AcceptedAt != nil || !invitation.ExpiresAt.After(now) {
+			continue
+		}
+		if _, ok := s.users[user.Username]; ok {
+			return 0, database.ErrUsernameTaken
+		}
+		user.Role = invitation.Role
+		stored := *user
+		stored.ID = s.nextID("users")
+		s.users[stored.Username] = stored
+
+		invitation.AcceptedAt, invitation.UserID = &now, &stored.ID
+		s.invitations[id] = invitation
+		return int64(stored.ID), nil
+	}
+	return 0, sql.ErrNoRows
+}
+
+// CreateSession stores a new session and returns its ID. Sessions that expired
+// before the new one was created are removed.
+func (s *Store) CreateSession(session *database.Session) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, stored := range s.sessions {
+		if stored.ExpiresAt.Before(session.CreatedAt) {
+			delete(s.sessions, id)
+		}
+	}
+	stored := *session
+	stored.ID = s.nextID("sessions")
+	stored.User = nil
+	s.sessions[stored.ID] = stored
+	return int64(stored.ID), nil
+}
+
+// GetSession retrieves the session with the token hash, and its user, if it has
+// not expired by now. It returns sql.ErrNoRows otherwise.
+func (s *Store) GetSession(tokenHash string, now time.Time) (*database.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, session := range s.sessions {
+		if session.TokenHash != tokenHash || !session.ExpiresAt.After(now) {
+			continue
+		}
+		for _, user := range s.users {
+			if user.ID == session.UserID {
+				session.User = &user
+				return &session, nil
+			}
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// GetUserSessions lists the sessions of a user that have not expired by now, newest first.
+func (s *Store) GetUserSessions(userID int, now time.Time) ([]database.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var sessions []database.Session
+	for _, session := range s.sessions {
+		if session.UserID == userID && session.ExpiresAt.After(now) {
+			session.TokenHash, session.CSRFToken = "", ""
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].CreatedAt.Equal(sessions[j].CreatedAt) {
+			return sessions[i].CreatedAt.After(sessions[j].CreatedAt)
+		}
+		return sessions[i].ID > sessions[j].ID
+	})
+	return sessions, nil
+}
+
+// DeleteSession revokes a session of a user. It returns sql.ErrNoRows if the user
+// has no session with that ID.
+func (s *Store) DeleteSession(userID, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if session, ok := s.sessions[id]; !ok || session.UserID != userID {
+		return sql.ErrNoRows
+	}
+	delete(s.sessions, id)
+	return nil
+}
+
+// CreateAPIToken stores a new API token and returns its ID.
+func (s *Store) CreateAPIToken(token *database.APIToken) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *token
+	stored.ID = s.nextID("api_tokens")
+	stored.Scopes = append([]string(nil), token.Scopes...)
+	stored.LastUsedAt = nil
+	stored.User = nil
+	s.apiTokens[stored.ID] = stored
+	return int64(stored.ID), nil
+}
+
+// UseAPIToken retrieves the API token with the hash, and its user, and records that
+// it was used at now. It returns sql.ErrNoRows if there is no such token.
+func (s *Store) UseAPIToken(tokenHash string, now time.Time) (*database.APIToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, token := range s.apiTokens {
+		if token.TokenHash != tokenHash {
+			continue
+		}
+		for _, user := range s.users {
+			if user.ID == token.UserID {
+				token.LastUsedAt = &now
+				s.apiTokens[id] = token
+				token.Scopes = append([]string(nil), token.Scopes...)
+				token.User = &user
+				return &token, nil
+			}
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// GetUserAPITokens lists the API tokens of a user, newest first.
+func (s *Store) GetUserAPITokens(userID int) ([]database.APIToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var tokens []database.APIToken
+	for _, token := range s.apiTokens {
+		if token.UserID == userID {
+			token.TokenHash = ""
+			token.Scopes = append([]string(nil), token.Scopes...)
+			tokens = append(tokens, token)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		if !tokens[i].CreatedAt.Equal(tokens[j].CreatedAt) {
+			return tokens[i].CreatedAt.After(tokens[j].CreatedAt)
+		}
+		return tokens[i].ID > tokens[j].ID
+	})
+	return tokens, nil
+}
+
+// DeleteAPIToken revokes an API token of a user. It returns sql.ErrNoRows if the
+// user has no token with that ID.
+func (s *Store) DeleteAPIToken(userID, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if token, ok := s.apiTokens[id]; !ok || token.UserID != userID {
+		return sql.ErrNoRows
+	}
+	delete(s.apiTokens, id)
+	return nil
+}
+
+// ReserveIdempotencyKey stores record as an unfinished request unless its scope and
+// key are already taken, in which case it returns the record holding them. Records
+// created before expiredBefore are forgotten first.
+func (s *Store) ReserveIdempotencyKey(record *database.IdempotencyRecord, expiredBefore time.Time) (*database.IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, stored := range s.idempotency {
+		if stored.CreatedAt.Before(expiredBefore) {
+			delete(s.idempotency, id)
+		}
+	}
+	id := [2]string{record.Scope, record.Key}
+	if stored, ok := s.idempotency[id]; ok {
+		stored.ResponseBody = append([]byte(nil), stored.ResponseBody...)
+		return &stored, nil
+	}
+	s.idempotency[id] = database.IdempotencyRecord{Scope: record.Scope, Key: record.Key, RequestHash: record.RequestHash, CreatedAt: record.CreatedAt}
+	return nil, nil
+}
+
+// SaveIdempotentResponse records the response to a reserved request.
+func (s *Store) SaveIdempotentResponse(record *database.IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := [2]string{record.Scope, record.Key}
+	if stored, ok := s.idempotency[id]; ok {
+		stored.StatusCode, stored.ETag = record.StatusCode, record.ETag
+		stored.ResponseBody = append([]byte(nil), record.ResponseBody...)
+		s.idempotency[id] = stored
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey forgets a reserved request.
+func (s *Store) ReleaseIdempotencyKey(scope, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.idempotency, [2]string{scope, key})
+	return nil
+}
+
+// read returns a copy of a stored invoice as the SQL store reports it: with its
+// balance, and overdue if it is outstanding past its due date.
+func read(stored *models.Invoice) *models.Invoice {
+	invoice := clone(stored)
+	invoice.UpdateBalance()
+	invoice.Status = models.DeriveStatus(invoice.Status, invoice.DueDate, time.Now())
+	return invoice
+}
+
+// clone returns a copy of invoice that shares no memory with it.
+func clone(invoice *models.Invoice) *models.Invoice {
+	c := *invoice
+	c.SentAt = copyTime(invoice.SentAt)
+	c.PaidAt = copyTime(invoice.PaidAt)
+	c.VoidedAt = copyTime(invoice.VoidedAt)
+	c.RecurrenceDate = copyTime(invoice.RecurrenceDate)
+	c.RecurringProfileID = copyInt(invoice.RecurringProfileID)
+	c.EstimateID = copyInt(invoice.EstimateID)
+	if invoice.BaseTotal != nil {
+		total := *invoice.BaseTotal
+		c.BaseTotal = &total
+	}
+	c.Taxes = append([]models.TaxSummary(nil), invoice.Taxes...)
+	c.LineItems = nil
+	for _, item := range invoice.LineItems {
+		item.TaxCodes = append([]string(nil), item.TaxCodes...)
+		c.LineItems = append(c.LineItems, item)
+	}
+	return &c
+}
+
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
+
+func copyInt(n *int) *int {
+	if n == nil {
+		return nil
+	}
+	c := *n
+	return &c
+}
@@ -0,0 +1,13 @@
+package memstore
+
+import (
+	"testing"
+
+	"tiny-invoicing/database/storetest"
+)
+
+func TestContract(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storetest.Store {
+		return New()
+	})
+}
@@ -0,0 +1,489 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"tiny-invoicing/migrations"
+)
+
+// ErrMigrationChanged is returned when an applied migration file no longer has the
+// checksum it had when it was applied.
+var ErrMigrationChanged = errors.New("applied migration has been changed")
+
+// ErrUnknownMigration is returned when the database has a migration applied that
+// this version does not know, e.g. because a newer version migrated it.
+var ErrUnknownMigration = errors.New("database has an unknown migration applied")
+
+// MigrationLockTimeout is how long MigrateUp and MigrateDown wait for another
+// process that is migrating the same database.
+var MigrationLockTimeout = time.Minute
+
+const (
+	// migrationLockName names the MySQL lock held while migrating.
+	migrationLockName = "tiny_invoicing.migrations"
+	// migrationLockKey is the PostgreSQL advisory lock held while migrating.
+	migrationLockKey int64 = 7365824531
+)
+
+// Migration is one version of the schema.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	// Down reverts Up; it is empty if the migration has no down file.
+	Down string
+	// Checksum is the SHA-256 of Up, recorded when the migration is applied.
+	Checksum string
+}
+
+// String returns the file name of the migration without its suffix, e.g. 0001_initial.
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// MigrationStatus is a migration and when it was applied, nil if it is pending.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrations returns the embedded migrations for the database DB is connected to.
+func Migrations() ([]Migration, error) {
+	dir, err := fs.Sub(migrations.Files, string(backend))
+	if err != nil {
+		return nil, err
+	}
+	return LoadMigrations(dir)
+}
+
+// LoadMigrations reads the NNNN_name.up.sql and NNNN_name.down.sql files at the top
+// of fsys and returns them in version order. Files without the .sql suffix are ignored.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		file := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(file, ".sql") {
+			continue
+		}
+		stem, up := strings.CutSuffix(file, ".up.sql")
+		if !up {
+			var down bool
+			if stem, down = strings.CutSuffix(file, ".down.sql"); !down {
+				return nil, fmt.Errorf("migration %s: name must end in .up.sql or .down.sql", file)
+			}
+		}
+		digits, name, _ := strings.Cut(stem, "_")
+		version, err := strconv.Atoi(digits)
+		if err != nil || version <= 0 || name == "" {
+			return nil, fmt.Errorf("migration %s: name must look like 0001_description.up.sql", file)
+		}
+		body, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", m, stem)
+		}
+		if up {
+			sum := sha256.Sum256(body)
+			m.Up, m.Checksum = string(body), hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Checksum == "" {
+			return nil, fmt.Errorf("migration %s has no up file", m)
+		}
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// MigrateUp applies the migrations that have not been applied yet, in version order,
+// and returns them. Before it changes anything it checks that the applied migrations
+// are all known and unchanged. It holds a lock while it runs, so that processes
+// started together migrate one after another.
+//
+// Each migration is applied in a transaction with its schema_migrations row. MySQL
+// commits DDL statements as they run, though: a MySQL migration that fails halfway
+// must be completed or undone by hand. On SQLite the whole run is one transaction.
+func MigrateUp(list []Migration) ([]Migration, error) {
+	var done []Migration
+	err := withMigrationLock(func(s *migrationSession) error {
+		if backend == MySQL {
+			if err := s.upgradeLegacySchema(); err != nil {
+				return fmt.Errorf("upgrading the existing tables: %w", err)
+			}
+		}
+		applied, err := s.applied(list)
+		if err != nil {
+			return err
+		}
+		for _, m := range list {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			err := s.apply(m.Up, "INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)",
+				m.Version, m.Name, m.Checksum, time.Now().UTC())
+			if err != nil {
+				return fmt.Errorf("migration %s: %w", m, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	if err != nil && backend == SQLite {
+		done = nil
+	}
+	return done, err
+}
+
+// MigrateDown reverts the last steps applied migrations, newest first, and returns
+// them. It fails without reverting anything if one of them has no down file.
+func MigrateDown(list []Migration, steps int) ([]Migration, error) {
+	var done []Migration
+	err := withMigrationLock(func(s *migrationSession) error {
+		applied, err := s.applied(list)
+		if err != nil {
+			return err
+		}
+		var revert []Migration
+		for k := len(list) - 1; k >= 0 && len(revert) < steps; k-- {
+			if _, ok := applied[list[k].Version]; !ok {
+				continue
+			}
+			if list[k].Down == "" {
+				return fmt.Errorf("migration %s cannot be reverted: it has no down file", list[k])
+			}
+			revert = append(revert, list[k])
+		}
+		for _, m := range revert {
+			if err := s.apply(m.Down, "DELETE FROM schema_migrations WHERE version = ?", m.Version); err != nil {
+				return fmt.Errorf("reverting migration %s: %w", m, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	if err != nil && backend == SQLite {
+		done = nil
+	}
+	return done, err
+}
+
+// GetMigrationStatus reports which of the migrations have been applied.
+func GetMigrationStatus(list []Migration) ([]MigrationStatus, error) {
+	var status []MigrationStatus
+	err := withMigrationLock(func(s *migrationSession) error {
+		applied, err := s.applied(list)
+		if err != nil {
+			return err
+		}
+		for _, m := range list {
+			entry := MigrationStatus{Migration: m}
+			if at, ok := applied[m.Version]; ok {
+				entry.AppliedAt = &at
+			}
+			status = append(status, entry)
+		}
+		return nil
+	})
+	return status, err
+}
+
+// migrationSession is a connection that holds the migration lock.
+type migrationSession struct {
+	ctx  context.Context
+	conn *sql.Conn
+	// tx is the write transaction that serves as the lock on SQLite, which has no
+	// named locks. The migrations of the run are applied inside it.
+	tx *sql.Tx
+}
+
+// migrationQuerier is implemented by *sql.Conn and *sql.Tx.
+type migrationQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// withMigrationLock runs fn while holding the migration lock.
+func withMigrationLock(fn func(s *migrationSession) error) error {
+	ctx := context.Background()
+	conn, err := DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	s := &migrationSession{ctx: ctx, conn: conn}
+	switch backend {
+	case MySQL:
+		var locked sql.NullInt64
+		err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", migrationLockName, int(MigrationLockTimeout/time.Second)).Scan(&locked)
+		if err == nil && locked.Int64 != 1 {
+			err = fmt.Errorf("another process has been migrating the database for %s", MigrationLockTimeout)
+		}
+		if err == nil {
+			defer conn.ExecContext(ctx, "DO RELEASE_LOCK(?)", migrationLockName)
+		}
+	case Postgres:
+		lockCtx, cancel := context.WithTimeout(ctx, MigrationLockTimeout)
+		_, err = conn.ExecContext(lockCtx, "SELECT pg_advisory_lock(?)", migrationLockKey)
+		cancel()
+		if err == nil {
+			defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock(?)", migrationLockKey)
+		}
+	case SQLite:
+		// Write transactions begin immediately (see Open), so this waits for
+		// any other writer and then keeps the database to itself.
+		s.tx, err = conn.BeginTx(ctx, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("taking the migration lock: %w", err)
+	}
+
+	err = fn(s)
+	if s.tx != nil {
+		if err != nil {
+			s.tx.Rollback()
+			return err
+		}
+		return s.tx.Commit()
+	}
+	return err
+}
+
+// q returns what the statements of the session run on.
+func (s *migrationSession) q() migrationQuerier {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.conn
+}
+
+// applied creates the schema_migrations table if needed and returns when each
+// applied migration was applied, by version. It fails if an applied migration is
+// not in list or no longer has the checksum it was applied with.
+func (s *migrationSession) applied(list []Migration) (map[int]time.Time, error) {
+	appliedAt := "DATETIME"
+	if backend == Postgres {
+		appliedAt = "TIMESTAMPTZ"
+	}
+	_, err := s.q().ExecContext(s.ctx, "CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, name VARCHAR(255) NOT NULL, checksum CHAR(64) NOT NULL, applied_at "+appliedAt+" NOT NULL)")
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[int]Migration, len(list))
+	for _, m := range list {
+		known[m.Version] = m
+	}
+	rows, err := s.q().QueryContext(s.ctx, "SELECT version, name, checksum, applied_at FROM schema_migrations ORDER BY version")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var name, checksum string
+		var at time.Time
+		if err := rows.Scan(&version, &name, &checksum, &at); err != nil {
+			return nil, err
+		}
+		m, ok := known[version]
+		if !ok {
+			return nil, fmt.Errorf("%w: %04d_%s", ErrUnknownMigration, version, name)
+		}
+		if m.Checksum != checksum {
+			return nil, fmt.Errorf("%w: %s", ErrMigrationChanged, m)
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+// apply runs the statements of script followed by record in one transaction, or in
+// the transaction of the session on SQLite.
+func (s *migrationSession) apply(script, record string, args ...interface{}) error {
+	if s.tx != nil {
+		return execScript(s.ctx, s.tx, script, record, args...)
+	}
+	tx, err := s.conn.BeginTx(s.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := execScript(s.ctx, tx, script, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// execScript runs the statements of script, then record with args.
+func execScript(ctx context.Context, tx *sql.Tx, script, record string, args ...interface{}) error {
+	for _, statement := range splitStatements(script) {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	_, err := tx.ExecContext(ctx, record, args...)
+	return err
+}
+
+// splitStatements splits a migration file into its statements.
+func splitStatements(script string) []string {
+	// Drop "--" comments first: they may contain semicolons.
+	lines := strings.Split(script, "\n")
+	for k, line := range lines {
+		lines[k], _, _ = strings.Cut(line, "--")
+	}
+	var statements []string
+	for _, statement := range strings.Split(strings.Join(lines, "\n"), ";") {
+		if statement = strings.TrimSpace(statement); statement != "" {
+			statements = append(statements, statement)
+		}
+	}
+	return statements
+}
+
+// upgradeLegacySchema brings the tables of a MySQL database created before versioned
+// migrations existed up to migration 0001, whose CREATE TABLE IF NOT EXISTS statements
+// then leave them alone. Such databases have an invoices table but no
+// schema_migrations table; nothing happens for any other database.
+func (s *migrationSession) upgradeLegacySchema() error {
+	var tables int
+	err := s.conn.QueryRowContext(s.ctx, "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = 'schema_migrations'").Scan(&tables)
+	if err != nil || tables > 0 {
+		return err
+	}
+	err = s.conn.QueryRowContext(s.ctx, "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = 'invoices'").Scan(&tables)
+	if err != nil || tables == 0 {
+		return err
+	}
+
+	// Columns added after the original schema; backfill runs right after the column is added.
+	columnPatches := []struct{ table, column, definition, backfill string }{
+		{"invoices", "rounding_mode", "VARCHAR(16) NOT NULL DEFAULT 'half_up'", ""},
+		// Carry the legacy paid flag over into the new status column.
+		{"invoices", "status", "VARCHAR(20) NOT NULL DEFAULT 'draft'", "UPDATE invoices SET status = 'paid' WHERE paid = TRUE"},
+		{"invoices", "sent_at", "DATETIME NULL", ""},
+		{"invoices", "paid_at", "DATETIME NULL", ""},
+		{"invoices", "voided_at", "DATETIME NULL", ""},
+		// Invoices marked paid before the payments ledger existed count as fully paid.
+		{"invoices", "amount_paid", "DECIMAL(20, 3) NOT NULL DEFAULT 0", "UPDATE invoices SET amount_paid = total WHERE status = 'paid'"},
+		{"invoices", "prices_include_tax", "BOOLEAN NOT NULL DEFAULT FALSE", ""},
+		{"invoices", "subtotal", "DECIMAL(20, 3) NOT NULL DEFAULT 0", "UPDATE invoices SET subtotal = total"},
+		{"invoices", "tax_total", "DECIMAL(20, 3) NOT NULL DEFAULT 0", ""},
+		{"invoice_items", "tax_codes", "VARCHAR(255) NOT NULL DEFAULT ''", ""},
+		{"invoice_items", "subtotal", "DECIMAL(20, 3) NOT NULL DEFAULT 0", "UPDATE invoice_items SET subtotal = total"},
+		{"invoice_items", "tax_amount", "DECIMAL(20, 3) NOT NULL DEFAULT 0", ""},
+		{"invoices", "discount_percent", "DECIMAL(9, 4) NOT NULL DEFAULT 0", ""},
+		{"invoices", "discount_amount", "DECIMAL(20, 3) NOT NULL DEFAULT 0", ""},
+		{"invoices", "discount", "DECIMAL(20, 3) NOT NULL DEFAULT 0", ""},
+		{"invoices", "discount_total", "DECIMAL(20, 3) NOT NULL DEFAULT 0", ""},
+		{"invoice_items", "discount_percent", "DECIMAL(9, 4) NOT NULL DEFAULT 0", ""},
+		{"invoice_items", "discount_amount", "DECIMAL(20, 3) NOT NULL DEFAULT 0", ""},
+		{"invoice_items", "discount", "DECIMAL(20, 3) NOT NULL DEFAULT 0", ""},
+		{"invoice_items", "invoice_discount", "DECIMAL(20, 3) NOT NULL DEFAULT 0", ""},
+		// Amounts written before currencies existed are in DefaultCurrency.
+		{"invoices", "currency", "CHAR(3) NOT NULL DEFAULT 'USD'", ""},
+		{"invoices", "base_currency", "CHAR(3) NULL", ""},
+		{"invoices", "exchange_rate", "DECIMAL(20, 8) NULL", ""},
+		{"invoices", "base_total", "DECIMAL(20, 3) NULL", "UPDATE invoices SET base_currency = currency, exchange_rate = 1, base_total = total WHERE status <> 'draft'"},
+		{"customers", "currency", "CHAR(3) NOT NULL DEFAULT 'USD'", ""},
+		// Invoices sent before numbering existed keep only their ID.
+		{"invoices", "invoice_number", "VARCHAR(64) NULL UNIQUE", ""},
+		{"customers", "template", "VARCHAR(64) NULL", ""},
+		{"invoices", "recurring_profile_id", "INT NULL", ""},
+		{"invoices", "amount_credited", "DECIMAL(20, 3) NOT NULL DEFAULT 0", ""},
+		{"invoices", "estimate_id", "INT NULL UNIQUE", ""},
+		// The key that stops a recurring profile generating the same run twice.
+		{"invoices", "recurrence_date", "DATETIME NULL", "ALTER TABLE invoices ADD UNIQUE KEY invoices_recurrence (recurring_profile_id, recurrence_date)"},
+	}
+
+	for _, patch := range columnPatches {
+		var count int
+		err := s.conn.QueryRowContext(s.ctx, "SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?",
+			patch.table, patch.column).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		if _, err := s.conn.ExecContext(s.ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", patch.table, patch.column, patch.definition)); err != nil {
+			return err
+		}
+		if patch.backfill != "" {
+			if _, err := s.conn.ExecContext(s.ctx, patch.backfill); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Widen amount columns to three decimal places, the most any supported currency uses
+	// (e.g. KWD). DECIMAL(20, 3) allows numbers up to 99,999,999,999,999,999.999
+	amountColumns := []struct{ table, column, definition string }{
+		{"invoices", "total", "DECIMAL(20, 3) NOT NULL"},
+		{"invoices", "amount_paid", "DECIMAL(20, 3) NOT NULL DEFAULT 0"},
+		{"invoices", "subtotal", "DECIMAL(20, 3) NOT NULL DEFAULT 0"},
+		{"invoices", "tax_total", "DECIMAL(20, 3) NOT NULL DEFAULT 0"},
+		{"invoices", "discount_amount", "DECIMAL(20, 3) NOT NULL DEFAULT 0"},
+		{"invoices", "discount", "DECIMAL(20, 3) NOT NULL DEFAULT 0"},
+		{"invoices", "discount_total", "DECIMAL(20, 3) NOT NULL DEFAULT 0"},
+		{"invoice_items", "unit_price", "DECIMAL(20, 3) NOT NULL"},
+		{"invoice_items", "total", "DECIMAL(20, 3) NOT NULL"},
+		{"invoice_items", "subtotal", "DECIMAL(20, 3) NOT NULL DEFAULT 0"},
+		{"invoice_items", "tax_amount", "DECIMAL(20, 3) NOT NULL DEFAULT 0"},
+		{"invoice_items", "discount_amount", "DECIMAL(20, 3) NOT NULL DEFAULT 0"},
+		{"invoice_items", "discount", "DECIMAL(20, 3) NOT NULL DEFAULT 0"},
+		{"invoice_items", "invoice_discount", "DECIMAL(20, 3) NOT NULL DEFAULT 0"},
+		{"invoice_taxes", "taxable_amount", "DECIMAL(20, 3) NOT NULL"},
+		{"invoice_taxes", "tax_amount", "DECIMAL(20, 3) NOT NULL"},
+		{"payments", "amount", "DECIMAL(20, 3) NOT NULL"},
+	}
+
+	for _, patch := range amountColumns {
+		var scale sql.NullInt64
+		err := s.conn.QueryRowContext(s.ctx, "SELECT numeric_scale FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?",
+			patch.table, patch.column).Scan(&scale)
+		if err == sql.ErrNoRows || scale.Int64 >= 3 {
+			// Tables that do not exist yet are created by migration 0001.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := s.conn.ExecContext(s.ctx, fmt.Sprintf("ALTER TABLE %s MODIFY %s %s", patch.table, patch.column, patch.definition)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
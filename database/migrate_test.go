@@ -0,0 +1,254 @@
+package database
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"tiny-invoicing/migrations"
+	"tiny-invoicing/models"
+)
+
+// testMigrations holds two small migrations, the second without a down file, and a
+// down file whose up file is missing.
+var testMigrations = fstest.MapFS{
+	"0001_notes.up.sql":    {Data: []byte("-- Notes; one per row.\nCREATE TABLE notes (id INTEGER PRIMARY KEY, body TEXT NOT NULL);\nINSERT INTO notes (body) VALUES ('first');")},
+	"0001_notes.down.sql":  {Data: []byte("DROP TABLE notes;")},
+	"0002_labels.up.sql":   {Data: []byte("CREATE TABLE labels (name TEXT PRIMARY KEY);")},
+	"0003_broken.down.sql": {Data: []byte("DROP TABLE broken;")},
+	"README.md":            {Data: []byte("not a migration")},
+}
+
+func TestLoadMigrations(t *testing.T) {
+	files := fstest.MapFS{}
+	for name, file := range testMigrations {
+		if name != "0003_broken.down.sql" {
+			files[name] = file
+		}
+	}
+	list, err := LoadMigrations(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].String() != "0001_notes" || list[1].String() != "0002_labels" {
+		t.Fatalf("migrations = %v, want 0001_notes and 0002_labels", list)
+	}
+	if list[0].Down != "DROP TABLE notes;" || list[1].Down != "" || len(list[0].Checksum) != 64 || list[0].Checksum == list[1].Checksum {
+		t.Errorf("migrations = %+v", list)
+	}
+
+	for name, files := range map[string]fstest.MapFS{
+		"missing up file": testMigrations,
+		"bad name":        {"1-notes.up.sql": {Data: []byte("SELECT 1")}},
+		"bad direction":   {"0001_notes.sql": {Data: []byte("SELECT 1")}},
+		"shared version":  {"0001_notes.up.sql": {Data: []byte("SELECT 1")}, "0001_labels.up.sql": {Data: []byte("SELECT 1")}},
+	} {
+		if _, err := LoadMigrations(files); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	openEmptySQLite(t)
+	files := fstest.MapFS{}
+	for name, file := range testMigrations {
+		if !strings.HasPrefix(name, "0003") {
+			files[name] = file
+		}
+	}
+	list, err := LoadMigrations(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := MigrateUp(list)
+	if err != nil || len(applied) != 2 {
+		t.Fatalf("MigrateUp = %v, %v; want both migrations", applied, err)
+	}
+	if applied, err := MigrateUp(list); err != nil || len(applied) != 0 {
+		t.Errorf("second MigrateUp = %v, %v; want nothing to do", applied, err)
+	}
+	status, err := GetMigrationStatus(list)
+	if err != nil || len(status) != 2 || status[0].AppliedAt == nil || status[1].AppliedAt == nil {
+		t.Errorf("GetMigrationStatus = %+v, %v", status, err)
+	}
+
+	// 0002 has no down file, so nothing is reverted.
+	if _, err := MigrateDown(list, 2); err == nil {
+		t.Error("expected an error reverting a migration without a down file")
+	}
+	if _, err := DB.Exec("INSERT INTO labels (name) VALUES ('urgent')"); err != nil {
+		t.Errorf("labels table after failed MigrateDown: %v", err)
+	}
+
+	// Applied migrations must not change, and the database must not be ahead of list.
+	changed := append([]Migration(nil), list...)
+	changed[0].Checksum = strings.Repeat("0", 64)
+	if _, err := MigrateUp(changed); !errors.Is(err, ErrMigrationChanged) {
+		t.Errorf("changed migration: got %v, want ErrMigrationChanged", err)
+	}
+	if _, err := MigrateUp(list[:1]); !errors.Is(err, ErrUnknownMigration) {
+		t.Errorf("unknown migration: got %v, want ErrUnknownMigration", err)
+	}
+
+	// Reverting 0001 drops its table and forgets it.
+	if _, err := DB.Exec("DELETE FROM schema_migrations WHERE version = 2"); err != nil {
+		t.Fatal(err)
+	}
+	if reverted, err := MigrateDown(list[:1], 1); err != nil || len(reverted) != 1 {
+		t.Fatalf("MigrateDown = %v, %v", reverted, err)
+	}
+	if _, err := DB.Exec("SELECT COUNT(*) FROM notes"); err == nil {
+		t.Error("notes table still exists after reverting 0001_notes")
+	}
+	status, err = GetMigrationStatus(list[:1])
+	if err != nil || status[0].AppliedAt != nil {
+		t.Errorf("GetMigrationStatus after MigrateDown = %+v, %v", status, err)
+	}
+}
+
+func TestMigrate_FailureRollsBack(t *testing.T) {
+	openEmptySQLite(t)
+	files := fstest.MapFS{
+		"0001_notes.up.sql":  testMigrations["0001_notes.up.sql"],
+		"0002_broken.up.sql": {Data: []byte("CREATE TABLE broken (id INTEGER);\nINSERT INTO missing VALUES (1);")},
+	}
+	list, err := LoadMigrations(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MigrateUp(list); err == nil || !strings.Contains(err.Error(), "0002_broken") {
+		t.Fatalf("MigrateUp = %v, want an error naming 0002_broken", err)
+	}
+
+	// On SQLite the whole run is undone.
+	status, err := GetMigrationStatus(list)
+	if err != nil || status[0].AppliedAt != nil || status[1].AppliedAt != nil {
+		t.Errorf("GetMigrationStatus = %+v, %v; want both pending", status, err)
+	}
+	if _, err := DB.Exec("SELECT COUNT(*) FROM notes"); err == nil {
+		t.Error("notes table exists after the failed run")
+	}
+}
+
+func TestMigrate_Embedded(t *testing.T) {
+	// Every backend has the same versions.
+	var want string
+	for _, dir := range []string{"mysql", "sqlite", "postgres"} {
+		files, err := fs.Sub(migrations.Files, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		list, err := LoadMigrations(files)
+		if err != nil {
+			t.Fatalf("%s: %v", dir, err)
+		}
+		var names []string
+		for _, m := range list {
+			if m.Down == "" {
+				t.Errorf("%s: migration %s has no down file", dir, m)
+			}
+			names = append(names, m.String())
+		}
+		if got := strings.Join(names, ", "); dir == "mysql" {
+			want = got
+		} else if got != want {
+			t.Errorf("%s migrations = %s, want %s as for mysql", dir, got, want)
+		}
+	}
+
+	openEmptySQLite(t)
+	list, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for round := 0; round < 2; round++ {
+		if _, err := MigrateUp(list); err != nil {
+			t.Fatalf("MigrateUp: %v", err)
+		}
+		if _, err := MigrateDown(list, len(list)); err != nil {
+			t.Fatalf("MigrateDown: %v", err)
+		}
+	}
+	var tables int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name NOT IN ('schema_migrations', 'sqlite_sequence')").Scan(&tables); err != nil || tables != 0 {
+		t.Errorf("%d tables left after reverting every migration (%v)", tables, err)
+	}
+}
+
+func TestMigrate_UserRoles(t *testing.T) {
+	openEmptySQLite(t)
+	list, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var before []Migration
+	for _, m := range list {
+		if m.Version < 7 {
+			before = append(before, m)
+		}
+	}
+	if _, err := MigrateUp(before); err != nil {
+		t.Fatal(err)
+	}
+	for _, user := range []struct {
+		name    string
+		isAdmin bool
+	}{{"clerk", false}, {"founder", true}, {"partner", true}} {
+		if _, err := DB.Exec("INSERT INTO users (username, password_hash, is_admin) VALUES (?, 'hash', ?)", user.name, user.isAdmin); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first admin becomes the owner and later admins stay admins. Everyone else
+	// gets no role, rather than being let in to read, until an admin assigns one.
+	if _, err := MigrateUp(list); err != nil {
+		t.Fatal(err)
+	}
+	for username, want := range map[string]models.Role{"clerk": models.RoleNone, "founder": models.RoleOwner, "partner": models.RoleAdmin} {
+		if user, err := GetUserByUsername(username); err != nil || user.Role != want {
+			t.Errorf("%s after migrating: %+v, %v; want role %s", username, user, err, want)
+		}
+	}
+
+	if _, err := MigrateDown(list, len(list)-len(before)); err != nil {
+		t.Fatal(err)
+	}
+	var admins int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM users WHERE is_admin").Scan(&admins); err != nil || admins != 2 {
+		t.Errorf("%d admins after reverting roles (%v), want 2", admins, err)
+	}
+}
+
+func TestMigrate_Concurrent(t *testing.T) {
+	openEmptySQLite(t)
+	list, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	total := 0
+	for k := 0; k < 4; k++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			applied, err := MigrateUp(list)
+			if err != nil {
+				t.Error(err)
+			}
+			mu.Lock()
+			total += len(applied)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if total != len(list) {
+		t.Errorf("%d migrations applied in total, want %d", total, len(list))
+	}
+}
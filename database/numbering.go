@@ -0,0 +1,98 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"tiny-invoicing/models"
+)
+
+// ErrNumberTaken is returned when the number a sequence hands out already belongs to
+// another document, as after its format was changed to one used before.
+var ErrNumberTaken = errors.New("document number is already taken; check the numbering format")
+
+// GetNumberSequences lists the configured numbering sequences.
+func GetNumberSequences() ([]models.NumberSequence, error) {
+	rows, err := DB.Query("SELECT name, format, reset_period FROM number_sequences ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sequences []models.NumberSequence
+	for rows.Next() {
+		var sequence models.NumberSequence
+		if err := rows.Scan(&sequence.Name, &sequence.Format, &sequence.Reset); err != nil {
+			return nil, err
+		}
+		sequences = append(sequences, sequence)
+	}
+	return sequences, rows.Err()
+}
+
+// UpdateNumberSequence changes the format and reset rule of a sequence. Numbers already
+// handed out are kept, and the counter carries on: when the reset rule changes, the
+// counter of the period that now is in effect, as of now, continues from the highest
+// number the sequence has handed out in any period. It returns sql.ErrNoRows if the
+// sequence does not exist.
+func UpdateNumberSequence(sequence *models.NumberSequence, now time.Time) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var reset models.SequenceReset
+	if err := tx.QueryRow("SELECT reset_period FROM number_sequences WHERE name = ?"+backend.forUpdate(), sequence.Name).Scan(&reset); err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE number_sequences SET format = ?, reset_period = ? WHERE name = ?",
+		sequence.Format, sequence.Reset, sequence.Name)
+	if err != nil {
+		return err
+	}
+
+	if reset != sequence.Reset {
+		var last int64
+		if err := tx.QueryRow("SELECT COALESCE(MAX(last_value), 0) FROM number_sequence_counters WHERE sequence_name = ?", sequence.Name).Scan(&last); err != nil {
+			return err
+		}
+		if last > 0 {
+			_, err = tx.Exec("INSERT INTO number_sequence_counters (sequence_name, period, last_value) VALUES (?, ?, ?)"+
+				backend.onConflict("sequence_name, period", "last_value = "+backend.excluded("last_value")),
+				sequence.Name, sequence.Period(now), last)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// nextNumber hands out the next number of a sequence for a document dated date.
+// The counter row stays locked until tx ends, so concurrent finalisations take
+// numbers one after another, and a rollback gives the number back: the numbers
+// of committed documents have no gaps.
+func nextNumber(tx *sql.Tx, name string, date time.Time) (string, error) {
+	var sequence models.NumberSequence
+	err := tx.QueryRow("SELECT name, format, reset_period FROM number_sequences WHERE name = ?", name).Scan(
+		&sequence.Name, &sequence.Format, &sequence.Reset)
+	if err != nil {
+		return "", err
+	}
+	period := sequence.Period(date)
+
+	_, err = tx.Exec("INSERT INTO number_sequence_counters (sequence_name, period, last_value) VALUES (?, ?, 1)"+
+		backend.onConflict("sequence_name, period", "last_value = number_sequence_counters.last_value + 1"),
+		name, period)
+	if err != nil {
+		return "", err
+	}
+	var value int64
+	err = tx.QueryRow("SELECT last_value FROM number_sequence_counters WHERE sequence_name = ? AND period = ?", name, period).Scan(&value)
+	if err != nil {
+		return "", err
+	}
+	return sequence.Number(date, value), nil
+}
@@ -0,0 +1,154 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"tiny-invoicing/models"
+)
+
+// paymentColumns is the column list read by scanPayment.
+const paymentColumns = "id, invoice_id, kind, amount, paid_on, method, reference, reversed_at, created_at"
+
+// scanPayment reads a payment of an invoice in the given currency.
+func scanPayment(row rowScanner, payment *models.Payment, currency string) error {
+	var a amounts
+	err := row.Scan(&payment.ID, &payment.InvoiceID, &payment.Kind, a.col(&payment.Amount), &payment.Date,
+		&payment.Method, &payment.Reference, &payment.ReversedAt, &payment.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return a.parse(currency)
+}
+
+// GetPayments lists an invoice's payment ledger in the order entries were recorded.
+// It returns sql.ErrNoRows if the invoice does not exist.
+func GetPayments(invoiceID int) ([]models.Payment, error) {
+	currency, err := InvoiceCurrency(invoiceID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := DB.Query("SELECT "+paymentColumns+" FROM payments WHERE invoice_id = ? ORDER BY id", invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payments []models.Payment
+	for rows.Next() {
+		var payment models.Payment
+		if err := scanPayment(rows, &payment, currency); err != nil {
+			return nil, err
+		}
+		payments = append(payments, payment)
+	}
+	return payments, rows.Err()
+}
+
+// lockInvoiceForPayment reads the fields that payments depend on and locks the invoice row
+// until the transaction ends, so concurrent payments cannot both spend the same balance.
+// Unless version is 0, the invoice must still be at that version.
+func lockInvoiceForPayment(tx *sql.Tx, invoiceID, version int) (*models.Invoice, error) {
+	var invoice models.Invoice
+	var a amounts
+	err := tx.QueryRow("SELECT id, currency, status, total, amount_paid, amount_credited, paid_at, version FROM invoices WHERE id = ?"+backend.forUpdate(), invoiceID).Scan(
+		&invoice.ID, &invoice.Currency, &invoice.Status, a.col(&invoice.Total), a.col(&invoice.AmountPaid), a.col(&invoice.AmountCredited), &invoice.PaidAt, &invoice.Version)
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 && version != invoice.Version {
+		return nil, ErrVersionMismatch
+	}
+	if err := a.parse(invoice.Currency); err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// saveInvoiceSettlement writes back the payment-driven fields of an invoice, moving
+// it to its next version.
+func saveInvoiceSettlement(tx *sql.Tx, invoice *models.Invoice) error {
+	_, err := tx.Exec("UPDATE invoices SET status = ?, amount_paid = ?, paid_at = ?, version = version + 1 WHERE id = ?",
+		invoice.Status, invoice.AmountPaid, invoice.PaidAt, invoice.ID)
+	if err != nil {
+		return err
+	}
+	invoice.Version++
+	return nil
+}
+
+// RecordPayment adds a payment or refund to an invoice's ledger and updates the invoice's
+// amount paid and status in the same transaction. Unless version is 0, the invoice must
+// still be at that version. It returns the payment's ID and the invoice's new version,
+// sql.ErrNoRows for unknown invoices, ErrVersionMismatch and the models payment errors
+// for entries the invoice cannot accept. The payment's amount must be in the invoice's
+// currency.
+func RecordPayment(payment *models.Payment, version int) (int64, int, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	invoice, err := lockInvoiceForPayment(tx, payment.InvoiceID, version)
+	if err != nil {
+		return 0, 0, err
+	}
+	if payment.Amount.Currency != invoice.Currency {
+		return 0, 0, fmt.Errorf("%w: amount is in %s but the invoice is in %s", models.ErrInvalidPayment, payment.Amount.Currency, invoice.Currency)
+	}
+
+	now := time.Now()
+	if err := invoice.ApplyPayment(payment, now); err != nil {
+		return 0, 0, err
+	}
+
+	paymentID, err := insert(tx, "INSERT INTO payments (invoice_id, kind, amount, paid_on, method, reference, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		payment.InvoiceID, payment.Kind, payment.Amount, payment.Date, payment.Method, payment.Reference, now)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err := saveInvoiceSettlement(tx, invoice); err != nil {
+		return 0, 0, err
+	}
+	payment.CreatedAt = now
+	return paymentID, invoice.Version, tx.Commit()
+}
+
+// ReversePayment marks a ledger entry as reversed (e.g. a bounced transfer) and
+// recalculates the invoice. Unless version is 0, the invoice must still be at that
+// version. It returns the reversed entry and the invoice's new version, sql.ErrNoRows
+// if the invoice or payment is unknown, and ErrVersionMismatch.
+func ReversePayment(invoiceID, paymentID, version int) (*models.Payment, int, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	invoice, err := lockInvoiceForPayment(tx, invoiceID, version)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var payment models.Payment
+	row := tx.QueryRow("SELECT "+paymentColumns+" FROM payments WHERE id = ? AND invoice_id = ?", paymentID, invoiceID)
+	if err := scanPayment(row, &payment, invoice.Currency); err != nil {
+		return nil, 0, err
+	}
+
+	if err := invoice.ReversePayment(&payment, time.Now()); err != nil {
+		return nil, 0, err
+	}
+
+	if _, err := tx.Exec("UPDATE payments SET reversed_at = ? WHERE id = ?", payment.ReversedAt, payment.ID); err != nil {
+		return nil, 0, err
+	}
+	if err := saveInvoiceSettlement(tx, invoice); err != nil {
+		return nil, 0, err
+	}
+	return &payment, invoice.Version, tx.Commit()
+}
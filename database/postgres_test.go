@@ -0,0 +1,19 @@
+package database
+
+import "testing"
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		query, want string
+	}{
+		{"SELECT 1", "SELECT 1"},
+		{"SELECT * FROM invoices WHERE id = ?", "SELECT * FROM invoices WHERE id = $1"},
+		{"INSERT INTO t (a, b) VALUES (?, ?), (?, ?)", "INSERT INTO t (a, b) VALUES ($1, $2), ($3, $4)"},
+		{"SELECT '?', \"a?\" FROM t WHERE x = ? AND y = 'it''s?'", "SELECT '?', \"a?\" FROM t WHERE x = $1 AND y = 'it''s?'"},
+	}
+	for _, tt := range tests {
+		if got := rebind(tt.query); got != tt.want {
+			t.Errorf("rebind(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
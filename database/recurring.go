@@ -0,0 +1,226 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"tiny-invoicing/models"
+)
+
+// ErrRecurrenceExists is returned by CreateInvoice when the run of a recurring
+// profile it was generated for already has an invoice.
+var ErrRecurrenceExists = errors.New("invoice already generated for this run")
+
+// recurringProfileColumns is the column list read by scanRecurringProfile.
+const recurringProfileColumns = "id, name, customer_id, currency, cadence, cron, start_date, end_date, due_days, prices_include_tax, discount_percent, discount_amount, rounding_mode, active, next_run, last_run"
+
+// scanRecurringProfile reads a row selected with recurringProfileColumns.
+func scanRecurringProfile(row rowScanner, profile *models.RecurringProfile) error {
+	var a amounts
+	err := row.Scan(&profile.ID, &profile.Name, &profile.CustomerID, &profile.Currency, &profile.Cadence, &profile.Cron,
+		&profile.StartDate, &profile.EndDate, &profile.DueDays, &profile.PricesIncludeTax,
+		&profile.DiscountPercent, a.col(&profile.DiscountAmount), &profile.RoundingMode,
+		&profile.Active, &profile.NextRun, &profile.LastRun)
+	if err != nil {
+		return err
+	}
+	return a.parse(profile.Currency)
+}
+
+// loadRecurringProfileItems fills in the line items of profiles.
+func loadRecurringProfileItems(profiles []models.RecurringProfile) error {
+	if len(profiles) == 0 {
+		return nil
+	}
+	index := make(map[int]*models.RecurringProfile, len(profiles))
+	ids := make([]interface{}, len(profiles))
+	for k := range profiles {
+		index[profiles[k].ID] = &profiles[k]
+		ids[k] = profiles[k].ID
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	rows, err := DB.Query("SELECT profile_id, description, quantity, unit_price, discount_percent, discount_amount, tax_codes FROM recurring_profile_items WHERE profile_id IN ("+placeholders+") ORDER BY id", ids...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var profileID int
+		var item models.LineItem
+		var a amounts
+		var taxCodes string
+		if err := rows.Scan(&profileID, &item.Description, &item.Quantity, a.col(&item.UnitPrice),
+			&item.DiscountPercent, a.col(&item.DiscountAmount), &taxCodes); err != nil {
+			return err
+		}
+		profile := index[profileID]
+		if err := a.parse(profile.Currency); err != nil {
+			return err
+		}
+		if taxCodes != "" {
+			item.TaxCodes = strings.Split(taxCodes, ",")
+		}
+		profile.LineItems = append(profile.LineItems, item)
+	}
+	return rows.Err()
+}
+
+// queryRecurringProfiles runs a query selecting recurringProfileColumns and loads the
+// line items of the profiles found.
+func queryRecurringProfiles(query string, args ...interface{}) ([]models.RecurringProfile, error) {
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var profiles []models.RecurringProfile
+	for rows.Next() {
+		var profile models.RecurringProfile
+		if err := scanRecurringProfile(rows, &profile); err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, profile)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := loadRecurringProfileItems(profiles); err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+// GetRecurringProfiles lists all recurring profiles, including inactive ones.
+func GetRecurringProfiles() ([]models.RecurringProfile, error) {
+	return queryRecurringProfiles("SELECT " + recurringProfileColumns + " FROM recurring_profiles ORDER BY id")
+}
+
+// GetRecurringProfileByID retrieves a single recurring profile with its line items.
+func GetRecurringProfileByID(id int) (*models.RecurringProfile, error) {
+	profiles, err := queryRecurringProfiles("SELECT "+recurringProfileColumns+" FROM recurring_profiles WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(profiles) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &profiles[0], nil
+}
+
+// GetDueRecurringProfiles lists the active profiles whose next run is at or before now.
+func GetDueRecurringProfiles(now time.Time) ([]models.RecurringProfile, error) {
+	return queryRecurringProfiles("SELECT "+recurringProfileColumns+" FROM recurring_profiles WHERE active = TRUE AND next_run IS NOT NULL AND next_run <= ? ORDER BY next_run", now)
+}
+
+// CreateRecurringProfile stores a validated profile and schedules its first run.
+func CreateRecurringProfile(profile *models.RecurringProfile) (int64, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := customerExists(tx, profile.CustomerID); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrUnknownCustomer
+		}
+		return 0, err
+	}
+
+	profile.LastRun = nil
+	profile.Schedule(time.Now())
+
+	id, err := insert(tx, "INSERT INTO recurring_profiles (name, customer_id, currency, cadence, cron, start_date, end_date, due_days, prices_include_tax, discount_percent, discount_amount, rounding_mode, active, next_run) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		profile.Name, profile.CustomerID, profile.Currency, profile.Cadence, profile.Cron, profile.StartDate, profile.EndDate,
+		profile.DueDays, profile.PricesIncludeTax, profile.DiscountPercent, profile.DiscountAmount, profile.RoundingMode,
+		profile.Active, profile.NextRun)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := insertRecurringProfileItems(tx, id, profile.LineItems); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// UpdateRecurringProfile replaces a profile and its line items, and reschedules its
+// next run after the last one. Invoices already generated are not changed. It
+// returns sql.ErrNoRows if the profile does not exist.
+func UpdateRecurringProfile(profile *models.RecurringProfile) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the row so the scheduler cannot run the profile while it changes.
+	var lastRun *time.Time
+	if err := tx.QueryRow("SELECT last_run FROM recurring_profiles WHERE id = ?"+backend.forUpdate(), profile.ID).Scan(&lastRun); err != nil {
+		return err
+	}
+	if err := customerExists(tx, profile.CustomerID); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrUnknownCustomer
+		}
+		return err
+	}
+
+	profile.LastRun = lastRun
+	profile.Schedule(time.Now())
+
+	_, err = tx.Exec("UPDATE recurring_profiles SET name = ?, customer_id = ?, currency = ?, cadence = ?, cron = ?, start_date = ?, end_date = ?, due_days = ?, prices_include_tax = ?, discount_percent = ?, discount_amount = ?, rounding_mode = ?, active = ?, next_run = ? WHERE id = ?",
+		profile.Name, profile.CustomerID, profile.Currency, profile.Cadence, profile.Cron, profile.StartDate, profile.EndDate,
+		profile.DueDays, profile.PricesIncludeTax, profile.DiscountPercent, profile.DiscountAmount, profile.RoundingMode,
+		profile.Active, profile.NextRun, profile.ID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM recurring_profile_items WHERE profile_id = ?", profile.ID); err != nil {
+		return err
+	}
+	if err := insertRecurringProfileItems(tx, int64(profile.ID), profile.LineItems); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertRecurringProfileItems(tx *sql.Tx, profileID int64, items []models.LineItem) error {
+	for _, item := range items {
+		_, err := tx.Exec("INSERT INTO recurring_profile_items (profile_id, description, quantity, unit_price, discount_percent, discount_amount, tax_codes) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			profileID, item.Description, item.Quantity, item.UnitPrice, item.DiscountPercent, item.DiscountAmount,
+			strings.Join(item.TaxCodes, ","))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AdvanceRecurringProfile records that the run of a profile has been generated and
+// moves it on to next, which is nil when the schedule has ended. It returns
+// sql.ErrNoRows if the profile is no longer due at run, because it was changed or
+// another process got there first.
+func AdvanceRecurringProfile(id int, run time.Time, next *time.Time) error {
+	result, err := DB.Exec("UPDATE recurring_profiles SET last_run = ?, next_run = ? WHERE id = ? AND next_run = ?",
+		run, next, id, run)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"sort"
+	"time"
+
+	"tiny-invoicing/models"
+)
+
+// GetSalesReport sums the invoices and credit notes issued between from and to
+// (inclusive) by currency, together with their base-currency equivalents. Drafts and
+// void invoices are left out.
+func GetSalesReport(from, to time.Time) (*models.SalesReport, error) {
+	base := models.BaseCurrency
+	byCurrency := make(map[string]*models.CurrencySales)
+	sales := func(currency string) *models.CurrencySales {
+		if byCurrency[currency] == nil {
+			byCurrency[currency] = &models.CurrencySales{
+				Currency:     currency,
+				Total:        models.NewMoney(0, currency),
+				BaseTotal:    models.NewMoney(0, base),
+				Credited:     models.NewMoney(0, currency),
+				BaseCredited: models.NewMoney(0, base),
+			}
+		}
+		return byCurrency[currency]
+	}
+
+	err := sumDocuments(`SELECT currency, COUNT(*), SUM(total), SUM(base_total) FROM invoices
+		WHERE status NOT IN ('draft', 'void') AND base_currency = ? AND issue_date BETWEEN ? AND ?
+		GROUP BY currency`, base, from, to, func(currency string, count int, total, baseTotal models.Money) {
+		s := sales(currency)
+		s.Invoices, s.Total, s.BaseTotal = count, total, baseTotal
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = sumDocuments(`SELECT currency, COUNT(*), SUM(total), SUM(base_total) FROM credit_notes
+		WHERE base_currency = ? AND issue_date BETWEEN ? AND ?
+		GROUP BY currency`, base, from, to, func(currency string, count int, total, baseTotal models.Money) {
+		s := sales(currency)
+		s.CreditNotes, s.Credited, s.BaseCredited = count, total, baseTotal
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.SalesReport{
+		From:         from,
+		To:           to,
+		BaseCurrency: base,
+		BaseTotal:    models.NewMoney(0, base),
+		BaseCredited: models.NewMoney(0, base),
+	}
+	for _, s := range byCurrency {
+		s.Net = s.Total.Sub(s.Credited)
+		s.BaseNet = s.BaseTotal.Sub(s.BaseCredited)
+		report.Currencies = append(report.Currencies, *s)
+		if report.BaseTotal, err = report.BaseTotal.Add(s.BaseTotal); err != nil {
+			return nil, err
+		}
+		if report.BaseCredited, err = report.BaseCredited.Add(s.BaseCredited); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(report.Currencies, func(i, j int) bool {
+		return report.Currencies[i].Currency < report.Currencies[j].Currency
+	})
+	report.BaseNet = report.BaseTotal.Sub(report.BaseCredited)
+	return report, nil
+}
+
+// sumDocuments runs a query returning currency, count, total and base total rows and
+// passes each row to add.
+func sumDocuments(query, base string, from, to time.Time, add func(currency string, count int, total, baseTotal models.Money)) error {
+	rows, err := DB.Query(query, base, from, to)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var currency, total, baseTotal string
+		var count int
+		if err := rows.Scan(&currency, &count, &total, &baseTotal); err != nil {
+			return err
+		}
+		documents, err := models.ParseMoney(total, currency, models.RoundHalfUp)
+		if err != nil {
+			return err
+		}
+		inBase, err := models.ParseMoney(baseTotal, base, models.RoundHalfUp)
+		if err != nil {
+			return err
+		}
+		add(currency, count, documents, inBase)
+	}
+	return rows.Err()
+}
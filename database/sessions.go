@@ -0,0 +1,81 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Session is a login session of a user.
+type Session struct {
+	ID int `json:"id"`
+	// TokenHash is the SHA-256 hash of the token in the session cookie.
+	TokenHash string `json:"-"`
+	// CSRFToken must accompany requests that change state using the session cookie.
+	CSRFToken string    `json:"-"`
+	UserID    int       `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// User is the user signed in, filled in by GetSession.
+	User *User `json:"-"`
+}
+
+// CreateSession stores a new session and returns its ID. Sessions that expired
+// before the new one was created are removed.
+func CreateSession(session *Session) (int64, error) {
+	if _, err := DB.Exec("DELETE FROM sessions WHERE expires_at < ?", session.CreatedAt.UTC()); err != nil {
+		return 0, err
+	}
+	return insert(DB, "INSERT INTO sessions (token_hash, csrf_token, user_id, created_at, expires_at) VALUES (?, ?, ?, ?, ?)",
+		session.TokenHash, session.CSRFToken, session.UserID, session.CreatedAt.UTC(), session.ExpiresAt.UTC())
+}
+
+// GetSession retrieves the session with the token hash, and its user, if it has
+// not expired by now. It returns sql.ErrNoRows otherwise.
+func GetSession(tokenHash string, now time.Time) (*Session, error) {
+	session := Session{TokenHash: tokenHash, User: &User{}}
+	err := DB.QueryRow(`SELECT s.id, s.csrf_token, s.user_id, s.created_at, s.expires_at, u.id, u.username, u.password_hash, COALESCE(u.role, '')
+		FROM sessions s JOIN users u ON u.id = s.user_id WHERE s.token_hash = ? AND s.expires_at > ?`, tokenHash, now.UTC()).Scan(
+		&session.ID, &session.CSRFToken, &session.UserID, &session.CreatedAt, &session.ExpiresAt,
+		&session.User.ID, &session.User.Username, &session.User.PasswordHash, &session.User.Role)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetUserSessions lists the sessions of a user that have not expired by now, newest first.
+func GetUserSessions(userID int, now time.Time) ([]Session, error) {
+	rows, err := DB.Query("SELECT id, user_id, created_at, expires_at FROM sessions WHERE user_id = ? AND expires_at > ? ORDER BY created_at DESC, id DESC",
+		userID, now.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []Session
+	for rows.Next() {
+		var session Session
+		if err := rows.Scan(&session.ID, &session.UserID, &session.CreatedAt, &session.ExpiresAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// DeleteSession revokes a session of a user. It returns sql.ErrNoRows if the user
+// has no session with that ID.
+func DeleteSession(userID, id int) error {
+	result, err := DB.Exec("DELETE FROM sessions WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
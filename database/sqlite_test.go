@@ -0,0 +1,164 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"tiny-invoicing/models"
+)
+
+// openEmptySQLite points DB at a new SQLite database without any tables.
+func openEmptySQLite(t *testing.T) {
+	t.Helper()
+	oldDB, oldBackend := DB, backend
+	t.Cleanup(func() {
+		DB.Close()
+		DB, backend = oldDB, oldBackend
+	})
+
+	if err := Open("sqlite:" + filepath.Join(t.TempDir(), "invoices.db")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// openSQLite points DB at a new, fully migrated SQLite database.
+func openSQLite(t *testing.T) {
+	t.Helper()
+	openEmptySQLite(t)
+	list, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MigrateUp(list); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+	if err := EnsureDefaultCustomer(); err != nil {
+		t.Fatal(err)
+	}
+	if err := EnsureNumberSequences(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSQLite_Invoices(t *testing.T) {
+	openSQLite(t)
+	if CurrentBackend() != SQLite {
+		t.Fatalf("backend = %s, want sqlite", CurrentBackend())
+	}
+
+	customerID, err := CreateCustomer(&Customer{Name: "Acme", Email: "billing@acme.test", Currency: "USD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CreateTaxRate(&models.TaxRate{Code: "VAT", Name: "VAT", Kind: models.TaxKindVAT, Rate: models.NewPercent(2000), Active: true}); err != nil {
+		t.Fatal(err)
+	}
+	rates, err := GetTaxRatesByCode([]string{"VAT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issued := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	invoice := &models.Invoice{CustomerID: int(customerID), Currency: "USD", IssueDate: issued, DueDate: issued.AddDate(0, 0, 14),
+		LineItems: []models.LineItem{
+			{Description: "Design", Quantity: 3, UnitPrice: models.NewMoney(3333, "USD"), TaxCodes: []string{"VAT"}},
+			{Description: "Hosting", Quantity: 1, UnitPrice: models.NewMoney(1050, "USD")},
+		}}
+	if err := invoice.SetTaxRates(rates); err != nil {
+		t.Fatal(err)
+	}
+	invoice.CalculateTotal()
+	id, err := CreateInvoice(invoice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := GetInvoiceByID(int(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Total != invoice.Total || stored.TaxTotal != invoice.TaxTotal || len(stored.LineItems) != 2 || len(stored.Taxes) != 1 {
+		t.Errorf("stored invoice = %+v, want %+v", stored, invoice)
+	}
+	if !stored.IssueDate.Equal(issued) || stored.Status != models.StatusDraft {
+		t.Errorf("stored issue date %v, status %s", stored.IssueDate, stored.Status)
+	}
+
+	if err := TransitionInvoiceStatus(int(id), 0, models.StatusSent, issued.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := TransitionInvoiceStatus(int(id), 0, models.StatusDraft, issued.Add(time.Hour)); err == nil {
+		t.Error("expected an error moving a sent invoice back to draft")
+	}
+	// Sending moved the invoice to version 2, so a payment based on version 1 is refused.
+	payment := models.Payment{InvoiceID: int(id), Amount: stored.Total, Date: issued, Method: "bank", Kind: models.PaymentKindPayment}
+	if _, _, err := RecordPayment(&payment, 1); err != ErrVersionMismatch {
+		t.Errorf("paying a stale version: got %v, want ErrVersionMismatch", err)
+	}
+	if _, version, err := RecordPayment(&payment, 2); err != nil || version != 3 {
+		t.Fatalf("RecordPayment = version %d, %v; want version 3", version, err)
+	}
+
+	invoices, err := GetInvoices(10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invoices) != 1 || invoices[0].Number != "INV-2026-00001" || invoices[0].Status != models.StatusPaid || !invoices[0].BalanceDue.IsZero() {
+		t.Errorf("invoices = %+v, want INV-2026-00001 paid in full", invoices)
+	}
+
+	report, err := GetSalesReport(issued, issued.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Currencies) != 1 || report.Currencies[0].Total != invoice.Total {
+		t.Errorf("report = %+v, want one invoice of %s", report, invoice.Total)
+	}
+
+	if err := DeleteCustomer(int(customerID)); err != ErrCustomerInUse {
+		t.Errorf("deleting a customer with invoices: got %v, want ErrCustomerInUse", err)
+	}
+	if _, err := GetInvoiceByID(int(id) + 1); err != sql.ErrNoRows {
+		t.Errorf("unknown invoice: got %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestSQLite_UsersAndSettings(t *testing.T) {
+	openSQLite(t)
+
+	if _, err := CreateUser(&User{Username: "admin", PasswordHash: "hash", Role: models.RoleAdmin}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CreateUser(&User{Username: "admin", PasswordHash: "other"}); err != ErrUsernameTaken {
+		t.Errorf("duplicate username: got %v, want ErrUsernameTaken", err)
+	}
+	user, err := GetUserByUsername("admin")
+	if err != nil || user.Role != models.RoleAdmin || user.PasswordHash != "hash" {
+		t.Errorf("GetUserByUsername = %+v, %v", user, err)
+	}
+
+	// Upserts replace the existing row.
+	for _, name := range []string{"Tiny Invoicing", "Tiny Invoicing Ltd"} {
+		if err := SaveBranding(&models.Branding{CompanyName: name, Color: models.DefaultBrandColor}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	branding, err := GetBranding()
+	if err != nil || branding.CompanyName != "Tiny Invoicing Ltd" {
+		t.Errorf("GetBranding = %+v, %v", branding, err)
+	}
+
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	for _, rate := range []string{"0.9", "0.92"} {
+		r, _ := models.ParseRate(rate)
+		if err := SaveExchangeRates([]models.ExchangeRate{{Base: "USD", Currency: "EUR", Rate: r, Date: day}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rates, err := GetExchangeRates("USD", "EUR", 10, 0)
+	if err != nil || len(rates) != 1 || rates[0].Rate.String() != "0.92" {
+		t.Errorf("GetExchangeRates = %+v, %v", rates, err)
+	}
+}
@@ -1,6 +1,10 @@
 package database
 
-import "tiny-invoicing/models"
+import (
+	"time"
+
+	"tiny-invoicing/models"
+)
 
 // Store is a database adapter that implements handler interfaces.
 type Store struct{}
@@ -9,3 +13,348 @@ type Store struct{}
 func (s *Store) CreateInvoice(invoice *models.Invoice) (int64, error) {
 	return CreateInvoice(invoice)
 }
+
+// GetInvoices calls the package-level GetInvoices function.
+func (s *Store) GetInvoices(limit, offset int) ([]models.Invoice, error) {
+	return GetInvoices(limit, offset)
+}
+
+// GetInvoiceByID calls the package-level GetInvoiceByID function.
+func (s *Store) GetInvoiceByID(id int) (*models.Invoice, error) {
+	return GetInvoiceByID(id)
+}
+
+// UpdateInvoice calls the package-level UpdateInvoice function.
+func (s *Store) UpdateInvoice(invoice *models.Invoice) error {
+	return UpdateInvoice(invoice)
+}
+
+// TransitionInvoiceStatus calls the package-level TransitionInvoiceStatus function.
+func (s *Store) TransitionInvoiceStatus(id, version int, status models.InvoiceStatus, at time.Time) error {
+	return TransitionInvoiceStatus(id, version, status, at)
+}
+
+// GetCustomers calls the package-level GetCustomers function.
+func (s *Store) GetCustomers(limit, offset int) ([]Customer, error) {
+	return GetCustomers(limit, offset)
+}
+
+// GetCustomerByID calls the package-level GetCustomerByID function.
+func (s *Store) GetCustomerByID(id int) (*Customer, error) {
+	return GetCustomerByID(id)
+}
+
+// CreateCustomer calls the package-level CreateCustomer function.
+func (s *Store) CreateCustomer(customer *Customer) (int64, error) {
+	return CreateCustomer(customer)
+}
+
+// UpdateCustomer calls the package-level UpdateCustomer function.
+func (s *Store) UpdateCustomer(customer *Customer) error {
+	return UpdateCustomer(customer)
+}
+
+// DeleteCustomer calls the package-level DeleteCustomer function.
+func (s *Store) DeleteCustomer(id int) error {
+	return DeleteCustomer(id)
+}
+
+// GetPayments calls the package-level GetPayments function.
+func (s *Store) GetPayments(invoiceID int) ([]models.Payment, error) {
+	return GetPayments(invoiceID)
+}
+
+// RecordPayment calls the package-level RecordPayment function.
+func (s *Store) RecordPayment(payment *models.Payment, version int) (int64, int, error) {
+	return RecordPayment(payment, version)
+}
+
+// ReversePayment calls the package-level ReversePayment function.
+func (s *Store) ReversePayment(invoiceID, paymentID, version int) (*models.Payment, int, error) {
+	return ReversePayment(invoiceID, paymentID, version)
+}
+
+// GetTaxRates calls the package-level GetTaxRates function.
+func (s *Store) GetTaxRates() ([]models.TaxRate, error) {
+	return GetTaxRates()
+}
+
+// GetTaxRateByID calls the package-level GetTaxRateByID function.
+func (s *Store) GetTaxRateByID(id int) (*models.TaxRate, error) {
+	return GetTaxRateByID(id)
+}
+
+// GetTaxRatesByCode calls the package-level GetTaxRatesByCode function.
+func (s *Store) GetTaxRatesByCode(codes []string) ([]models.TaxRate, error) {
+	return GetTaxRatesByCode(codes)
+}
+
+// CreateTaxRate calls the package-level CreateTaxRate function.
+func (s *Store) CreateTaxRate(rate *models.TaxRate) (int64, error) {
+	return CreateTaxRate(rate)
+}
+
+// UpdateTaxRate calls the package-level UpdateTaxRate function.
+func (s *Store) UpdateTaxRate(rate *models.TaxRate) error {
+	return UpdateTaxRate(rate)
+}
+
+// InvoiceCurrency calls the package-level InvoiceCurrency function.
+func (s *Store) InvoiceCurrency(id int) (string, error) {
+	return InvoiceCurrency(id)
+}
+
+// GetExchangeRates calls the package-level GetExchangeRates function.
+func (s *Store) GetExchangeRates(base, currency string, limit, offset int) ([]models.ExchangeRate, error) {
+	return GetExchangeRates(base, currency, limit, offset)
+}
+
+// SaveExchangeRates calls the package-level SaveExchangeRates function.
+func (s *Store) SaveExchangeRates(rates []models.ExchangeRate) error {
+	return SaveExchangeRates(rates)
+}
+
+// GetSalesReport calls the package-level GetSalesReport function.
+func (s *Store) GetSalesReport(from, to time.Time) (*models.SalesReport, error) {
+	return GetSalesReport(from, to)
+}
+
+// GetNumberSequences calls the package-level GetNumberSequences function.
+func (s *Store) GetNumberSequences() ([]models.NumberSequence, error) {
+	return GetNumberSequences()
+}
+
+// UpdateNumberSequence calls the package-level UpdateNumberSequence function.
+func (s *Store) UpdateNumberSequence(sequence *models.NumberSequence, now time.Time) error {
+	return UpdateNumberSequence(sequence, now)
+}
+
+// GetBranding calls the package-level GetBranding function.
+func (s *Store) GetBranding() (*models.Branding, error) {
+	return GetBranding()
+}
+
+// SaveBranding calls the package-level SaveBranding function.
+func (s *Store) SaveBranding(branding *models.Branding) error {
+	return SaveBranding(branding)
+}
+
+// GetInvoiceTemplates calls the package-level GetInvoiceTemplates function.
+func (s *Store) GetInvoiceTemplates() ([]models.InvoiceTemplate, error) {
+	return GetInvoiceTemplates()
+}
+
+// GetInvoiceTemplate calls the package-level GetInvoiceTemplate function.
+func (s *Store) GetInvoiceTemplate(name string) (*models.InvoiceTemplate, error) {
+	return GetInvoiceTemplate(name)
+}
+
+// SaveInvoiceTemplate calls the package-level SaveInvoiceTemplate function.
+func (s *Store) SaveInvoiceTemplate(template *models.InvoiceTemplate) error {
+	return SaveInvoiceTemplate(template)
+}
+
+// DeleteInvoiceTemplate calls the package-level DeleteInvoiceTemplate function.
+func (s *Store) DeleteInvoiceTemplate(name string) error {
+	return DeleteInvoiceTemplate(name)
+}
+
+// RecordDelivery calls the package-level RecordDelivery function.
+func (s *Store) RecordDelivery(delivery *models.Delivery) (int64, error) {
+	return RecordDelivery(delivery)
+}
+
+// GetDeliveries calls the package-level GetDeliveries function.
+func (s *Store) GetDeliveries(invoiceID int) ([]models.Delivery, error) {
+	return GetDeliveries(invoiceID)
+}
+
+// GetRecurringProfiles calls the package-level GetRecurringProfiles function.
+func (s *Store) GetRecurringProfiles() ([]models.RecurringProfile, error) {
+	return GetRecurringProfiles()
+}
+
+// GetRecurringProfileByID calls the package-level GetRecurringProfileByID function.
+func (s *Store) GetRecurringProfileByID(id int) (*models.RecurringProfile, error) {
+	return GetRecurringProfileByID(id)
+}
+
+// GetDueRecurringProfiles calls the package-level GetDueRecurringProfiles function.
+func (s *Store) GetDueRecurringProfiles(now time.Time) ([]models.RecurringProfile, error) {
+	return GetDueRecurringProfiles(now)
+}
+
+// CreateRecurringProfile calls the package-level CreateRecurringProfile function.
+func (s *Store) CreateRecurringProfile(profile *models.RecurringProfile) (int64, error) {
+	return CreateRecurringProfile(profile)
+}
+
+// UpdateRecurringProfile calls the package-level UpdateRecurringProfile function.
+func (s *Store) UpdateRecurringProfile(profile *models.RecurringProfile) error {
+	return UpdateRecurringProfile(profile)
+}
+
+// AdvanceRecurringProfile calls the package-level AdvanceRecurringProfile function.
+func (s *Store) AdvanceRecurringProfile(id int, run time.Time, next *time.Time) error {
+	return AdvanceRecurringProfile(id, run, next)
+}
+
+// GetCreditNotes calls the package-level GetCreditNotes function.
+func (s *Store) GetCreditNotes(limit, offset int) ([]models.CreditNote, error) {
+	return GetCreditNotes(limit, offset)
+}
+
+// GetCreditNoteByID calls the package-level GetCreditNoteByID function.
+func (s *Store) GetCreditNoteByID(id int) (*models.CreditNote, error) {
+	return GetCreditNoteByID(id)
+}
+
+// GetInvoiceCreditNotes calls the package-level GetInvoiceCreditNotes function.
+func (s *Store) GetInvoiceCreditNotes(invoiceID int) ([]models.CreditNote, error) {
+	return GetInvoiceCreditNotes(invoiceID)
+}
+
+// CreateCreditNote calls the package-level CreateCreditNote function.
+func (s *Store) CreateCreditNote(note *models.CreditNote, version int) (int64, int, error) {
+	return CreateCreditNote(note, version)
+}
+
+// GetEstimates calls the package-level GetEstimates function.
+func (s *Store) GetEstimates(limit, offset int) ([]models.Estimate, error) {
+	return GetEstimates(limit, offset)
+}
+
+// GetEstimateByID calls the package-level GetEstimateByID function.
+func (s *Store) GetEstimateByID(id int) (*models.Estimate, error) {
+	return GetEstimateByID(id)
+}
+
+// CreateEstimate calls the package-level CreateEstimate function.
+func (s *Store) CreateEstimate(estimate *models.Estimate) (int64, error) {
+	return CreateEstimate(estimate)
+}
+
+// TransitionEstimateStatus calls the package-level TransitionEstimateStatus function.
+func (s *Store) TransitionEstimateStatus(id int, status models.EstimateStatus, at time.Time) error {
+	return TransitionEstimateStatus(id, status, at)
+}
+
+// CreateUser calls the package-level CreateUser function.
+func (s *Store) CreateUser(user *User) (int64, error) {
+	return CreateUser(user)
+}
+
+// GetUserByUsername calls the package-level GetUserByUsername function.
+func (s *Store) GetUserByUsername(username string) (*User, error) {
+	return GetUserByUsername(username)
+}
+
+// CreateFirstUser calls the package-level CreateFirstUser function.
+func (s *Store) CreateFirstUser(user *User) (int64, error) {
+	return CreateFirstUser(user)
+}
+
+// CountUsers calls the package-level CountUsers function.
+func (s *Store) CountUsers() (int, error) {
+	return CountUsers()
+}
+
+// GetUserByID calls the package-level GetUserByID function.
+func (s *Store) GetUserByID(id int) (*User, error) {
+	return GetUserByID(id)
+}
+
+// GetUsers calls the package-level GetUsers function.
+func (s *Store) GetUsers() ([]User, error) {
+	return GetUsers()
+}
+
+// UpdateUserRole calls the package-level UpdateUserRole function.
+func (s *Store) UpdateUserRole(id int, role models.Role) error {
+	return UpdateUserRole(id, role)
+}
+
+// CreateInvitation calls the package-level CreateInvitation function.
+func (s *Store) CreateInvitation(invitation *Invitation) (int64, error) {
+	return CreateInvitation(invitation)
+}
+
+// GetInvitations calls the package-level GetInvitations function.
+func (s *Store) GetInvitations(now time.Time) ([]Invitation, error) {
+	return GetInvitations(now)
+}
+
+// GetInvitationByToken calls the package-level GetInvitationByToken function.
+func (s *Store) GetInvitationByToken(tokenHash string, now time.Time) (*Invitation, error) {
+	return GetInvitationByToken(tokenHash, now)
+}
+
+// GetInvitation calls the package-level GetInvitation function.
+func (s *Store) GetInvitation(id int) (*Invitation, error) {
+	return GetInvitation(id)
+}
+
+// DeleteInvitation calls the package-level DeleteInvitation function.
+func (s *Store) DeleteInvitation(id int) error {
+	return DeleteInvitation(id)
+}
+
+// AcceptInvitation calls the package-level AcceptInvitation function.
+func (s *Store) AcceptInvitation(tokenHash string, user *User, now time.Time) (int64, error) {
+	return AcceptInvitation(tokenHash, user, now)
+}
+
+// CreateSession calls the package-level CreateSession function.
+func (s *Store) CreateSession(session *Session) (int64, error) {
+	return CreateSession(session)
+}
+
+// GetSession calls the package-level GetSession function.
+func (s *Store) GetSession(tokenHash string, now time.Time) (*Session, error) {
+	return GetSession(tokenHash, now)
+}
+
+// GetUserSessions calls the package-level GetUserSessions function.
+func (s *Store) GetUserSessions(userID int, now time.Time) ([]Session, error) {
+	return GetUserSessions(userID, now)
+}
+
+// DeleteSession calls the package-level DeleteSession function.
+func (s *Store) DeleteSession(userID, id int) error {
+	return DeleteSession(userID, id)
+}
+
+// CreateAPIToken calls the package-level CreateAPIToken function.
+func (s *Store) CreateAPIToken(token *APIToken) (int64, error) {
+	return CreateAPIToken(token)
+}
+
+// UseAPIToken calls the package-level UseAPIToken function.
+func (s *Store) UseAPIToken(tokenHash string, now time.Time) (*APIToken, error) {
+	return UseAPIToken(tokenHash, now)
+}
+
+// GetUserAPITokens calls the package-level GetUserAPITokens function.
+func (s *Store) GetUserAPITokens(userID int) ([]APIToken, error) {
+	return GetUserAPITokens(userID)
+}
+
+// DeleteAPIToken calls the package-level DeleteAPIToken function.
+func (s *Store) DeleteAPIToken(userID, id int) error {
+	return DeleteAPIToken(userID, id)
+}
+
+// ReserveIdempotencyKey calls the package-level ReserveIdempotencyKey function.
+func (s *Store) ReserveIdempotencyKey(record *IdempotencyRecord, expiredBefore time.Time) (*IdempotencyRecord, error) {
+	return ReserveIdempotencyKey(record, expiredBefore)
+}
+
+// SaveIdempotentResponse calls the package-level SaveIdempotentResponse function.
+func (s *Store) SaveIdempotentResponse(record *IdempotencyRecord) error {
+	return SaveIdempotentResponse(record)
+}
+
+// ReleaseIdempotencyKey calls the package-level ReleaseIdempotencyKey function.
+func (s *Store) ReleaseIdempotencyKey(scope, key string) error {
+	return ReleaseIdempotencyKey(scope, key)
+}
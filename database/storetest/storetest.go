@@ -0,0 +1,945 @@
+// Package storetest is a contract test suite for the invoice, customer and user
+// stores. Every store backend runs it from its own tests, so the handlers see the
+// same IDs, errors and ordering whichever backend they are given.
+package storetest
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"tiny-invoicing/database"
+	"tiny-invoicing/models"
+)
+
+// Store is the persistence every backend provides for invoices, customers and users.
+type Store interface {
+	GetCustomers(limit, offset int) ([]database.Customer, error)
+	GetCustomerByID(id int) (*database.Customer, error)
+	CreateCustomer(customer *database.Customer) (int64, error)
+	UpdateCustomer(customer *database.Customer) error
+	DeleteCustomer(id int) error
+
+	CreateInvoice(invoice *models.Invoice) (int64, error)
+	GetInvoices(limit, offset int) ([]models.Invoice, error)
+	GetInvoiceByID(id int) (*models.Invoice, error)
+	UpdateInvoice(invoice *models.Invoice) error
+	TransitionInvoiceStatus(id, version int, status models.InvoiceStatus, at time.Time) error
+	UpdateNumberSequence(sequence *models.NumberSequence, now time.Time) error
+
+	CreateUser(user *database.User) (int64, error)
+	CreateFirstUser(user *database.User) (int64, error)
+	CountUsers() (int, error)
+	GetUserByUsername(username string) (*database.User, error)
+	GetUserByID(id int) (*database.User, error)
+	GetUsers() ([]database.User, error)
+	UpdateUserRole(id int, role models.Role) error
+
+	CreateInvitation(invitation *database.Invitation) (int64, error)
+	GetInvitations(now time.Time) ([]database.Invitation, error)
+	GetInvitation(id int) (*database.Invitation, error)
+	GetInvitationByToken(tokenHash string, now time.Time) (*database.Invitation, error)
+	DeleteInvitation(id int) error
+	AcceptInvitation(tokenHash string, user *database.User, now time.Time) (int64, error)
+
+	CreateSession(session *database.Session) (int64, error)
+	GetSession(tokenHash string, now time.Time) (*database.Session, error)
+	GetUserSessions(userID int, now time.Time) ([]database.Session, error)
+	DeleteSession(userID, id int) error
+
+	CreateAPIToken(token *database.APIToken) (int64, error)
+	UseAPIToken(tokenHash string, now time.Time) (*database.APIToken, error)
+	GetUserAPITokens(userID int) ([]database.APIToken, error)
+	DeleteAPIToken(userID, id int) error
+
+	ReserveIdempotencyKey(record *database.IdempotencyRecord, expiredBefore time.Time) (*database.IdempotencyRecord, error)
+	SaveIdempotentResponse(record *database.IdempotencyRecord) error
+	ReleaseIdempotencyKey(scope, key string) error
+}
+
+// Run runs the contract against stores made by open. Each test gets a new, empty
+// store: no customers, invoices or users, and the default numbering sequences.
+func Run(t *testing.T, open func(t *testing.T) Store) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s Store)
+	}{
+		{"Customers", testCustomers},
+		{"CustomerPagination", testCustomerPagination},
+		{"DeleteCustomer", testDeleteCustomer},
+		{"Invoices", testInvoices},
+		{"InvoicePagination", testInvoicePagination},
+		{"UpdateInvoice", testUpdateInvoice},
+		{"InvoiceVersions", testInvoiceVersions},
+		{"SendInvoice", testSendInvoice},
+		{"NumberResetChange", testNumberResetChange},
+		{"InvalidTransitions", testInvalidTransitions},
+		{"Users", testUsers},
+		{"UserRoles", testUserRoles},
+		{"FirstUser", testFirstUser},
+		{"Invitations", testInvitations},
+		{"Sessions", testSessions},
+		{"APITokens", testAPITokens},
+		{"IdempotencyKeys", testIdempotencyKeys},
+		{"ConcurrentWrites", testConcurrentWrites},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, open(t))
+		})
+	}
+}
+
+// day returns midnight UTC of a day in March 2026.
+func day(d int) time.Time {
+	return time.Date(2026, 3, d, 0, 0, 0, 0, time.UTC)
+}
+
+func createCustomer(t *testing.T, s Store, name string) int {
+	t.Helper()
+	id, err := s.CreateCustomer(&database.Customer{Name: name, Email: "billing@example.com", Address: "1 Main St", Currency: "USD"})
+	if err != nil {
+		t.Fatalf("CreateCustomer(%s): %v", name, err)
+	}
+	return int(id)
+}
+
+// newInvoice returns a calculated draft invoice of two lines totalling 25.00.
+func newInvoice(customerID int, currency string, issued time.Time) *models.Invoice {
+	invoice := &models.Invoice{
+		CustomerID: customerID, Currency: currency, IssueDate: issued, DueDate: issued.AddDate(1, 0, 0),
+		LineItems: []models.LineItem{
+			{Description: "Design", Quantity: 2, UnitPrice: models.NewMoney(1000, currency)},
+			{Description: "Hosting", Quantity: 1, UnitPrice: models.NewMoney(500, currency)},
+		},
+	}
+	invoice.CalculateTotal()
+	return invoice
+}
+
+func createInvoice(t *testing.T, s Store, invoice *models.Invoice) int {
+	t.Helper()
+	id, err := s.CreateInvoice(invoice)
+	if err != nil {
+		t.Fatalf("CreateInvoice: %v", err)
+	}
+	return int(id)
+}
+
+func testCustomers(t *testing.T, s Store) {
+	first := createCustomer(t, s, "Acme")
+	second := createCustomer(t, s, "Globex")
+	if first <= 0 || second <= first {
+		t.Errorf("customer IDs %d, %d; want positive and increasing", first, second)
+	}
+
+	customer, err := s.GetCustomerByID(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := database.Customer{ID: second, Name: "Globex", Email: "billing@example.com", Address: "1 Main St", Currency: "USD"}
+	if *customer != want {
+		t.Errorf("GetCustomerByID = %+v, want %+v", *customer, want)
+	}
+
+	customer.Name, customer.Template = "Globex Corp", "compact"
+	if err := s.UpdateCustomer(customer); err != nil {
+		t.Fatal(err)
+	}
+	if updated, err := s.GetCustomerByID(second); err != nil || *updated != *customer {
+		t.Errorf("after UpdateCustomer: %+v, %v; want %+v", updated, err, *customer)
+	}
+
+	if _, err := s.GetCustomerByID(second + 100); err != sql.ErrNoRows {
+		t.Errorf("GetCustomerByID(unknown): got %v, want sql.ErrNoRows", err)
+	}
+	if err := s.UpdateCustomer(&database.Customer{ID: second + 100, Name: "Nobody", Currency: "USD"}); err != sql.ErrNoRows {
+		t.Errorf("UpdateCustomer(unknown): got %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testCustomerPagination(t *testing.T, s Store) {
+	// Ordered by name, then by ID for equal names.
+	ids := make(map[string]int)
+	for _, name := range []string{"Delta", "Alpha", "Charlie", "Bravo", "Alpha"} {
+		ids[name] = createCustomer(t, s, name)
+	}
+
+	customers, err := s.GetCustomers(10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, customer := range customers {
+		names = append(names, customer.Name)
+	}
+	if fmt.Sprint(names) != "[Alpha Alpha Bravo Charlie Delta]" {
+		t.Errorf("customer order = %v", names)
+	}
+	if len(customers) == 5 && customers[0].ID >= customers[1].ID {
+		t.Errorf("customers of the same name not ordered by ID: %d, %d", customers[0].ID, customers[1].ID)
+	}
+
+	customers, err = s.GetCustomers(2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(customers) != 2 || customers[0].ID != ids["Bravo"] || customers[1].ID != ids["Charlie"] {
+		t.Errorf("GetCustomers(2, 2) = %+v, want Bravo and Charlie", customers)
+	}
+
+	customers, err = s.GetCustomers(10, 5)
+	if err != nil || len(customers) != 0 {
+		t.Errorf("GetCustomers past the end = %+v, %v; want none", customers, err)
+	}
+}
+
+func testDeleteCustomer(t *testing.T, s Store) {
+	unused := createCustomer(t, s, "Unused")
+	billed := createCustomer(t, s, "Billed")
+	createInvoice(t, s, newInvoice(billed, "USD", day(1)))
+
+	if err := s.DeleteCustomer(billed); err != database.ErrCustomerInUse {
+		t.Errorf("deleting a customer with invoices: got %v, want database.ErrCustomerInUse", err)
+	}
+	if err := s.DeleteCustomer(unused); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetCustomerByID(unused); err != sql.ErrNoRows {
+		t.Errorf("deleted customer: got %v, want sql.ErrNoRows", err)
+	}
+	if err := s.DeleteCustomer(unused); err != sql.ErrNoRows {
+		t.Errorf("deleting twice: got %v, want sql.ErrNoRows", err)
+	}
+
+	// IDs are not reused.
+	if again := createCustomer(t, s, "Unused"); again <= billed {
+		t.Errorf("new customer got ID %d after deleting %d", again, unused)
+	}
+}
+
+func testInvoices(t *testing.T, s Store) {
+	customerID := createCustomer(t, s, "Acme")
+
+	if _, err := s.CreateInvoice(newInvoice(customerID+100, "USD", day(1))); err != database.ErrUnknownCustomer {
+		t.Errorf("invoice for an unknown customer: got %v, want database.ErrUnknownCustomer", err)
+	}
+
+	invoice := newInvoice(customerID, "USD", day(1))
+	first := createInvoice(t, s, invoice)
+	second := createInvoice(t, s, newInvoice(customerID, "USD", day(2)))
+	if first <= 0 || second <= first {
+		t.Errorf("invoice IDs %d, %d; want positive and increasing", first, second)
+	}
+
+	stored, err := s.GetInvoiceByID(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.ID != first || stored.CustomerID != customerID || stored.Currency != "USD" || stored.Number != "" {
+		t.Errorf("stored invoice = %+v", stored)
+	}
+	if !stored.IssueDate.Equal(day(1)) || !stored.DueDate.Equal(invoice.DueDate) {
+		t.Errorf("stored dates %v, %v; want %v, %v", stored.IssueDate, stored.DueDate, day(1), invoice.DueDate)
+	}
+	if stored.Status != models.StatusDraft || stored.RoundingMode != models.RoundHalfUp {
+		t.Errorf("stored status %q, rounding mode %q; want draft, half_up", stored.Status, stored.RoundingMode)
+	}
+	if stored.Subtotal != invoice.Subtotal || stored.Total != invoice.Total || stored.BalanceDue != invoice.Total || !stored.AmountPaid.IsZero() {
+		t.Errorf("stored amounts: subtotal %s, total %s, balance %s, paid %s; want a balance of %s",
+			stored.Subtotal, stored.Total, stored.BalanceDue, stored.AmountPaid, invoice.Total)
+	}
+	if len(stored.LineItems) != 2 {
+		t.Fatalf("stored %d line items, want 2", len(stored.LineItems))
+	}
+	for k, item := range stored.LineItems {
+		want := invoice.LineItems[k]
+		if item.ID <= 0 || item.InvoiceID != first || item.Description != want.Description || item.Quantity != want.Quantity ||
+			item.UnitPrice != want.UnitPrice || item.Total != want.Total {
+			t.Errorf("line item %d = %+v, want %+v", k, item, want)
+		}
+	}
+	if stored.LineItems[0].ID >= stored.LineItems[1].ID {
+		t.Errorf("line items not in the order they were added: %+v", stored.LineItems)
+	}
+
+	// Changing what the store returned does not change the store.
+	stored.LineItems[0].Description = "Changed"
+	if again, _ := s.GetInvoiceByID(first); again.LineItems[0].Description != "Design" {
+		t.Error("GetInvoiceByID returned memory shared with the store")
+	}
+
+	if _, err := s.GetInvoiceByID(second + 100); err != sql.ErrNoRows {
+		t.Errorf("GetInvoiceByID(unknown): got %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testInvoicePagination(t *testing.T, s Store) {
+	customerID := createCustomer(t, s, "Acme")
+	// Newest issue date first, then the most recently created.
+	older := createInvoice(t, s, newInvoice(customerID, "USD", day(1)))
+	newest := createInvoice(t, s, newInvoice(customerID, "USD", day(3)))
+	middle := createInvoice(t, s, newInvoice(customerID, "USD", day(2)))
+	middleLater := createInvoice(t, s, newInvoice(customerID, "USD", day(2)))
+
+	invoices, err := s.GetInvoices(10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for _, invoice := range invoices {
+		ids = append(ids, invoice.ID)
+	}
+	if want := fmt.Sprint([]int{newest, middleLater, middle, older}); fmt.Sprint(ids) != want {
+		t.Errorf("invoice order = %v, want %s", ids, want)
+	}
+	if len(invoices) > 0 && (invoices[0].Total.Amount != 2500 || invoices[0].LineItems != nil) {
+		t.Errorf("listed invoice = %+v, want a total of 25.00 without line items", invoices[0])
+	}
+
+	invoices, err = s.GetInvoices(2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invoices) != 2 || invoices[0].ID != middleLater || invoices[1].ID != middle {
+		t.Errorf("GetInvoices(2, 1) = %+v, want invoices %d and %d", invoices, middleLater, middle)
+	}
+
+	invoices, err = s.GetInvoices(10, 4)
+	if err != nil || len(invoices) != 0 {
+		t.Errorf("GetInvoices past the end = %+v, %v; want none", invoices, err)
+	}
+}
+
+func testUpdateInvoice(t *testing.T, s Store) {
+	acme := createCustomer(t, s, "Acme")
+	globex := createCustomer(t, s, "Globex")
+	id := createInvoice(t, s, newInvoice(acme, "USD", day(1)))
+
+	// Move Hosting first, drop Design and add Support.
+	edit := newInvoice(globex, "EUR", day(1))
+	edit.ID = id
+	edit.DueDate = day(20)
+	edit.Notes = "Thanks for your business."
+	edit.LineItems = []models.LineItem{
+		{Description: "Hosting", Quantity: 3, UnitPrice: models.NewMoney(500, "EUR")},
+		{Description: "Support", Quantity: 1, UnitPrice: models.NewMoney(250, "EUR")},
+	}
+	edit.CalculateTotal()
+	if err := s.UpdateInvoice(edit); err != nil {
+		t.Fatal(err)
+	}
+
+	invoice, err := s.GetInvoiceByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invoice.CustomerID != globex || invoice.Currency != "EUR" || !invoice.DueDate.Equal(day(20)) || invoice.Notes != edit.Notes {
+		t.Errorf("edited invoice: customer %d, %s, due %v, notes %q", invoice.CustomerID, invoice.Currency, invoice.DueDate, invoice.Notes)
+	}
+	if invoice.Total != models.NewMoney(1750, "EUR") || invoice.Status != models.StatusDraft {
+		t.Errorf("edited invoice: total %v, status %s; want EUR 17.50, draft", invoice.Total, invoice.Status)
+	}
+	if len(invoice.LineItems) != 2 || invoice.LineItems[0].Description != "Hosting" || invoice.LineItems[0].Quantity != 3 || invoice.LineItems[1].Description != "Support" {
+		t.Errorf("edited line items = %+v; want Hosting x3, then Support", invoice.LineItems)
+	}
+
+	// Line items come back in exactly the order given, edit after edit.
+	for _, order := range [][]string{{"Support", "Hosting", "Design"}, {"Design", "Support", "Hosting"}} {
+		edit.LineItems = nil
+		for _, description := range order {
+			edit.LineItems = append(edit.LineItems, models.LineItem{Description: description, Quantity: 1, UnitPrice: models.NewMoney(100, "EUR")})
+		}
+		edit.CalculateTotal()
+		if err := s.UpdateInvoice(edit); err != nil {
+			t.Fatal(err)
+		}
+		invoice, err := s.GetInvoiceByID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, item := range invoice.LineItems {
+			got = append(got, item.Description)
+		}
+		if strings.Join(got, ", ") != strings.Join(order, ", ") {
+			t.Errorf("line items after reordering = %v; want %v", got, order)
+		}
+	}
+
+	// Unknown customers and invoices change nothing.
+	edit.CustomerID = globex + 100
+	if err := s.UpdateInvoice(edit); !errors.Is(err, database.ErrUnknownCustomer) {
+		t.Errorf("unknown customer: got %v, want database.ErrUnknownCustomer", err)
+	}
+	edit.ID, edit.CustomerID = id+100, globex
+	if err := s.UpdateInvoice(edit); err != sql.ErrNoRows {
+		t.Errorf("unknown invoice: got %v, want sql.ErrNoRows", err)
+	}
+
+	// Once sent, an invoice is locked.
+	sent := createInvoice(t, s, newInvoice(acme, "USD", day(1)))
+	if err := s.TransitionInvoiceStatus(sent, 0, models.StatusSent, day(2)); err != nil {
+		t.Fatal(err)
+	}
+	edit.ID, edit.Notes = sent, "Too late"
+	if err := s.UpdateInvoice(edit); !errors.Is(err, models.ErrInvoiceLocked) {
+		t.Errorf("editing a sent invoice: got %v, want models.ErrInvoiceLocked", err)
+	}
+	if invoice, err := s.GetInvoiceByID(sent); err != nil || invoice.Notes != "" || invoice.CustomerID != acme {
+		t.Errorf("sent invoice after a rejected edit: %+v, %v", invoice, err)
+	}
+}
+
+func testInvoiceVersions(t *testing.T, s Store) {
+	customerID := createCustomer(t, s, "Acme")
+	id := createInvoice(t, s, newInvoice(customerID, "USD", day(1)))
+	version := func() int {
+		t.Helper()
+		invoice, err := s.GetInvoiceByID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return invoice.Version
+	}
+	if v := version(); v != 1 {
+		t.Fatalf("new invoice at version %d, want 1", v)
+	}
+
+	edit := newInvoice(customerID, "USD", day(1))
+	edit.ID, edit.Version, edit.Notes = id, 1, "First edit"
+	if err := s.UpdateInvoice(edit); err != nil {
+		t.Fatal(err)
+	}
+	if v := version(); v != 2 {
+		t.Errorf("edited invoice at version %d, want 2", v)
+	}
+
+	// Writes based on version 1 are now stale; version 0 skips the check.
+	edit.Notes = "Stale edit"
+	if err := s.UpdateInvoice(edit); !errors.Is(err, database.ErrVersionMismatch) {
+		t.Errorf("stale edit: got %v, want database.ErrVersionMismatch", err)
+	}
+	if err := s.TransitionInvoiceStatus(id, 1, models.StatusSent, day(2)); !errors.Is(err, database.ErrVersionMismatch) {
+		t.Errorf("stale send: got %v, want database.ErrVersionMismatch", err)
+	}
+	if invoice, err := s.GetInvoiceByID(id); err != nil || invoice.Notes != "First edit" || invoice.Status != models.StatusDraft {
+		t.Errorf("after stale writes: %+v, %v; want the first edit, still a draft", invoice, err)
+	}
+	edit.Version = 0
+	if err := s.UpdateInvoice(edit); err != nil {
+		t.Errorf("unchecked edit: %v", err)
+	}
+
+	if err := s.TransitionInvoiceStatus(id, 3, models.StatusSent, day(2)); err != nil {
+		t.Fatal(err)
+	}
+	if v := version(); v != 4 {
+		t.Errorf("sent invoice at version %d, want 4", v)
+	}
+}
+
+func testSendInvoice(t *testing.T, s Store) {
+	customerID := createCustomer(t, s, "Acme")
+	foreign := createInvoice(t, s, newInvoice(customerID, "JPY", day(1)))
+	first := createInvoice(t, s, newInvoice(customerID, "USD", day(1)))
+	second := createInvoice(t, s, newInvoice(customerID, "USD", day(2)))
+	sentAt := day(2).Add(9 * time.Hour)
+
+	// Without an exchange rate the invoice stays a draft and uses up no number.
+	if err := s.TransitionInvoiceStatus(foreign, 0, models.StatusSent, sentAt); !errors.Is(err, models.ErrNoExchangeRate) {
+		t.Errorf("sending without an exchange rate: got %v, want models.ErrNoExchangeRate", err)
+	}
+	if invoice, err := s.GetInvoiceByID(foreign); err != nil || invoice.Status != models.StatusDraft || invoice.Number != "" {
+		t.Errorf("after a failed send: %+v, %v; want an unnumbered draft", invoice, err)
+	}
+
+	for _, id := range []int{first, second} {
+		if err := s.TransitionInvoiceStatus(id, 0, models.StatusSent, sentAt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	invoice, err := s.GetInvoiceByID(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invoice.Number != "INV-2026-00001" || invoice.Status != models.StatusSent || invoice.SentAt == nil || !invoice.SentAt.Equal(sentAt) {
+		t.Errorf("sent invoice: number %q, status %q, sent at %v; want INV-2026-00001, sent, %v", invoice.Number, invoice.Status, invoice.SentAt, sentAt)
+	}
+	if invoice.BaseCurrency != models.BaseCurrency || invoice.ExchangeRate != models.OneRate || invoice.BaseTotal == nil || *invoice.BaseTotal != invoice.Total {
+		t.Errorf("base snapshot: %s at %s = %v; want %s at 1", invoice.BaseCurrency, invoice.ExchangeRate, invoice.BaseTotal, models.BaseCurrency)
+	}
+	if invoice, err := s.GetInvoiceByID(second); err != nil || invoice.Number != "INV-2026-00002" {
+		t.Errorf("second sent invoice: %+v, %v; want number INV-2026-00002", invoice, err)
+	}
+
+	if err := s.TransitionInvoiceStatus(second+100, 0, models.StatusSent, sentAt); err != sql.ErrNoRows {
+		t.Errorf("sending an unknown invoice: got %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testNumberResetChange(t *testing.T, s Store) {
+	customerID := createCustomer(t, s, "Acme")
+	send := func(issued time.Time, want string) {
+		t.Helper()
+		id := createInvoice(t, s, newInvoice(customerID, "USD", issued))
+		if err := s.TransitionInvoiceStatus(id, 0, models.StatusSent, issued); err != nil {
+			t.Fatalf("sending an invoice for %s: %v", want, err)
+		}
+		if invoice, err := s.GetInvoiceByID(id); err != nil || invoice.Number != want {
+			t.Fatalf("sent invoice: %+v, %v; want number %s", invoice, err, want)
+		}
+	}
+	reset := func(reset models.SequenceReset) {
+		t.Helper()
+		sequence := models.NumberSequence{Name: models.SequenceInvoice, Format: models.DefaultInvoiceNumberFormat, Reset: reset}
+		if err := s.UpdateNumberSequence(&sequence, day(10)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send(day(1), "INV-2026-00001")
+	send(day(1).AddDate(-1, 0, 0), "INV-2025-00001")
+	send(day(2), "INV-2026-00002")
+
+	// The counter carries on from the highest number handed out when the reset changes.
+	reset(models.ResetNever)
+	send(day(3), "INV-2026-00003")
+	reset(models.ResetYearly)
+	send(day(4), "INV-2026-00004")
+	// Setting the same rule again changes nothing.
+	reset(models.ResetYearly)
+	send(day(5), "INV-2026-00005")
+
+	if err := s.UpdateNumberSequence(&models.NumberSequence{Name: "receipt", Format: "R{seq}", Reset: models.ResetNever}, day(10)); err != sql.ErrNoRows {
+		t.Errorf("updating an unknown sequence: got %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testInvalidTransitions(t *testing.T, s Store) {
+	customerID := createCustomer(t, s, "Acme")
+	id := createInvoice(t, s, newInvoice(customerID, "USD", day(1)))
+
+	for _, status := range []models.InvoiceStatus{models.StatusPaid, models.StatusDraft} {
+		if err := s.TransitionInvoiceStatus(id, 0, status, day(2)); !errors.Is(err, models.ErrInvalidTransition) {
+			t.Errorf("draft to %s: got %v, want models.ErrInvalidTransition", status, err)
+		}
+	}
+	if err := s.TransitionInvoiceStatus(id, 0, models.StatusVoid, day(2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.TransitionInvoiceStatus(id, 0, models.StatusSent, day(3)); !errors.Is(err, models.ErrInvalidTransition) {
+		t.Errorf("void to sent: got %v, want models.ErrInvalidTransition", err)
+	}
+
+	invoice, err := s.GetInvoiceByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invoice.Status != models.StatusVoid || invoice.VoidedAt == nil || !invoice.VoidedAt.Equal(day(2)) || invoice.Number != "" {
+		t.Errorf("voided invoice: status %q, voided at %v, number %q", invoice.Status, invoice.VoidedAt, invoice.Number)
+	}
+}
+
+func testUsers(t *testing.T, s Store) {
+	first, err := s.CreateUser(&database.User{Username: "admin", PasswordHash: "hash", Role: models.RoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.CreateUser(&database.User{Username: "clerk", PasswordHash: "hash2", Role: models.RoleViewer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first <= 0 || second <= first {
+		t.Errorf("user IDs %d, %d; want positive and increasing", first, second)
+	}
+
+	if _, err := s.CreateUser(&database.User{Username: "admin", PasswordHash: "other"}); err != database.ErrUsernameTaken {
+		t.Errorf("duplicate username: got %v, want database.ErrUsernameTaken", err)
+	}
+
+	user, err := s.GetUserByUsername("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (database.User{ID: int(first), Username: "admin", PasswordHash: "hash", Role: models.RoleAdmin}); *user != want {
+		t.Errorf("GetUserByUsername = %+v, want %+v", *user, want)
+	}
+	if user, err := s.GetUserByUsername("clerk"); err != nil || user.Role != models.RoleViewer {
+		t.Errorf("GetUserByUsername(clerk) = %+v, %v; want a viewer", user, err)
+	}
+	if user, err := s.GetUserByID(int(second)); err != nil || user.Username != "clerk" {
+		t.Errorf("GetUserByID(%d) = %+v, %v; want clerk", second, user, err)
+	}
+	if _, err := s.GetUserByID(int(second) + 100); err != sql.ErrNoRows {
+		t.Errorf("GetUserByID(unknown): got %v, want sql.ErrNoRows", err)
+	}
+	if _, err := s.GetUserByUsername("nobody"); err != sql.ErrNoRows {
+		t.Errorf("GetUserByUsername(unknown): got %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testUserRoles(t *testing.T, s Store) {
+	owner, err := s.CreateUser(&database.User{Username: "owner", PasswordHash: "hash", Role: models.RoleOwner})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clerk, err := s.CreateUser(&database.User{Username: "clerk", PasswordHash: "hash", Role: models.RoleViewer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.UpdateUserRole(int(clerk), models.RoleAccountant); err != nil {
+		t.Fatal(err)
+	}
+	users, err := s.GetUsers()
+	if err != nil || len(users) != 2 || users[0].Username != "clerk" || users[0].Role != models.RoleAccountant || users[1].Role != models.RoleOwner {
+		t.Errorf("GetUsers = %+v, %v; want the accountant clerk, then the owner", users, err)
+	}
+	if err := s.UpdateUserRole(int(clerk)+100, models.RoleViewer); err != sql.ErrNoRows {
+		t.Errorf("unknown user: got %v, want sql.ErrNoRows", err)
+	}
+
+	// The only owner cannot be demoted until there is another.
+	if err := s.UpdateUserRole(int(owner), models.RoleAdmin); err != models.ErrLastOwner {
+		t.Errorf("demoting the last owner: got %v, want models.ErrLastOwner", err)
+	}
+	if err := s.UpdateUserRole(int(owner), models.RoleOwner); err != nil {
+		t.Errorf("keeping the last owner an owner: %v", err)
+	}
+	if err := s.UpdateUserRole(int(clerk), models.RoleOwner); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateUserRole(int(owner), models.RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+	if user, err := s.GetUserByUsername("owner"); err != nil || user.Role != models.RoleViewer {
+		t.Errorf("demoted owner = %+v, %v; want a viewer", user, err)
+	}
+}
+
+func testFirstUser(t *testing.T, s Store) {
+	if users, err := s.CountUsers(); err != nil || users != 0 {
+		t.Fatalf("CountUsers = %d, %v; want 0", users, err)
+	}
+	id, err := s.CreateFirstUser(&database.User{Username: "owner", PasswordHash: "hash", Role: models.RoleOwner})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user, err := s.GetUserByUsername("owner"); err != nil || user.ID != int(id) || user.Role != models.RoleOwner {
+		t.Errorf("first user = %+v, %v; want owner %d", user, err, id)
+	}
+	if _, err := s.CreateFirstUser(&database.User{Username: "intruder", PasswordHash: "hash", Role: models.RoleOwner}); err != database.ErrUsersExist {
+		t.Errorf("second first user: got %v, want database.ErrUsersExist", err)
+	}
+	if users, err := s.CountUsers(); err != nil || users != 1 {
+		t.Errorf("CountUsers = %d, %v; want 1", users, err)
+	}
+}
+
+func testInvitations(t *testing.T, s Store) {
+	owner, err := s.CreateUser(&database.User{Username: "owner", PasswordHash: "hash", Role: models.RoleOwner})
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := day(1).Add(9 * time.Hour)
+	invite := func(hash string, role models.Role, at time.Time) int {
+		t.Helper()
+		id, err := s.CreateInvitation(&database.Invitation{TokenHash: hash, Role: role, CreatedBy: int(owner), CreatedAt: at, ExpiresAt: at.Add(24 * time.Hour)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return int(id)
+	}
+	accountant := invite("h1", models.RoleAccountant, at)
+	viewer := invite("h2", models.RoleViewer, at.Add(time.Hour))
+	withdrawn := invite("h3", models.RoleAdmin, at.Add(2*time.Hour))
+
+	invitations, err := s.GetInvitations(at.Add(3 * time.Hour))
+	if err != nil || len(invitations) != 3 || invitations[0].ID != withdrawn || invitations[2].ID != accountant {
+		t.Fatalf("GetInvitations = %+v, %v; want invitations %d, %d and %d", invitations, err, withdrawn, viewer, accountant)
+	}
+	if got := invitations[2]; got.Role != models.RoleAccountant || got.CreatedBy != int(owner) || !got.ExpiresAt.Equal(at.Add(24*time.Hour)) || got.AcceptedAt != nil {
+		t.Errorf("pending invitation = %+v", got)
+	}
+	if got, err := s.GetInvitation(withdrawn); err != nil || got.Role != models.RoleAdmin || got.CreatedBy != int(owner) || got.AcceptedAt != nil {
+		t.Errorf("GetInvitation = %+v, %v; want the pending admin invitation", got, err)
+	}
+	if err := s.DeleteInvitation(withdrawn); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteInvitation(withdrawn); err != sql.ErrNoRows {
+		t.Errorf("withdrawing twice: got %v, want sql.ErrNoRows", err)
+	}
+	if _, err := s.GetInvitation(withdrawn); err != sql.ErrNoRows {
+		t.Errorf("GetInvitation of a withdrawn invitation: got %v, want sql.ErrNoRows", err)
+	}
+
+	// Accepting creates the user with the invited role, once.
+	accepted := at.Add(4 * time.Hour)
+	if got, err := s.GetInvitationByToken("h1", accepted); err != nil || got.ID != accountant || got.Role != models.RoleAccountant {
+		t.Errorf("GetInvitationByToken = %+v, %v; want invitation %d", got, err, accountant)
+	}
+	if _, err := s.GetInvitationByToken("h3", accepted); err != sql.ErrNoRows {
+		t.Errorf("GetInvitationByToken of a withdrawn invitation: got %v, want sql.ErrNoRows", err)
+	}
+	if _, err := s.AcceptInvitation("h1", &database.User{Username: "owner", PasswordHash: "hash"}, accepted); err != database.ErrUsernameTaken {
+		t.Errorf("accepting with a taken username: got %v, want database.ErrUsernameTaken", err)
+	}
+	id, err := s.AcceptInvitation("h1", &database.User{Username: "books", PasswordHash: "hash"}, accepted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user, err := s.GetUserByUsername("books"); err != nil || user.ID != int(id) || user.Role != models.RoleAccountant {
+		t.Errorf("invited user = %+v, %v; want an accountant with ID %d", user, err, id)
+	}
+	if got, err := s.GetInvitation(accountant); err != nil || got.AcceptedAt == nil || got.UserID == nil || *got.UserID != int(id) {
+		t.Errorf("GetInvitation after accepting = %+v, %v; want it accepted by user %d", got, err, id)
+	}
+	if _, err := s.AcceptInvitation("h1", &database.User{Username: "books2", PasswordHash: "hash"}, accepted); err != sql.ErrNoRows {
+		t.Errorf("accepting twice: got %v, want sql.ErrNoRows", err)
+	}
+	if err := s.DeleteInvitation(accountant); err != sql.ErrNoRows {
+		t.Errorf("withdrawing an accepted invitation: got %v, want sql.ErrNoRows", err)
+	}
+
+	if _, err := s.GetInvitationByToken("h1", accepted); err != sql.ErrNoRows {
+		t.Errorf("GetInvitationByToken of an accepted invitation: got %v, want sql.ErrNoRows", err)
+	}
+
+	// Expired and withdrawn invitations cannot be accepted.
+	if _, err := s.GetInvitationByToken("h2", at.Add(25*time.Hour)); err != sql.ErrNoRows {
+		t.Errorf("GetInvitationByToken of an expired invitation: got %v, want sql.ErrNoRows", err)
+	}
+	if _, err := s.AcceptInvitation("h2", &database.User{Username: "late", PasswordHash: "hash"}, at.Add(25*time.Hour)); err != sql.ErrNoRows {
+		t.Errorf("accepting an expired invitation: got %v, want sql.ErrNoRows", err)
+	}
+	if _, err := s.AcceptInvitation("h3", &database.User{Username: "late", PasswordHash: "hash"}, accepted); err != sql.ErrNoRows {
+		t.Errorf("accepting a withdrawn invitation: got %v, want sql.ErrNoRows", err)
+	}
+	if invitations, err := s.GetInvitations(accepted); err != nil || len(invitations) != 1 || invitations[0].ID != viewer {
+		t.Errorf("GetInvitations after accepting = %+v, %v; want only invitation %d", invitations, err, viewer)
+	}
+}
+
+func testSessions(t *testing.T, s Store) {
+	userID, err := s.CreateUser(&database.User{Username: "admin", PasswordHash: "hash", Role: models.RoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherID, err := s.CreateUser(&database.User{Username: "clerk", PasswordHash: "hash", Role: models.RoleViewer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := day(1).Add(9 * time.Hour)
+	create := func(user int64, token string, at time.Time) int {
+		t.Helper()
+		id, err := s.CreateSession(&database.Session{TokenHash: token, CSRFToken: "csrf-" + token, UserID: int(user), CreatedAt: at, ExpiresAt: at.Add(12 * time.Hour)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return int(id)
+	}
+	first := create(userID, "t1", at)
+	second := create(userID, "t2", at.Add(time.Hour))
+	create(otherID, "t3", at)
+
+	session, err := s.GetSession("t1", at.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.ID != first || session.CSRFToken != "csrf-t1" || !session.ExpiresAt.Equal(at.Add(12*time.Hour)) || session.User == nil || session.User.Username != "admin" || session.User.Role != models.RoleAdmin {
+		t.Errorf("GetSession = %+v (user %+v)", session, session.User)
+	}
+	if _, err := s.GetSession("t1", at.Add(12*time.Hour)); err != sql.ErrNoRows {
+		t.Errorf("expired session: got %v, want sql.ErrNoRows", err)
+	}
+	if _, err := s.GetSession("unknown", at); err != sql.ErrNoRows {
+		t.Errorf("unknown session: got %v, want sql.ErrNoRows", err)
+	}
+
+	sessions, err := s.GetUserSessions(int(userID), at.Add(2*time.Hour))
+	if err != nil || len(sessions) != 2 || sessions[0].ID != second || sessions[1].ID != first {
+		t.Errorf("GetUserSessions = %+v, %v; want sessions %d and %d", sessions, err, second, first)
+	}
+
+	// Users revoke only their own sessions.
+	if err := s.DeleteSession(int(otherID), first); err != sql.ErrNoRows {
+		t.Errorf("revoking another user's session: got %v, want sql.ErrNoRows", err)
+	}
+	if err := s.DeleteSession(int(userID), first); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetSession("t1", at); err != sql.ErrNoRows {
+		t.Errorf("revoked session: got %v, want sql.ErrNoRows", err)
+	}
+
+	// Creating a session clears out expired ones, so asking as of earlier finds only the new one.
+	fourth := create(userID, "t4", at.Add(24*time.Hour))
+	if sessions, err := s.GetUserSessions(int(userID), at); err != nil || len(sessions) != 1 || sessions[0].ID != fourth {
+		t.Errorf("sessions after expiry = %+v, %v; want only session %d", sessions, err, fourth)
+	}
+}
+
+func testAPITokens(t *testing.T, s Store) {
+	userID, err := s.CreateUser(&database.User{Username: "admin", PasswordHash: "hash", Role: models.RoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherID, err := s.CreateUser(&database.User{Username: "clerk", PasswordHash: "hash", Role: models.RoleViewer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := day(1).Add(9 * time.Hour)
+	create := func(user int64, name, hash string, at time.Time, scopes ...string) int {
+		t.Helper()
+		id, err := s.CreateAPIToken(&database.APIToken{UserID: int(user), Name: name, TokenHash: hash, Scopes: scopes, CreatedAt: at})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return int(id)
+	}
+	export := create(userID, "Export", "h1", at, "read")
+	sync := create(userID, "Sync", "h2", at.Add(time.Hour), "read", "invoices:write", "customers:write")
+	create(otherID, "Other", "h3", at)
+
+	tokens, err := s.GetUserAPITokens(int(userID))
+	if err != nil || len(tokens) != 2 || tokens[0].ID != sync || tokens[1].ID != export {
+		t.Fatalf("GetUserAPITokens = %+v, %v; want tokens %d and %d", tokens, err, sync, export)
+	}
+	if got := tokens[0]; got.Name != "Sync" || len(got.Scopes) != 3 || got.Scopes[1] != "invoices:write" || got.LastUsedAt != nil || !got.CreatedAt.Equal(at.Add(time.Hour)) {
+		t.Errorf("unused token = %+v", got)
+	}
+
+	used := at.Add(2 * time.Hour)
+	token, err := s.UseAPIToken("h1", used)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.ID != export || len(token.Scopes) != 1 || token.Scopes[0] != "read" || token.User == nil || token.User.Username != "admin" || token.User.Role != models.RoleAdmin {
+		t.Errorf("UseAPIToken = %+v (user %+v)", token, token.User)
+	}
+	if tokens, err := s.GetUserAPITokens(int(userID)); err != nil || tokens[1].LastUsedAt == nil || !tokens[1].LastUsedAt.Equal(used) {
+		t.Errorf("token after use = %+v, %v; want last used at %v", tokens[1], err, used)
+	}
+	if _, err := s.UseAPIToken("unknown", used); err != sql.ErrNoRows {
+		t.Errorf("unknown token: got %v, want sql.ErrNoRows", err)
+	}
+
+	// Users revoke only their own tokens.
+	if err := s.DeleteAPIToken(int(otherID), export); err != sql.ErrNoRows {
+		t.Errorf("revoking another user's token: got %v, want sql.ErrNoRows", err)
+	}
+	if err := s.DeleteAPIToken(int(userID), export); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.UseAPIToken("h1", used); err != sql.ErrNoRows {
+		t.Errorf("revoked token: got %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testIdempotencyKeys(t *testing.T, s Store) {
+	at := day(1).Add(9 * time.Hour)
+	reserve := func(scope, key, hash string, at time.Time) *database.IdempotencyRecord {
+		t.Helper()
+		existing, err := s.ReserveIdempotencyKey(&database.IdempotencyRecord{Scope: scope, Key: key, RequestHash: hash, CreatedAt: at}, at.Add(-24*time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return existing
+	}
+
+	if existing := reserve("POST /api/invoices", "k1", "h1", at); existing != nil {
+		t.Fatalf("first reservation returned %+v, want nil", existing)
+	}
+	// While the first request runs, retries see it unfinished.
+	if existing := reserve("POST /api/invoices", "k1", "h1", at.Add(time.Minute)); existing == nil || existing.StatusCode != 0 || existing.RequestHash != "h1" {
+		t.Errorf("retry during the first request = %+v, want the unfinished record", existing)
+	}
+	// Keys are separate per scope.
+	if existing := reserve("POST /api/customers", "k1", "h2", at); existing != nil {
+		t.Errorf("same key in another scope = %+v, want nil", existing)
+	}
+
+	body := []byte(`{"id":1}`)
+	if err := s.SaveIdempotentResponse(&database.IdempotencyRecord{Scope: "POST /api/invoices", Key: "k1", StatusCode: 201, ETag: `"1"`, ResponseBody: body}); err != nil {
+		t.Fatal(err)
+	}
+	existing := reserve("POST /api/invoices", "k1", "h3", at.Add(time.Hour))
+	if existing == nil || existing.StatusCode != 201 || existing.ETag != `"1"` || string(existing.ResponseBody) != string(body) || existing.RequestHash != "h1" || !existing.CreatedAt.Equal(at) {
+		t.Errorf("retry after the response = %+v, want the stored 201 response", existing)
+	}
+
+	// Released and expired keys can be used again.
+	if err := s.ReleaseIdempotencyKey("POST /api/customers", "k1"); err != nil {
+		t.Fatal(err)
+	}
+	if existing := reserve("POST /api/customers", "k1", "h4", at); existing != nil {
+		t.Errorf("released key = %+v, want nil", existing)
+	}
+	if existing := reserve("POST /api/invoices", "k1", "h5", at.Add(25*time.Hour)); existing != nil {
+		t.Errorf("expired key = %+v, want nil", existing)
+	}
+}
+
+func testConcurrentWrites(t *testing.T, s Store) {
+	customerID := createCustomer(t, s, "Acme")
+	const n = 8
+
+	var invoices []int
+	for k := 0; k < n; k++ {
+		invoices = append(invoices, createInvoice(t, s, newInvoice(customerID, "USD", day(1))))
+	}
+
+	// Concurrent creates get distinct IDs, and concurrent sends distinct numbers.
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*n)
+	ids := make(chan int64, n)
+	for k := 0; k < n; k++ {
+		wg.Add(2)
+		go func(k int) {
+			defer wg.Done()
+			id, err := s.CreateCustomer(&database.Customer{Name: fmt.Sprintf("Customer %d", k), Currency: "USD"})
+			errs <- err
+			ids <- id
+		}(k)
+		go func(id int) {
+			defer wg.Done()
+			errs <- s.TransitionInvoiceStatus(id, 0, models.StatusSent, day(2))
+		}(invoices[k])
+	}
+	wg.Wait()
+	close(errs)
+	close(ids)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	seen := make(map[int64]bool)
+	for id := range ids {
+		if seen[id] {
+			t.Errorf("customer ID %d handed out twice", id)
+		}
+		seen[id] = true
+	}
+	numbers := make(map[string]bool)
+	for _, id := range invoices {
+		invoice, err := s.GetInvoiceByID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		numbers[invoice.Number] = true
+	}
+	for k := 1; k <= n; k++ {
+		if number := fmt.Sprintf("INV-2026-%05d", k); !numbers[number] {
+			t.Errorf("number %s not handed out; got %v", number, numbers)
+		}
+	}
+}
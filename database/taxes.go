@@ -0,0 +1,124 @@
+package database
+
+import (
+	"errors"
+	"strings"
+
+	"tiny-invoicing/models"
+)
+
+// ErrDuplicateTaxCode is returned when a tax rate's code is already taken.
+var ErrDuplicateTaxCode = errors.New("tax code already exists")
+
+// taxRateColumns is the column list read by scanTaxRate.
+const taxRateColumns = "id, code, name, kind, rate, compound, active"
+
+func scanTaxRate(row rowScanner, rate *models.TaxRate) error {
+	return row.Scan(&rate.ID, &rate.Code, &rate.Name, &rate.Kind, &rate.Rate, &rate.Compound, &rate.Active)
+}
+
+// GetTaxRates retrieves all tax rates ordered by code, including inactive ones.
+func GetTaxRates() ([]models.TaxRate, error) {
+	rows, err := DB.Query("SELECT " + taxRateColumns + " FROM tax_rates ORDER BY code")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates []models.TaxRate
+	for rows.Next() {
+		var rate models.TaxRate
+		if err := scanTaxRate(rows, &rate); err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+	return rates, rows.Err()
+}
+
+// GetTaxRateByID retrieves a single tax rate. It returns sql.ErrNoRows if the rate does not exist.
+func GetTaxRateByID(id int) (*models.TaxRate, error) {
+	var rate models.TaxRate
+	if err := scanTaxRate(DB.QueryRow("SELECT "+taxRateColumns+" FROM tax_rates WHERE id = ?", id), &rate); err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+// GetTaxRatesByCode retrieves the tax rates with the given codes. Unknown codes are
+// simply missing from the result.
+func GetTaxRatesByCode(codes []string) ([]models.TaxRate, error) {
+	if len(codes) == 0 {
+		return nil, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(codes)), ", ")
+	args := make([]interface{}, len(codes))
+	for i, code := range codes {
+		args[i] = strings.ToUpper(strings.TrimSpace(code))
+	}
+
+	rows, err := DB.Query("SELECT "+taxRateColumns+" FROM tax_rates WHERE code IN ("+placeholders+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates []models.TaxRate
+	for rows.Next() {
+		var rate models.TaxRate
+		if err := scanTaxRate(rows, &rate); err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+	return rates, rows.Err()
+}
+
+// CreateTaxRate inserts a new tax rate and returns its ID. It returns ErrDuplicateTaxCode
+// if the code is already in use.
+func CreateTaxRate(rate *models.TaxRate) (int64, error) {
+	id, err := insert(DB, "INSERT INTO tax_rates (code, name, kind, rate, compound, active) VALUES (?, ?, ?, ?, ?, ?)",
+		rate.Code, rate.Name, rate.Kind, rate.Rate, rate.Compound, rate.Active)
+	if isDuplicateKey(err) {
+		return 0, ErrDuplicateTaxCode
+	}
+	return id, err
+}
+
+// UpdateTaxRate overwrites an existing tax rate. Invoices keep the rates they were
+// created with. It returns sql.ErrNoRows if the rate does not exist.
+func UpdateTaxRate(rate *models.TaxRate) error {
+	var exists int
+	if err := DB.QueryRow("SELECT 1 FROM tax_rates WHERE id = ?", rate.ID).Scan(&exists); err != nil {
+		return err
+	}
+	_, err := DB.Exec("UPDATE tax_rates SET code = ?, name = ?, kind = ?, rate = ?, compound = ?, active = ? WHERE id = ?",
+		rate.Code, rate.Name, rate.Kind, rate.Rate, rate.Compound, rate.Active, rate.ID)
+	if isDuplicateKey(err) {
+		return ErrDuplicateTaxCode
+	}
+	return err
+}
+
+// getInvoiceTaxes loads the stored per-rate tax summary of an invoice.
+func getInvoiceTaxes(invoiceID int, currency string) ([]models.TaxSummary, error) {
+	rows, err := DB.Query("SELECT code, name, kind, rate, compound, taxable_amount, tax_amount FROM invoice_taxes WHERE invoice_id = ? ORDER BY id", invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var taxes []models.TaxSummary
+	for rows.Next() {
+		var tax models.TaxSummary
+		var a amounts
+		if err := rows.Scan(&tax.Code, &tax.Name, &tax.Kind, &tax.Rate, &tax.Compound, a.col(&tax.TaxableAmount), a.col(&tax.TaxAmount)); err != nil {
+			return nil, err
+		}
+		if err := a.parse(currency); err != nil {
+			return nil, err
+		}
+		taxes = append(taxes, tax)
+	}
+	return taxes, rows.Err()
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"database/sql"
+
+	"tiny-invoicing/models"
+)
+
+// GetInvoiceTemplates lists the templates saved in the database.
+func GetInvoiceTemplates() ([]models.InvoiceTemplate, error) {
+	rows, err := DB.Query("SELECT name, body FROM invoice_templates ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var templates []models.InvoiceTemplate
+	for rows.Next() {
+		var template models.InvoiceTemplate
+		if err := rows.Scan(&template.Name, &template.Body); err != nil {
+			return nil, err
+		}
+		templates = append(templates, template)
+	}
+	return templates, rows.Err()
+}
+
+// GetInvoiceTemplate retrieves a saved template. It returns sql.ErrNoRows if there is none with that name.
+func GetInvoiceTemplate(name string) (*models.InvoiceTemplate, error) {
+	var template models.InvoiceTemplate
+	err := DB.QueryRow("SELECT name, body FROM invoice_templates WHERE name = ?", name).Scan(&template.Name, &template.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// SaveInvoiceTemplate creates or replaces a template.
+func SaveInvoiceTemplate(template *models.InvoiceTemplate) error {
+	_, err := DB.Exec("INSERT INTO invoice_templates (name, body) VALUES (?, ?)"+backend.onConflict("name", "body = "+backend.excluded("body")),
+		template.Name, template.Body)
+	return err
+}
+
+// DeleteInvoiceTemplate removes a saved template. Customers using it fall back to the
+// default template. It returns sql.ErrNoRows if there is none with that name.
+func DeleteInvoiceTemplate(name string) error {
+	result, err := DB.Exec("DELETE FROM invoice_templates WHERE name = ?", name)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
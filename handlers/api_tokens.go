@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"tiny-invoicing/auth"
+	"tiny-invoicing/database"
+	"tiny-invoicing/models"
+	"tiny-invoicing/response"
+)
+
+// APITokenStore defines the interface for API tokens.
+type APITokenStore interface {
+	CreateAPIToken(token *database.APIToken) (int64, error)
+	GetUserAPITokens(userID int) ([]database.APIToken, error)
+	DeleteAPIToken(userID, id int) error
+}
+
+// APITokenHandler handles the API tokens users create for scripts.
+type APITokenHandler struct {
+	Store APITokenStore
+}
+
+// createdAPIToken is an API token as returned when created: the only time the token
+// itself is shown.
+type createdAPIToken struct {
+	database.APIToken
+	Token string `json:"token"`
+}
+
+// CreateAPIToken creates an API token for the signed-in user with a name and scopes.
+func (h *APITokenHandler) CreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 255 {
+		response.Error(w, http.StatusBadRequest, "A name of up to 255 characters is required")
+		return
+	}
+	if len(req.Scopes) == 0 {
+		response.Error(w, http.StatusBadRequest, "At least one scope is required")
+		return
+	}
+	user := auth.CurrentUser(r)
+	requested := make(map[string]bool)
+	for _, scope := range req.Scopes {
+		if !auth.ValidScope(scope) {
+			response.Error(w, http.StatusBadRequest, fmt.Sprintf("Unknown scope %q, expected one of %s", scope, strings.Join(auth.Scopes, ", ")))
+			return
+		}
+		if !user.Role.Can(models.Permission(scope)) {
+			response.Error(w, http.StatusForbidden, fmt.Sprintf("The %s role cannot grant the %s scope", user.Role, scope))
+			return
+		}
+		requested[scope] = true
+	}
+	// Store each scope once, in the order of auth.Scopes.
+	var scopes []string
+	for _, scope := range auth.Scopes {
+		if requested[scope] {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	apiToken, token, err := auth.NewAPIToken(user, req.Name, scopes, time.Now())
+	if err != nil {
+		log.Printf("Error generating API token: %v", err)
+		response.Error(w, http.StatusInternalServerError, "Failed to create API token")
+		return
+	}
+	id, err := h.Store.CreateAPIToken(apiToken)
+	if err != nil {
+		log.Printf("Error creating API token in DB: %v", err)
+		response.Error(w, http.StatusInternalServerError, "Failed to create API token")
+		return
+	}
+	apiToken.ID = int(id)
+	response.JSON(w, http.StatusCreated, createdAPIToken{APIToken: *apiToken, Token: token})
+}
+
+// GetAPITokens lists the signed-in user's API tokens with when each was last used.
+func (h *APITokenHandler) GetAPITokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := h.Store.GetUserAPITokens(auth.CurrentUser(r).ID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to retrieve API tokens")
+		return
+	}
+	if tokens == nil {
+		tokens = []database.APIToken{}
+	}
+	response.JSON(w, http.StatusOK, tokens)
+}
+
+// RevokeAPIToken deletes one of the signed-in user's API tokens; scripts using it
+// are turned away from then on.
+func (h *APITokenHandler) RevokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Path[len("/api/tokens/"):])
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid API token ID")
+		return
+	}
+
+	if err := h.Store.DeleteAPIToken(auth.CurrentUser(r).ID, id); err != nil {
+		if err == sql.ErrNoRows {
+			response.Error(w, http.StatusNotFound, "API token not found")
+		} else {
+			log.Printf("Error deleting API token in DB: %v", err)
+			response.Error(w, http.StatusInternalServerError, "Failed to revoke API token")
+		}
+		return
+	}
+	response.JSON(w, http.StatusOK, map[string]string{"message": "API token revoked"})
+}
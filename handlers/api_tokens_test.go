@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"tiny-invoicing/auth"
+	"tiny-invoicing/database"
+	"tiny-invoicing/database/memstore"
+	"tiny-invoicing/models"
+)
+
+func TestAPITokens(t *testing.T) {
+	store := memstore.New()
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for username, role := range map[string]models.Role{"admin": models.RoleAdmin, "other": models.RoleViewer} {
+		if _, err := store.CreateUser(&database.User{Username: username, PasswordHash: string(hash), Role: role}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tokens := &APITokenHandler{Store: store}
+	do := func(method, path, username, body string, want int, into interface{}) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.SetBasicAuth(username, "secret")
+		rr := httptest.NewRecorder()
+		auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case method == "GET":
+				tokens.GetAPITokens(w, r)
+			case method == "POST":
+				tokens.CreateAPIToken(w, r)
+			default:
+				tokens.RevokeAPIToken(w, r)
+			}
+		})(rr, req)
+		if rr.Code != want {
+			t.Fatalf("%s %s: got status %d want %d: %s", method, path, rr.Code, want, rr.Body.String())
+		}
+		if into != nil {
+			if err := json.NewDecoder(rr.Body).Decode(into); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	do("POST", "/api/tokens", "admin", `{"scopes": ["read"]}`, http.StatusBadRequest, nil)
+	do("POST", "/api/tokens", "admin", `{"name": "Export"}`, http.StatusBadRequest, nil)
+	do("POST", "/api/tokens", "admin", `{"name": "Export", "scopes": ["read", "admin"]}`, http.StatusBadRequest, nil)
+	// Tokens get no more than their user's role allows.
+	do("POST", "/api/tokens", "other", `{"name": "Export", "scopes": ["read", "invoices:write"]}`, http.StatusForbidden, nil)
+
+	var created createdAPIToken
+	do("POST", "/api/tokens", "admin", `{"name": "Sync", "scopes": ["invoices:write", "read", "read"]}`, http.StatusCreated, &created)
+	if !strings.HasPrefix(created.Token, auth.APITokenPrefix) || created.Name != "Sync" || strings.Join(created.Scopes, " ") != "read invoices:write" {
+		t.Errorf("created token = %+v", created)
+	}
+
+	// The token works as a bearer token; the listing shows when it was used but not the token.
+	req := httptest.NewRequest("GET", "/api/invoices", nil)
+	req.Header.Set("Authorization", "Bearer "+created.Token)
+	rr := httptest.NewRecorder()
+	auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {})(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("request with the new token: got status %d want %d", rr.Code, http.StatusOK)
+	}
+	var listed []map[string]interface{}
+	do("GET", "/api/tokens", "admin", "", http.StatusOK, &listed)
+	if len(listed) != 1 || listed[0]["name"] != "Sync" || listed[0]["last_used_at"] == nil || listed[0]["token"] != nil {
+		t.Errorf("GET /api/tokens = %+v", listed)
+	}
+	do("GET", "/api/tokens", "other", "", http.StatusOK, &listed)
+	if len(listed) != 0 {
+		t.Errorf("GET /api/tokens as another user = %+v, want none", listed)
+	}
+
+	path := fmt.Sprintf("/api/tokens/%d", created.ID)
+	do("DELETE", path, "other", "", http.StatusNotFound, nil)
+	do("DELETE", path, "admin", "", http.StatusOK, nil)
+	do("DELETE", path, "admin", "", http.StatusNotFound, nil)
+
+	rr = httptest.NewRecorder()
+	auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {})(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("request with a revoked token: got status %d want %d", rr.Code, http.StatusUnauthorized)
+	}
+}
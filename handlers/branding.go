@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"tiny-invoicing/models"
+	"tiny-invoicing/response"
+)
+
+// BrandingStore defines the interface for branding persistence.
+type BrandingStore interface {
+	GetBranding() (*models.Branding, error)
+	SaveBranding(branding *models.Branding) error
+}
+
+// BrandingHandler handles the company branding printed on invoices.
+type BrandingHandler struct {
+	Store BrandingStore
+}
+
+// GetBranding returns the current branding.
+func (h *BrandingHandler) GetBranding(w http.ResponseWriter, r *http.Request) {
+	branding, err := h.Store.GetBranding()
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to retrieve branding")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, *branding)
+}
+
+// UpdateBranding replaces the branding. It applies to every invoice rendered from
+// then on, including invoices already sent.
+func (h *BrandingHandler) UpdateBranding(w http.ResponseWriter, r *http.Request) {
+	var branding models.Branding
+	if err := json.NewDecoder(r.Body).Decode(&branding); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := branding.Validate(); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid branding: "+err.Error())
+		return
+	}
+
+	if err := h.Store.SaveBranding(&branding); err != nil {
+		log.Printf("Error saving branding in DB: %v", err)
+		response.Error(w, http.StatusInternalServerError, "Failed to update branding")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, branding)
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"tiny-invoicing/models"
+)
+
+// MockBrandingStore is a mock implementation of BrandingStore.
+type MockBrandingStore struct {
+	GetBrandingFunc  func() (*models.Branding, error)
+	SaveBrandingFunc func(branding *models.Branding) error
+}
+
+func (m *MockBrandingStore) GetBranding() (*models.Branding, error) {
+	if m.GetBrandingFunc != nil {
+		return m.GetBrandingFunc()
+	}
+	return &models.Branding{Color: models.DefaultBrandColor}, nil
+}
+
+func (m *MockBrandingStore) SaveBranding(branding *models.Branding) error {
+	if m.SaveBrandingFunc != nil {
+		return m.SaveBrandingFunc(branding)
+	}
+	return nil
+}
+
+func TestUpdateBranding_Success(t *testing.T) {
+	var saved models.Branding
+	handler := &BrandingHandler{Store: &MockBrandingStore{
+		SaveBrandingFunc: func(branding *models.Branding) error {
+			saved = *branding
+			return nil
+		},
+	}}
+
+	reqBody := []byte(`{"company_name": "Tiny Invoicing Ltd", "logo_url": "https://example.com/logo.png", "footer": "Thank you"}`)
+	req := httptest.NewRequest("PUT", "/api/branding", bytes.NewBuffer(reqBody))
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(handler.UpdateBranding).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	// A missing colour is saved as the default.
+	if saved.CompanyName != "Tiny Invoicing Ltd" || saved.Color != models.DefaultBrandColor {
+		t.Errorf("saved branding = %+v", saved)
+	}
+}
+
+func TestUpdateBranding_InvalidColor(t *testing.T) {
+	handler := &BrandingHandler{Store: &MockBrandingStore{
+		SaveBrandingFunc: func(branding *models.Branding) error {
+			t.Error("invalid branding should not be saved")
+			return nil
+		},
+	}}
+
+	req := httptest.NewRequest("PUT", "/api/branding", bytes.NewBufferString(`{"color": "blue"}`))
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(handler.UpdateBranding).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"tiny-invoicing/database"
+	"tiny-invoicing/models"
+	"tiny-invoicing/response"
+)
+
+// CreditNoteStore defines the interface for credit note persistence.
+type CreditNoteStore interface {
+	GetCreditNotes(limit, offset int) ([]models.CreditNote, error)
+	GetCreditNoteByID(id int) (*models.CreditNote, error)
+	GetInvoiceCreditNotes(invoiceID int) ([]models.CreditNote, error)
+	CreateCreditNote(note *models.CreditNote, version int) (int64, int, error)
+}
+
+// CreditNoteHandler handles credit note requests.
+type CreditNoteHandler struct {
+	Store CreditNoteStore
+}
+
+// GetCreditNotes lists credit notes, newest first.
+func (h *CreditNoteHandler) GetCreditNotes(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 {
+		limit = 20
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	notes, err := h.Store.GetCreditNotes(limit, offset)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to retrieve credit notes")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, notes)
+}
+
+// GetCreditNote retrieves a single credit note.
+func (h *CreditNoteHandler) GetCreditNote(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Path[len("/api/credit-notes/"):])
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid credit note ID")
+		return
+	}
+
+	note, err := h.Store.GetCreditNoteByID(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			response.Error(w, http.StatusNotFound, "Credit note not found")
+		} else {
+			response.Error(w, http.StatusInternalServerError, "Failed to retrieve credit note")
+		}
+		return
+	}
+
+	response.JSON(w, http.StatusOK, *note)
+}
+
+// GetInvoiceCreditNotes lists the credit notes issued against an invoice.
+func (h *CreditNoteHandler) GetInvoiceCreditNotes(w http.ResponseWriter, r *http.Request) {
+	invoiceID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid invoice ID")
+		return
+	}
+
+	notes, err := h.Store.GetInvoiceCreditNotes(invoiceID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			response.Error(w, http.StatusNotFound, "Invoice not found")
+		} else {
+			response.Error(w, http.StatusInternalServerError, "Failed to retrieve credit notes")
+		}
+		return
+	}
+
+	response.JSON(w, http.StatusOK, notes)
+}
+
+// CreateCreditNote issues a credit note against an invoice. Without "lines" it credits
+// everything not yet credited; otherwise each line names a "line_item_id" of the
+// invoice and the "quantity" to credit. An If-Match header, if given, must carry the
+// invoice's current ETag; the response carries the invoice's new one.
+func (h *CreditNoteHandler) CreateCreditNote(w http.ResponseWriter, r *http.Request) {
+	invoiceID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid invoice ID")
+		return
+	}
+	version, ok := optionalIfMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Reason    string    `json:"reason"`
+		IssueDate time.Time `json:"issue_date"`
+		Lines     []struct {
+			LineItemID int `json:"line_item_id"`
+			Quantity   int `json:"quantity"`
+		} `json:"lines"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// The issue date defaults to today.
+	note := models.CreditNote{InvoiceID: invoiceID, Reason: request.Reason, IssueDate: request.IssueDate}
+	for _, line := range request.Lines {
+		note.Lines = append(note.Lines, models.CreditNoteLine{LineItemID: line.LineItemID, Quantity: line.Quantity})
+	}
+
+	noteID, version, err := h.Store.CreateCreditNote(&note, version)
+	if err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			response.Error(w, http.StatusNotFound, "Invoice not found")
+		case errors.Is(err, database.ErrVersionMismatch):
+			response.Error(w, http.StatusPreconditionFailed, err.Error())
+		case errors.Is(err, models.ErrInvalidCreditNote), errors.Is(err, models.ErrOverCredit):
+			response.Error(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, models.ErrNotCreditable), errors.Is(err, database.ErrNumberTaken):
+			response.Error(w, http.StatusConflict, err.Error())
+		default:
+			log.Printf("Error creating credit note in DB: %v", err)
+			response.Error(w, http.StatusInternalServerError, "Failed to create credit note")
+		}
+		return
+	}
+
+	note.ID = int(noteID)
+	w.Header().Set("ETag", invoiceETag(version))
+	response.JSON(w, http.StatusCreated, note)
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"tiny-invoicing/database"
+	"tiny-invoicing/models"
+)
+
+// MockCreditNoteStore is a mock implementation of CreditNoteStore.
+type MockCreditNoteStore struct {
+	GetCreditNotesFunc        func(limit, offset int) ([]models.CreditNote, error)
+	GetCreditNoteByIDFunc     func(id int) (*models.CreditNote, error)
+	GetInvoiceCreditNotesFunc func(invoiceID int) ([]models.CreditNote, error)
+	CreateCreditNoteFunc      func(note *models.CreditNote, version int) (int64, int, error)
+}
+
+func (m *MockCreditNoteStore) GetCreditNotes(limit, offset int) ([]models.CreditNote, error) {
+	if m.GetCreditNotesFunc != nil {
+		return m.GetCreditNotesFunc(limit, offset)
+	}
+	return nil, nil
+}
+
+func (m *MockCreditNoteStore) GetCreditNoteByID(id int) (*models.CreditNote, error) {
+	if m.GetCreditNoteByIDFunc != nil {
+		return m.GetCreditNoteByIDFunc(id)
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockCreditNoteStore) GetInvoiceCreditNotes(invoiceID int) ([]models.CreditNote, error) {
+	if m.GetInvoiceCreditNotesFunc != nil {
+		return m.GetInvoiceCreditNotesFunc(invoiceID)
+	}
+	return nil, nil
+}
+
+func (m *MockCreditNoteStore) CreateCreditNote(note *models.CreditNote, version int) (int64, int, error) {
+	if m.CreateCreditNoteFunc != nil {
+		return m.CreateCreditNoteFunc(note, version)
+	}
+	return 1, 2, nil
+}
+
+func TestCreateCreditNote_Success(t *testing.T) {
+	handler := &CreditNoteHandler{Store: &MockCreditNoteStore{
+		CreateCreditNoteFunc: func(note *models.CreditNote, version int) (int64, int, error) {
+			if note.InvoiceID != 4 || len(note.Lines) != 1 || note.Lines[0].LineItemID != 12 || note.Lines[0].Quantity != 2 {
+				t.Errorf("credit note passed to the store = %+v", note)
+			}
+			if !note.IssueDate.Equal(time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)) {
+				t.Errorf("issue date = %v", note.IssueDate)
+			}
+			note.Number = "CN-2026-00001"
+			note.Total = models.NewMoney(2400, "USD")
+			return 9, 3, nil
+		},
+	}}
+
+	reqBody := []byte(`{"reason": "Returned", "issue_date": "2026-03-10T00:00:00Z", "lines": [{"line_item_id": 12, "quantity": 2}]}`)
+	req := httptest.NewRequest("POST", "/api/invoices/4/credit-notes", bytes.NewBuffer(reqBody))
+	req.SetPathValue("id", "4")
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(handler.CreateCreditNote).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusCreated, rr.Body.String())
+	}
+	if !bytes.Contains(rr.Body.Bytes(), []byte(`"id":9,"credit_note_number":"CN-2026-00001"`)) {
+		t.Errorf("unexpected body: %s", rr.Body.String())
+	}
+	if etag := rr.Header().Get("ETag"); etag != `"3"` {
+		t.Errorf("ETag = %s, want the invoice's new version \"3\"", etag)
+	}
+}
+
+func TestCreateCreditNote_Errors(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{sql.ErrNoRows, http.StatusNotFound},
+		{database.ErrVersionMismatch, http.StatusPreconditionFailed},
+		{fmt.Errorf("%w: invoice is draft", models.ErrNotCreditable), http.StatusConflict},
+		{fmt.Errorf("%w: line item 12 has 1 left to credit", models.ErrOverCredit), http.StatusBadRequest},
+		{fmt.Errorf("%w: line item 99 is not on the invoice", models.ErrInvalidCreditNote), http.StatusBadRequest},
+		{fmt.Errorf("db error"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		handler := &CreditNoteHandler{Store: &MockCreditNoteStore{
+			CreateCreditNoteFunc: func(note *models.CreditNote, version int) (int64, int, error) {
+				return 0, 0, tt.err
+			},
+		}}
+
+		req := httptest.NewRequest("POST", "/api/invoices/4/credit-notes", bytes.NewBufferString(`{}`))
+		req.SetPathValue("id", "4")
+		rr := httptest.NewRecorder()
+
+		http.HandlerFunc(handler.CreateCreditNote).ServeHTTP(rr, req)
+
+		if rr.Code != tt.want {
+			t.Errorf("%v: got status %v, want %v", tt.err, rr.Code, tt.want)
+		}
+	}
+}
+
+func TestGetInvoiceCreditNotes_UnknownInvoice(t *testing.T) {
+	handler := &CreditNoteHandler{Store: &MockCreditNoteStore{
+		GetInvoiceCreditNotesFunc: func(invoiceID int) ([]models.CreditNote, error) {
+			return nil, sql.ErrNoRows
+		},
+	}}
+
+	req := httptest.NewRequest("GET", "/api/invoices/4/credit-notes", nil)
+	req.SetPathValue("id", "4")
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(handler.GetInvoiceCreditNotes).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+}
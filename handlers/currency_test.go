@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"tiny-invoicing/database"
+	"tiny-invoicing/models"
+)
+
+func TestCreateInvoice_DefaultsToCustomerCurrency(t *testing.T) {
+	var created models.Invoice
+	handler := &InvoiceHandler{Store: &MockInvoiceStore{
+		GetCustomerByIDFunc: func(id int) (*database.Customer, error) {
+			return &database.Customer{ID: id, Currency: "JPY"}, nil
+		},
+		CreateInvoiceFunc: func(invoice *models.Invoice) (int64, error) {
+			created = *invoice
+			return 1, nil
+		},
+	}}
+
+	reqBody := []byte(`{
+		"customer_id": 3,
+		"issue_date": "2025-12-31T00:00:00Z",
+		"due_date": "2026-01-14T00:00:00Z",
+		"line_items": [{"description": "Consulting", "quantity": 2, "unit_price": 1500}]
+	}`)
+	req := httptest.NewRequest("POST", "/api/invoices", bytes.NewBuffer(reqBody))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handler.CreateInvoice).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+	}
+	if created.Currency != "JPY" || created.Total != models.NewMoney(3000, "JPY") {
+		t.Errorf("expected a total of 3000 JPY, got %d %s", created.Total.Amount, created.Currency)
+	}
+	if !bytes.Contains(rr.Body.Bytes(), []byte(`"currency":"JPY"`)) || !bytes.Contains(rr.Body.Bytes(), []byte(`"total":3000,`)) {
+		t.Errorf("handler returned unexpected body: %s", rr.Body.String())
+	}
+}
+
+func TestCreateInvoice_UnsupportedCurrency(t *testing.T) {
+	handler := &InvoiceHandler{Store: &MockInvoiceStore{}}
+
+	reqBody := []byte(`{
+		"customer_id": 1,
+		"currency": "ABC",
+		"issue_date": "2025-12-31T00:00:00Z",
+		"due_date": "2026-01-14T00:00:00Z",
+		"line_items": [{"description": "Item", "quantity": 1, "unit_price": 10}]
+	}`)
+	req := httptest.NewRequest("POST", "/api/invoices", bytes.NewBuffer(reqBody))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handler.CreateInvoice).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
+
+func TestUpdateInvoice_SendWithoutExchangeRate(t *testing.T) {
+	store, customerID := newInvoiceStore(t)
+	id := seedInvoice(t, store, customerID, "EUR", time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC))
+
+	req := httptest.NewRequest("PUT", fmt.Sprintf("/api/invoices/%d", id), bytes.NewBufferString(`{"status": "sent"}`))
+	req.Header.Set("If-Match", `"1"`)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc((&InvoiceHandler{Store: store}).UpdateInvoice).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusConflict {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusConflict)
+	}
+	expected := "{\"error\":\"no exchange rate for EUR against USD on 2026-01-05\"}\n"
+	if rr.Body.String() != expected {
+		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+	}
+	if invoice, _ := store.GetInvoiceByID(id); invoice.Status != models.StatusDraft {
+		t.Errorf("invoice status = %s, want it to stay a draft", invoice.Status)
+	}
+}
+
+func TestRecordPayment_UsesInvoiceCurrency(t *testing.T) {
+	var recorded models.Payment
+	handler := &PaymentHandler{Store: &MockPaymentStore{
+		CurrencyFunc: func(invoiceID int) (string, error) {
+			return "KWD", nil
+		},
+		RecordPaymentFunc: func(payment *models.Payment, version int) (int64, int, error) {
+			recorded = *payment
+			return 1, 2, nil
+		},
+	}}
+
+	req := httptest.NewRequest("POST", "/api/invoices/7/payments", bytes.NewBufferString(`{"amount": 12.345, "method": "cash"}`))
+	req.SetPathValue("id", "7")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handler.RecordPayment).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+	}
+	if recorded.Amount != models.NewMoney(12345, "KWD") {
+		t.Errorf("expected 12345 fils, got %+v", recorded.Amount)
+	}
+}
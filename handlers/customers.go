@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	"tiny-invoicing/database"
+	"tiny-invoicing/models"
+	"tiny-invoicing/response"
+	"tiny-invoicing/templates"
+)
+
+// maxFieldLength matches the VARCHAR(255) columns of the customers table.
+const maxFieldLength = 255
+
+// CustomerHandler handles customer-related requests.
+type CustomerHandler struct {
+	Store CustomerStore
+	// Templates is used to check the customer's invoice template; it may be nil.
+	Templates *templates.Renderer
+}
+
+// GetCustomers lists customers.
+func (h *CustomerHandler) GetCustomers(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 {
+		limit = 20
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	customers, err := h.Store.GetCustomers(limit, offset)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to retrieve customers")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, customers)
+}
+
+// GetCustomer retrieves a single customer.
+func (h *CustomerHandler) GetCustomer(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Path[len("/api/customers/"):])
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid customer ID")
+		return
+	}
+
+	customer, err := h.Store.GetCustomerByID(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			response.Error(w, http.StatusNotFound, "Customer not found")
+		} else {
+			response.Error(w, http.StatusInternalServerError, "Failed to retrieve customer")
+		}
+		return
+	}
+
+	response.JSON(w, http.StatusOK, *customer)
+}
+
+// CreateCustomer creates a new customer.
+func (h *CustomerHandler) CreateCustomer(w http.ResponseWriter, r *http.Request) {
+	var customer database.Customer
+	if err := json.NewDecoder(r.Body).Decode(&customer); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if msg := validateCustomer(&customer); msg != "" {
+		response.Error(w, http.StatusBadRequest, msg)
+		return
+	}
+	if !h.templateExists(w, customer.Template) {
+		return
+	}
+
+	customerID, err := h.Store.CreateCustomer(&customer)
+	if err != nil {
+		log.Printf("Error creating customer in DB: %v", err)
+		response.Error(w, http.StatusInternalServerError, "Failed to create customer")
+		return
+	}
+
+	customer.ID = int(customerID)
+	response.JSON(w, http.StatusCreated, customer)
+}
+
+// UpdateCustomer replaces the details of an existing customer.
+func (h *CustomerHandler) UpdateCustomer(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Path[len("/api/customers/"):])
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid customer ID")
+		return
+	}
+
+	var customer database.Customer
+	if err := json.NewDecoder(r.Body).Decode(&customer); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	customer.ID = id
+
+	if msg := validateCustomer(&customer); msg != "" {
+		response.Error(w, http.StatusBadRequest, msg)
+		return
+	}
+	if !h.templateExists(w, customer.Template) {
+		return
+	}
+
+	if err := h.Store.UpdateCustomer(&customer); err != nil {
+		if err == sql.ErrNoRows {
+			response.Error(w, http.StatusNotFound, "Customer not found")
+		} else {
+			log.Printf("Error updating customer in DB: %v", err)
+			response.Error(w, http.StatusInternalServerError, "Failed to update customer")
+		}
+		return
+	}
+
+	response.JSON(w, http.StatusOK, customer)
+}
+
+// DeleteCustomer removes a customer that has no invoices.
+func (h *CustomerHandler) DeleteCustomer(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Path[len("/api/customers/"):])
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid customer ID")
+		return
+	}
+
+	if err := h.Store.DeleteCustomer(id); err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			response.Error(w, http.StatusNotFound, "Customer not found")
+		case errors.Is(err, database.ErrCustomerInUse):
+			response.Error(w, http.StatusConflict, "Customer has invoices, estimates or recurring profiles and cannot be deleted")
+		default:
+			log.Printf("Error deleting customer in DB: %v", err)
+			response.Error(w, http.StatusInternalServerError, "Failed to delete customer")
+		}
+		return
+	}
+
+	response.JSON(w, http.StatusOK, map[string]string{"message": "Customer deleted successfully"})
+}
+
+// templateExists reports whether the named invoice template can be found, writing an
+// error response if it cannot. An empty name selects the default template.
+func (h *CustomerHandler) templateExists(w http.ResponseWriter, name string) bool {
+	if name == "" || h.Templates == nil {
+		return true
+	}
+	exists, err := h.Templates.Exists(name)
+	if err != nil {
+		log.Printf("Error looking up template %q: %v", name, err)
+		response.Error(w, http.StatusInternalServerError, "Failed to save customer")
+		return false
+	}
+	if !exists {
+		response.Error(w, http.StatusBadRequest, "Unknown template")
+		return false
+	}
+	return true
+}
+
+// validateCustomer normalises the customer's fields and returns a message describing
+// the first problem found, or an empty string if the customer is valid.
+func validateCustomer(customer *database.Customer) string {
+	customer.Name = strings.TrimSpace(customer.Name)
+	customer.Email = strings.TrimSpace(customer.Email)
+	customer.Address = strings.TrimSpace(customer.Address)
+
+	if customer.Name == "" || customer.Email == "" || customer.Address == "" {
+		return "Name, email and address are required"
+	}
+	if len(customer.Name) > maxFieldLength || len(customer.Email) > maxFieldLength || len(customer.Address) > maxFieldLength {
+		return "Name, email and address must be at most 255 characters"
+	}
+
+	// Accept a bare address only ("Jane <jane@example.com>" is rejected) with a dotted domain.
+	addr, err := mail.ParseAddress(customer.Email)
+	if err != nil || addr.Address != customer.Email {
+		return "Invalid email address"
+	}
+	domain := addr.Address[strings.LastIndex(addr.Address, "@")+1:]
+	if !strings.Contains(domain, ".") || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") {
+		return "Invalid email address"
+	}
+
+	// New invoices for the customer default to this currency.
+	if strings.TrimSpace(customer.Currency) == "" {
+		customer.Currency = models.BaseCurrency
+	}
+	currency, err := models.ParseCurrency(customer.Currency)
+	if err != nil {
+		return "Unsupported currency"
+	}
+	customer.Currency = currency
+
+	customer.Template = strings.TrimSpace(customer.Template)
+	if customer.Template != "" && !models.ValidTemplateName(customer.Template) {
+		return "Invalid template name"
+	}
+	return ""
+}
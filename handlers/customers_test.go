@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"tiny-invoicing/database"
+	"tiny-invoicing/templates"
+)
+
+// MockCustomerStore is a mock implementation of CustomerStore.
+type MockCustomerStore struct {
+	GetCustomersFunc    func(limit, offset int) ([]database.Customer, error)
+	GetCustomerByIDFunc func(id int) (*database.Customer, error)
+	CreateCustomerFunc  func(customer *database.Customer) (int64, error)
+	UpdateCustomerFunc  func(customer *database.Customer) error
+	DeleteCustomerFunc  func(id int) error
+}
+
+func (m *MockCustomerStore) GetCustomers(limit, offset int) ([]database.Customer, error) {
+	if m.GetCustomersFunc != nil {
+		return m.GetCustomersFunc(limit, offset)
+	}
+	return nil, nil
+}
+
+func (m *MockCustomerStore) GetCustomerByID(id int) (*database.Customer, error) {
+	if m.GetCustomerByIDFunc != nil {
+		return m.GetCustomerByIDFunc(id)
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockCustomerStore) CreateCustomer(customer *database.Customer) (int64, error) {
+	if m.CreateCustomerFunc != nil {
+		return m.CreateCustomerFunc(customer)
+	}
+	return 0, nil
+}
+
+func (m *MockCustomerStore) UpdateCustomer(customer *database.Customer) error {
+	if m.UpdateCustomerFunc != nil {
+		return m.UpdateCustomerFunc(customer)
+	}
+	return nil
+}
+
+func (m *MockCustomerStore) DeleteCustomer(id int) error {
+	if m.DeleteCustomerFunc != nil {
+		return m.DeleteCustomerFunc(id)
+	}
+	return nil
+}
+
+func TestCreateCustomer_Success(t *testing.T) {
+	var saved database.Customer
+	handler := &CustomerHandler{Store: &MockCustomerStore{
+		CreateCustomerFunc: func(customer *database.Customer) (int64, error) {
+			saved = *customer
+			return 7, nil
+		},
+	}}
+
+	reqBody := []byte(`{"name": " Acme Corp ", "email": "billing@acme.example", "address": "1 Main St"}`)
+	req := httptest.NewRequest("POST", "/api/customers", bytes.NewBuffer(reqBody))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handler.CreateCustomer).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusCreated {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+	}
+	if saved.Name != "Acme Corp" {
+		t.Errorf("expected trimmed name to be stored, got %q", saved.Name)
+	}
+
+	expected := "{\"id\":7,\"name\":\"Acme Corp\",\"email\":\"billing@acme.example\",\"address\":\"1 Main St\",\"currency\":\"USD\"}\n"
+	if rr.Body.String() != expected {
+		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+	}
+}
+
+func TestCreateCustomer_Validation(t *testing.T) {
+	handler := &CustomerHandler{Store: &MockCustomerStore{}}
+
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`{"name": "Acme", "email": "billing@acme.example"}`, "{\"error\":\"Name, email and address are required\"}\n"},
+		{`{"name": "Acme", "email": "not-an-email", "address": "1 Main St"}`, "{\"error\":\"Invalid email address\"}\n"},
+		{`{"name": "Acme", "email": "Billing <billing@acme.example>", "address": "1 Main St"}`, "{\"error\":\"Invalid email address\"}\n"},
+		{`{"name": "Acme", "email": "billing@localhost", "address": "1 Main St"}`, "{\"error\":\"Invalid email address\"}\n"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/api/customers", bytes.NewBufferString(tt.body))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(handler.CreateCustomer).ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", tt.body, status, http.StatusBadRequest)
+		}
+		if rr.Body.String() != tt.want {
+			t.Errorf("%s: handler returned unexpected body: got %v want %v", tt.body, rr.Body.String(), tt.want)
+		}
+	}
+}
+
+func TestCreateCustomer_UnknownTemplate(t *testing.T) {
+	handler := &CustomerHandler{
+		Store: &MockCustomerStore{
+			CreateCustomerFunc: func(customer *database.Customer) (int64, error) {
+				t.Error("customer with an unknown template should not be stored")
+				return 0, nil
+			},
+		},
+		Templates: &templates.Renderer{},
+	}
+
+	reqBody := []byte(`{"name": "Acme", "email": "billing@acme.example", "address": "1 Main St", "template": "fancy"}`)
+	req := httptest.NewRequest("POST", "/api/customers", bytes.NewBuffer(reqBody))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handler.CreateCustomer).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+	if want := "{\"error\":\"Unknown template\"}\n"; rr.Body.String() != want {
+		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), want)
+	}
+}
+
+func TestGetCustomer_NotFound(t *testing.T) {
+	handler := &CustomerHandler{Store: &MockCustomerStore{}}
+
+	req := httptest.NewRequest("GET", "/api/customers/42", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handler.GetCustomer).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+	}
+}
+
+func TestUpdateCustomer_UsesPathID(t *testing.T) {
+	var updatedID int
+	handler := &CustomerHandler{Store: &MockCustomerStore{
+		UpdateCustomerFunc: func(customer *database.Customer) error {
+			updatedID = customer.ID
+			return nil
+		},
+	}}
+
+	reqBody := []byte(`{"id": 99, "name": "Acme", "email": "billing@acme.example", "address": "1 Main St"}`)
+	req := httptest.NewRequest("PUT", "/api/customers/3", bytes.NewBuffer(reqBody))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handler.UpdateCustomer).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if updatedID != 3 {
+		t.Errorf("expected customer 3 to be updated, got %d", updatedID)
+	}
+}
+
+func TestDeleteCustomer_InUse(t *testing.T) {
+	handler := &CustomerHandler{Store: &MockCustomerStore{
+		DeleteCustomerFunc: func(id int) error {
+			return database.ErrCustomerInUse
+		},
+	}}
+
+	req := httptest.NewRequest("DELETE", "/api/customers/1", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handler.DeleteCustomer).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusConflict {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusConflict)
+	}
+}
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"tiny-invoicing/database"
+	"tiny-invoicing/models"
+	"tiny-invoicing/response"
+)
+
+// EstimateStore defines the interface for estimate persistence.
+type EstimateStore interface {
+	GetEstimates(limit, offset int) ([]models.Estimate, error)
+	GetEstimateByID(id int) (*models.Estimate, error)
+	CreateEstimate(estimate *models.Estimate) (int64, error)
+	TransitionEstimateStatus(id int, status models.EstimateStatus, at time.Time) error
+	GetCustomerByID(id int) (*database.Customer, error)
+	GetTaxRatesByCode(codes []string) ([]models.TaxRate, error)
+	CreateInvoice(invoice *models.Invoice) (int64, error)
+}
+
+// EstimateHandler handles estimate requests.
+type EstimateHandler struct {
+	Store EstimateStore
+}
+
+// GetEstimates lists estimates, newest first.
+func (h *EstimateHandler) GetEstimates(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 {
+		limit = 20
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	estimates, err := h.Store.GetEstimates(limit, offset)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to retrieve estimates")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, estimates)
+}
+
+// GetEstimate retrieves a single estimate.
+func (h *EstimateHandler) GetEstimate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Path[len("/api/estimates/"):])
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid estimate ID")
+		return
+	}
+
+	estimate, err := h.Store.GetEstimateByID(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			response.Error(w, http.StatusNotFound, "Estimate not found")
+		} else {
+			response.Error(w, http.StatusInternalServerError, "Failed to retrieve estimate")
+		}
+		return
+	}
+
+	response.JSON(w, http.StatusOK, *estimate)
+}
+
+// CreateEstimate creates a draft estimate. Estimates without a currency use the
+// customer's.
+func (h *EstimateHandler) CreateEstimate(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// Amounts are read in the estimate's currency, so settle that first.
+	var head struct {
+		CustomerID int    `json:"customer_id"`
+		Currency   string `json:"currency"`
+	}
+	if err := json.Unmarshal(body, &head); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	var estimate models.Estimate
+	if head.Currency == "" && head.CustomerID != 0 {
+		customer, err := h.Store.GetCustomerByID(head.CustomerID)
+		if err == sql.ErrNoRows {
+			response.Error(w, http.StatusBadRequest, "Unknown customer")
+			return
+		}
+		if err != nil {
+			log.Printf("Error loading customer: %v", err)
+			response.Error(w, http.StatusInternalServerError, "Failed to create estimate")
+			return
+		}
+		estimate.Currency = customer.Currency
+	}
+
+	if err := json.Unmarshal(body, &estimate); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if err := estimate.Validate(); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid estimate: "+err.Error())
+		return
+	}
+
+	invoice := estimate.Invoice(estimate.IssueDate, estimate.ExpiryDate)
+	rates, err := h.Store.GetTaxRatesByCode(invoice.TaxCodes())
+	if err != nil {
+		log.Printf("Error loading tax rates: %v", err)
+		response.Error(w, http.StatusInternalServerError, "Failed to create estimate")
+		return
+	}
+	if err := estimate.Calculate(rates); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	estimateID, err := h.Store.CreateEstimate(&estimate)
+	if errors.Is(err, database.ErrUnknownCustomer) {
+		response.Error(w, http.StatusBadRequest, "Unknown customer")
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating estimate in DB: %v", err)
+		response.Error(w, http.StatusInternalServerError, "Failed to create estimate")
+		return
+	}
+
+	estimate.ID = int(estimateID)
+	response.JSON(w, http.StatusCreated, estimate)
+}
+
+// UpdateEstimate moves an estimate to a new status ({"status": "sent"}).
+func (h *EstimateHandler) UpdateEstimate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Path[len("/api/estimates/"):])
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid estimate ID")
+		return
+	}
+
+	var payload struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	status, err := models.ParseEstimateStatus(payload.Status)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid estimate status")
+		return
+	}
+
+	if err := h.Store.TransitionEstimateStatus(id, status, time.Now()); err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			response.Error(w, http.StatusNotFound, "Estimate not found")
+		case errors.Is(err, models.ErrInvalidEstimateTransition), errors.Is(err, database.ErrNumberTaken):
+			response.Error(w, http.StatusConflict, err.Error())
+		default:
+			log.Printf("Error updating estimate status in DB: %v", err)
+			response.Error(w, http.StatusInternalServerError, "Failed to update estimate")
+		}
+		return
+	}
+
+	response.JSON(w, http.StatusOK, map[string]string{"message": "Estimate updated successfully"})
+}
+
+// ConvertEstimate creates a draft invoice from an accepted estimate. The invoice is
+// issued today and due DefaultDueDays later unless the request gives "issue_date" or
+// "due_date", and its taxes are calculated with the current tax rates.
+func (h *EstimateHandler) ConvertEstimate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid estimate ID")
+		return
+	}
+
+	// The body is optional.
+	var request struct {
+		IssueDate *time.Time `json:"issue_date"`
+		DueDate   *time.Time `json:"due_date"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	estimate, err := h.Store.GetEstimateByID(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			response.Error(w, http.StatusNotFound, "Estimate not found")
+		} else {
+			response.Error(w, http.StatusInternalServerError, "Failed to convert estimate")
+		}
+		return
+	}
+	if err := estimate.Convertible(); err != nil {
+		response.Error(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	issued := time.Now().UTC().Truncate(24 * time.Hour)
+	if request.IssueDate != nil {
+		issued = *request.IssueDate
+	}
+	due := issued.AddDate(0, 0, models.DefaultDueDays)
+	if request.DueDate != nil {
+		due = *request.DueDate
+	}
+	if due.Before(issued) {
+		response.Error(w, http.StatusBadRequest, "due_date must not be before issue_date")
+		return
+	}
+
+	invoice := estimate.Invoice(issued, due)
+	rates, err := h.Store.GetTaxRatesByCode(invoice.TaxCodes())
+	if err != nil {
+		log.Printf("Error loading tax rates: %v", err)
+		response.Error(w, http.StatusInternalServerError, "Failed to convert estimate")
+		return
+	}
+	if err := invoice.SetTaxRates(rates); err != nil {
+		response.Error(w, http.StatusConflict, "Invalid tax code: "+err.Error())
+		return
+	}
+	if err := invoice.ValidateDiscounts(); err != nil {
+		response.Error(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err := invoice.CalculateTotal(); err != nil {
+		response.Error(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	invoiceID, err := h.Store.CreateInvoice(invoice)
+	if errors.Is(err, database.ErrEstimateConverted) {
+		response.Error(w, http.StatusConflict, "Estimate has already been converted")
+		return
+	}
+	if err != nil {
+		log.Printf("Error converting estimate %d: %v", id, err)
+		response.Error(w, http.StatusInternalServerError, "Failed to convert estimate")
+		return
+	}
+
+	invoice.ID, invoice.Version = int(invoiceID), 1
+	w.Header().Set("ETag", invoiceETag(invoice.Version))
+	response.JSON(w, http.StatusCreated, invoice)
+}
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"tiny-invoicing/database"
+	"tiny-invoicing/models"
+)
+
+// MockEstimateStore is a mock implementation of EstimateStore.
+type MockEstimateStore struct {
+	GetEstimatesFunc             func(limit, offset int) ([]models.Estimate, error)
+	GetEstimateByIDFunc          func(id int) (*models.Estimate, error)
+	CreateEstimateFunc           func(estimate *models.Estimate) (int64, error)
+	TransitionEstimateStatusFunc func(id int, status models.EstimateStatus, at time.Time) error
+	CreateInvoiceFunc            func(invoice *models.Invoice) (int64, error)
+}
+
+func (m *MockEstimateStore) GetEstimates(limit, offset int) ([]models.Estimate, error) {
+	if m.GetEstimatesFunc != nil {
+		return m.GetEstimatesFunc(limit, offset)
+	}
+	return nil, nil
+}
+
+func (m *MockEstimateStore) GetEstimateByID(id int) (*models.Estimate, error) {
+	if m.GetEstimateByIDFunc != nil {
+		return m.GetEstimateByIDFunc(id)
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockEstimateStore) CreateEstimate(estimate *models.Estimate) (int64, error) {
+	if m.CreateEstimateFunc != nil {
+		return m.CreateEstimateFunc(estimate)
+	}
+	return 1, nil
+}
+
+func (m *MockEstimateStore) TransitionEstimateStatus(id int, status models.EstimateStatus, at time.Time) error {
+	if m.TransitionEstimateStatusFunc != nil {
+		return m.TransitionEstimateStatusFunc(id, status, at)
+	}
+	return nil
+}
+
+func (m *MockEstimateStore) GetCustomerByID(id int) (*database.Customer, error) {
+	return &database.Customer{ID: id, Currency: "EUR"}, nil
+}
+
+func (m *MockEstimateStore) GetTaxRatesByCode(codes []string) ([]models.TaxRate, error) {
+	return nil, nil
+}
+
+func (m *MockEstimateStore) CreateInvoice(invoice *models.Invoice) (int64, error) {
+	if m.CreateInvoiceFunc != nil {
+		return m.CreateInvoiceFunc(invoice)
+	}
+	return 1, nil
+}
+
+// acceptedEstimate returns an accepted estimate that has not been converted yet.
+func acceptedEstimate(id int) (*models.Estimate, error) {
+	return &models.Estimate{
+		ID: id, CustomerID: 2, Currency: "USD", Status: models.EstimateAccepted,
+		IssueDate: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), ExpiryDate: time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC),
+		LineItems: []models.LineItem{{Description: "Design", Quantity: 2, UnitPrice: models.NewMoney(10000, "USD")}},
+	}, nil
+}
+
+func TestCreateEstimate_Success(t *testing.T) {
+	var stored models.Estimate
+	handler := &EstimateHandler{Store: &MockEstimateStore{
+		CreateEstimateFunc: func(estimate *models.Estimate) (int64, error) {
+			stored = *estimate
+			return 4, nil
+		},
+	}}
+
+	reqBody := []byte(`{"customer_id": 2, "issue_date": "2026-03-01T00:00:00Z", "expiry_date": "2026-03-31T00:00:00Z", "status": "accepted",
+		"line_items": [{"description": "Design", "quantity": 2, "unit_price": "100.00"}]}`)
+	req := httptest.NewRequest("POST", "/api/estimates", bytes.NewBuffer(reqBody))
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(handler.CreateEstimate).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusCreated, rr.Body.String())
+	}
+	if stored.Currency != "EUR" || stored.Total.Amount != 20000 || stored.LineItems[0].Total.Amount != 20000 {
+		t.Errorf("stored estimate = %+v, want 200.00 EUR in the customer's currency", stored)
+	}
+	if stored.Status != "" {
+		t.Errorf("stored status = %q; the store sets new estimates to draft", stored.Status)
+	}
+}
+
+func TestCreateEstimate_ExpiryBeforeIssue(t *testing.T) {
+	handler := &EstimateHandler{Store: &MockEstimateStore{}}
+
+	reqBody := []byte(`{"customer_id": 2, "issue_date": "2026-03-01T00:00:00Z", "expiry_date": "2026-02-01T00:00:00Z",
+		"line_items": [{"description": "Design", "quantity": 1, "unit_price": "100.00"}]}`)
+	req := httptest.NewRequest("POST", "/api/estimates", bytes.NewBuffer(reqBody))
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(handler.CreateEstimate).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestUpdateEstimate_AcceptExpired(t *testing.T) {
+	handler := &EstimateHandler{Store: &MockEstimateStore{
+		TransitionEstimateStatusFunc: func(id int, status models.EstimateStatus, at time.Time) error {
+			estimate := models.Estimate{Status: models.EstimateExpired}
+			return estimate.TransitionTo(status, at)
+		},
+	}}
+
+	req := httptest.NewRequest("PUT", "/api/estimates/4", bytes.NewBufferString(`{"status": "accepted"}`))
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(handler.UpdateEstimate).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusConflict {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusConflict)
+	}
+}
+
+func TestConvertEstimate_Success(t *testing.T) {
+	var created models.Invoice
+	handler := &EstimateHandler{Store: &MockEstimateStore{
+		GetEstimateByIDFunc: acceptedEstimate,
+		CreateInvoiceFunc: func(invoice *models.Invoice) (int64, error) {
+			created = *invoice
+			return 12, nil
+		},
+	}}
+
+	req := httptest.NewRequest("POST", "/api/estimates/4/convert", bytes.NewBufferString(`{"issue_date": "2026-04-01T00:00:00Z"}`))
+	req.SetPathValue("id", "4")
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(handler.ConvertEstimate).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusCreated, rr.Body.String())
+	}
+	if created.EstimateID == nil || *created.EstimateID != 4 || created.Status != models.StatusDraft {
+		t.Errorf("created invoice = %+v, want a draft referring to estimate 4", created)
+	}
+	if want := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC); !created.DueDate.Equal(want) {
+		t.Errorf("due date = %v, want %v", created.DueDate, want)
+	}
+	if created.Total.Amount != 20000 {
+		t.Errorf("invoice total = %s, want 200.00", created.Total)
+	}
+	if !bytes.Contains(rr.Body.Bytes(), []byte(`"id":12`)) || !bytes.Contains(rr.Body.Bytes(), []byte(`"estimate_id":4`)) {
+		t.Errorf("unexpected body: %s", rr.Body.String())
+	}
+	// The new invoice can be edited with the version it was created at.
+	if etag := rr.Header().Get("ETag"); etag != `"1"` {
+		t.Errorf("ETag = %s, want the new invoice's version \"1\"", etag)
+	}
+	if !bytes.Contains(rr.Body.Bytes(), []byte(`"version":1`)) {
+		t.Errorf("body does not carry version 1: %s", rr.Body.String())
+	}
+}
+
+func TestConvertEstimate_Conflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		estimate func(id int) (*models.Estimate, error)
+		create   func(invoice *models.Invoice) (int64, error)
+		want     int
+	}{
+		{"unknown", func(id int) (*models.Estimate, error) { return nil, sql.ErrNoRows }, nil, http.StatusNotFound},
+		{"not accepted", func(id int) (*models.Estimate, error) {
+			estimate, _ := acceptedEstimate(id)
+			estimate.Status = models.EstimateExpired
+			return estimate, nil
+		}, nil, http.StatusConflict},
+		{"converted concurrently", acceptedEstimate, func(invoice *models.Invoice) (int64, error) {
+			return 0, database.ErrEstimateConverted
+		}, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		handler := &EstimateHandler{Store: &MockEstimateStore{
+			GetEstimateByIDFunc: tt.estimate,
+			CreateInvoiceFunc:   tt.create,
+		}}
+
+		req := httptest.NewRequest("POST", "/api/estimates/4/convert", nil)
+		req.SetPathValue("id", "4")
+		rr := httptest.NewRecorder()
+
+		http.HandlerFunc(handler.ConvertEstimate).ServeHTTP(rr, req)
+
+		if rr.Code != tt.want {
+			t.Errorf("%s: got status %v, want %v: %s", tt.name, rr.Code, tt.want, rr.Body.String())
+		}
+	}
+}
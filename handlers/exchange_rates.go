@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"tiny-invoicing/models"
+	"tiny-invoicing/response"
+)
+
+// ExchangeRateStore defines the interface for exchange rate persistence.
+type ExchangeRateStore interface {
+	GetExchangeRates(base, currency string, limit, offset int) ([]models.ExchangeRate, error)
+}
+
+// ExchangeRateHandler handles exchange rate requests. Rates are loaded from the
+// file named by EXCHANGE_RATES_FILE at startup.
+type ExchangeRateHandler struct {
+	Store ExchangeRateStore
+}
+
+// GetExchangeRates lists rates against the base currency, newest first, optionally
+// for a single currency (?currency=EUR).
+func (h *ExchangeRateHandler) GetExchangeRates(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 {
+		limit = 20
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	currency := r.URL.Query().Get("currency")
+	if currency != "" {
+		var err error
+		if currency, err = models.ParseCurrency(currency); err != nil {
+			response.Error(w, http.StatusBadRequest, "Unsupported currency")
+			return
+		}
+	}
+
+	rates, err := h.Store.GetExchangeRates(models.BaseCurrency, currency, limit, offset)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to retrieve exchange rates")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, rates)
+}
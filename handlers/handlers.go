@@ -1,55 +1,181 @@
 package handlers
 
 import (
+	"bytes"
 	"database/sql"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
-	"tiny-invoicing/auth"
 	"tiny-invoicing/database"
+	"tiny-invoicing/mailer"
 	"tiny-invoicing/models" // Add models import
+	"tiny-invoicing/pdf"
 	"tiny-invoicing/response"
+	"tiny-invoicing/templates"
 )
 
 // InvoiceStore defines the interface for invoice persistence.
 type InvoiceStore interface {
 	CreateInvoice(invoice *models.Invoice) (int64, error)
+	GetCustomerByID(id int) (*database.Customer, error)
+	GetTaxRatesByCode(codes []string) ([]models.TaxRate, error)
+	GetBranding() (*models.Branding, error)
+	GetInvoices(limit, offset int) ([]models.Invoice, error)
+	GetInvoiceByID(id int) (*models.Invoice, error)
+	UpdateInvoice(invoice *models.Invoice) error
+	TransitionInvoiceStatus(id, version int, status models.InvoiceStatus, at time.Time) error
+	RecordDelivery(delivery *models.Delivery) (int64, error)
+	GetDeliveries(invoiceID int) ([]models.Delivery, error)
+}
+
+// CustomerStore defines the interface for customer persistence.
+type CustomerStore interface {
+	GetCustomers(limit, offset int) ([]database.Customer, error)
+	GetCustomerByID(id int) (*database.Customer, error)
+	CreateCustomer(customer *database.Customer) (int64, error)
+	UpdateCustomer(customer *database.Customer) error
+	DeleteCustomer(id int) error
+}
+
+// UserStore defines the interface for user persistence.
+type UserStore interface {
+	CreateUser(user *database.User) (int64, error)
+	GetUsers() ([]database.User, error)
+	GetUserByID(id int) (*database.User, error)
+	UpdateUserRole(id int, role models.Role) error
 }
 
 // InvoiceHandler handles invoice-related requests.
 type InvoiceHandler struct {
 	Store InvoiceStore
+	// Templates renders invoice previews and email bodies.
+	Templates *templates.Renderer
+	// Mailer sends invoices by email; sending is unavailable while it is nil.
+	Mailer mailer.Sender
 }
 
-// CreateInvoice creates a new invoice.
+// CreateInvoice creates a new invoice. Invoices without a currency use the customer's.
 func (h *InvoiceHandler) CreateInvoice(w http.ResponseWriter, r *http.Request) {
-	var invoice models.Invoice
-	if err := json.NewDecoder(r.Body).Decode(&invoice); err != nil {
-		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+	invoice, ok := h.readInvoice(w, r, "Failed to create invoice")
+	if !ok {
 		return
 	}
 
+	invoiceID, err := h.Store.CreateInvoice(invoice)
+	if errors.Is(err, database.ErrUnknownCustomer) {
+		response.Error(w, http.StatusBadRequest, "Unknown customer")
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating invoice in DB: %v", err)
+		response.Error(w, http.StatusInternalServerError, "Failed to create invoice")
+		return
+	}
+
+	invoice.ID, invoice.Version = int(invoiceID), 1
+	w.Header().Set("ETag", invoiceETag(invoice.Version))
+	response.JSON(w, http.StatusCreated, invoice)
+}
+
+// readInvoice decodes and validates the draft invoice in the request body and
+// calculates its totals. It writes the error response and returns false if not valid.
+func (h *InvoiceHandler) readInvoice(w http.ResponseWriter, r *http.Request, failure string) (*models.Invoice, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return nil, false
+	}
+
+	// Amounts are read in the invoice's currency, so settle that first.
+	var head struct {
+		CustomerID int    `json:"customer_id"`
+		Currency   string `json:"currency"`
+	}
+	if err := json.Unmarshal(body, &head); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return nil, false
+	}
+	invoice := &models.Invoice{}
+	if head.Currency == "" && head.CustomerID != 0 {
+		customer, err := h.Store.GetCustomerByID(head.CustomerID)
+		if err == sql.ErrNoRows {
+			response.Error(w, http.StatusBadRequest, "Unknown customer")
+			return nil, false
+		}
+		if err != nil {
+			log.Printf("Error loading customer: %v", err)
+			response.Error(w, http.StatusInternalServerError, failure)
+			return nil, false
+		}
+		invoice.Currency = customer.Currency
+	}
+
+	if err := json.Unmarshal(body, invoice); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return nil, false
+	}
+
 	// Basic validation for models.Invoice
 	if invoice.CustomerID == 0 || invoice.IssueDate.IsZero() || invoice.DueDate.IsZero() || len(invoice.LineItems) == 0 {
 		response.Error(w, http.StatusBadRequest, "Missing required fields")
-		return
+		return nil, false
+	}
+	if err := models.ValidateQuantities(invoice.LineItems); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return nil, false
 	}
 
-	invoice.CalculateTotal()
+	// Invoices are written as drafts; later states are reached through status changes.
+	if invoice.Status != "" && invoice.Status != models.StatusDraft {
+		response.Error(w, http.StatusBadRequest, "Invoices must be saved as draft")
+		return nil, false
+	}
+	invoice.Status = models.StatusDraft
+	// Only the recurring scheduler links invoices to the runs of a profile, and only
+	// conversion links them to estimates.
+	invoice.RecurringProfileID, invoice.RecurrenceDate = nil, nil
+	invoice.EstimateID = nil
 
-	invoiceID, err := h.Store.CreateInvoice(&invoice)
+	currency, err := models.ParseCurrency(invoice.Currency)
 	if err != nil {
-		log.Printf("Error creating invoice in DB: %v", err)
-		// DEBUG MODE: Mengirim error asli ke frontend
-		response.Error(w, http.StatusInternalServerError, fmt.Sprintf("DB Error: %v", err))
-		return
+		response.Error(w, http.StatusBadRequest, "Unsupported currency")
+		return nil, false
 	}
+	invoice.Currency = currency
 
-	invoice.ID = int(invoiceID)
-	response.JSON(w, http.StatusCreated, invoice)
+	mode, err := models.ParseRoundingMode(string(invoice.RoundingMode))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid rounding mode")
+		return nil, false
+	}
+	invoice.RoundingMode = mode
+
+	rates, err := h.Store.GetTaxRatesByCode(invoice.TaxCodes())
+	if err != nil {
+		log.Printf("Error loading tax rates: %v", err)
+		response.Error(w, http.StatusInternalServerError, failure)
+		return nil, false
+	}
+	if err := invoice.SetTaxRates(rates); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid tax code: "+err.Error())
+		return nil, false
+	}
+
+	if err := invoice.ValidateDiscounts(); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	if err := invoice.CalculateTotal(); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	return invoice, true
 }
 
 // GetInvoices lists all invoices.
@@ -63,7 +189,7 @@ func (h *InvoiceHandler) GetInvoices(w http.ResponseWriter, r *http.Request) {
 		offset = 0
 	}
 
-	invoices, err := database.GetInvoices(limit, offset)
+	invoices, err := h.Store.GetInvoices(limit, offset)
 	if err != nil {
 		response.Error(w, http.StatusInternalServerError, "Failed to retrieve invoices")
 		return
@@ -72,16 +198,18 @@ func (h *InvoiceHandler) GetInvoices(w http.ResponseWriter, r *http.Request) {
 	response.JSON(w, http.StatusOK, invoices)
 }
 
-
-// GetInvoice retrieves a single invoice.
+// GetInvoice retrieves a single invoice. It is rendered as a PDF document when the
+// path ends in ".pdf" or the client accepts application/pdf.
 func (h *InvoiceHandler) GetInvoice(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.Atoi(r.URL.Path[len("/api/invoices/"):])
+	path := r.URL.Path[len("/api/invoices/"):]
+	asPDF := strings.HasSuffix(path, ".pdf") || strings.Contains(r.Header.Get("Accept"), "application/pdf")
+	id, err := strconv.Atoi(strings.TrimSuffix(path, ".pdf"))
 	if err != nil {
 		response.Error(w, http.StatusBadRequest, "Invalid invoice ID")
 		return
 	}
 
-	invoice, err := database.GetInvoiceByID(id)
+	invoice, err := h.Store.GetInvoiceByID(id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			response.Error(w, http.StatusNotFound, "Invoice not found")
@@ -91,71 +219,266 @@ func (h *InvoiceHandler) GetInvoice(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.Header().Set("ETag", invoiceETag(invoice.Version))
+	if asPDF {
+		h.writeInvoicePDF(w, invoice)
+		return
+	}
+
 	response.JSON(w, http.StatusOK, *invoice)
 }
 
-// UpdateInvoice updates an invoice.
-func (h *InvoiceHandler) UpdateInvoice(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.Atoi(r.URL.Path[len("/api/invoices/"):])
+// invoiceETag returns the entity tag of an invoice at version.
+func invoiceETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// ifMatchVersion returns the invoice version named by the If-Match header, or 0 for
+// "*". Writes to invoices must name the version they were based on: without the
+// header it writes 428 Precondition Required, and for anything but a single strong
+// entity tag 412 Precondition Failed, and returns false.
+func ifMatchVersion(w http.ResponseWriter, r *http.Request) (int, bool) {
+	tag := strings.TrimSpace(r.Header.Get("If-Match"))
+	if tag == "" {
+		response.Error(w, http.StatusPreconditionRequired, "If-Match header is required")
+		return 0, false
+	}
+	if tag == "*" {
+		return 0, true
+	}
+	version, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(tag, `"`), `"`))
+	if err != nil || version < 1 || tag != invoiceETag(version) {
+		response.Error(w, http.StatusPreconditionFailed, "If-Match does not match the invoice")
+		return 0, false
+	}
+	return version, true
+}
+
+// optionalIfMatchVersion is ifMatchVersion for writes that may be made without naming
+// a version, such as recording a payment: without the header it returns 0, which
+// skips the version check.
+func optionalIfMatchVersion(w http.ResponseWriter, r *http.Request) (int, bool) {
+	if strings.TrimSpace(r.Header.Get("If-Match")) == "" {
+		return 0, true
+	}
+	return ifMatchVersion(w, r)
+}
+
+// writeInvoicePDF renders invoice for its customer with the current branding.
+func (h *InvoiceHandler) writeInvoicePDF(w http.ResponseWriter, invoice *models.Invoice) {
+	customer, branding, err := h.documentParts(invoice)
+	if err != nil {
+		log.Printf("Error loading invoice %d for rendering: %v", invoice.ID, err)
+		response.Error(w, http.StatusInternalServerError, "Failed to render invoice")
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Invoice(&buf, invoice, customer, *branding); err != nil {
+		log.Printf("Error rendering invoice %d: %v", invoice.ID, err)
+		response.Error(w, http.StatusInternalServerError, "Failed to render invoice")
+		return
+	}
+
+	filename := pdfFilename(invoice)
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `inline; filename="`+filename+`"`)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	buf.WriteTo(w)
+}
+
+// pdfFilename names the PDF document of invoice, e.g. "invoice-INV-2026-00001.pdf".
+func pdfFilename(invoice *models.Invoice) string {
+	return "invoice-" + strings.TrimPrefix(invoice.Title(), "#") + ".pdf"
+}
+
+// PreviewInvoice renders an invoice as HTML with its customer's template, or the
+// template named by the "template" query parameter.
+func (h *InvoiceHandler) PreviewInvoice(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
 		response.Error(w, http.StatusBadRequest, "Invalid invoice ID")
 		return
 	}
 
-	var payload struct {
-		Status string `json:"status"`
+	invoice, err := h.Store.GetInvoiceByID(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			response.Error(w, http.StatusNotFound, "Invoice not found")
+		} else {
+			response.Error(w, http.StatusInternalServerError, "Failed to retrieve invoice")
+		}
+		return
 	}
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+
+	customer, branding, err := h.documentParts(invoice)
+	if err != nil {
+		log.Printf("Error loading invoice %d for rendering: %v", invoice.ID, err)
+		response.Error(w, http.StatusInternalServerError, "Failed to render invoice")
 		return
 	}
 
-	if err := database.UpdateInvoiceStatusString(id, payload.Status); err != nil {
-		response.Error(w, http.StatusInternalServerError, "Failed to update invoice")
+	var buf bytes.Buffer
+	name := r.URL.Query().Get("template")
+	err = h.renderHTML(&buf, invoice, customer, branding, name)
+	if errors.Is(err, templates.ErrUnknownTemplate) {
+		response.Error(w, http.StatusNotFound, "Template not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error rendering invoice %d: %v", invoice.ID, err)
+		response.Error(w, http.StatusInternalServerError, "Failed to render invoice")
 		return
 	}
 
-	response.JSON(w, http.StatusOK, map[string]string{"message": "Invoice updated successfully"})
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	buf.WriteTo(w)
+}
+
+// renderHTML renders invoice with the named template, or with the customer's template
+// if name is empty. It returns templates.ErrUnknownTemplate only for a named template;
+// a customer template that has since been removed is replaced by the default.
+func (h *InvoiceHandler) renderHTML(buf *bytes.Buffer, invoice *models.Invoice, customer *database.Customer, branding *models.Branding, name string) error {
+	data := templates.NewData(invoice, customer, *branding)
+	if name != "" {
+		return h.Templates.Render(buf, name, data)
+	}
+
+	name = customer.Template
+	if name == "" {
+		name = models.DefaultTemplate
+	}
+	err := h.Templates.Render(buf, name, data)
+	if errors.Is(err, templates.ErrUnknownTemplate) {
+		log.Printf("Template %q of customer %d not found, using the default", name, customer.ID)
+		buf.Reset()
+		err = h.Templates.Render(buf, models.DefaultTemplate, data)
+	}
+	return err
+}
+
+// documentParts loads what is printed on an invoice besides the invoice itself.
+func (h *InvoiceHandler) documentParts(invoice *models.Invoice) (*database.Customer, *models.Branding, error) {
+	customer, err := h.Store.GetCustomerByID(invoice.CustomerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	branding, err := h.Store.GetBranding()
+	if err != nil {
+		return nil, nil, err
+	}
+	return customer, branding, nil
 }
 
-// CreateAdminUser creates a new admin user.
-// TODO: Remove this endpoint or secure it properly in a production environment.
-func CreateAdminUser(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+// UpdateInvoice updates an invoice. A body holding only a status, such as
+// {"status": "sent"}, moves the invoice through its lifecycle. Any other body is a
+// whole draft invoice that replaces the customer, dates, notes and line items of a
+// draft; invoices that have left draft are locked. The If-Match header must carry
+// the ETag of the version the change is based on. Either way the response is the
+// stored invoice with its new ETag.
+func (h *InvoiceHandler) UpdateInvoice(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Path[len("/api/invoices/"):])
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid invoice ID")
+		return
+	}
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
 		return
 	}
 
-	var creds struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
 	}
-	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
 		response.Error(w, http.StatusBadRequest, "Invalid request payload")
 		return
 	}
+	if _, ok := fields["status"]; !ok || len(fields) > 1 {
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		h.editInvoice(w, r, id, version)
+		return
+	}
 
-	if creds.Username == "" || creds.Password == "" {
-		response.Error(w, http.StatusBadRequest, "Username and password are required")
+	var payload struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request payload")
 		return
 	}
 
-	hashedPassword, err := auth.HashPassword(creds.Password)
+	status, err := models.ParseInvoiceStatus(payload.Status)
 	if err != nil {
-		response.Error(w, http.StatusInternalServerError, "Failed to hash password")
+		response.Error(w, http.StatusBadRequest, "Invalid invoice status")
 		return
 	}
 
-	user := &database.User{
-		Username:     creds.Username,
-		PasswordHash: hashedPassword,
-		IsAdmin:      true,
+	if err := h.Store.TransitionInvoiceStatus(id, version, status, time.Now()); err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			response.Error(w, http.StatusNotFound, "Invoice not found")
+		case errors.Is(err, database.ErrVersionMismatch):
+			response.Error(w, http.StatusPreconditionFailed, err.Error())
+		case errors.Is(err, models.ErrInvalidTransition), errors.Is(err, models.ErrNoExchangeRate), errors.Is(err, database.ErrNumberTaken):
+			response.Error(w, http.StatusConflict, err.Error())
+		default:
+			log.Printf("Error updating invoice status in DB: %v", err)
+			response.Error(w, http.StatusInternalServerError, "Failed to update invoice")
+		}
+		return
+	}
+
+	h.writeUpdatedInvoice(w, id)
+}
+
+// editInvoice replaces draft invoice id, if still at version, with the invoice in
+// the request body and responds with the stored result.
+func (h *InvoiceHandler) editInvoice(w http.ResponseWriter, r *http.Request, id, version int) {
+	invoice, ok := h.readInvoice(w, r, "Failed to update invoice")
+	if !ok {
+		return
+	}
+	invoice.ID, invoice.Version = id, version
+
+	if err := h.Store.UpdateInvoice(invoice); err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			response.Error(w, http.StatusNotFound, "Invoice not found")
+		case errors.Is(err, database.ErrVersionMismatch):
+			response.Error(w, http.StatusPreconditionFailed, err.Error())
+		case errors.Is(err, database.ErrUnknownCustomer):
+			response.Error(w, http.StatusBadRequest, "Unknown customer")
+		case errors.Is(err, models.ErrInvoiceLocked):
+			response.Error(w, http.StatusConflict, err.Error())
+		default:
+			log.Printf("Error updating invoice in DB: %v", err)
+			response.Error(w, http.StatusInternalServerError, "Failed to update invoice")
+		}
+		return
 	}
 
-	if _, err := database.CreateUser(user); err != nil {
-		response.Error(w, http.StatusInternalServerError, "Failed to create admin user")
+	h.writeUpdatedInvoice(w, id)
+}
+
+// writeUpdatedInvoice responds with invoice id as stored after a change, and its ETag.
+func (h *InvoiceHandler) writeUpdatedInvoice(w http.ResponseWriter, id int) {
+	updated, err := h.Store.GetInvoiceByID(id)
+	if err != nil {
+		log.Printf("Error loading updated invoice: %v", err)
+		response.Error(w, http.StatusInternalServerError, "Failed to update invoice")
 		return
 	}
+	w.Header().Set("ETag", invoiceETag(updated.Version))
+	response.JSON(w, http.StatusOK, updated)
+}
 
-	response.JSON(w, http.StatusCreated, map[string]string{"message": "Admin user created successfully"})
+// UserHandler handles user administration requests.
+type UserHandler struct {
+	Store UserStore
 }
@@ -3,20 +3,39 @@ package handlers
 import (
 	"bytes"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
 	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"tiny-invoicing/auth"
 	"tiny-invoicing/database"
+	"tiny-invoicing/database/memstore"
 	"tiny-invoicing/models"
-
-	"github.com/DATA-DOG/go-sqlmock"
 )
 
+func TestMain(m *testing.M) {
+	auth.PasswordCost = bcrypt.MinCost
+	os.Exit(m.Run())
+}
+
 // MockInvoiceStore is a mock implementation of InvoiceStore.
 type MockInvoiceStore struct {
-	CreateInvoiceFunc func(invoice *models.Invoice) (int64, error)
+	CreateInvoiceFunc     func(invoice *models.Invoice) (int64, error)
+	GetTaxRatesByCodeFunc func(codes []string) ([]models.TaxRate, error)
+	GetCustomerByIDFunc   func(id int) (*database.Customer, error)
+	GetBrandingFunc       func() (*models.Branding, error)
+	GetInvoiceByIDFunc    func(id int) (*models.Invoice, error)
+	UpdateInvoiceFunc     func(invoice *models.Invoice) error
+	TransitionFunc        func(id, version int, status models.InvoiceStatus, at time.Time) error
+	RecordDeliveryFunc    func(delivery *models.Delivery) (int64, error)
+	GetDeliveriesFunc     func(invoiceID int) ([]models.Delivery, error)
+	GetInvoicesFunc       func(limit, offset int) ([]models.Invoice, error)
 }
 
 func (m *MockInvoiceStore) CreateInvoice(invoice *models.Invoice) (int64, error) {
@@ -26,9 +45,166 @@ func (m *MockInvoiceStore) CreateInvoice(invoice *models.Invoice) (int64, error)
 	return 0, nil
 }
 
+func (m *MockInvoiceStore) GetCustomerByID(id int) (*database.Customer, error) {
+	if m.GetCustomerByIDFunc != nil {
+		return m.GetCustomerByIDFunc(id)
+	}
+	return &database.Customer{ID: id, Currency: "USD"}, nil
+}
+
+func (m *MockInvoiceStore) GetBranding() (*models.Branding, error) {
+	if m.GetBrandingFunc != nil {
+		return m.GetBrandingFunc()
+	}
+	return &models.Branding{Color: models.DefaultBrandColor}, nil
+}
+
+func (m *MockInvoiceStore) GetInvoices(limit, offset int) ([]models.Invoice, error) {
+	if m.GetInvoicesFunc != nil {
+		return m.GetInvoicesFunc(limit, offset)
+	}
+	return nil, nil
+}
+
+func (m *MockInvoiceStore) GetInvoiceByID(id int) (*models.Invoice, error) {
+	if m.GetInvoiceByIDFunc != nil {
+		return m.GetInvoiceByIDFunc(id)
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockInvoiceStore) UpdateInvoice(invoice *models.Invoice) error {
+	if m.UpdateInvoiceFunc != nil {
+		return m.UpdateInvoiceFunc(invoice)
+	}
+	return nil
+}
+
+func (m *MockInvoiceStore) TransitionInvoiceStatus(id, version int, status models.InvoiceStatus, at time.Time) error {
+	if m.TransitionFunc != nil {
+		return m.TransitionFunc(id, version, status, at)
+	}
+	return nil
+}
+
+func (m *MockInvoiceStore) RecordDelivery(delivery *models.Delivery) (int64, error) {
+	if m.RecordDeliveryFunc != nil {
+		return m.RecordDeliveryFunc(delivery)
+	}
+	return 0, nil
+}
+
+func (m *MockInvoiceStore) GetDeliveries(invoiceID int) ([]models.Delivery, error) {
+	if m.GetDeliveriesFunc != nil {
+		return m.GetDeliveriesFunc(invoiceID)
+	}
+	return nil, nil
+}
+
+func (m *MockInvoiceStore) GetTaxRatesByCode(codes []string) ([]models.TaxRate, error) {
+	if m.GetTaxRatesByCodeFunc != nil {
+		return m.GetTaxRatesByCodeFunc(codes)
+	}
+	return nil, nil
+}
+
 func TestHandlers(t *testing.T) {
-	// TODO: Implement actual handler tests with a test server and mocked database
-	t.Skip("Skipping handler tests until a test server and mocked database setup is available.")
+	store := memstore.New()
+	invoices := &InvoiceHandler{Store: store}
+	customers := &CustomerHandler{Store: store}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/customers" && r.Method == http.MethodPost:
+			customers.CreateCustomer(w, r)
+		case r.URL.Path == "/api/invoices" && r.Method == http.MethodPost:
+			invoices.CreateInvoice(w, r)
+		case r.URL.Path == "/api/invoices":
+			invoices.GetInvoices(w, r)
+		case r.Method == http.MethodPut:
+			invoices.UpdateInvoice(w, r)
+		default:
+			invoices.GetInvoice(w, r)
+		}
+	}))
+	defer server.Close()
+
+	// ifMatch is sent as the If-Match header when set, and etag holds the last ETag received.
+	var ifMatch, etag string
+	do := func(method, path, body string, want int, into interface{}) {
+		t.Helper()
+		req, err := http.NewRequest(method, server.URL+path, bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Fatalf("%s %s: got status %v want %v", method, path, resp.StatusCode, want)
+		}
+		if tag := resp.Header.Get("ETag"); tag != "" {
+			etag = tag
+		}
+		if into != nil {
+			if err := json.NewDecoder(resp.Body).Decode(into); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	var customer database.Customer
+	do("POST", "/api/customers", `{"name": "Acme", "email": "billing@acme.example", "address": "1 Main St", "currency": "EUR"}`, http.StatusCreated, &customer)
+
+	var created models.Invoice
+	do("POST", "/api/invoices", fmt.Sprintf(`{"customer_id": %d, "issue_date": "2026-03-14T00:00:00Z", "due_date": "2099-03-28T00:00:00Z",
+		"line_items": [{"description": "Item 1", "quantity": 2, "unit_price": "10.00"}]}`, customer.ID), http.StatusCreated, &created)
+	if created.Currency != "EUR" || created.Total.Amount != 2000 {
+		t.Errorf("created invoice = %+v, want 20.00 EUR", created)
+	}
+
+	if created.Version != 1 || etag != `"1"` {
+		t.Errorf("created invoice at version %d with ETag %s, want 1", created.Version, etag)
+	}
+	path := fmt.Sprintf("/api/invoices/%d", created.ID)
+	do("GET", path, "", http.StatusOK, nil)
+	if etag != `"1"` {
+		t.Errorf("new invoice ETag = %s, want \"1\"", etag)
+	}
+
+	// Changes must name the version they are based on.
+	do("PUT", path, `{"status": "sent"}`, http.StatusPreconditionRequired, nil)
+	ifMatch = etag
+
+	// EUR invoices need an exchange rate to be sent.
+	do("PUT", path, `{"status": "sent"}`, http.StatusConflict, nil)
+	rate, _ := models.ParseRate("0.8")
+	store.SaveExchangeRates([]models.ExchangeRate{{Base: "USD", Currency: "EUR", Rate: rate, Date: created.IssueDate}})
+	do("PUT", path, `{"status": "sent"}`, http.StatusOK, nil)
+
+	// The first send moved the invoice on, so a second one based on version 1 fails.
+	do("PUT", path, `{"status": "void"}`, http.StatusPreconditionFailed, nil)
+
+	var sent models.Invoice
+	do("GET", path, "", http.StatusOK, &sent)
+	if sent.Number != "INV-2026-00001" || sent.Status != models.StatusSent || sent.BaseTotal == nil || sent.BaseTotal.Amount != 2500 {
+		t.Errorf("sent invoice = %+v, want INV-2026-00001 worth 25.00 USD", sent)
+	}
+	if sent.Version != 2 || etag != `"2"` {
+		t.Errorf("sent invoice at version %d with ETag %s, want 2", sent.Version, etag)
+	}
+	ifMatch = ""
+
+	var listed []models.Invoice
+	do("GET", "/api/invoices", "", http.StatusOK, &listed)
+	if len(listed) != 1 || listed[0].Number != sent.Number {
+		t.Errorf("listed invoices = %+v", listed)
+	}
+	do("GET", "/api/invoices/999", "", http.StatusNotFound, nil)
 }
 
 func TestCreateInvoice_InvalidInput(t *testing.T) {
@@ -36,7 +212,7 @@ func TestCreateInvoice_InvalidInput(t *testing.T) {
 
 	// Setup a test server
 	reqBody := []byte(`{
-		"client_id": 0,
+		"customer_id": 0,
 		"issue_date": "0001-01-01T00:00:00Z",
 		"due_date": "0001-01-01T00:00:00Z",
 		"total": 150.75,
@@ -74,7 +250,7 @@ func TestCreateInvoice_Success(t *testing.T) {
 	handler := &InvoiceHandler{Store: mockStore}
 
 	reqBody := []byte(`{
-		"client_id": 1,
+		"customer_id": 1,
 		"issue_date": "2025-12-31T00:00:00Z",
 		"due_date": "2026-01-14T00:00:00Z",
 		"status": "draft",
@@ -99,6 +275,36 @@ func TestCreateInvoice_Success(t *testing.T) {
 	}
 }
 
+func TestCreateInvoice_InvalidAmounts(t *testing.T) {
+	handler := &InvoiceHandler{Store: &MockInvoiceStore{
+		CreateInvoiceFunc: func(invoice *models.Invoice) (int64, error) {
+			t.Errorf("invoice with totals %s stored", invoice.Total)
+			return 1, nil
+		},
+	}}
+
+	tests := []struct {
+		name  string
+		items string
+	}{
+		{"zero quantity", `{"description": "Item", "quantity": 0, "unit_price": 10.0}`},
+		{"negative quantity", `{"description": "Item", "quantity": -2, "unit_price": 10.0}`},
+		{"line overflows", `{"description": "Item", "quantity": 1000000000000, "unit_price": 1000000.00}`},
+		{"total overflows", `{"description": "Item", "quantity": 1, "unit_price": 50000000000000000.00},
+			{"description": "Item", "quantity": 1, "unit_price": 50000000000000000.00}`},
+	}
+	for _, tt := range tests {
+		body := `{"customer_id": 1, "issue_date": "2025-12-31T00:00:00Z", "due_date": "2026-01-14T00:00:00Z", "line_items": [` + tt.items + `]}`
+		req := httptest.NewRequest("POST", "/invoices", bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		handler.CreateInvoice(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: got status %d want %d: %s", tt.name, rr.Code, http.StatusBadRequest, rr.Body.String())
+		}
+	}
+}
+
 func TestCreateInvoice_DatabaseError(t *testing.T) {
 	mockStore := &MockInvoiceStore{
 		CreateInvoiceFunc: func(invoice *models.Invoice) (int64, error) {
@@ -108,7 +314,7 @@ func TestCreateInvoice_DatabaseError(t *testing.T) {
 	handler := &InvoiceHandler{Store: mockStore}
 
 	reqBody := []byte(`{
-		"client_id": 1,
+		"customer_id": 1,
 		"issue_date": "2025-12-31T00:00:00Z",
 		"due_date": "2026-01-14T00:00:00Z",
 		"status": "draft",
@@ -135,6 +341,40 @@ func TestCreateInvoice_DatabaseError(t *testing.T) {
 	}
 }
 
+func TestCreateInvoice_UnknownCustomer(t *testing.T) {
+	mockStore := &MockInvoiceStore{
+		CreateInvoiceFunc: func(invoice *models.Invoice) (int64, error) {
+			return 0, database.ErrUnknownCustomer
+		},
+	}
+	handler := &InvoiceHandler{Store: mockStore}
+
+	reqBody := []byte(`{
+		"customer_id": 404,
+		"issue_date": "2025-12-31T00:00:00Z",
+		"due_date": "2026-01-14T00:00:00Z",
+		"line_items": [{"description": "Item 1", "quantity": 1, "unit_price": 10.0}]
+	}`)
+	req, err := http.NewRequest("POST", "/invoices", bytes.NewBuffer(reqBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handler.CreateInvoice).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusBadRequest)
+	}
+
+	expected := "{\"error\":\"Unknown customer\"}\n"
+	if rr.Body.String() != expected {
+		t.Errorf("handler returned unexpected body: got %v want %v",
+			rr.Body.String(), expected)
+	}
+}
+
 func TestCreateInvoice_InvalidJSON(t *testing.T) {
 	handler := &InvoiceHandler{}
 
@@ -160,67 +400,56 @@ func TestCreateInvoice_InvalidJSON(t *testing.T) {
 	}
 }
 
-func TestGetInvoice_Success(t *testing.T) {
-	db, mock, err := sqlmock.New()
+// newInvoiceStore returns an in-memory store with one customer, who pays in USD.
+func newInvoiceStore(t *testing.T) (*memstore.Store, int) {
+	store := memstore.New()
+	customerID, err := store.CreateCustomer(&database.Customer{Name: "Acme", Currency: "USD"})
 	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		t.Fatal(err)
 	}
-	defer db.Close()
-
-	oldDB := database.DB
-	database.DB = db
-	defer func() { database.DB = oldDB }()
-
-	issueDate := time.Now().Truncate(time.Second)
-	dueDate := issueDate.Add(14 * 24 * time.Hour)
-
-	// Expectations for Invoice
-	invoiceRows := sqlmock.NewRows([]string{"id", "client_id", "issue_date", "due_date", "status", "total"}).
-		AddRow(1, 1, issueDate, dueDate, "draft", 25.0)
-
-	mock.ExpectQuery("SELECT id, client_id, issue_date, due_date, status, total FROM invoices WHERE id = ?").
-		WithArgs(1).
-		WillReturnRows(invoiceRows)
+	return store, int(customerID)
+}
 
-	// Expectations for Line Items
-	itemRows := sqlmock.NewRows([]string{"id", "invoice_id", "description", "quantity", "unit_price"}).
-		AddRow(1, 1, "Item 1", 2, 10.0).
-		AddRow(2, 1, "Item 2", 1, 5.0)
+// seedInvoice stores a draft invoice of 25.00 issued on the given date.
+func seedInvoice(t *testing.T, store *memstore.Store, customerID int, currency string, issued time.Time) int {
+	invoice := &models.Invoice{CustomerID: customerID, Currency: currency, IssueDate: issued, DueDate: issued.AddDate(0, 0, 14),
+		LineItems: []models.LineItem{
+			{Description: "Item 1", Quantity: 2, UnitPrice: models.NewMoney(1000, currency)},
+			{Description: "Item 2", Quantity: 1, UnitPrice: models.NewMoney(500, currency)},
+		}}
+	invoice.CalculateTotal()
+	id, err := store.CreateInvoice(invoice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return int(id)
+}
 
-	mock.ExpectQuery("SELECT id, invoice_id, description, quantity, unit_price FROM line_items WHERE invoice_id = ?").
-		WithArgs(1).
-		WillReturnRows(itemRows)
+func TestGetInvoice_Success(t *testing.T) {
+	store, customerID := newInvoiceStore(t)
+	id := seedInvoice(t, store, customerID, "USD", time.Now().Truncate(time.Second))
 
-	req, err := http.NewRequest("GET", "/api/invoices/1", nil)
+	req, err := http.NewRequest("GET", fmt.Sprintf("/api/invoices/%d", id), nil)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	rr := httptest.NewRecorder()
-	handler := &InvoiceHandler{}
+	handler := &InvoiceHandler{Store: store}
 
 	http.HandlerFunc(handler.GetInvoice).ServeHTTP(rr, req)
 
 	// Assertions
-	if err := mock.ExpectationsWereMet(); err != nil {
-		t.Errorf("there were unfulfilled expectations: %s", err)
+	if !bytes.Contains(rr.Body.Bytes(), []byte(`"total":25.00`)) {
+		t.Errorf("handler returned unexpected body, want exact decimal total: %s", rr.Body.String())
+	}
+	if !bytes.Contains(rr.Body.Bytes(), []byte(`"description":"Item 2"`)) {
+		t.Errorf("handler returned unexpected body, want line items: %s", rr.Body.String())
 	}
 }
 
 func TestGetInvoice_NotFound(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-	}
-	defer db.Close()
-
-	oldDB := database.DB
-	database.DB = db
-	defer func() { database.DB = oldDB }()
-
-	mock.ExpectQuery("SELECT id, client_id, issue_date, due_date, status, total FROM invoices WHERE id = ?").
-		WithArgs(999).
-		WillReturnError(sql.ErrNoRows)
+	store, _ := newInvoiceStore(t)
 
 	req, err := http.NewRequest("GET", "/api/invoices/999", nil)
 	if err != nil {
@@ -228,7 +457,7 @@ func TestGetInvoice_NotFound(t *testing.T) {
 	}
 
 	rr := httptest.NewRecorder()
-	handler := &InvoiceHandler{}
+	handler := &InvoiceHandler{Store: store}
 
 	http.HandlerFunc(handler.GetInvoice).ServeHTTP(rr, req)
 
@@ -239,76 +468,305 @@ func TestGetInvoice_NotFound(t *testing.T) {
 }
 
 func TestGetInvoices_Success(t *testing.T) {
-	db, mock, err := sqlmock.New()
+	store, customerID := newInvoiceStore(t)
+	issueDate := time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)
+	seedInvoice(t, store, customerID, "USD", issueDate)
+	paid := seedInvoice(t, store, customerID, "USD", issueDate.AddDate(0, 0, 1))
+
+	req, err := http.NewRequest("GET", "/api/invoices", nil)
 	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		t.Fatal(err)
 	}
-	defer db.Close()
 
-	oldDB := database.DB
-	database.DB = db
-	defer func() { database.DB = oldDB }()
+	rr := httptest.NewRecorder()
+	handler := &InvoiceHandler{Store: store}
 
-	issueDate := time.Now().Truncate(time.Second)
-	dueDate := issueDate.Add(14 * 24 * time.Hour)
+	http.HandlerFunc(handler.GetInvoices).ServeHTTP(rr, req)
 
-	rows := sqlmock.NewRows([]string{"id", "client_id", "issue_date", "due_date", "status", "total"}).
-		AddRow(1, 1, issueDate, dueDate, "draft", 25.0).
-		AddRow(2, 2, issueDate, dueDate, "paid", 100.0)
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	var invoices []models.Invoice
+	if err := json.Unmarshal(rr.Body.Bytes(), &invoices); err != nil {
+		t.Fatal(err)
+	}
+	if len(invoices) != 2 || invoices[0].ID != paid {
+		t.Errorf("handler returned %+v, want two invoices, newest first", invoices)
+	}
+}
 
-	mock.ExpectQuery("SELECT id, client_id, issue_date, due_date, status, total FROM invoices ORDER BY issue_date DESC LIMIT \\? OFFSET \\?").
-		WithArgs(20, 0).
-		WillReturnRows(rows)
+func TestGetInvoices_Pagination(t *testing.T) {
+	store, customerID := newInvoiceStore(t)
+	var ids []int
+	for day := 1; day <= 20; day++ {
+		ids = append(ids, seedInvoice(t, store, customerID, "USD", time.Date(2026, 3, day, 0, 0, 0, 0, time.UTC)))
+	}
 
-	req, err := http.NewRequest("GET", "/api/invoices", nil)
+	req, err := http.NewRequest("GET", "/api/invoices?limit=10&offset=5", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	rr := httptest.NewRecorder()
-	handler := &InvoiceHandler{}
+	handler := &InvoiceHandler{Store: store}
 
 	http.HandlerFunc(handler.GetInvoices).ServeHTTP(rr, req)
 
-	if err := mock.ExpectationsWereMet(); err != nil {
-		t.Errorf("there were unfulfilled expectations: %s", err)
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusOK)
+	}
+
+	var invoices []models.Invoice
+	if err := json.Unmarshal(rr.Body.Bytes(), &invoices); err != nil {
+		t.Fatal(err)
+	}
+	// Newest first: offset 5 skips the invoices of March 20 to 16.
+	if len(invoices) != 10 || invoices[0].ID != ids[14] || invoices[9].ID != ids[5] {
+		t.Errorf("handler returned %d invoices starting with %d, want 10 starting with %d", len(invoices), invoices[0].ID, ids[14])
 	}
 }
 
-func TestGetInvoices_Pagination(t *testing.T) {
-	db, mock, err := sqlmock.New()
+func TestUpdateInvoice_IllegalTransition(t *testing.T) {
+	store, customerID := newInvoiceStore(t)
+	id := seedInvoice(t, store, customerID, "USD", time.Now())
+	if err := store.TransitionInvoiceStatus(id, 0, models.StatusVoid, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("PUT", fmt.Sprintf("/api/invoices/%d", id), bytes.NewBufferString(`{"status": "draft"}`))
 	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		t.Fatal(err)
 	}
-	defer db.Close()
+	req.Header.Set("If-Match", `"2"`)
+
+	rr := httptest.NewRecorder()
+	handler := &InvoiceHandler{Store: store}
 
-	oldDB := database.DB
-	database.DB = db
-	defer func() { database.DB = oldDB }()
+	http.HandlerFunc(handler.UpdateInvoice).ServeHTTP(rr, req)
 
-	rows := sqlmock.NewRows([]string{"id", "client_id", "issue_date", "due_date", "status", "total"}).
-		AddRow(1, 1, time.Now(), time.Now(), "draft", 25.0)
+	if status := rr.Code; status != http.StatusConflict {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusConflict)
+	}
+}
 
-	mock.ExpectQuery("SELECT id, client_id, issue_date, due_date, status, total FROM invoices ORDER BY issue_date DESC LIMIT \\? OFFSET \\?").
-		WithArgs(10, 5).
-		WillReturnRows(rows)
+func TestUpdateInvoice_Send(t *testing.T) {
+	store, customerID := newInvoiceStore(t)
+	id := seedInvoice(t, store, customerID, "USD", time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC))
 
-	req, err := http.NewRequest("GET", "/api/invoices?limit=10&offset=5", nil)
+	req, err := http.NewRequest("PUT", fmt.Sprintf("/api/invoices/%d", id), bytes.NewBufferString(`{"status": "sent"}`))
 	if err != nil {
 		t.Fatal(err)
 	}
+	req.Header.Set("If-Match", `"1"`)
 
 	rr := httptest.NewRecorder()
-	handler := &InvoiceHandler{}
+	handler := &InvoiceHandler{Store: store}
 
-	http.HandlerFunc(handler.GetInvoices).ServeHTTP(rr, req)
+	http.HandlerFunc(handler.UpdateInvoice).ServeHTTP(rr, req)
 
 	if status := rr.Code; status != http.StatusOK {
 		t.Errorf("handler returned wrong status code: got %v want %v",
 			status, http.StatusOK)
 	}
 
-	if err := mock.ExpectationsWereMet(); err != nil {
-		t.Errorf("there were unfulfilled expectations: %s", err)
+	// Sending takes the next number of the invoice sequence for the issue year, and
+	// an invoice in the base currency is snapshotted at a rate of one.
+	invoice, err := store.GetInvoiceByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invoice.Number != "INV-2026-00001" || invoice.SentAt == nil || invoice.ExchangeRate != models.OneRate {
+		t.Errorf("sent invoice = %+v, want number INV-2026-00001 at a rate of one", invoice)
+	}
+
+	// The response is the sent invoice with its new ETag, ready for the next change.
+	var body models.Invoice
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body.Number != "INV-2026-00001" {
+		t.Errorf("response = %s", rr.Body.String())
+	}
+	if etag := rr.Header().Get("ETag"); etag != `"2"` {
+		t.Errorf("ETag = %s, want \"2\"", etag)
+	}
+}
+
+func TestUpdateInvoice_SendNumberTaken(t *testing.T) {
+	store, customerID := newInvoiceStore(t)
+	issued := time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)
+	first := seedInvoice(t, store, customerID, "USD", issued)
+	second := seedInvoice(t, store, customerID, "USD", issued)
+	if err := store.TransitionInvoiceStatus(first, 0, models.StatusSent, issued); err != nil {
+		t.Fatal(err)
+	}
+	// A format that keeps producing the first invoice's number.
+	sequence := models.NumberSequence{Name: models.SequenceInvoice, Format: "INV-{YYYY}-00001", Reset: models.ResetYearly}
+	if err := store.UpdateNumberSequence(&sequence, issued); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("PUT", fmt.Sprintf("/api/invoices/%d", second), bytes.NewBufferString(`{"status": "sent"}`))
+	req.Header.Set("If-Match", `"1"`)
+	rr := httptest.NewRecorder()
+	handler := &InvoiceHandler{Store: store}
+	http.HandlerFunc(handler.UpdateInvoice).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusConflict {
+		t.Errorf("handler returned wrong status code: got %v want %v: %s", status, http.StatusConflict, rr.Body.String())
+	}
+	if invoice, err := store.GetInvoiceByID(second); err != nil || invoice.Status != models.StatusDraft {
+		t.Errorf("after a number clash: %+v, %v; want a draft", invoice, err)
+	}
+}
+
+func TestUpdateInvoice_EditDraft(t *testing.T) {
+	store, customerID := newInvoiceStore(t)
+	id := seedInvoice(t, store, customerID, "USD", time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC))
+
+	// Item 2 moves first with a new quantity, Item 1 goes and Item 3 is added.
+	reqBody := fmt.Sprintf(`{
+		"customer_id": %d,
+		"issue_date": "2026-03-15T00:00:00Z",
+		"due_date": "2026-03-29T00:00:00Z",
+		"notes": "Payable within 14 days.",
+		"line_items": [
+			{"description": "Item 2", "quantity": 3, "unit_price": 5.00},
+			{"description": "Item 3", "quantity": 1, "unit_price": 2.50}
+		]
+	}`, customerID)
+	req, err := http.NewRequest("PUT", fmt.Sprintf("/api/invoices/%d", id), bytes.NewBufferString(reqBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("If-Match", `"1"`)
+
+	rr := httptest.NewRecorder()
+	handler := &InvoiceHandler{Store: store}
+
+	http.HandlerFunc(handler.UpdateInvoice).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s",
+			status, http.StatusOK, rr.Body)
+	}
+
+	var invoice models.Invoice
+	if err := json.Unmarshal(rr.Body.Bytes(), &invoice); err != nil {
+		t.Fatal(err)
+	}
+	if invoice.ID != id || invoice.Total != models.NewMoney(1750, "USD") || invoice.Notes != "Payable within 14 days." || invoice.Status != models.StatusDraft {
+		t.Errorf("edited invoice = %+v, want draft %d totalling 17.50 with notes", invoice, id)
+	}
+	if len(invoice.LineItems) != 2 || invoice.LineItems[0].Description != "Item 2" || invoice.LineItems[1].Description != "Item 3" {
+		t.Errorf("edited line items = %+v, want Item 2 then Item 3", invoice.LineItems)
+	}
+	if invoice.Version != 2 || rr.Header().Get("ETag") != `"2"` {
+		t.Errorf("edited invoice at version %d with ETag %s, want 2", invoice.Version, rr.Header().Get("ETag"))
+	}
+}
+
+func TestUpdateInvoice_EditErrors(t *testing.T) {
+	store, customerID := newInvoiceStore(t)
+	draft := seedInvoice(t, store, customerID, "USD", time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC))
+	sent := seedInvoice(t, store, customerID, "USD", time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC))
+	if err := store.TransitionInvoiceStatus(sent, 0, models.StatusSent, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	edit := func(customerID int, status string) string {
+		return fmt.Sprintf(`{
+			"customer_id": %d,
+			"issue_date": "2026-03-15T00:00:00Z",
+			"due_date": "2026-03-29T00:00:00Z",
+			"status": %q,
+			"line_items": [{"description": "Item 1", "quantity": 1, "unit_price": 10.0}]
+		}`, customerID, status)
+	}
+	tests := []struct {
+		name    string
+		id      int
+		ifMatch string
+		body    string
+		want    int
+	}{
+		{"sent invoice", sent, "*", edit(customerID, ""), http.StatusConflict},
+		{"unknown invoice", sent + 100, "*", edit(customerID, ""), http.StatusNotFound},
+		{"unknown customer", draft, `"1"`, edit(customerID+100, ""), http.StatusBadRequest},
+		{"status change", draft, `"1"`, edit(customerID, "sent"), http.StatusBadRequest},
+		{"missing line items", draft, `"1"`, `{"customer_id": 1, "notes": "x"}`, http.StatusBadRequest},
+		{"stale version", sent, `"1"`, edit(customerID, ""), http.StatusPreconditionFailed},
+		{"weak entity tag", draft, `W/"1"`, edit(customerID, ""), http.StatusPreconditionFailed},
+		{"no If-Match", draft, "", edit(customerID, ""), http.StatusPreconditionRequired},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest("PUT", fmt.Sprintf("/api/invoices/%d", tt.id), bytes.NewBufferString(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.ifMatch != "" {
+			req.Header.Set("If-Match", tt.ifMatch)
+		}
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc((&InvoiceHandler{Store: store}).UpdateInvoice).ServeHTTP(rr, req)
+
+		if rr.Code != tt.want {
+			t.Errorf("%s: got status %d, want %d: %s", tt.name, rr.Code, tt.want, rr.Body)
+		}
+	}
+
+	invoice, err := store.GetInvoiceByID(sent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invoice.LineItems) != 2 || invoice.Total != models.NewMoney(2500, "USD") {
+		t.Errorf("sent invoice changed: %+v", invoice)
+	}
+}
+
+func TestCreateInvoice_RejectsNonDraftStatus(t *testing.T) {
+	handler := &InvoiceHandler{Store: &MockInvoiceStore{}}
+
+	reqBody := []byte(`{
+		"customer_id": 1,
+		"issue_date": "2025-12-31T00:00:00Z",
+		"due_date": "2026-01-14T00:00:00Z",
+		"status": "paid",
+		"line_items": [{"description": "Item 1", "quantity": 1, "unit_price": 10.0}]
+	}`)
+	req, err := http.NewRequest("POST", "/invoices", bytes.NewBuffer(reqBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handler.CreateInvoice).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusBadRequest)
+	}
+}
+
+func TestCreateInvoice_DiscountTooLarge(t *testing.T) {
+	handler := &InvoiceHandler{Store: &MockInvoiceStore{}}
+
+	reqBody := []byte(`{
+		"customer_id": 1,
+		"issue_date": "2025-12-31T00:00:00Z",
+		"due_date": "2026-01-14T00:00:00Z",
+		"discount_amount": 50.00,
+		"line_items": [{"description": "Item", "quantity": 2, "unit_price": 10.00, "discount_percent": 10}]
+	}`)
+	req := httptest.NewRequest("POST", "/api/invoices", bytes.NewBuffer(reqBody))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handler.CreateInvoice).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+	expected := "{\"error\":\"invalid discount: invoice discount exceeds the amount it applies to\"}\n"
+	if rr.Body.String() != expected {
+		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
 	}
 }
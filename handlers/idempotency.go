@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"tiny-invoicing/auth"
+	"tiny-invoicing/database"
+	"tiny-invoicing/response"
+)
+
+// IdempotencyStore defines the interface for remembering requests made with an
+// Idempotency-Key header.
+type IdempotencyStore interface {
+	ReserveIdempotencyKey(record *database.IdempotencyRecord, expiredBefore time.Time) (*database.IdempotencyRecord, error)
+	SaveIdempotentResponse(record *database.IdempotencyRecord) error
+	ReleaseIdempotencyKey(scope, key string) error
+}
+
+// DefaultIdempotencyTTL is how long idempotency keys are remembered by default.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// Idempotency makes requests safe to retry. The first request with a given
+// Idempotency-Key header is handled and its response stored with a hash of the
+// request body; retries with the same key and body get the stored response back.
+// Keys are separate per user and endpoint.
+type Idempotency struct {
+	Store IdempotencyStore
+	// TTL is how long a key is remembered; DefaultIdempotencyTTL if zero.
+	TTL time.Duration
+}
+
+// Wrap returns next guarded by idempotency keys. Requests without the header are
+// passed straight through. Reusing a key for a different body is rejected with 422
+// Unprocessable Entity, and retrying while the first request is still running with
+// 409 Conflict. Server errors are not stored, so such requests can be retried.
+// Replayed responses keep their ETag, so clients learn the version to edit.
+func (i *Idempotency) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > 255 {
+			response.Error(w, http.StatusBadRequest, "Idempotency-Key must be at most 255 characters")
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		hash := sha256.Sum256(body)
+
+		record := &database.IdempotencyRecord{
+			Scope:       idempotencyScope(r),
+			Key:         key,
+			RequestHash: hex.EncodeToString(hash[:]),
+			CreatedAt:   time.Now(),
+		}
+		ttl := i.TTL
+		if ttl <= 0 {
+			ttl = DefaultIdempotencyTTL
+		}
+		existing, err := i.Store.ReserveIdempotencyKey(record, record.CreatedAt.Add(-ttl))
+		if err != nil {
+			log.Printf("Error reserving idempotency key: %v", err)
+			response.Error(w, http.StatusInternalServerError, "Failed to process request")
+			return
+		}
+		switch {
+		case existing == nil:
+		case existing.RequestHash != record.RequestHash:
+			response.Error(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+			return
+		case existing.StatusCode == 0:
+			response.Error(w, http.StatusConflict, "A request with this Idempotency-Key is still being processed")
+			return
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			if existing.ETag != "" {
+				w.Header().Set("ETag", existing.ETag)
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(existing.StatusCode)
+			w.Write(existing.ResponseBody)
+			return
+		}
+
+		// Give the key back if the request fails before it is answered.
+		saved := false
+		defer func() {
+			if !saved {
+				if err := i.Store.ReleaseIdempotencyKey(record.Scope, record.Key); err != nil {
+					log.Printf("Error releasing idempotency key: %v", err)
+				}
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(recorder, r)
+		if recorder.status >= 500 {
+			return
+		}
+		record.StatusCode, record.ResponseBody = recorder.status, recorder.body.Bytes()
+		record.ETag = recorder.Header().Get("ETag")
+		if err := i.Store.SaveIdempotentResponse(record); err != nil {
+			log.Printf("Error saving idempotent response: %v", err)
+			return
+		}
+		saved = true
+	}
+}
+
+// idempotencyScope keeps the keys of different users and endpoints apart.
+func idempotencyScope(r *http.Request) string {
+	scope := r.Method + " " + r.URL.Path
+	if user := auth.CurrentUser(r); user != nil {
+		scope = "user " + strconv.Itoa(user.ID) + " " + scope
+	}
+	return scope
+}
+
+// responseRecorder passes a response through while keeping a copy of its status and body.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"tiny-invoicing/database"
+)
+
+func TestIdempotency_CreateInvoice(t *testing.T) {
+	store, customerID := newInvoiceStore(t)
+	create := (&Idempotency{Store: store}).Wrap((&InvoiceHandler{Store: store}).CreateInvoice)
+	body := fmt.Sprintf(`{"customer_id": %d, "issue_date": "2026-03-14T00:00:00Z", "due_date": "2026-03-28T00:00:00Z",
+		"line_items": [{"description": "Item 1", "quantity": 1, "unit_price": 10.0}]}`, customerID)
+
+	post := func(key, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/api/invoices", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		rr := httptest.NewRecorder()
+		create(rr, req)
+		return rr
+	}
+	count := func() int {
+		t.Helper()
+		invoices, err := store.GetInvoices(100, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(invoices)
+	}
+
+	first := post("order-1", body)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("first request: got status %d want %d: %s", first.Code, http.StatusCreated, first.Body)
+	}
+
+	// A retry gets the same response back and creates nothing.
+	retry := post("order-1", body)
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() || retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("retry: got %d %q, want the first response replayed", retry.Code, retry.Body)
+	}
+	if etag := retry.Header().Get("ETag"); etag != `"1"` || etag != first.Header().Get("ETag") {
+		t.Errorf("retry ETag = %s, want the first response's %s", etag, first.Header().Get("ETag"))
+	}
+	if n := count(); n != 1 {
+		t.Errorf("%d invoices after a retry, want 1", n)
+	}
+
+	// The same key with another body is refused; requests without a key are not deduplicated.
+	if rr := post("order-1", strings.Replace(body, "Item 1", "Item 2", 1)); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("reused key: got status %d want %d", rr.Code, http.StatusUnprocessableEntity)
+	}
+	post("", body)
+	post("", body)
+	if n := count(); n != 3 {
+		t.Errorf("%d invoices after two requests without a key, want 3", n)
+	}
+
+	// Client errors are replayed too.
+	if rr := post("order-2", `{"customer_id": 0}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("invalid request: got status %d want %d", rr.Code, http.StatusBadRequest)
+	}
+	if rr := post("order-2", `{"customer_id": 0}`); rr.Code != http.StatusBadRequest || rr.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("retried invalid request: got status %d, replayed %q", rr.Code, rr.Header().Get("Idempotent-Replayed"))
+	}
+}
+
+func TestIdempotency_PendingAndExpired(t *testing.T) {
+	store, customerID := newInvoiceStore(t)
+	create := (&Idempotency{Store: store, TTL: time.Hour}).Wrap((&InvoiceHandler{Store: store}).CreateInvoice)
+	body := fmt.Sprintf(`{"customer_id": %d, "issue_date": "2026-03-14T00:00:00Z", "due_date": "2026-03-28T00:00:00Z",
+		"line_items": [{"description": "Item 1", "quantity": 1, "unit_price": 10.0}]}`, customerID)
+
+	post := func(key string) int {
+		req := httptest.NewRequest("POST", "/api/invoices", strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", key)
+		rr := httptest.NewRecorder()
+		create(rr, req)
+		return rr.Code
+	}
+
+	// A request that is still running holds its key.
+	req := httptest.NewRequest("POST", "/api/invoices", nil)
+	hash := sha256.Sum256([]byte(body))
+	_, err := store.ReserveIdempotencyKey(&database.IdempotencyRecord{Scope: idempotencyScope(req), Key: "running", RequestHash: hex.EncodeToString(hash[:]), CreatedAt: time.Now()}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := post("running"); code != http.StatusConflict {
+		t.Errorf("retry while running: got status %d want %d", code, http.StatusConflict)
+	}
+
+	// Keys older than the TTL are forgotten.
+	_, err = store.ReserveIdempotencyKey(&database.IdempotencyRecord{Scope: idempotencyScope(req), Key: "old", RequestHash: "x", CreatedAt: time.Now().Add(-2 * time.Hour)}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := post("old"); code != http.StatusCreated {
+		t.Errorf("expired key: got status %d want %d", code, http.StatusCreated)
+	}
+}
+
+func TestIdempotency_ServerErrorsAreRetried(t *testing.T) {
+	store, _ := newInvoiceStore(t)
+	calls := 0
+	handler := (&Idempotency{Store: store}).Wrap(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			http.Error(w, "database down", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	for _, want := range []int{http.StatusInternalServerError, http.StatusCreated, http.StatusCreated} {
+		req := httptest.NewRequest("POST", "/api/invoices", strings.NewReader("{}"))
+		req.Header.Set("Idempotency-Key", "retry-me")
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		if rr.Code != want {
+			t.Errorf("got status %d want %d", rr.Code, want)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("handler called %d times, want 2", calls)
+	}
+}
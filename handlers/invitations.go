@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"tiny-invoicing/auth"
+	"tiny-invoicing/database"
+	"tiny-invoicing/models"
+	"tiny-invoicing/response"
+)
+
+// DefaultInvitationTTL is how long invitation links work by default.
+const DefaultInvitationTTL = 7 * 24 * time.Hour
+
+// InvitationStore defines the interface for invitations.
+type InvitationStore interface {
+	CreateInvitation(invitation *database.Invitation) (int64, error)
+	GetInvitations(now time.Time) ([]database.Invitation, error)
+	GetInvitation(id int) (*database.Invitation, error)
+	GetInvitationByToken(tokenHash string, now time.Time) (*database.Invitation, error)
+	DeleteInvitation(id int) error
+	AcceptInvitation(tokenHash string, user *database.User, now time.Time) (int64, error)
+}
+
+// InvitationHandler handles invitations to sign up with a role.
+type InvitationHandler struct {
+	Store InvitationStore
+	// TTL is how long an invitation link works; DefaultInvitationTTL if zero.
+	TTL time.Duration
+}
+
+// createdInvitation is an invitation as returned when created: the only time its
+// link is shown.
+type createdInvitation struct {
+	database.Invitation
+	Token string `json:"token"`
+	// Link is the page the invitee signs up on, relative to the server's address.
+	Link string `json:"link"`
+}
+
+// CreateInvitation invites someone to sign up with a role. Admins invite admins,
+// accountants and viewers; only owners invite owners.
+func (h *InvitationHandler) CreateInvitation(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	role, err := models.ParseRole(req.Role)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	user := auth.CurrentUser(r)
+	if !user.Role.CanAssign(role) {
+		response.Error(w, http.StatusForbidden, "Only owners can manage owners")
+		return
+	}
+
+	token, err := auth.NewToken()
+	if err != nil {
+		log.Printf("Error generating invitation token: %v", err)
+		response.Error(w, http.StatusInternalServerError, "Failed to create invitation")
+		return
+	}
+	ttl := h.TTL
+	if ttl <= 0 {
+		ttl = DefaultInvitationTTL
+	}
+	now := time.Now()
+	invitation := database.Invitation{
+		TokenHash: auth.HashToken(token),
+		Role:      role,
+		CreatedBy: user.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	id, err := h.Store.CreateInvitation(&invitation)
+	if err != nil {
+		log.Printf("Error creating invitation in DB: %v", err)
+		response.Error(w, http.StatusInternalServerError, "Failed to create invitation")
+		return
+	}
+	invitation.ID = int(id)
+	response.JSON(w, http.StatusCreated, createdInvitation{
+		Invitation: invitation,
+		Token:      token,
+		Link:       "/?invitation=" + url.QueryEscape(token),
+	})
+}
+
+// GetInvitations lists the invitations that are still open.
+func (h *InvitationHandler) GetInvitations(w http.ResponseWriter, r *http.Request) {
+	invitations, err := h.Store.GetInvitations(time.Now())
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to retrieve invitations")
+		return
+	}
+	if invitations == nil {
+		invitations = []database.Invitation{}
+	}
+	response.JSON(w, http.StatusOK, invitations)
+}
+
+// RevokeInvitation withdraws an invitation that has not been accepted. As when
+// inviting, only owners revoke invitations to be an owner.
+func (h *InvitationHandler) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Path[len("/api/invitations/"):])
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid invitation ID")
+		return
+	}
+
+	invitation, err := h.Store.GetInvitation(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			response.Error(w, http.StatusNotFound, "Invitation not found")
+		} else {
+			log.Printf("Error loading invitation from DB: %v", err)
+			response.Error(w, http.StatusInternalServerError, "Failed to revoke invitation")
+		}
+		return
+	}
+	if !auth.CurrentUser(r).Role.CanAssign(invitation.Role) {
+		response.Error(w, http.StatusForbidden, "Only owners can manage owners")
+		return
+	}
+
+	if err := h.Store.DeleteInvitation(id); err != nil {
+		if err == sql.ErrNoRows {
+			response.Error(w, http.StatusNotFound, "Invitation not found")
+		} else {
+			log.Printf("Error deleting invitation in DB: %v", err)
+			response.Error(w, http.StatusInternalServerError, "Failed to revoke invitation")
+		}
+		return
+	}
+	response.JSON(w, http.StatusOK, map[string]string{"message": "Invitation revoked"})
+}
+
+// AcceptInvitation signs up with an invitation's token, a username and a password.
+// The new user gets the invitation's role, and the link stops working.
+func (h *InvitationHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		newAccount
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if req.Token == "" {
+		response.Error(w, http.StatusBadRequest, "Invitation token is required")
+		return
+	}
+	// Check the token before the costly password hash, so that bad tokens are cheap
+	// to turn away. AcceptInvitation checks it again as it uses it.
+	tokenHash := auth.HashToken(req.Token)
+	if _, err := h.Store.GetInvitationByToken(tokenHash, time.Now()); err != nil {
+		if err == sql.ErrNoRows {
+			response.Error(w, http.StatusNotFound, "Invitation is invalid, expired or already used")
+		} else {
+			log.Printf("Error loading invitation from DB: %v", err)
+			response.Error(w, http.StatusInternalServerError, "Failed to accept invitation")
+		}
+		return
+	}
+	// The role is filled in from the invitation.
+	user, ok := req.user(w, "")
+	if !ok {
+		return
+	}
+
+	id, err := h.Store.AcceptInvitation(tokenHash, user, time.Now())
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			response.Error(w, http.StatusNotFound, "Invitation is invalid, expired or already used")
+		case database.ErrUsernameTaken:
+			response.Error(w, http.StatusConflict, "Username already taken")
+		default:
+			log.Printf("Error accepting invitation in DB: %v", err)
+			response.Error(w, http.StatusInternalServerError, "Failed to accept invitation")
+		}
+		return
+	}
+	user.ID = int(id)
+	response.JSON(w, http.StatusCreated, user)
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"tiny-invoicing/auth"
+	"tiny-invoicing/database"
+	"tiny-invoicing/database/memstore"
+	"tiny-invoicing/models"
+)
+
+func TestInvitations(t *testing.T) {
+	store := memstore.New()
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for username, role := range map[string]models.Role{"owner": models.RoleOwner, "admin": models.RoleAdmin, "bookkeeper": models.RoleAccountant} {
+		if _, err := store.CreateUser(&database.User{Username: username, PasswordHash: string(hash), Role: role}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	invitations := &InvitationHandler{Store: store}
+	do := func(method, path, username, body string, want int, into interface{}) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		if path == "/api/invitations/accept" {
+			invitations.AcceptInvitation(rr, req)
+		} else {
+			req.SetBasicAuth(username, "secret")
+			auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+				switch method {
+				case "GET":
+					auth.Require(models.PermissionManageUsers, invitations.GetInvitations)(w, r)
+				case "POST":
+					auth.Require(models.PermissionManageUsers, invitations.CreateInvitation)(w, r)
+				default:
+					auth.Require(models.PermissionManageUsers, invitations.RevokeInvitation)(w, r)
+				}
+			})(rr, req)
+		}
+		if rr.Code != want {
+			t.Fatalf("%s %s: got status %d want %d: %s", method, path, rr.Code, want, rr.Body.String())
+		}
+		if into != nil {
+			if err := json.NewDecoder(rr.Body).Decode(into); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	do("POST", "/api/invitations", "bookkeeper", `{"role": "viewer"}`, http.StatusForbidden, nil)
+	do("POST", "/api/invitations", "admin", `{"role": "root"}`, http.StatusBadRequest, nil)
+	do("POST", "/api/invitations", "admin", `{"role": "owner"}`, http.StatusForbidden, nil)
+
+	var created createdInvitation
+	do("POST", "/api/invitations", "admin", `{"role": "accountant"}`, http.StatusCreated, &created)
+	if created.Token == "" || created.Link != "/?invitation="+created.Token || created.Role != models.RoleAccountant {
+		t.Errorf("created invitation = %+v", created)
+	}
+	if d := created.ExpiresAt.Sub(created.CreatedAt); d != DefaultInvitationTTL {
+		t.Errorf("invitation expires after %v, want %v", d, DefaultInvitationTTL)
+	}
+	var listed []map[string]interface{}
+	do("GET", "/api/invitations", "admin", "", http.StatusOK, &listed)
+	if len(listed) != 1 || listed[0]["role"] != "accountant" || listed[0]["token"] != nil {
+		t.Errorf("GET /api/invitations = %+v", listed)
+	}
+
+	accept := func(token, username string) string {
+		return fmt.Sprintf(`{"token": %q, "username": %q, "password": "pw"}`, token, username)
+	}
+	// Bad tokens are turned away before the password is hashed, which would fail here.
+	auth.PasswordCost = bcrypt.MaxCost + 1
+	do("POST", "/api/invitations/accept", "", accept("wrong", "carol"), http.StatusNotFound, nil)
+	auth.PasswordCost = bcrypt.MinCost
+	do("POST", "/api/invitations/accept", "", accept(created.Token, "admin"), http.StatusConflict, nil)
+	var user database.User
+	do("POST", "/api/invitations/accept", "", accept(created.Token, "carol"), http.StatusCreated, &user)
+	if user.Username != "carol" || user.Role != models.RoleAccountant {
+		t.Errorf("accepted invitation created %+v", user)
+	}
+	// Links work once, and accepted invitations are no longer listed or revocable.
+	do("POST", "/api/invitations/accept", "", accept(created.Token, "dave"), http.StatusNotFound, nil)
+	do("GET", "/api/invitations", "admin", "", http.StatusOK, &listed)
+	if len(listed) != 0 {
+		t.Errorf("GET /api/invitations after acceptance = %+v, want none", listed)
+	}
+	do("DELETE", fmt.Sprintf("/api/invitations/%d", created.ID), "admin", "", http.StatusNotFound, nil)
+
+	do("POST", "/api/invitations", "admin", `{"role": "viewer"}`, http.StatusCreated, &created)
+	do("DELETE", fmt.Sprintf("/api/invitations/%d", created.ID), "admin", "", http.StatusOK, nil)
+	do("POST", "/api/invitations/accept", "", accept(created.Token, "erin"), http.StatusNotFound, nil)
+
+	// Only owners revoke invitations to be an owner.
+	do("POST", "/api/invitations", "owner", `{"role": "owner"}`, http.StatusCreated, &created)
+	do("DELETE", fmt.Sprintf("/api/invitations/%d", created.ID), "admin", "", http.StatusForbidden, nil)
+	do("GET", "/api/invitations", "admin", "", http.StatusOK, &listed)
+	if len(listed) != 1 || listed[0]["role"] != "owner" {
+		t.Errorf("GET /api/invitations after a refused revocation = %+v, want the owner invitation", listed)
+	}
+	do("DELETE", fmt.Sprintf("/api/invitations/%d", created.ID), "owner", "", http.StatusOK, nil)
+	do("DELETE", "/api/invitations/999", "admin", "", http.StatusNotFound, nil)
+
+	invitations.TTL = time.Nanosecond
+	do("POST", "/api/invitations", "admin", `{"role": "viewer"}`, http.StatusCreated, &created)
+	time.Sleep(time.Millisecond)
+	do("POST", "/api/invitations/accept", "", accept(created.Token, "erin"), http.StatusNotFound, nil)
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"tiny-invoicing/models"
+	"tiny-invoicing/response"
+)
+
+// NumberSequenceStore defines the interface for numbering sequence persistence.
+type NumberSequenceStore interface {
+	GetNumberSequences() ([]models.NumberSequence, error)
+	UpdateNumberSequence(sequence *models.NumberSequence, now time.Time) error
+}
+
+// NumberSequenceHandler handles numbering sequence configuration requests.
+type NumberSequenceHandler struct {
+	Store NumberSequenceStore
+}
+
+// GetNumberSequences lists the numbering sequences.
+func (h *NumberSequenceHandler) GetNumberSequences(w http.ResponseWriter, r *http.Request) {
+	sequences, err := h.Store.GetNumberSequences()
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to retrieve number sequences")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, sequences)
+}
+
+// UpdateNumberSequence changes the format and reset rule of a sequence. The new
+// format applies to documents finalised from now on; the counter is not reset, and
+// carries on when the reset rule changes.
+func (h *NumberSequenceHandler) UpdateNumberSequence(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Path[len("/api/number-sequences/"):]
+
+	var sequence models.NumberSequence
+	if err := json.NewDecoder(r.Body).Decode(&sequence); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	sequence.Name = name
+
+	if err := sequence.Validate(); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid number sequence: "+err.Error())
+		return
+	}
+
+	if err := h.Store.UpdateNumberSequence(&sequence, time.Now()); err != nil {
+		if err == sql.ErrNoRows {
+			response.Error(w, http.StatusNotFound, "Number sequence not found")
+		} else {
+			log.Printf("Error updating number sequence in DB: %v", err)
+			response.Error(w, http.StatusInternalServerError, "Failed to update number sequence")
+		}
+		return
+	}
+
+	response.JSON(w, http.StatusOK, sequence)
+}
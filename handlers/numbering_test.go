@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"tiny-invoicing/models"
+)
+
+// MockNumberSequenceStore is a mock implementation of NumberSequenceStore.
+type MockNumberSequenceStore struct {
+	GetNumberSequencesFunc   func() ([]models.NumberSequence, error)
+	UpdateNumberSequenceFunc func(sequence *models.NumberSequence) error
+}
+
+func (m *MockNumberSequenceStore) GetNumberSequences() ([]models.NumberSequence, error) {
+	if m.GetNumberSequencesFunc != nil {
+		return m.GetNumberSequencesFunc()
+	}
+	return nil, nil
+}
+
+func (m *MockNumberSequenceStore) UpdateNumberSequence(sequence *models.NumberSequence, now time.Time) error {
+	if m.UpdateNumberSequenceFunc != nil {
+		return m.UpdateNumberSequenceFunc(sequence)
+	}
+	return nil
+}
+
+func TestUpdateNumberSequence_Success(t *testing.T) {
+	var updated models.NumberSequence
+	handler := &NumberSequenceHandler{Store: &MockNumberSequenceStore{
+		UpdateNumberSequenceFunc: func(sequence *models.NumberSequence) error {
+			updated = *sequence
+			return nil
+		},
+	}}
+
+	reqBody := []byte(`{"format": "{YY}{MM}-{seq:4}", "reset": "yearly"}`)
+	req := httptest.NewRequest("PUT", "/api/number-sequences/invoice", bytes.NewBuffer(reqBody))
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(handler.UpdateNumberSequence).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if updated.Name != "invoice" || updated.Format != "{YY}{MM}-{seq:4}" || updated.Reset != models.ResetYearly {
+		t.Errorf("stored sequence = %+v", updated)
+	}
+}
+
+func TestUpdateNumberSequence_InvalidFormat(t *testing.T) {
+	handler := &NumberSequenceHandler{Store: &MockNumberSequenceStore{
+		UpdateNumberSequenceFunc: func(sequence *models.NumberSequence) error {
+			t.Error("invalid sequence should not be stored")
+			return nil
+		},
+	}}
+
+	// Without the year, a yearly sequence would repeat its numbers.
+	reqBody := []byte(`{"format": "INV-{seq:05}", "reset": "yearly"}`)
+	req := httptest.NewRequest("PUT", "/api/number-sequences/invoice", bytes.NewBuffer(reqBody))
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(handler.UpdateNumberSequence).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestUpdateNumberSequence_NotFound(t *testing.T) {
+	handler := &NumberSequenceHandler{Store: &MockNumberSequenceStore{
+		UpdateNumberSequenceFunc: func(sequence *models.NumberSequence) error {
+			return sql.ErrNoRows
+		},
+	}}
+
+	reqBody := []byte(`{"format": "Q-{seq}", "reset": "never"}`)
+	req := httptest.NewRequest("PUT", "/api/number-sequences/quote", bytes.NewBuffer(reqBody))
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(handler.UpdateNumberSequence).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"tiny-invoicing/database"
+	"tiny-invoicing/models"
+	"tiny-invoicing/response"
+)
+
+// PaymentStore defines the interface for the payment ledger.
+type PaymentStore interface {
+	GetPayments(invoiceID int) ([]models.Payment, error)
+	InvoiceCurrency(invoiceID int) (string, error)
+	RecordPayment(payment *models.Payment, version int) (int64, int, error)
+	ReversePayment(invoiceID, paymentID, version int) (*models.Payment, int, error)
+}
+
+// PaymentHandler handles requests under /api/invoices/{id}/payments.
+type PaymentHandler struct {
+	Store PaymentStore
+}
+
+// GetPayments lists the payments and refunds recorded against an invoice.
+func (h *PaymentHandler) GetPayments(w http.ResponseWriter, r *http.Request) {
+	invoiceID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid invoice ID")
+		return
+	}
+
+	payments, err := h.Store.GetPayments(invoiceID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			response.Error(w, http.StatusNotFound, "Invoice not found")
+		} else {
+			response.Error(w, http.StatusInternalServerError, "Failed to retrieve payments")
+		}
+		return
+	}
+
+	response.JSON(w, http.StatusOK, payments)
+}
+
+// RecordPayment adds a payment or refund to an invoice. An If-Match header, if given,
+// must carry the invoice's current ETag; the response carries the invoice's new one.
+func (h *PaymentHandler) RecordPayment(w http.ResponseWriter, r *http.Request) {
+	invoiceID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid invoice ID")
+		return
+	}
+	version, ok := optionalIfMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	// Amounts are read in the invoice's currency.
+	currency, err := h.Store.InvoiceCurrency(invoiceID)
+	if err != nil {
+		writePaymentError(w, err, "Failed to record payment")
+		return
+	}
+
+	payment := models.Payment{Amount: models.NewMoney(0, currency)}
+	if err := json.NewDecoder(r.Body).Decode(&payment); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	payment.ID = 0
+	payment.InvoiceID = invoiceID
+	payment.ReversedAt = nil
+	if payment.Date.IsZero() {
+		payment.Date = time.Now().UTC().Truncate(24 * time.Hour)
+	}
+
+	if err := payment.Validate(); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	paymentID, version, err := h.Store.RecordPayment(&payment, version)
+	if err != nil {
+		writePaymentError(w, err, "Failed to record payment")
+		return
+	}
+
+	payment.ID = int(paymentID)
+	w.Header().Set("ETag", invoiceETag(version))
+	response.JSON(w, http.StatusCreated, payment)
+}
+
+// ReversePayment cancels a payment or refund, e.g. after a bounced transfer. It takes
+// If-Match and returns the invoice's new ETag like RecordPayment.
+func (h *PaymentHandler) ReversePayment(w http.ResponseWriter, r *http.Request) {
+	invoiceID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid invoice ID")
+		return
+	}
+	paymentID, err := strconv.Atoi(r.PathValue("paymentID"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid payment ID")
+		return
+	}
+	version, ok := optionalIfMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	payment, version, err := h.Store.ReversePayment(invoiceID, paymentID, version)
+	if err != nil {
+		writePaymentError(w, err, "Failed to reverse payment")
+		return
+	}
+
+	w.Header().Set("ETag", invoiceETag(version))
+	response.JSON(w, http.StatusOK, *payment)
+}
+
+// writePaymentError maps ledger errors to HTTP responses.
+func writePaymentError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case err == sql.ErrNoRows:
+		response.Error(w, http.StatusNotFound, "Invoice or payment not found")
+	case errors.Is(err, database.ErrVersionMismatch):
+		response.Error(w, http.StatusPreconditionFailed, err.Error())
+	case errors.Is(err, models.ErrNotPayable), errors.Is(err, models.ErrAlreadyReversed):
+		response.Error(w, http.StatusConflict, err.Error())
+	case errors.Is(err, models.ErrOverpayment), errors.Is(err, models.ErrRefundExceedsPaid), errors.Is(err, models.ErrInvalidPayment):
+		response.Error(w, http.StatusBadRequest, err.Error())
+	default:
+		log.Printf("Error in payment ledger: %v", err)
+		response.Error(w, http.StatusInternalServerError, fallback)
+	}
+}
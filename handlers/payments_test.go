@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"tiny-invoicing/database"
+	"tiny-invoicing/models"
+)
+
+// MockPaymentStore is a mock implementation of PaymentStore.
+type MockPaymentStore struct {
+	GetPaymentsFunc    func(invoiceID int) ([]models.Payment, error)
+	CurrencyFunc       func(invoiceID int) (string, error)
+	RecordPaymentFunc  func(payment *models.Payment, version int) (int64, int, error)
+	ReversePaymentFunc func(invoiceID, paymentID, version int) (*models.Payment, int, error)
+}
+
+func (m *MockPaymentStore) GetPayments(invoiceID int) ([]models.Payment, error) {
+	if m.GetPaymentsFunc != nil {
+		return m.GetPaymentsFunc(invoiceID)
+	}
+	return nil, nil
+}
+
+func (m *MockPaymentStore) InvoiceCurrency(invoiceID int) (string, error) {
+	if m.CurrencyFunc != nil {
+		return m.CurrencyFunc(invoiceID)
+	}
+	return "USD", nil
+}
+
+func (m *MockPaymentStore) RecordPayment(payment *models.Payment, version int) (int64, int, error) {
+	if m.RecordPaymentFunc != nil {
+		return m.RecordPaymentFunc(payment, version)
+	}
+	return 0, 0, nil
+}
+
+func (m *MockPaymentStore) ReversePayment(invoiceID, paymentID, version int) (*models.Payment, int, error) {
+	if m.ReversePaymentFunc != nil {
+		return m.ReversePaymentFunc(invoiceID, paymentID, version)
+	}
+	return nil, 0, sql.ErrNoRows
+}
+
+func TestRecordPayment_Success(t *testing.T) {
+	var recorded models.Payment
+	handler := &PaymentHandler{Store: &MockPaymentStore{
+		RecordPaymentFunc: func(payment *models.Payment, version int) (int64, int, error) {
+			recorded = *payment
+			return 3, 5, nil
+		},
+	}}
+
+	reqBody := []byte(`{"amount": 40.00, "date": "2026-01-05T00:00:00Z", "method": "bank_transfer", "reference": "TRX-1"}`)
+	req := httptest.NewRequest("POST", "/api/invoices/7/payments", bytes.NewBuffer(reqBody))
+	req.SetPathValue("id", "7")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handler.RecordPayment).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusCreated {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+	}
+	if recorded.InvoiceID != 7 || recorded.Kind != models.PaymentKindPayment || recorded.Amount.Amount != 4000 {
+		t.Errorf("unexpected payment passed to store: %+v", recorded)
+	}
+	if etag := rr.Header().Get("ETag"); etag != `"5"` {
+		t.Errorf("ETag = %s, want the invoice's new version \"5\"", etag)
+	}
+}
+
+func TestRecordPayment_IfMatch(t *testing.T) {
+	handler := &PaymentHandler{Store: &MockPaymentStore{
+		RecordPaymentFunc: func(payment *models.Payment, version int) (int64, int, error) {
+			if version != 4 {
+				return 0, 0, database.ErrVersionMismatch
+			}
+			return 3, 5, nil
+		},
+	}}
+
+	for tag, want := range map[string]int{`"4"`: http.StatusCreated, `"3"`: http.StatusPreconditionFailed, "4": http.StatusPreconditionFailed} {
+		req := httptest.NewRequest("POST", "/api/invoices/7/payments", bytes.NewBufferString(`{"amount": 40.00, "method": "cash"}`))
+		req.SetPathValue("id", "7")
+		req.Header.Set("If-Match", tag)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(handler.RecordPayment).ServeHTTP(rr, req)
+
+		if rr.Code != want {
+			t.Errorf("If-Match %s: got status %v want %v", tag, rr.Code, want)
+		}
+	}
+}
+
+func TestRecordPayment_Overpayment(t *testing.T) {
+	handler := &PaymentHandler{Store: &MockPaymentStore{
+		RecordPaymentFunc: func(payment *models.Payment, version int) (int64, int, error) {
+			return 0, 0, models.ErrOverpayment
+		},
+	}}
+
+	reqBody := []byte(`{"amount": 1000, "method": "cash"}`)
+	req := httptest.NewRequest("POST", "/api/invoices/7/payments", bytes.NewBuffer(reqBody))
+	req.SetPathValue("id", "7")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handler.RecordPayment).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+	expected := "{\"error\":\"payment exceeds balance due\"}\n"
+	if rr.Body.String() != expected {
+		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+	}
+}
+
+func TestRecordPayment_InvalidAmount(t *testing.T) {
+	handler := &PaymentHandler{Store: &MockPaymentStore{}}
+
+	req := httptest.NewRequest("POST", "/api/invoices/7/payments", bytes.NewBufferString(`{"amount": -5, "method": "cash"}`))
+	req.SetPathValue("id", "7")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handler.RecordPayment).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
+
+func TestReversePayment_NotPayable(t *testing.T) {
+	handler := &PaymentHandler{Store: &MockPaymentStore{
+		ReversePaymentFunc: func(invoiceID, paymentID, version int) (*models.Payment, int, error) {
+			return nil, 0, models.ErrAlreadyReversed
+		},
+	}}
+
+	req := httptest.NewRequest("POST", "/api/invoices/7/payments/2/reverse", nil)
+	req.SetPathValue("id", "7")
+	req.SetPathValue("paymentID", "2")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handler.ReversePayment).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusConflict {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusConflict)
+	}
+}
+
+func TestGetPayments_InvoiceNotFound(t *testing.T) {
+	handler := &PaymentHandler{Store: &MockPaymentStore{
+		GetPaymentsFunc: func(invoiceID int) ([]models.Payment, error) {
+			return nil, sql.ErrNoRows
+		},
+	}}
+
+	req := httptest.NewRequest("GET", "/api/invoices/404/payments", nil)
+	req.SetPathValue("id", "404")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handler.GetPayments).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+	}
+}
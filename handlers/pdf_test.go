@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"tiny-invoicing/database"
+	"tiny-invoicing/models"
+)
+
+func TestGetInvoice_PDF(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		accept string
+	}{
+		{"pdf extension", "/api/invoices/1.pdf", ""},
+		{"accept header", "/api/invoices/1", "application/pdf"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var customerID int
+			handler := &InvoiceHandler{
+				Store: &MockInvoiceStore{
+					GetInvoiceByIDFunc: sentInvoice,
+					GetCustomerByIDFunc: func(id int) (*database.Customer, error) {
+						customerID = id
+						return &database.Customer{ID: id, Name: "Acme", Address: "1 Main St", Currency: "USD"}, nil
+					},
+					GetBrandingFunc: func() (*models.Branding, error) {
+						return &models.Branding{CompanyName: "Tiny Invoicing Ltd", Color: "#198754"}, nil
+					},
+				},
+			}
+
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rr := httptest.NewRecorder()
+
+			http.HandlerFunc(handler.GetInvoice).ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+			}
+			if got := rr.Header().Get("Content-Type"); got != "application/pdf" {
+				t.Errorf("Content-Type = %q, want application/pdf", got)
+			}
+			if got := rr.Header().Get("Content-Disposition"); got != `inline; filename="invoice-INV-2026-00042.pdf"` {
+				t.Errorf("Content-Disposition = %q", got)
+			}
+			if !bytes.HasPrefix(rr.Body.Bytes(), []byte("%PDF-")) {
+				t.Errorf("body is not a PDF: %q", rr.Body.String()[:min(rr.Body.Len(), 20)])
+			}
+			if customerID != 3 {
+				t.Errorf("loaded customer %d, want the invoice's customer 3", customerID)
+			}
+		})
+	}
+}
+
+// sentInvoice returns a sent invoice of 25.00 USD to customer 3.
+func sentInvoice(id int) (*models.Invoice, error) {
+	issueDate := time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)
+	invoice := &models.Invoice{
+		ID: id, Number: "INV-2026-00042", CustomerID: 3, Currency: "USD",
+		IssueDate: issueDate, DueDate: issueDate.AddDate(0, 0, 14), Status: models.StatusSent, SentAt: &issueDate,
+		LineItems: []models.LineItem{{ID: 1, InvoiceID: id, Description: "Item 1", Quantity: 1, UnitPrice: models.NewMoney(2500, "USD")}},
+	}
+	invoice.CalculateTotal()
+	return invoice, nil
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"tiny-invoicing/database"
+	"tiny-invoicing/models"
+	"tiny-invoicing/response"
+)
+
+// RecurringProfileStore defines the interface for recurring profile persistence.
+type RecurringProfileStore interface {
+	GetRecurringProfiles() ([]models.RecurringProfile, error)
+	GetRecurringProfileByID(id int) (*models.RecurringProfile, error)
+	CreateRecurringProfile(profile *models.RecurringProfile) (int64, error)
+	UpdateRecurringProfile(profile *models.RecurringProfile) error
+	GetCustomerByID(id int) (*database.Customer, error)
+	GetTaxRatesByCode(codes []string) ([]models.TaxRate, error)
+}
+
+// RecurringProfileHandler handles recurring invoice profile requests. The invoices
+// themselves are generated by the recurring package's scheduler.
+type RecurringProfileHandler struct {
+	Store RecurringProfileStore
+}
+
+// GetRecurringProfiles lists all recurring profiles, including inactive ones.
+func (h *RecurringProfileHandler) GetRecurringProfiles(w http.ResponseWriter, r *http.Request) {
+	profiles, err := h.Store.GetRecurringProfiles()
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to retrieve recurring profiles")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, profiles)
+}
+
+// GetRecurringProfile retrieves a single recurring profile.
+func (h *RecurringProfileHandler) GetRecurringProfile(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Path[len("/api/recurring-profiles/"):])
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid recurring profile ID")
+		return
+	}
+
+	profile, err := h.Store.GetRecurringProfileByID(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			response.Error(w, http.StatusNotFound, "Recurring profile not found")
+		} else {
+			response.Error(w, http.StatusInternalServerError, "Failed to retrieve recurring profile")
+		}
+		return
+	}
+
+	response.JSON(w, http.StatusOK, *profile)
+}
+
+// CreateRecurringProfile creates a recurring profile. Profiles are active unless
+// "active" is false, and without a currency they use the customer's.
+func (h *RecurringProfileHandler) CreateRecurringProfile(w http.ResponseWriter, r *http.Request) {
+	profile, ok := h.readProfile(w, r, "Failed to create recurring profile")
+	if !ok {
+		return
+	}
+
+	profileID, err := h.Store.CreateRecurringProfile(profile)
+	if errors.Is(err, database.ErrUnknownCustomer) {
+		response.Error(w, http.StatusBadRequest, "Unknown customer")
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating recurring profile in DB: %v", err)
+		response.Error(w, http.StatusInternalServerError, "Failed to create recurring profile")
+		return
+	}
+
+	profile.ID = int(profileID)
+	response.JSON(w, http.StatusCreated, profile)
+}
+
+// UpdateRecurringProfile replaces a recurring profile. The next run is rescheduled
+// from the new cadence; invoices already generated are not changed. Set "active"
+// to false to pause a profile.
+func (h *RecurringProfileHandler) UpdateRecurringProfile(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Path[len("/api/recurring-profiles/"):])
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid recurring profile ID")
+		return
+	}
+
+	profile, ok := h.readProfile(w, r, "Failed to update recurring profile")
+	if !ok {
+		return
+	}
+	profile.ID = id
+
+	if err := h.Store.UpdateRecurringProfile(profile); err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			response.Error(w, http.StatusNotFound, "Recurring profile not found")
+		case errors.Is(err, database.ErrUnknownCustomer):
+			response.Error(w, http.StatusBadRequest, "Unknown customer")
+		default:
+			log.Printf("Error updating recurring profile in DB: %v", err)
+			response.Error(w, http.StatusInternalServerError, "Failed to update recurring profile")
+		}
+		return
+	}
+
+	response.JSON(w, http.StatusOK, profile)
+}
+
+// readProfile decodes and validates the profile in the request body, checking that
+// it makes a valid invoice. It writes the error response and returns false if not.
+func (h *RecurringProfileHandler) readProfile(w http.ResponseWriter, r *http.Request, failure string) (*models.RecurringProfile, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return nil, false
+	}
+
+	// Amounts are read in the profile's currency, so settle that first.
+	var head struct {
+		CustomerID int    `json:"customer_id"`
+		Currency   string `json:"currency"`
+	}
+	if err := json.Unmarshal(body, &head); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return nil, false
+	}
+	profile := &models.RecurringProfile{Active: true}
+	if head.Currency == "" && head.CustomerID != 0 {
+		customer, err := h.Store.GetCustomerByID(head.CustomerID)
+		if err == sql.ErrNoRows {
+			response.Error(w, http.StatusBadRequest, "Unknown customer")
+			return nil, false
+		}
+		if err != nil {
+			log.Printf("Error loading customer: %v", err)
+			response.Error(w, http.StatusInternalServerError, failure)
+			return nil, false
+		}
+		profile.Currency = customer.Currency
+	}
+
+	if err := json.Unmarshal(body, profile); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return nil, false
+	}
+	if err := profile.Validate(); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid recurring profile: "+err.Error())
+		return nil, false
+	}
+
+	// Try the line items on an invoice, as the scheduler will.
+	invoice := profile.Invoice(profile.StartDate)
+	rates, err := h.Store.GetTaxRatesByCode(invoice.TaxCodes())
+	if err != nil {
+		log.Printf("Error loading tax rates: %v", err)
+		response.Error(w, http.StatusInternalServerError, failure)
+		return nil, false
+	}
+	if err := invoice.SetTaxRates(rates); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid tax code: "+err.Error())
+		return nil, false
+	}
+	if err := invoice.ValidateDiscounts(); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	if err := invoice.CalculateTotal(); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	return profile, true
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"tiny-invoicing/database"
+	"tiny-invoicing/models"
+)
+
+// MockRecurringProfileStore is a mock implementation of RecurringProfileStore.
+type MockRecurringProfileStore struct {
+	GetRecurringProfilesFunc    func() ([]models.RecurringProfile, error)
+	GetRecurringProfileByIDFunc func(id int) (*models.RecurringProfile, error)
+	CreateRecurringProfileFunc  func(profile *models.RecurringProfile) (int64, error)
+	UpdateRecurringProfileFunc  func(profile *models.RecurringProfile) error
+}
+
+func (m *MockRecurringProfileStore) GetRecurringProfiles() ([]models.RecurringProfile, error) {
+	if m.GetRecurringProfilesFunc != nil {
+		return m.GetRecurringProfilesFunc()
+	}
+	return nil, nil
+}
+
+func (m *MockRecurringProfileStore) GetRecurringProfileByID(id int) (*models.RecurringProfile, error) {
+	if m.GetRecurringProfileByIDFunc != nil {
+		return m.GetRecurringProfileByIDFunc(id)
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockRecurringProfileStore) CreateRecurringProfile(profile *models.RecurringProfile) (int64, error) {
+	if m.CreateRecurringProfileFunc != nil {
+		return m.CreateRecurringProfileFunc(profile)
+	}
+	return 1, nil
+}
+
+func (m *MockRecurringProfileStore) UpdateRecurringProfile(profile *models.RecurringProfile) error {
+	if m.UpdateRecurringProfileFunc != nil {
+		return m.UpdateRecurringProfileFunc(profile)
+	}
+	return nil
+}
+
+func (m *MockRecurringProfileStore) GetCustomerByID(id int) (*database.Customer, error) {
+	return &database.Customer{ID: id, Currency: "EUR"}, nil
+}
+
+func (m *MockRecurringProfileStore) GetTaxRatesByCode(codes []string) ([]models.TaxRate, error) {
+	return nil, nil
+}
+
+func TestCreateRecurringProfile_Success(t *testing.T) {
+	var stored models.RecurringProfile
+	handler := &RecurringProfileHandler{Store: &MockRecurringProfileStore{
+		CreateRecurringProfileFunc: func(profile *models.RecurringProfile) (int64, error) {
+			stored = *profile
+			return 7, nil
+		},
+	}}
+
+	reqBody := []byte(`{"name": "Monthly retainer", "customer_id": 3, "cadence": "monthly", "start_date": "2026-01-31T00:00:00Z", "due_days": 14,
+		"line_items": [{"description": "Support", "quantity": 1, "unit_price": "1500.00"}]}`)
+	req := httptest.NewRequest("POST", "/api/recurring-profiles", bytes.NewBuffer(reqBody))
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(handler.CreateRecurringProfile).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusCreated, rr.Body.String())
+	}
+	if !stored.Active || stored.Currency != "EUR" || stored.LineItems[0].UnitPrice.Currency != "EUR" {
+		t.Errorf("stored profile = %+v, want an active profile in the customer's currency", stored)
+	}
+	if stored.RoundingMode != models.DefaultRoundingMode {
+		t.Errorf("rounding mode = %q, want the default pinned", stored.RoundingMode)
+	}
+}
+
+func TestCreateRecurringProfile_Invalid(t *testing.T) {
+	handler := &RecurringProfileHandler{Store: &MockRecurringProfileStore{
+		CreateRecurringProfileFunc: func(profile *models.RecurringProfile) (int64, error) {
+			t.Error("invalid profile should not be stored")
+			return 0, nil
+		},
+	}}
+
+	tests := []string{
+		`{"name": "No items", "customer_id": 3, "cadence": "monthly", "start_date": "2026-01-01T00:00:00Z", "line_items": []}`,
+		`{"name": "Bad cadence", "customer_id": 3, "cadence": "daily", "start_date": "2026-01-01T00:00:00Z", "line_items": [{"description": "x", "quantity": 1, "unit_price": "1"}]}`,
+		`{"name": "Bad cron", "customer_id": 3, "cadence": "cron", "cron": "0 0 * *", "start_date": "2026-01-01T00:00:00Z", "line_items": [{"description": "x", "quantity": 1, "unit_price": "1"}]}`,
+		`{"name": "Ends early", "customer_id": 3, "cadence": "weekly", "start_date": "2026-01-01T00:00:00Z", "end_date": "2025-12-01T00:00:00Z", "line_items": [{"description": "x", "quantity": 1, "unit_price": "1"}]}`,
+		`{"name": "Unknown tax", "customer_id": 3, "cadence": "weekly", "start_date": "2026-01-01T00:00:00Z", "line_items": [{"description": "x", "quantity": 1, "unit_price": "1", "tax_codes": ["VAT"]}]}`,
+	}
+	for _, body := range tests {
+		req := httptest.NewRequest("POST", "/api/recurring-profiles", bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+
+		http.HandlerFunc(handler.CreateRecurringProfile).ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: got status %v, want %v", body, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestUpdateRecurringProfile_NotFound(t *testing.T) {
+	handler := &RecurringProfileHandler{Store: &MockRecurringProfileStore{
+		UpdateRecurringProfileFunc: func(profile *models.RecurringProfile) error {
+			if profile.ID != 5 {
+				t.Errorf("updated profile %d, want 5", profile.ID)
+			}
+			return sql.ErrNoRows
+		},
+	}}
+
+	reqBody := []byte(`{"name": "Paused", "customer_id": 3, "cadence": "cron", "cron": "0 9 1 * *", "start_date": "2026-01-01T00:00:00Z", "active": false,
+		"line_items": [{"description": "Support", "quantity": 1, "unit_price": "10"}]}`)
+	req := httptest.NewRequest("PUT", "/api/recurring-profiles/5", bytes.NewBuffer(reqBody))
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(handler.UpdateRecurringProfile).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+}
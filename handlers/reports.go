@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"tiny-invoicing/models"
+	"tiny-invoicing/response"
+)
+
+// ReportStore defines the interface for reporting queries.
+type ReportStore interface {
+	GetSalesReport(from, to time.Time) (*models.SalesReport, error)
+}
+
+// ReportHandler handles reporting requests.
+type ReportHandler struct {
+	Store ReportStore
+}
+
+// GetSalesReport sums issued invoices by currency and in the base currency for the
+// issue dates ?from=YYYY-MM-DD to ?to=YYYY-MM-DD. The period defaults to the current
+// year up to today.
+func (h *ReportHandler) GetSalesReport(w http.ResponseWriter, r *http.Request) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from := time.Date(today.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	to := today
+
+	var err error
+	if value := r.URL.Query().Get("from"); value != "" {
+		if from, err = time.Parse("2006-01-02", value); err != nil {
+			response.Error(w, http.StatusBadRequest, "Invalid from date")
+			return
+		}
+	}
+	if value := r.URL.Query().Get("to"); value != "" {
+		if to, err = time.Parse("2006-01-02", value); err != nil {
+			response.Error(w, http.StatusBadRequest, "Invalid to date")
+			return
+		}
+	}
+	if to.Before(from) {
+		response.Error(w, http.StatusBadRequest, "The from date must not be after the to date")
+		return
+	}
+
+	report, err := h.Store.GetSalesReport(from, to)
+	if err != nil {
+		log.Printf("Error building sales report: %v", err)
+		response.Error(w, http.StatusInternalServerError, "Failed to build report")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, *report)
+}
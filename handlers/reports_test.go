@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"tiny-invoicing/models"
+)
+
+// MockReportStore is a mock implementation of ReportStore.
+type MockReportStore struct {
+	GetSalesReportFunc func(from, to time.Time) (*models.SalesReport, error)
+}
+
+func (m *MockReportStore) GetSalesReport(from, to time.Time) (*models.SalesReport, error) {
+	if m.GetSalesReportFunc != nil {
+		return m.GetSalesReportFunc(from, to)
+	}
+	return &models.SalesReport{From: from, To: to}, nil
+}
+
+func TestGetSalesReport_Success(t *testing.T) {
+	handler := &ReportHandler{Store: &MockReportStore{
+		GetSalesReportFunc: func(from, to time.Time) (*models.SalesReport, error) {
+			return &models.SalesReport{
+				From:         from,
+				To:           to,
+				BaseCurrency: "USD",
+				Currencies: []models.CurrencySales{
+					{
+						Currency: "JPY", Invoices: 2, Total: models.NewMoney(150000, "JPY"), BaseTotal: models.NewMoney(100000, "USD"),
+						CreditNotes: 1, Credited: models.NewMoney(15000, "JPY"), BaseCredited: models.NewMoney(10000, "USD"),
+						Net: models.NewMoney(135000, "JPY"), BaseNet: models.NewMoney(90000, "USD"),
+					},
+				},
+				BaseTotal:    models.NewMoney(100000, "USD"),
+				BaseCredited: models.NewMoney(10000, "USD"),
+				BaseNet:      models.NewMoney(90000, "USD"),
+			}, nil
+		},
+	}}
+
+	req := httptest.NewRequest("GET", "/api/reports/sales?from=2026-01-01&to=2026-03-31", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handler.GetSalesReport).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	expected := `{"from":"2026-01-01T00:00:00Z","to":"2026-03-31T00:00:00Z","base_currency":"USD","currencies":[{"currency":"JPY","invoices":2,"total":150000,"base_total":1000.00,"credit_notes":1,"credited":15000,"base_credited":100.00,"net":135000,"base_net":900.00}],"base_total":1000.00,"base_credited":100.00,"base_net":900.00}` + "\n"
+	if rr.Body.String() != expected {
+		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+	}
+}
+
+func TestGetSalesReport_InvalidRange(t *testing.T) {
+	handler := &ReportHandler{Store: &MockReportStore{}}
+
+	for _, query := range []string{"?from=2026-13-01", "?from=2026-03-01&to=2026-02-01"} {
+		req := httptest.NewRequest("GET", "/api/reports/sales"+query, nil)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(handler.GetSalesReport).ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", query, status, http.StatusBadRequest)
+		}
+	}
+}
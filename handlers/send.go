@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"tiny-invoicing/database"
+	"tiny-invoicing/mailer"
+	"tiny-invoicing/models"
+	"tiny-invoicing/pdf"
+	"tiny-invoicing/response"
+)
+
+// SendInvoice emails an invoice to its customer: the invoice rendered with the
+// customer's template as the body, and the PDF attached. A draft is moved to sent
+// first, which gives it its number. Every attempt is recorded in the invoice's
+// delivery log; a failed attempt can simply be repeated. An If-Match header, if
+// given, must carry the invoice's current ETag, and the response carries the ETag
+// of the invoice as sent.
+func (h *InvoiceHandler) SendInvoice(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid invoice ID")
+		return
+	}
+
+	version, ok := optionalIfMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	if h.Mailer == nil {
+		response.Error(w, http.StatusServiceUnavailable, "Email is not configured")
+		return
+	}
+
+	invoice, err := h.Store.GetInvoiceByID(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			response.Error(w, http.StatusNotFound, "Invoice not found")
+		} else {
+			response.Error(w, http.StatusInternalServerError, "Failed to retrieve invoice")
+		}
+		return
+	}
+	if version != 0 && version != invoice.Version {
+		response.Error(w, http.StatusPreconditionFailed, database.ErrVersionMismatch.Error())
+		return
+	}
+	if invoice.Status == models.StatusVoid {
+		response.Error(w, http.StatusConflict, "Void invoices cannot be sent")
+		return
+	}
+
+	customer, branding, err := h.documentParts(invoice)
+	if err != nil {
+		log.Printf("Error loading invoice %d for sending: %v", invoice.ID, err)
+		response.Error(w, http.StatusInternalServerError, "Failed to send invoice")
+		return
+	}
+	if customer.Email == "" {
+		response.Error(w, http.StatusConflict, "Customer has no email address")
+		return
+	}
+
+	if invoice.Status == models.StatusDraft {
+		if err := h.Store.TransitionInvoiceStatus(id, version, models.StatusSent, time.Now()); err != nil {
+			switch {
+			case errors.Is(err, database.ErrVersionMismatch):
+				response.Error(w, http.StatusPreconditionFailed, err.Error())
+			case errors.Is(err, models.ErrInvalidTransition), errors.Is(err, models.ErrNoExchangeRate), errors.Is(err, database.ErrNumberTaken):
+				response.Error(w, http.StatusConflict, err.Error())
+			default:
+				log.Printf("Error updating invoice status in DB: %v", err)
+				response.Error(w, http.StatusInternalServerError, "Failed to send invoice")
+			}
+			return
+		}
+		// Reload to pick up the number and sent date.
+		if invoice, err = h.Store.GetInvoiceByID(id); err != nil {
+			log.Printf("Error reloading invoice %d: %v", id, err)
+			response.Error(w, http.StatusInternalServerError, "Failed to send invoice")
+			return
+		}
+	}
+	w.Header().Set("ETag", invoiceETag(invoice.Version))
+
+	var body, document bytes.Buffer
+	if err := h.renderHTML(&body, invoice, customer, branding, ""); err != nil {
+		log.Printf("Error rendering invoice %d: %v", invoice.ID, err)
+		response.Error(w, http.StatusInternalServerError, "Failed to render invoice")
+		return
+	}
+	if err := pdf.Invoice(&document, invoice, customer, *branding); err != nil {
+		log.Printf("Error rendering invoice %d: %v", invoice.ID, err)
+		response.Error(w, http.StatusInternalServerError, "Failed to render invoice")
+		return
+	}
+
+	subject := "Invoice " + invoice.Title()
+	if branding.CompanyName != "" {
+		subject += " from " + branding.CompanyName
+	}
+	messageID, sendErr := h.Mailer.Send(&mailer.Message{
+		To:      customer.Email,
+		Subject: subject,
+		HTML:    body.String(),
+		Attachments: []mailer.Attachment{
+			{Filename: pdfFilename(invoice), ContentType: "application/pdf", Data: document.Bytes()},
+		},
+	})
+
+	delivery := models.Delivery{
+		InvoiceID: invoice.ID,
+		Recipient: customer.Email,
+		SentAt:    time.Now().UTC().Truncate(time.Second),
+		MessageID: messageID,
+		Result:    models.DeliverySent,
+	}
+	if sendErr != nil {
+		delivery.Result = models.DeliveryFailed
+		delivery.Error = sendErr.Error()
+	}
+	deliveryID, err := h.Store.RecordDelivery(&delivery)
+	if err != nil {
+		// The email may already be on its way, so report the outcome regardless.
+		log.Printf("Error recording delivery of invoice %d: %v", invoice.ID, err)
+	}
+	delivery.ID = int(deliveryID)
+
+	if sendErr != nil {
+		log.Printf("Error emailing invoice %d to %s: %v", invoice.ID, customer.Email, sendErr)
+		response.Error(w, http.StatusBadGateway, "Failed to send invoice email")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, delivery)
+}
+
+// GetDeliveries lists the attempts to email an invoice.
+func (h *InvoiceHandler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid invoice ID")
+		return
+	}
+
+	deliveries, err := h.Store.GetDeliveries(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			response.Error(w, http.StatusNotFound, "Invoice not found")
+		} else {
+			response.Error(w, http.StatusInternalServerError, "Failed to retrieve deliveries")
+		}
+		return
+	}
+
+	response.JSON(w, http.StatusOK, deliveries)
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"tiny-invoicing/database"
+	"tiny-invoicing/mailer"
+	"tiny-invoicing/mailer/mailtest"
+	"tiny-invoicing/models"
+	"tiny-invoicing/templates"
+)
+
+// sendFixture is an InvoiceHandler whose store holds one invoice and records
+// transitions and deliveries, with email going to a local SMTP server.
+type sendFixture struct {
+	handler     *InvoiceHandler
+	server      *mailtest.Server
+	invoice     models.Invoice
+	transitions []models.InvoiceStatus
+	deliveries  []models.Delivery
+}
+
+func newSendFixture(t *testing.T, status models.InvoiceStatus) *sendFixture {
+	t.Helper()
+	server, err := mailtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	date := time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)
+	f := &sendFixture{server: server}
+	f.invoice = models.Invoice{
+		ID: 1, CustomerID: 3, Currency: "USD", IssueDate: date, DueDate: date.AddDate(0, 0, 14), Status: status, Version: 1,
+		LineItems: []models.LineItem{{Description: "Consulting", Quantity: 1, UnitPrice: models.NewMoney(2500, "USD")}},
+	}
+	f.invoice.CalculateTotal()
+
+	f.handler = &InvoiceHandler{
+		Store: &MockInvoiceStore{
+			GetInvoiceByIDFunc: func(id int) (*models.Invoice, error) {
+				invoice := f.invoice
+				return &invoice, nil
+			},
+			TransitionFunc: func(id, version int, status models.InvoiceStatus, at time.Time) error {
+				if version != 0 && version != f.invoice.Version {
+					return database.ErrVersionMismatch
+				}
+				f.transitions = append(f.transitions, status)
+				f.invoice.Status = status
+				f.invoice.Number = "INV-2026-00042"
+				f.invoice.Version++
+				return nil
+			},
+			GetCustomerByIDFunc: func(id int) (*database.Customer, error) {
+				return &database.Customer{ID: id, Name: "Acme", Email: "billing@acme.example", Address: "1 Main St", Currency: "USD"}, nil
+			},
+			GetBrandingFunc: func() (*models.Branding, error) {
+				return &models.Branding{CompanyName: "Tiny Invoicing Ltd", Color: models.DefaultBrandColor}, nil
+			},
+			RecordDeliveryFunc: func(delivery *models.Delivery) (int64, error) {
+				f.deliveries = append(f.deliveries, *delivery)
+				return int64(len(f.deliveries)), nil
+			},
+		},
+		Templates: &templates.Renderer{},
+		Mailer:    &mailer.SMTP{Addr: server.Addr, From: "Billing <billing@tiny.example>"},
+	}
+	return f
+}
+
+func (f *sendFixture) send() *httptest.ResponseRecorder {
+	return f.sendIfMatch("")
+}
+
+// sendIfMatch sends the invoice with an If-Match header, unless tag is empty.
+func (f *sendFixture) sendIfMatch(tag string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/api/invoices/1/send", nil)
+	req.SetPathValue("id", "1")
+	if tag != "" {
+		req.Header.Set("If-Match", tag)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(f.handler.SendInvoice).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestSendInvoice_Draft(t *testing.T) {
+	f := newSendFixture(t, models.StatusDraft)
+
+	rr := f.send()
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	if len(f.transitions) != 1 || f.transitions[0] != models.StatusSent {
+		t.Errorf("transitions = %v, want [sent]", f.transitions)
+	}
+	if etag := rr.Header().Get("ETag"); etag != `"2"` {
+		t.Errorf("ETag = %s, want the sent invoice's \"2\"", etag)
+	}
+
+	messages := f.server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("SMTP server received %d messages, want 1", len(messages))
+	}
+	data := string(messages[0].Data)
+	if messages[0].To[0] != "billing@acme.example" {
+		t.Errorf("recipient = %v", messages[0].To)
+	}
+	// The email is rendered after the invoice has been numbered.
+	for _, want := range []string{"Subject: Invoice INV-2026-00042 from Tiny Invoicing Ltd", "text/html", "filename=invoice-INV-2026-00042.pdf"} {
+		if !strings.Contains(data, want) {
+			t.Errorf("email does not contain %q", want)
+		}
+	}
+
+	if len(f.deliveries) != 1 {
+		t.Fatalf("recorded %d deliveries, want 1", len(f.deliveries))
+	}
+	delivery := f.deliveries[0]
+	if delivery.Result != models.DeliverySent || delivery.Recipient != "billing@acme.example" || delivery.MessageID == "" {
+		t.Errorf("delivery = %+v", delivery)
+	}
+	if !strings.Contains(data, "Message-ID: "+delivery.MessageID) {
+		t.Error("logged Message-ID does not match the email")
+	}
+
+	var body models.Delivery
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body.ID != 1 {
+		t.Errorf("response = %s", rr.Body.String())
+	}
+}
+
+func TestSendInvoice_IfMatch(t *testing.T) {
+	f := newSendFixture(t, models.StatusDraft)
+
+	if rr := f.sendIfMatch(`"2"`); rr.Code != http.StatusPreconditionFailed {
+		t.Fatalf("sending a stale version: got status %v want %v", rr.Code, http.StatusPreconditionFailed)
+	}
+	if len(f.transitions) != 0 || len(f.server.Messages()) != 0 {
+		t.Fatalf("a stale send changed the invoice (%v) or emailed it", f.transitions)
+	}
+
+	rr := f.sendIfMatch(`"1"`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	if etag := rr.Header().Get("ETag"); etag != `"2"` {
+		t.Errorf("ETag = %s, want \"2\"", etag)
+	}
+}
+
+func TestSendInvoice_Resend(t *testing.T) {
+	f := newSendFixture(t, models.StatusSent)
+	f.invoice.Number = "INV-2026-00007"
+
+	if rr := f.send(); rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if len(f.transitions) != 0 {
+		t.Errorf("a sent invoice should not change status, got %v", f.transitions)
+	}
+	if len(f.server.Messages()) != 1 {
+		t.Error("invoice was not emailed again")
+	}
+}
+
+func TestSendInvoice_SMTPFailure(t *testing.T) {
+	f := newSendFixture(t, models.StatusSent)
+	f.server.Reject(true)
+
+	rr := f.send()
+
+	if rr.Code != http.StatusBadGateway {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadGateway)
+	}
+	if len(f.deliveries) != 1 || f.deliveries[0].Result != models.DeliveryFailed || f.deliveries[0].Error == "" {
+		t.Errorf("failed attempt was not logged: %+v", f.deliveries)
+	}
+}
+
+func TestSendInvoice_Void(t *testing.T) {
+	f := newSendFixture(t, models.StatusVoid)
+
+	rr := f.send()
+
+	if rr.Code != http.StatusConflict {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusConflict)
+	}
+	if len(f.server.Messages()) != 0 || len(f.deliveries) != 0 {
+		t.Error("void invoice was sent")
+	}
+}
+
+func TestSendInvoice_NotConfigured(t *testing.T) {
+	f := newSendFixture(t, models.StatusDraft)
+	f.handler.Mailer = nil
+
+	rr := f.send()
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusServiceUnavailable)
+	}
+	if !bytes.Contains(rr.Body.Bytes(), []byte("Email is not configured")) || len(f.transitions) != 0 {
+		t.Errorf("unexpected response %s, transitions %v", rr.Body.String(), f.transitions)
+	}
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"tiny-invoicing/auth"
+	"tiny-invoicing/database"
+	"tiny-invoicing/models"
+	"tiny-invoicing/response"
+)
+
+// SessionStore defines the interface for login sessions.
+type SessionStore interface {
+	GetUserByUsername(username string) (*database.User, error)
+	CreateSession(session *database.Session) (int64, error)
+	GetUserSessions(userID int, now time.Time) ([]database.Session, error)
+	DeleteSession(userID, id int) error
+}
+
+// SessionHandler handles logging in and out with session cookies.
+type SessionHandler struct {
+	Store SessionStore
+	// TTL is how long a session lasts; auth.DefaultSessionTTL if zero.
+	TTL time.Duration
+}
+
+// sessionInfo describes the session of the signed-in user. Requests that change
+// state must send CSRFToken in the X-CSRF-Token header.
+type sessionInfo struct {
+	Username  string      `json:"username"`
+	Role      models.Role `json:"role"`
+	CSRFToken string      `json:"csrf_token"`
+	ExpiresAt time.Time   `json:"expires_at"`
+}
+
+// Login checks a username and password and starts a session, sent as an HttpOnly
+// cookie. The response carries the session's CSRF token.
+func (h *SessionHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var creds struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if creds.Username == "" || creds.Password == "" {
+		response.Error(w, http.StatusBadRequest, "Username and password are required")
+		return
+	}
+
+	user, err := h.Store.GetUserByUsername(creds.Username)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error loading user: %v", err)
+		response.Error(w, http.StatusInternalServerError, "Failed to log in")
+		return
+	}
+	if err == sql.ErrNoRows {
+		auth.CheckUnknownUser(creds.Password)
+		response.Error(w, http.StatusUnauthorized, "Invalid credentials")
+		return
+	}
+	if !auth.CheckPasswordHash(creds.Password, user.PasswordHash) {
+		response.Error(w, http.StatusUnauthorized, "Invalid credentials")
+		return
+	}
+	if !auth.HasRole(w, user) {
+		return
+	}
+
+	ttl := h.TTL
+	if ttl <= 0 {
+		ttl = auth.DefaultSessionTTL
+	}
+	session, token, err := auth.NewSession(user, time.Now(), ttl)
+	if err != nil {
+		log.Printf("Error creating session token: %v", err)
+		response.Error(w, http.StatusInternalServerError, "Failed to log in")
+		return
+	}
+	if _, err := h.Store.CreateSession(session); err != nil {
+		log.Printf("Error creating session in DB: %v", err)
+		response.Error(w, http.StatusInternalServerError, "Failed to log in")
+		return
+	}
+
+	auth.SetSessionCookie(w, token, session.ExpiresAt)
+	response.JSON(w, http.StatusOK, sessionInfo{Username: user.Username, Role: user.Role, CSRFToken: session.CSRFToken, ExpiresAt: session.ExpiresAt})
+}
+
+// Logout revokes the current session and clears its cookie.
+func (h *SessionHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if session := auth.CurrentSession(r); session != nil {
+		if err := h.Store.DeleteSession(session.UserID, session.ID); err != nil && err != sql.ErrNoRows {
+			log.Printf("Error deleting session in DB: %v", err)
+			response.Error(w, http.StatusInternalServerError, "Failed to log out")
+			return
+		}
+	}
+	auth.ClearSessionCookie(w)
+	response.JSON(w, http.StatusOK, map[string]string{"message": "Logged out"})
+}
+
+// GetSession describes the current session, so that a reloaded page can pick up its
+// CSRF token.
+func (h *SessionHandler) GetSession(w http.ResponseWriter, r *http.Request) {
+	session := auth.CurrentSession(r)
+	if session == nil {
+		response.Error(w, http.StatusNotFound, "Not logged in with a session")
+		return
+	}
+	response.JSON(w, http.StatusOK, sessionInfo{Username: session.User.Username, Role: session.User.Role, CSRFToken: session.CSRFToken, ExpiresAt: session.ExpiresAt})
+}
+
+// GetSessions lists the signed-in user's sessions, marking the current one.
+func (h *SessionHandler) GetSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := h.Store.GetUserSessions(auth.CurrentUser(r).ID, time.Now())
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to retrieve sessions")
+		return
+	}
+
+	type listedSession struct {
+		database.Session
+		Current bool `json:"current"`
+	}
+	listed := []listedSession{}
+	current := auth.CurrentSession(r)
+	for _, session := range sessions {
+		listed = append(listed, listedSession{Session: session, Current: current != nil && current.ID == session.ID})
+	}
+	response.JSON(w, http.StatusOK, listed)
+}
+
+// RevokeSession ends one of the signed-in user's sessions, such as one left open on
+// another device.
+func (h *SessionHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Path[len("/api/sessions/"):])
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+
+	if err := h.Store.DeleteSession(auth.CurrentUser(r).ID, id); err != nil {
+		if err == sql.ErrNoRows {
+			response.Error(w, http.StatusNotFound, "Session not found")
+		} else {
+			log.Printf("Error deleting session in DB: %v", err)
+			response.Error(w, http.StatusInternalServerError, "Failed to revoke session")
+		}
+		return
+	}
+	if current := auth.CurrentSession(r); current != nil && current.ID == id {
+		auth.ClearSessionCookie(w)
+	}
+	response.JSON(w, http.StatusOK, map[string]string{"message": "Session revoked"})
+}
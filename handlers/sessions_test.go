@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"tiny-invoicing/auth"
+	"tiny-invoicing/database"
+	"tiny-invoicing/database/memstore"
+	"tiny-invoicing/models"
+)
+
+func TestSessions(t *testing.T) {
+	store := memstore.New()
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.CreateUser(&database.User{Username: "admin", PasswordHash: string(hash), Role: models.RoleAdmin}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.CreateUser(&database.User{Username: "clerk", PasswordHash: string(hash), Role: models.RoleViewer}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.CreateUser(&database.User{Username: "former", PasswordHash: string(hash), Role: models.RoleNone}); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions := &SessionHandler{Store: store, TTL: time.Hour}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/login":
+			sessions.Login(w, r)
+		case r.URL.Path == "/api/logout":
+			auth.Authenticate(store, sessions.Logout)(w, r)
+		case r.URL.Path == "/api/session":
+			auth.Authenticate(store, sessions.GetSession)(w, r)
+		case r.URL.Path == "/api/sessions":
+			auth.Authenticate(store, sessions.GetSessions)(w, r)
+		default:
+			auth.Authenticate(store, sessions.RevokeSession)(w, r)
+		}
+	}))
+	defer server.Close()
+
+	// login returns the session cookie and CSRF token of a new session.
+	login := func(username, password string, want int) (*http.Cookie, string) {
+		t.Helper()
+		resp, err := http.Post(server.URL+"/api/login", "application/json",
+			strings.NewReader(fmt.Sprintf(`{"username": %q, "password": %q}`, username, password)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Fatalf("login as %s: got status %d want %d", username, resp.StatusCode, want)
+		}
+		if want != http.StatusOK {
+			return nil, ""
+		}
+		var info sessionInfo
+		if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+			t.Fatal(err)
+		}
+		cookies := resp.Cookies()
+		if len(cookies) != 1 || cookies[0].Name != auth.SessionCookie || !cookies[0].HttpOnly || !cookies[0].Secure || cookies[0].SameSite != http.SameSiteLaxMode {
+			t.Fatalf("login cookies = %+v, want one HttpOnly, Secure, SameSite session cookie", cookies)
+		}
+		if info.Username != username || info.CSRFToken == "" || info.ExpiresAt.Before(time.Now().Add(59*time.Minute)) {
+			t.Errorf("login response = %+v", info)
+		}
+		return cookies[0], info.CSRFToken
+	}
+	do := func(method, path string, cookie *http.Cookie, csrf string, want int, into interface{}) {
+		t.Helper()
+		req, err := http.NewRequest(method, server.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.AddCookie(cookie)
+		if csrf != "" {
+			req.Header.Set(auth.CSRFHeader, csrf)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Fatalf("%s %s: got status %d want %d", method, path, resp.StatusCode, want)
+		}
+		if into != nil {
+			if err := json.NewDecoder(resp.Body).Decode(into); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	login("admin", "wrong", http.StatusUnauthorized)
+	login("nobody", "secret", http.StatusUnauthorized)
+	login("former", "secret", http.StatusForbidden)
+	// Every role may sign in; what they can do is up to their role.
+	login("clerk", "secret", http.StatusOK)
+
+	laptop, laptopCSRF := login("admin", "secret", http.StatusOK)
+	phone, phoneCSRF := login("admin", "secret", http.StatusOK)
+
+	var info sessionInfo
+	do("GET", "/api/session", laptop, "", http.StatusOK, &info)
+	if info.CSRFToken != laptopCSRF {
+		t.Errorf("GET /api/session CSRF token = %q, want the one from login", info.CSRFToken)
+	}
+
+	var listed []struct {
+		ID      int  `json:"id"`
+		Current bool `json:"current"`
+	}
+	do("GET", "/api/sessions", laptop, "", http.StatusOK, &listed)
+	if len(listed) != 2 || !listed[1].Current || listed[0].Current {
+		t.Fatalf("GET /api/sessions = %+v, want the phone session, then the current laptop one", listed)
+	}
+
+	// Revoking needs the CSRF token of the session making the request.
+	phoneID := listed[0].ID
+	do("DELETE", fmt.Sprintf("/api/sessions/%d", phoneID), laptop, "", http.StatusForbidden, nil)
+	do("DELETE", fmt.Sprintf("/api/sessions/%d", phoneID), laptop, phoneCSRF, http.StatusForbidden, nil)
+	do("DELETE", fmt.Sprintf("/api/sessions/%d", phoneID), laptop, laptopCSRF, http.StatusOK, nil)
+	do("GET", "/api/session", phone, "", http.StatusUnauthorized, nil)
+
+	do("POST", "/api/logout", laptop, laptopCSRF, http.StatusOK, nil)
+	do("GET", "/api/session", laptop, "", http.StatusUnauthorized, nil)
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+
+	"tiny-invoicing/database"
+	"tiny-invoicing/models"
+	"tiny-invoicing/response"
+)
+
+// SetupStore defines the interface for creating the first user.
+type SetupStore interface {
+	CreateFirstUser(user *database.User) (int64, error)
+}
+
+// SetupHandler creates the owner of a new install, who must show the setup token.
+type SetupHandler struct {
+	Store SetupStore
+	// Token is the setup token. It works once and is cleared when used; if empty,
+	// as for installs that already have users, setup is disabled.
+	Token string
+
+	mu sync.Mutex
+}
+
+// GetSetupStatus reports whether the owner still has to be set up.
+func (h *SetupHandler) GetSetupStatus(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	required := h.Token != ""
+	h.mu.Unlock()
+	response.JSON(w, http.StatusOK, map[string]bool{"setup_required": required})
+}
+
+// CreateOwner creates the first user as owner, given the setup token. The token works
+// once; after that, users are created by admins or through invitations.
+func (h *SetupHandler) CreateOwner(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		newAccount
+		SetupToken string `json:"setup_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.Token == "" {
+		response.Error(w, http.StatusConflict, "Setup has already been completed")
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(req.SetupToken), []byte(h.Token)) != 1 {
+		response.Error(w, http.StatusForbidden, "Invalid setup token")
+		return
+	}
+	user, ok := req.user(w, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	id, err := h.Store.CreateFirstUser(user)
+	if err == database.ErrUsersExist {
+		h.Token = ""
+		response.Error(w, http.StatusConflict, "Setup has already been completed")
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating owner in DB: %v", err)
+		response.Error(w, http.StatusInternalServerError, "Failed to create owner")
+		return
+	}
+	h.Token = ""
+	user.ID = int(id)
+	response.JSON(w, http.StatusCreated, user)
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"tiny-invoicing/database/memstore"
+	"tiny-invoicing/models"
+)
+
+func TestCreateOwner(t *testing.T) {
+	store := memstore.New()
+	setup := &SetupHandler{Store: store, Token: "setup-token"}
+	create := func(body string, want int) {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/api/setup", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		setup.CreateOwner(rr, req)
+		if rr.Code != want {
+			t.Fatalf("POST /api/setup %s: got status %d want %d: %s", body, rr.Code, want, rr.Body.String())
+		}
+	}
+	status := func(want string) {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		setup.GetSetupStatus(rr, httptest.NewRequest("GET", "/api/setup", nil))
+		if got := strings.TrimSpace(rr.Body.String()); got != want {
+			t.Errorf("GET /api/setup = %s, want %s", got, want)
+		}
+	}
+
+	status(`{"setup_required":true}`)
+	create(`{"username": "owner", "password": "secret", "setup_token": "guess"}`, http.StatusForbidden)
+	create(`{"username": "owner", "password": "", "setup_token": "setup-token"}`, http.StatusBadRequest)
+	create(`{"username": "owner", "password": "secret", "setup_token": "setup-token"}`, http.StatusCreated)
+	// The token works once.
+	create(`{"username": "intruder", "password": "secret", "setup_token": "setup-token"}`, http.StatusConflict)
+	status(`{"setup_required":false}`)
+
+	user, err := store.GetUserByUsername("owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Role != models.RoleOwner {
+		t.Errorf("first user's role = %s, want %s", user.Role, models.RoleOwner)
+	}
+
+	// A token left over from before users were added, e.g. by create-user, does not work.
+	setup = &SetupHandler{Store: store, Token: "setup-token"}
+	create(`{"username": "intruder", "password": "secret", "setup_token": "setup-token"}`, http.StatusConflict)
+	status(`{"setup_required":false}`)
+}
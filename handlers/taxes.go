@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"tiny-invoicing/database"
+	"tiny-invoicing/models"
+	"tiny-invoicing/response"
+)
+
+// TaxRateStore defines the interface for tax rate persistence.
+type TaxRateStore interface {
+	GetTaxRates() ([]models.TaxRate, error)
+	GetTaxRateByID(id int) (*models.TaxRate, error)
+	CreateTaxRate(rate *models.TaxRate) (int64, error)
+	UpdateTaxRate(rate *models.TaxRate) error
+}
+
+// TaxRateHandler handles tax rate configuration requests.
+type TaxRateHandler struct {
+	Store TaxRateStore
+}
+
+// GetTaxRates lists all tax rates, including inactive ones.
+func (h *TaxRateHandler) GetTaxRates(w http.ResponseWriter, r *http.Request) {
+	rates, err := h.Store.GetTaxRates()
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to retrieve tax rates")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, rates)
+}
+
+// GetTaxRate retrieves a single tax rate.
+func (h *TaxRateHandler) GetTaxRate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Path[len("/api/tax-rates/"):])
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid tax rate ID")
+		return
+	}
+
+	rate, err := h.Store.GetTaxRateByID(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			response.Error(w, http.StatusNotFound, "Tax rate not found")
+		} else {
+			response.Error(w, http.StatusInternalServerError, "Failed to retrieve tax rate")
+		}
+		return
+	}
+
+	response.JSON(w, http.StatusOK, *rate)
+}
+
+// CreateTaxRate creates a new tax rate. Rates are active unless "active" is false.
+func (h *TaxRateHandler) CreateTaxRate(w http.ResponseWriter, r *http.Request) {
+	rate := models.TaxRate{Active: true}
+	if err := json.NewDecoder(r.Body).Decode(&rate); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := rate.Validate(); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid tax rate: "+err.Error())
+		return
+	}
+
+	rateID, err := h.Store.CreateTaxRate(&rate)
+	if errors.Is(err, database.ErrDuplicateTaxCode) {
+		response.Error(w, http.StatusConflict, "Tax code already exists")
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating tax rate in DB: %v", err)
+		response.Error(w, http.StatusInternalServerError, "Failed to create tax rate")
+		return
+	}
+
+	rate.ID = int(rateID)
+	response.JSON(w, http.StatusCreated, rate)
+}
+
+// UpdateTaxRate replaces an existing tax rate. Invoices already created keep the
+// rate they were calculated with; deactivate a rate to stop it being used.
+func (h *TaxRateHandler) UpdateTaxRate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Path[len("/api/tax-rates/"):])
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid tax rate ID")
+		return
+	}
+
+	rate := models.TaxRate{Active: true}
+	if err := json.NewDecoder(r.Body).Decode(&rate); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	rate.ID = id
+
+	if err := rate.Validate(); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid tax rate: "+err.Error())
+		return
+	}
+
+	if err := h.Store.UpdateTaxRate(&rate); err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			response.Error(w, http.StatusNotFound, "Tax rate not found")
+		case errors.Is(err, database.ErrDuplicateTaxCode):
+			response.Error(w, http.StatusConflict, "Tax code already exists")
+		default:
+			log.Printf("Error updating tax rate in DB: %v", err)
+			response.Error(w, http.StatusInternalServerError, "Failed to update tax rate")
+		}
+		return
+	}
+
+	response.JSON(w, http.StatusOK, rate)
+}
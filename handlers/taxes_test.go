@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"tiny-invoicing/database"
+	"tiny-invoicing/models"
+)
+
+// MockTaxRateStore is a mock implementation of TaxRateStore.
+type MockTaxRateStore struct {
+	GetTaxRatesFunc    func() ([]models.TaxRate, error)
+	GetTaxRateByIDFunc func(id int) (*models.TaxRate, error)
+	CreateTaxRateFunc  func(rate *models.TaxRate) (int64, error)
+	UpdateTaxRateFunc  func(rate *models.TaxRate) error
+}
+
+func (m *MockTaxRateStore) GetTaxRates() ([]models.TaxRate, error) {
+	if m.GetTaxRatesFunc != nil {
+		return m.GetTaxRatesFunc()
+	}
+	return nil, nil
+}
+
+func (m *MockTaxRateStore) GetTaxRateByID(id int) (*models.TaxRate, error) {
+	if m.GetTaxRateByIDFunc != nil {
+		return m.GetTaxRateByIDFunc(id)
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockTaxRateStore) CreateTaxRate(rate *models.TaxRate) (int64, error) {
+	if m.CreateTaxRateFunc != nil {
+		return m.CreateTaxRateFunc(rate)
+	}
+	return 0, nil
+}
+
+func (m *MockTaxRateStore) UpdateTaxRate(rate *models.TaxRate) error {
+	if m.UpdateTaxRateFunc != nil {
+		return m.UpdateTaxRateFunc(rate)
+	}
+	return nil
+}
+
+func TestCreateTaxRate_Success(t *testing.T) {
+	var created models.TaxRate
+	handler := &TaxRateHandler{Store: &MockTaxRateStore{
+		CreateTaxRateFunc: func(rate *models.TaxRate) (int64, error) {
+			created = *rate
+			return 4, nil
+		},
+	}}
+
+	reqBody := []byte(`{"code": "ny", "name": "New York sales tax", "kind": "sales_tax", "rate": 8.875}`)
+	req := httptest.NewRequest("POST", "/api/tax-rates", bytes.NewBuffer(reqBody))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handler.CreateTaxRate).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusCreated {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+	}
+	if created.Code != "NY" || created.Rate != models.Percent(88750) || !created.Active {
+		t.Errorf("unexpected tax rate passed to store: %+v", created)
+	}
+}
+
+func TestCreateTaxRate_InvalidKind(t *testing.T) {
+	handler := &TaxRateHandler{Store: &MockTaxRateStore{}}
+
+	reqBody := []byte(`{"code": "X", "name": "Mystery tax", "kind": "excise", "rate": 5}`)
+	req := httptest.NewRequest("POST", "/api/tax-rates", bytes.NewBuffer(reqBody))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handler.CreateTaxRate).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
+
+func TestUpdateTaxRate_DuplicateCode(t *testing.T) {
+	handler := &TaxRateHandler{Store: &MockTaxRateStore{
+		UpdateTaxRateFunc: func(rate *models.TaxRate) error {
+			return database.ErrDuplicateTaxCode
+		},
+	}}
+
+	reqBody := []byte(`{"code": "VAT", "name": "Standard VAT", "kind": "vat", "rate": 20}`)
+	req := httptest.NewRequest("PUT", "/api/tax-rates/2", bytes.NewBuffer(reqBody))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handler.UpdateTaxRate).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusConflict {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusConflict)
+	}
+}
+
+func TestCreateInvoice_AppliesTaxRates(t *testing.T) {
+	var created models.Invoice
+	handler := &InvoiceHandler{Store: &MockInvoiceStore{
+		GetTaxRatesByCodeFunc: func(codes []string) ([]models.TaxRate, error) {
+			return []models.TaxRate{{Code: "VAT", Name: "Standard VAT", Kind: models.TaxKindVAT, Rate: models.NewPercent(2000), Active: true}}, nil
+		},
+		CreateInvoiceFunc: func(invoice *models.Invoice) (int64, error) {
+			created = *invoice
+			return 1, nil
+		},
+	}}
+
+	reqBody := []byte(`{
+		"customer_id": 1,
+		"issue_date": "2025-12-31T00:00:00Z",
+		"due_date": "2026-01-14T00:00:00Z",
+		"line_items": [
+			{"description": "Consulting", "quantity": 3, "unit_price": 33.33, "tax_codes": ["vat"]},
+			{"description": "Postage", "quantity": 1, "unit_price": 5.00}
+		]
+	}`)
+	req := httptest.NewRequest("POST", "/api/invoices", bytes.NewBuffer(reqBody))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handler.CreateInvoice).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+	}
+	if created.Subtotal.String() != "104.99" || created.TaxTotal.String() != "20.00" || created.Total.String() != "124.99" {
+		t.Errorf("unexpected totals: subtotal %s, tax %s, total %s", created.Subtotal, created.TaxTotal, created.Total)
+	}
+	if len(created.Taxes) != 1 || created.Taxes[0].TaxableAmount.String() != "99.99" {
+		t.Errorf("unexpected tax summary: %+v", created.Taxes)
+	}
+}
+
+func TestCreateInvoice_UnknownTaxCode(t *testing.T) {
+	handler := &InvoiceHandler{Store: &MockInvoiceStore{}}
+
+	reqBody := []byte(`{
+		"customer_id": 1,
+		"issue_date": "2025-12-31T00:00:00Z",
+		"due_date": "2026-01-14T00:00:00Z",
+		"line_items": [{"description": "Item", "quantity": 1, "unit_price": 10.00, "tax_codes": ["GST"]}]
+	}`)
+	req := httptest.NewRequest("POST", "/api/invoices", bytes.NewBuffer(reqBody))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handler.CreateInvoice).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+	expected := "{\"error\":\"Invalid tax code: unknown tax code \\\"GST\\\"\"}\n"
+	if rr.Body.String() != expected {
+		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+	}
+}
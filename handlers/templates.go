@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"tiny-invoicing/models"
+	"tiny-invoicing/response"
+	"tiny-invoicing/templates"
+)
+
+// TemplateStore defines the interface for invoice template persistence.
+type TemplateStore interface {
+	GetInvoiceTemplates() ([]models.InvoiceTemplate, error)
+	GetInvoiceTemplate(name string) (*models.InvoiceTemplate, error)
+	SaveInvoiceTemplate(template *models.InvoiceTemplate) error
+	DeleteInvoiceTemplate(name string) error
+}
+
+// TemplateHandler handles invoice templates saved in the database. Templates in the
+// templates directory and the built-in default are not managed here.
+type TemplateHandler struct {
+	Store TemplateStore
+}
+
+// GetTemplates lists the saved templates.
+func (h *TemplateHandler) GetTemplates(w http.ResponseWriter, r *http.Request) {
+	saved, err := h.Store.GetInvoiceTemplates()
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to retrieve templates")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, saved)
+}
+
+// GetTemplate retrieves a saved template.
+func (h *TemplateHandler) GetTemplate(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Path[len("/api/templates/"):]
+
+	template, err := h.Store.GetInvoiceTemplate(name)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			response.Error(w, http.StatusNotFound, "Template not found")
+		} else {
+			response.Error(w, http.StatusInternalServerError, "Failed to retrieve template")
+		}
+		return
+	}
+
+	response.JSON(w, http.StatusOK, *template)
+}
+
+// SaveTemplate creates or replaces a template. The body must be a valid html/template
+// that renders a sample invoice without errors.
+func (h *TemplateHandler) SaveTemplate(w http.ResponseWriter, r *http.Request) {
+	var template models.InvoiceTemplate
+	if err := json.NewDecoder(r.Body).Decode(&template); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	template.Name = r.URL.Path[len("/api/templates/"):]
+
+	if err := template.Validate(); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid template: "+err.Error())
+		return
+	}
+	if err := templates.Check(template.Body); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid template: "+err.Error())
+		return
+	}
+
+	if err := h.Store.SaveInvoiceTemplate(&template); err != nil {
+		log.Printf("Error saving template in DB: %v", err)
+		response.Error(w, http.StatusInternalServerError, "Failed to save template")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, template)
+}
+
+// DeleteTemplate removes a saved template. Customers using it get the default template.
+func (h *TemplateHandler) DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Path[len("/api/templates/"):]
+
+	if err := h.Store.DeleteInvoiceTemplate(name); err != nil {
+		if err == sql.ErrNoRows {
+			response.Error(w, http.StatusNotFound, "Template not found")
+		} else {
+			log.Printf("Error deleting template in DB: %v", err)
+			response.Error(w, http.StatusInternalServerError, "Failed to delete template")
+		}
+		return
+	}
+
+	response.JSON(w, http.StatusOK, map[string]string{"message": "Template deleted successfully"})
+}
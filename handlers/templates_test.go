@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tiny-invoicing/database"
+	"tiny-invoicing/models"
+	"tiny-invoicing/templates"
+)
+
+// MockTemplateStore is a mock implementation of TemplateStore.
+type MockTemplateStore struct {
+	GetInvoiceTemplatesFunc   func() ([]models.InvoiceTemplate, error)
+	GetInvoiceTemplateFunc    func(name string) (*models.InvoiceTemplate, error)
+	SaveInvoiceTemplateFunc   func(template *models.InvoiceTemplate) error
+	DeleteInvoiceTemplateFunc func(name string) error
+}
+
+func (m *MockTemplateStore) GetInvoiceTemplates() ([]models.InvoiceTemplate, error) {
+	if m.GetInvoiceTemplatesFunc != nil {
+		return m.GetInvoiceTemplatesFunc()
+	}
+	return nil, nil
+}
+
+func (m *MockTemplateStore) GetInvoiceTemplate(name string) (*models.InvoiceTemplate, error) {
+	if m.GetInvoiceTemplateFunc != nil {
+		return m.GetInvoiceTemplateFunc(name)
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockTemplateStore) SaveInvoiceTemplate(template *models.InvoiceTemplate) error {
+	if m.SaveInvoiceTemplateFunc != nil {
+		return m.SaveInvoiceTemplateFunc(template)
+	}
+	return nil
+}
+
+func (m *MockTemplateStore) DeleteInvoiceTemplate(name string) error {
+	if m.DeleteInvoiceTemplateFunc != nil {
+		return m.DeleteInvoiceTemplateFunc(name)
+	}
+	return nil
+}
+
+func TestSaveTemplate_Success(t *testing.T) {
+	var saved models.InvoiceTemplate
+	handler := &TemplateHandler{Store: &MockTemplateStore{
+		SaveInvoiceTemplateFunc: func(template *models.InvoiceTemplate) error {
+			saved = *template
+			return nil
+		},
+	}}
+
+	reqBody := []byte(`{"body": "<h1>{{.Invoice.Title}}</h1>"}`)
+	req := httptest.NewRequest("PUT", "/api/templates/compact", bytes.NewBuffer(reqBody))
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(handler.SaveTemplate).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	if saved.Name != "compact" || saved.Body != "<h1>{{.Invoice.Title}}</h1>" {
+		t.Errorf("saved template = %+v", saved)
+	}
+}
+
+func TestSaveTemplate_Invalid(t *testing.T) {
+	handler := &TemplateHandler{Store: &MockTemplateStore{
+		SaveInvoiceTemplateFunc: func(template *models.InvoiceTemplate) error {
+			t.Error("invalid template should not be saved")
+			return nil
+		},
+	}}
+
+	tests := []struct {
+		path string
+		body string
+	}{
+		{"/api/templates/compact", `{"body": "{{.Invoice.Totl}}"}`},
+		{"/api/templates/compact", `{"body": "{{end}}"}`},
+		{"/api/templates/Bad Name", `{"body": "ok"}`},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("PUT", "/api/templates/x", bytes.NewBufferString(tt.body))
+		req.URL.Path = tt.path
+		rr := httptest.NewRecorder()
+
+		http.HandlerFunc(handler.SaveTemplate).ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: handler returned wrong status code: got %v want %v", tt.path, tt.body, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestPreviewInvoice(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "compact.html"), []byte(`<p>{{.Invoice.Title}} for {{.Customer.Name}} by {{.Branding.CompanyName}}</p>`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		template string // the customer's
+		query    string
+		status   int
+		want     string
+	}{
+		{"customer template", "compact", "", http.StatusOK, "<p>INV-2026-00042 for Acme by Tiny Invoicing Ltd</p>"},
+		{"default template", "", "", http.StatusOK, "<h1>INVOICE</h1>"},
+		{"removed customer template", "gone", "", http.StatusOK, "<h1>INVOICE</h1>"},
+		{"requested template", "", "?template=compact", http.StatusOK, "<p>INV-2026-00042"},
+		{"unknown requested template", "", "?template=gone", http.StatusNotFound, "Template not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &InvoiceHandler{
+				Store: &MockInvoiceStore{
+					GetInvoiceByIDFunc: sentInvoice,
+					GetCustomerByIDFunc: func(id int) (*database.Customer, error) {
+						return &database.Customer{ID: id, Name: "Acme", Address: "1 Main St", Currency: "USD", Template: tt.template}, nil
+					},
+					GetBrandingFunc: func() (*models.Branding, error) {
+						return &models.Branding{CompanyName: "Tiny Invoicing Ltd", Color: models.DefaultBrandColor}, nil
+					},
+				},
+				Templates: &templates.Renderer{Dir: dir},
+			}
+
+			req := httptest.NewRequest("GET", "/api/invoices/1/preview"+tt.query, nil)
+			req.SetPathValue("id", "1")
+			rr := httptest.NewRecorder()
+
+			http.HandlerFunc(handler.PreviewInvoice).ServeHTTP(rr, req)
+
+			if rr.Code != tt.status {
+				t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, tt.status, rr.Body.String())
+			}
+			if !strings.Contains(rr.Body.String(), tt.want) {
+				t.Errorf("body %q does not contain %q", rr.Body.String(), tt.want)
+			}
+			if tt.status == http.StatusOK && rr.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+				t.Errorf("Content-Type = %q", rr.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"tiny-invoicing/auth"
+	"tiny-invoicing/database"
+	"tiny-invoicing/models"
+	"tiny-invoicing/response"
+)
+
+// newAccount holds the username and password someone signs up with.
+type newAccount struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// user validates the account and returns the user to create, with its password
+// hashed. It writes the error response and returns false if the account is invalid.
+func (a newAccount) user(w http.ResponseWriter, role models.Role) (*database.User, bool) {
+	if a.Username == "" || a.Password == "" {
+		response.Error(w, http.StatusBadRequest, "Username and password are required")
+		return nil, false
+	}
+	hashedPassword, err := auth.HashPassword(a.Password)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to hash password")
+		return nil, false
+	}
+	return &database.User{Username: a.Username, PasswordHash: hashedPassword, Role: role}, true
+}
+
+// CreateUser creates a user with a role. Admins create admins, accountants and
+// viewers; only owners create owners.
+func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		newAccount
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	role, err := models.ParseRole(req.Role)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !auth.CurrentUser(r).Role.CanAssign(role) {
+		response.Error(w, http.StatusForbidden, "Only owners can manage owners")
+		return
+	}
+	user, ok := req.user(w, role)
+	if !ok {
+		return
+	}
+
+	id, err := h.Store.CreateUser(user)
+	if err != nil {
+		if err == database.ErrUsernameTaken {
+			response.Error(w, http.StatusConflict, "Username already taken")
+			return
+		}
+		log.Printf("Error creating user in DB: %v", err)
+		response.Error(w, http.StatusInternalServerError, "Failed to create user")
+		return
+	}
+	user.ID = int(id)
+	response.JSON(w, http.StatusCreated, user)
+}
+
+// GetUsers lists all users with their roles.
+func (h *UserHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := h.Store.GetUsers()
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to retrieve users")
+		return
+	}
+	if users == nil {
+		users = []database.User{}
+	}
+	response.JSON(w, http.StatusOK, users)
+}
+
+// UpdateUserRole assigns a user a new role. Admins manage admins, accountants and
+// viewers; only owners can make or unmake owners, and the last owner stays one.
+func (h *UserHandler) UpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Path[len("/api/users/"):])
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	role, err := models.ParseRole(req.Role)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, err := h.Store.GetUserByID(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			response.Error(w, http.StatusNotFound, "User not found")
+		} else {
+			log.Printf("Error loading user: %v", err)
+			response.Error(w, http.StatusInternalServerError, "Failed to update user")
+		}
+		return
+	}
+	if actor := auth.CurrentUser(r); !actor.Role.CanAssign(user.Role) || !actor.Role.CanAssign(role) {
+		response.Error(w, http.StatusForbidden, "Only owners can manage owners")
+		return
+	}
+
+	if err := h.Store.UpdateUserRole(id, role); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			response.Error(w, http.StatusNotFound, "User not found")
+		case models.ErrLastOwner:
+			response.Error(w, http.StatusConflict, "The last owner cannot be given another role")
+		default:
+			log.Printf("Error updating user role in DB: %v", err)
+			response.Error(w, http.StatusInternalServerError, "Failed to update user")
+		}
+		return
+	}
+	user.Role = role
+	response.JSON(w, http.StatusOK, user)
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"tiny-invoicing/auth"
+	"tiny-invoicing/database"
+	"tiny-invoicing/database/memstore"
+	"tiny-invoicing/models"
+)
+
+func TestUpdateUserRole(t *testing.T) {
+	store := memstore.New()
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[string]int)
+	for _, user := range []database.User{
+		{Username: "owner", Role: models.RoleOwner},
+		{Username: "admin", Role: models.RoleAdmin},
+		{Username: "bookkeeper", Role: models.RoleViewer},
+	} {
+		user.PasswordHash = string(hash)
+		id, err := store.CreateUser(&user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[user.Username] = int(id)
+	}
+
+	users := &UserHandler{Store: store}
+	assign := func(as, username, role string, want int) {
+		t.Helper()
+		req := httptest.NewRequest("PUT", fmt.Sprintf("/api/users/%d", ids[username]), strings.NewReader(fmt.Sprintf(`{"role": %q}`, role)))
+		req.SetBasicAuth(as, "secret")
+		rr := httptest.NewRecorder()
+		auth.Authenticate(store, auth.Require(models.PermissionManageUsers, users.UpdateUserRole))(rr, req)
+		if rr.Code != want {
+			t.Fatalf("%s making %s %s: got status %d want %d: %s", as, username, role, rr.Code, want, rr.Body.String())
+		}
+	}
+
+	assign("bookkeeper", "bookkeeper", "admin", http.StatusForbidden)
+	assign("admin", "bookkeeper", "root", http.StatusBadRequest)
+	assign("admin", "bookkeeper", "accountant", http.StatusOK)
+	// Only owners manage owners.
+	assign("admin", "admin", "owner", http.StatusForbidden)
+	assign("admin", "owner", "viewer", http.StatusForbidden)
+	assign("owner", "owner", "admin", http.StatusConflict)
+	assign("owner", "admin", "owner", http.StatusOK)
+	assign("owner", "owner", "admin", http.StatusOK)
+
+	req := httptest.NewRequest("GET", "/api/users", nil)
+	req.SetBasicAuth("owner", "secret")
+	rr := httptest.NewRecorder()
+	auth.Authenticate(store, auth.Require(models.PermissionManageUsers, users.GetUsers))(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GET /api/users: got status %d want %d", rr.Code, http.StatusOK)
+	}
+	var listed []map[string]interface{}
+	if err := json.NewDecoder(rr.Body).Decode(&listed); err != nil {
+		t.Fatal(err)
+	}
+	roles := make(map[string]interface{})
+	for _, user := range listed {
+		if _, ok := user["password_hash"]; ok {
+			t.Errorf("user %v lists a password hash", user["username"])
+		}
+		roles[user["username"].(string)] = user["role"]
+	}
+	if roles["owner"] != "admin" || roles["admin"] != "owner" || roles["bookkeeper"] != "accountant" {
+		t.Errorf("roles = %v", roles)
+	}
+}
+
+func TestCreateUser(t *testing.T) {
+	store := memstore.New()
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for username, role := range map[string]models.Role{"admin": models.RoleAdmin, "bookkeeper": models.RoleAccountant} {
+		if _, err := store.CreateUser(&database.User{Username: username, PasswordHash: string(hash), Role: role}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	users := &UserHandler{Store: store}
+	create := func(as, body string, want int) {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/api/users", strings.NewReader(body))
+		req.SetBasicAuth(as, "secret")
+		rr := httptest.NewRecorder()
+		auth.Authenticate(store, auth.Require(models.PermissionManageUsers, users.CreateUser))(rr, req)
+		if rr.Code != want {
+			t.Fatalf("%s creating %s: got status %d want %d: %s", as, body, rr.Code, want, rr.Body.String())
+		}
+	}
+
+	// Creating users takes an authenticated admin.
+	create("nobody", `{"username": "ann", "password": "pw", "role": "viewer"}`, http.StatusUnauthorized)
+	create("bookkeeper", `{"username": "ann", "password": "pw", "role": "viewer"}`, http.StatusForbidden)
+	create("admin", `{"username": "ann", "password": "", "role": "viewer"}`, http.StatusBadRequest)
+	create("admin", `{"username": "ann", "password": "pw", "role": "root"}`, http.StatusBadRequest)
+	create("admin", `{"username": "ann", "password": "pw", "role": "owner"}`, http.StatusForbidden)
+	create("admin", `{"username": "ann", "password": "pw", "role": "viewer"}`, http.StatusCreated)
+	create("admin", `{"username": "ann", "password": "pw", "role": "accountant"}`, http.StatusConflict)
+
+	user, err := store.GetUserByUsername("ann")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Role != models.RoleViewer || !auth.CheckPasswordHash("pw", user.PasswordHash) {
+		t.Errorf("created user = %+v", user)
+	}
+}
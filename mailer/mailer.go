@@ -0,0 +1,141 @@
+// Package mailer sends HTML email with attachments through an SMTP server.
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Attachment is a file attached to a message.
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Message is an HTML email to a single recipient.
+type Message struct {
+	To          string
+	Subject     string
+	HTML        string
+	Attachments []Attachment
+}
+
+// Sender delivers messages. It returns the Message-ID given to the message, also
+// when delivery fails, so that failures can be traced in the server's logs.
+type Sender interface {
+	Send(msg *Message) (messageID string, err error)
+}
+
+// SMTP sends messages through an SMTP server, upgrading the connection with
+// STARTTLS when the server offers it.
+type SMTP struct {
+	// Addr is the server's host:port.
+	Addr string
+	// Username and Password are used for PLAIN authentication if Username is set.
+	// net/smtp only sends them over TLS or to localhost.
+	Username string
+	Password string
+	// From is the sender, e.g. "Billing <billing@example.com>".
+	From string
+}
+
+// Send delivers msg.
+func (s *SMTP) Send(msg *Message) (string, error) {
+	from, err := mail.ParseAddress(s.From)
+	if err != nil {
+		return "", fmt.Errorf("invalid sender address: %w", err)
+	}
+	messageID := newMessageID(from.Address)
+
+	data, err := msg.Encode(s.From, messageID, time.Now())
+	if err != nil {
+		return messageID, err
+	}
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, _ := net.SplitHostPort(s.Addr)
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+	return messageID, smtp.SendMail(s.Addr, auth, from.Address, []string{msg.To}, data)
+}
+
+// newMessageID returns a unique Message-ID in the sender's domain.
+func newMessageID(from string) string {
+	random := make([]byte, 16)
+	rand.Read(random)
+	domain := "localhost"
+	if at := strings.LastIndex(from, "@"); at >= 0 {
+		domain = from[at+1:]
+	}
+	return "<" + hex.EncodeToString(random) + "@" + domain + ">"
+}
+
+// Encode formats msg as a MIME message: the HTML body followed by the attachments.
+func (msg *Message) Encode(from, messageID string, date time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	body := multipart.NewWriter(&buf)
+
+	header := func(name, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
+	}
+	header("From", from)
+	header("To", msg.To)
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("Message-ID", messageID)
+	header("MIME-Version", "1.0")
+	header("Content-Type", "multipart/mixed; boundary="+body.Boundary())
+	buf.WriteString("\r\n")
+
+	part, err := body.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/html; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	qp := quotedprintable.NewWriter(part)
+	if _, err := qp.Write([]byte(msg.HTML)); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+
+	for _, attachment := range msg.Attachments {
+		part, err := body.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {attachment.ContentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})},
+		})
+		if err != nil {
+			return nil, err
+		}
+		// Base64 in lines of 76 characters, as MIME requires.
+		encoded := base64.StdEncoding.EncodeToString(attachment.Data)
+		for len(encoded) > 76 {
+			fmt.Fprintf(part, "%s\r\n", encoded[:76])
+			encoded = encoded[76:]
+		}
+		fmt.Fprintf(part, "%s\r\n", encoded)
+	}
+
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,109 @@
+package mailer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"tiny-invoicing/mailer/mailtest"
+)
+
+func TestSMTP_Send(t *testing.T) {
+	server, err := mailtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	pdf := bytes.Repeat([]byte("%PDF-1.4 binary \x00\xff "), 20)
+	sender := &SMTP{Addr: server.Addr, From: "Billing <billing@example.com>"}
+	messageID, err := sender.Send(&Message{
+		To:      "customer@example.org",
+		Subject: "Invoice INV-2026-00001 from Café Ltd",
+		HTML:    "<p>Please find your invoice attached.</p>",
+		Attachments: []Attachment{
+			{Filename: "invoice-INV-2026-00001.pdf", ContentType: "application/pdf", Data: pdf},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if !strings.HasSuffix(messageID, "@example.com>") {
+		t.Errorf("messageID = %q, want one in the sender's domain", messageID)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("server received %d messages, want 1", len(messages))
+	}
+	received := messages[0]
+	if received.From != "billing@example.com" || len(received.To) != 1 || received.To[0] != "customer@example.org" {
+		t.Errorf("envelope = %s -> %v", received.From, received.To)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(received.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := msg.Header.Get("Message-ID"); got != messageID {
+		t.Errorf("Message-ID header = %q, want %q", got, messageID)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if subject != "Invoice INV-2026-00001 from Café Ltd" {
+		t.Errorf("Subject = %q", subject)
+	}
+
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+
+	html, err := parts.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(quotedprintable.NewReader(html))
+	if string(body) != "<p>Please find your invoice attached.</p>" {
+		t.Errorf("HTML part = %q", body)
+	}
+
+	attachment, err := parts.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attachment.FileName() != "invoice-INV-2026-00001.pdf" {
+		t.Errorf("attachment name = %q", attachment.FileName())
+	}
+	data, _ := io.ReadAll(base64.NewDecoder(base64.StdEncoding, attachment))
+	if !bytes.Equal(data, pdf) {
+		t.Error("attachment does not round-trip")
+	}
+}
+
+func TestSMTP_SendRejected(t *testing.T) {
+	server, err := mailtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Reject(true)
+
+	sender := &SMTP{Addr: server.Addr, From: "billing@example.com"}
+	messageID, err := sender.Send(&Message{To: "nobody@example.org", Subject: "Invoice", HTML: "<p>Hi</p>"})
+	if err == nil {
+		t.Fatal("Send succeeded although the server rejected the recipient")
+	}
+	if messageID == "" {
+		t.Error("a failed send should still report its Message-ID")
+	}
+	if len(server.Messages()) != 0 {
+		t.Error("server kept a rejected message")
+	}
+}
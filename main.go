@@ -1,59 +1,580 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net"
 	"net/http"
+	"net/mail"
+	"os"
 	"time"
 
 	"tiny-invoicing/auth"
 	"tiny-invoicing/database"
 	"tiny-invoicing/handlers"
+	"tiny-invoicing/mailer"
+	"tiny-invoicing/models"
+	"tiny-invoicing/recurring"
+	"tiny-invoicing/templates"
 )
 
 func main() {
+	// Rounding mode for amounts that do not divide evenly into cents (half_up or half_even)
+	mode, err := models.ParseRoundingMode(os.Getenv("ROUNDING_MODE"))
+	if err != nil {
+		log.Fatalf("Invalid ROUNDING_MODE: %v", err)
+	}
+	models.DefaultRoundingMode = mode
+
+	// Currency that reports are expressed in
+	if code := os.Getenv("BASE_CURRENCY"); code != "" {
+		base, err := models.ParseCurrency(code)
+		if err != nil {
+			log.Fatalf("Invalid BASE_CURRENCY: %v", err)
+		}
+		models.BaseCurrency = base
+	}
+
+	// Company details and colours printed on invoices until branding is saved through the API
+	branding := models.Branding{
+		CompanyName:         os.Getenv("COMPANY_NAME"),
+		CompanyAddress:      os.Getenv("COMPANY_ADDRESS"),
+		Color:               os.Getenv("BRAND_COLOR"),
+		PaymentInstructions: os.Getenv("PAYMENT_INSTRUCTIONS"),
+		Footer:              os.Getenv("INVOICE_FOOTER"),
+	}
+	if err := branding.Validate(); err != nil {
+		log.Fatalf("Invalid BRAND_COLOR: %v", err)
+	}
+
 	// Initialize database
 	if err := database.InitDB(); err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer database.DB.Close()
 
+	// "tiny-invoicing migrate ..." manages the schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
+
+	// Bring the schema up to date
+	if err := migrateUp(); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+
+	// "tiny-invoicing create-user ..." adds a user, such as the first owner, and exits
+	if len(os.Args) > 1 && os.Args[1] == "create-user" {
+		if err := runCreateUser(os.Args[2:], os.Stdin); err != nil {
+			log.Fatalf("create-user: %v", err)
+		}
+		return
+	}
+
 	// Ensure a default customer exists for the demo
 	if err := database.EnsureDefaultCustomer(); err != nil {
 		log.Printf("Warning: Failed to ensure default customer: %v", err)
 	}
+	if err := database.EnsureNumberSequences(); err != nil {
+		log.Fatalf("Failed to create numbering sequences: %v", err)
+	}
+
+	if err := database.EnsureBranding(branding); err != nil {
+		log.Printf("Warning: Failed to store default branding: %v", err)
+	}
+
+	// Load exchange rates against the base currency, if a rates file is configured
+	if path := os.Getenv("EXCHANGE_RATES_FILE"); path != "" {
+		if err := loadExchangeRates(path); err != nil {
+			log.Fatalf("Failed to load exchange rates: %v", err)
+		}
+	}
 
 	// Set up router
 	mux := http.NewServeMux()
 
+	store := &database.Store{}
+
+	// Invoice templates: saved through the API, then files in TEMPLATES_DIR, then the built-in default
+	renderer := &templates.Renderer{
+		Store: store,
+		Dir:   os.Getenv("TEMPLATES_DIR"),
+	}
+
 	invoiceHandler := &handlers.InvoiceHandler{
-		Store: &database.Store{},
+		Store:     store,
+		Templates: renderer,
+	}
+	// Email delivery of invoices, if an SMTP server is configured
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		if _, err := mail.ParseAddress(os.Getenv("SMTP_FROM")); err != nil {
+			log.Fatalf("Invalid SMTP_FROM: %v", err)
+		}
+		invoiceHandler.Mailer = &mailer.SMTP{
+			Addr:     net.JoinHostPort(host, port),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("SMTP_FROM"),
+		}
+	}
+	customerHandler := &handlers.CustomerHandler{
+		Store:     store,
+		Templates: renderer,
+	}
+	paymentHandler := &handlers.PaymentHandler{
+		Store: store,
+	}
+	taxRateHandler := &handlers.TaxRateHandler{
+		Store: store,
 	}
+	exchangeRateHandler := &handlers.ExchangeRateHandler{
+		Store: store,
+	}
+	reportHandler := &handlers.ReportHandler{
+		Store: store,
+	}
+	numberSequenceHandler := &handlers.NumberSequenceHandler{
+		Store: store,
+	}
+	brandingHandler := &handlers.BrandingHandler{
+		Store: store,
+	}
+	templateHandler := &handlers.TemplateHandler{
+		Store: store,
+	}
+	creditNoteHandler := &handlers.CreditNoteHandler{
+		Store: store,
+	}
+	recurringProfileHandler := &handlers.RecurringProfileHandler{
+		Store: store,
+	}
+	estimateHandler := &handlers.EstimateHandler{
+		Store: store,
+	}
+	userHandler := &handlers.UserHandler{
+		Store: store,
+	}
+	invitationHandler := &handlers.InvitationHandler{
+		Store: store,
+		TTL:   handlers.DefaultInvitationTTL,
+	}
+	if ttl := os.Getenv("INVITATION_TTL"); ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d <= 0 {
+			log.Fatalf("Invalid INVITATION_TTL: %q", ttl)
+		}
+		invitationHandler.TTL = d
+	}
+
+	// Until there are users, the first owner signs up with a one-time setup token
+	setupHandler := &handlers.SetupHandler{
+		Store: store,
+	}
+	users, err := database.CountUsers()
+	if err != nil {
+		log.Fatalf("Failed to count users: %v", err)
+	}
+	if users == 0 {
+		setupHandler.Token = os.Getenv("SETUP_TOKEN")
+		if setupHandler.Token == "" {
+			if setupHandler.Token, err = auth.NewToken(); err != nil {
+				log.Fatalf("Failed to generate setup token: %v", err)
+			}
+		}
+		log.Printf("No users yet: create the owner in the browser with setup token %s, or run \"tiny-invoicing create-user -username NAME\"", setupHandler.Token)
+	}
+	apiTokenHandler := &handlers.APITokenHandler{
+		Store: store,
+	}
+	sessionHandler := &handlers.SessionHandler{
+		Store: store,
+		TTL:   auth.DefaultSessionTTL,
+	}
+	if ttl := os.Getenv("SESSION_TTL"); ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d <= 0 {
+			log.Fatalf("Invalid SESSION_TTL: %q", ttl)
+		}
+		sessionHandler.TTL = d
+	}
+
+	// Retried invoice creations with the same Idempotency-Key get the first response back
+	idempotency := &handlers.Idempotency{Store: store, TTL: handlers.DefaultIdempotencyTTL}
+	if ttl := os.Getenv("IDEMPOTENCY_KEY_TTL"); ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d <= 0 {
+			log.Fatalf("Invalid IDEMPOTENCY_KEY_TTL: %q", ttl)
+		}
+		idempotency.TTL = d
+	}
+
+	// Generate the invoices of recurring profiles as their runs fall due
+	scheduler := &recurring.Scheduler{Store: store}
+	go scheduler.Run(context.Background())
+
+	// First-run setup of the owner, with the setup token logged at startup
+	mux.HandleFunc("/api/setup", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			setupHandler.GetSetupStatus(w, r)
+		case http.MethodPost:
+			setupHandler.CreateOwner(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	// Browser sessions: log in once, then send the session cookie and CSRF token
+	mux.HandleFunc("/api/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		sessionHandler.Login(w, r)
+	})
+	mux.HandleFunc("/api/logout", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		auth.Require(models.PermissionAccount, sessionHandler.Logout)(w, r)
+	}))
+	mux.HandleFunc("/api/session", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		auth.Require(models.PermissionAccount, sessionHandler.GetSession)(w, r)
+	}))
+	mux.HandleFunc("/api/sessions", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		auth.Require(models.PermissionAccount, sessionHandler.GetSessions)(w, r)
+	}))
+	mux.HandleFunc("/api/sessions/", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		auth.Require(models.PermissionAccount, sessionHandler.RevokeSession)(w, r)
+	}))
 
-	// Public route to create an admin user (for demo purposes)
-	mux.HandleFunc("/api/admin/create-user", handlers.CreateAdminUser)
+	// API tokens for scripts, sent as "Authorization: Bearer <token>"
+	mux.HandleFunc("/api/tokens", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			auth.Require(models.PermissionAccount, apiTokenHandler.GetAPITokens)(w, r)
+		case http.MethodPost:
+			auth.Require(models.PermissionAccount, apiTokenHandler.CreateAPIToken)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	mux.HandleFunc("/api/tokens/", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		auth.Require(models.PermissionAccount, apiTokenHandler.RevokeAPIToken)(w, r)
+	}))
+
+	// User management: who may sign in, and with which role
+	mux.HandleFunc("/api/users", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			auth.Require(models.PermissionManageUsers, userHandler.GetUsers)(w, r)
+		case http.MethodPost:
+			auth.Require(models.PermissionManageUsers, userHandler.CreateUser)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	mux.HandleFunc("/api/users/", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		auth.Require(models.PermissionManageUsers, userHandler.UpdateUserRole)(w, r)
+	}))
+
+	// Invitation links for teammates to sign up with a role
+	mux.HandleFunc("/api/invitations", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			auth.Require(models.PermissionManageUsers, invitationHandler.GetInvitations)(w, r)
+		case http.MethodPost:
+			auth.Require(models.PermissionManageUsers, invitationHandler.CreateInvitation)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	mux.HandleFunc("/api/invitations/", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		auth.Require(models.PermissionManageUsers, invitationHandler.RevokeInvitation)(w, r)
+	}))
+	mux.HandleFunc("/api/invitations/accept", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		invitationHandler.AcceptInvitation(w, r)
+	})
 
 	// API routes
-	mux.HandleFunc("/api/invoices", auth.BasicAuth(func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/api/invoices", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
-			invoiceHandler.GetInvoices(w, r)
+			auth.Require(models.PermissionRead, invoiceHandler.GetInvoices)(w, r)
 		case http.MethodPost:
-			invoiceHandler.CreateInvoice(w, r)
+			auth.Require(models.PermissionWriteInvoices, idempotency.Wrap(invoiceHandler.CreateInvoice))(w, r)
 		default:
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
 	}))
-	mux.HandleFunc("/api/invoices/", auth.BasicAuth(func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/api/invoices/", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
-			invoiceHandler.GetInvoice(w, r)
+			auth.Require(models.PermissionRead, invoiceHandler.GetInvoice)(w, r)
 		case http.MethodPut:
-			invoiceHandler.UpdateInvoice(w, r)
+			auth.Require(models.PermissionWriteInvoices, invoiceHandler.UpdateInvoice)(w, r)
 		default:
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
 	}))
 
+	mux.HandleFunc("/api/invoices/{id}/preview", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		auth.Require(models.PermissionRead, invoiceHandler.PreviewInvoice)(w, r)
+	}))
+
+	mux.HandleFunc("/api/invoices/{id}/send", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		auth.Require(models.PermissionWriteInvoices, invoiceHandler.SendInvoice)(w, r)
+	}))
+	mux.HandleFunc("/api/invoices/{id}/deliveries", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		auth.Require(models.PermissionRead, invoiceHandler.GetDeliveries)(w, r)
+	}))
+
+	mux.HandleFunc("/api/invoices/{id}/payments", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			auth.Require(models.PermissionRead, paymentHandler.GetPayments)(w, r)
+		case http.MethodPost:
+			auth.Require(models.PermissionWriteInvoices, paymentHandler.RecordPayment)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	mux.HandleFunc("/api/invoices/{id}/payments/{paymentID}/reverse", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		auth.Require(models.PermissionWriteInvoices, paymentHandler.ReversePayment)(w, r)
+	}))
+
+	mux.HandleFunc("/api/invoices/{id}/credit-notes", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			auth.Require(models.PermissionRead, creditNoteHandler.GetInvoiceCreditNotes)(w, r)
+		case http.MethodPost:
+			auth.Require(models.PermissionWriteInvoices, creditNoteHandler.CreateCreditNote)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	mux.HandleFunc("/api/credit-notes", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		auth.Require(models.PermissionRead, creditNoteHandler.GetCreditNotes)(w, r)
+	}))
+	mux.HandleFunc("/api/credit-notes/", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		auth.Require(models.PermissionRead, creditNoteHandler.GetCreditNote)(w, r)
+	}))
+
+	mux.HandleFunc("/api/customers", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			auth.Require(models.PermissionRead, customerHandler.GetCustomers)(w, r)
+		case http.MethodPost:
+			auth.Require(models.PermissionWriteCustomers, customerHandler.CreateCustomer)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	mux.HandleFunc("/api/customers/", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			auth.Require(models.PermissionRead, customerHandler.GetCustomer)(w, r)
+		case http.MethodPut:
+			auth.Require(models.PermissionWriteCustomers, customerHandler.UpdateCustomer)(w, r)
+		case http.MethodDelete:
+			auth.Require(models.PermissionWriteCustomers, customerHandler.DeleteCustomer)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+
+	mux.HandleFunc("/api/tax-rates", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			auth.Require(models.PermissionRead, taxRateHandler.GetTaxRates)(w, r)
+		case http.MethodPost:
+			auth.Require(models.PermissionWriteSettings, taxRateHandler.CreateTaxRate)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	mux.HandleFunc("/api/tax-rates/", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			auth.Require(models.PermissionRead, taxRateHandler.GetTaxRate)(w, r)
+		case http.MethodPut:
+			auth.Require(models.PermissionWriteSettings, taxRateHandler.UpdateTaxRate)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+
+	mux.HandleFunc("/api/exchange-rates", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		auth.Require(models.PermissionRead, exchangeRateHandler.GetExchangeRates)(w, r)
+	}))
+
+	mux.HandleFunc("/api/reports/sales", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		auth.Require(models.PermissionRead, reportHandler.GetSalesReport)(w, r)
+	}))
+
+	mux.HandleFunc("/api/number-sequences", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		auth.Require(models.PermissionRead, numberSequenceHandler.GetNumberSequences)(w, r)
+	}))
+	mux.HandleFunc("/api/number-sequences/", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		auth.Require(models.PermissionWriteSettings, numberSequenceHandler.UpdateNumberSequence)(w, r)
+	}))
+
+	mux.HandleFunc("/api/branding", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			auth.Require(models.PermissionRead, brandingHandler.GetBranding)(w, r)
+		case http.MethodPut:
+			auth.Require(models.PermissionWriteSettings, brandingHandler.UpdateBranding)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+
+	mux.HandleFunc("/api/templates", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		auth.Require(models.PermissionRead, templateHandler.GetTemplates)(w, r)
+	}))
+	mux.HandleFunc("/api/templates/", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			auth.Require(models.PermissionRead, templateHandler.GetTemplate)(w, r)
+		case http.MethodPut:
+			auth.Require(models.PermissionWriteSettings, templateHandler.SaveTemplate)(w, r)
+		case http.MethodDelete:
+			auth.Require(models.PermissionWriteSettings, templateHandler.DeleteTemplate)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+
+	mux.HandleFunc("/api/recurring-profiles", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			auth.Require(models.PermissionRead, recurringProfileHandler.GetRecurringProfiles)(w, r)
+		case http.MethodPost:
+			auth.Require(models.PermissionWriteInvoices, recurringProfileHandler.CreateRecurringProfile)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	mux.HandleFunc("/api/recurring-profiles/", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			auth.Require(models.PermissionRead, recurringProfileHandler.GetRecurringProfile)(w, r)
+		case http.MethodPut:
+			auth.Require(models.PermissionWriteInvoices, recurringProfileHandler.UpdateRecurringProfile)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+
+	mux.HandleFunc("/api/estimates", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			auth.Require(models.PermissionRead, estimateHandler.GetEstimates)(w, r)
+		case http.MethodPost:
+			auth.Require(models.PermissionWriteInvoices, estimateHandler.CreateEstimate)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	mux.HandleFunc("/api/estimates/", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			auth.Require(models.PermissionRead, estimateHandler.GetEstimate)(w, r)
+		case http.MethodPut:
+			auth.Require(models.PermissionWriteInvoices, estimateHandler.UpdateEstimate)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	mux.HandleFunc("/api/estimates/{id}/convert", auth.Authenticate(store, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		auth.Require(models.PermissionWriteInvoices, estimateHandler.ConvertEstimate)(w, r)
+	}))
+
 	// Static file server
 	mux.Handle("/", http.FileServer(http.Dir("static")))
 
@@ -70,4 +591,24 @@ func main() {
 	if err := server.ListenAndServe(); err != nil {
 		log.Fatalf("Server failed to start: %v", err)
 	}
-}
\ No newline at end of file
+}
+
+// loadExchangeRates stores the rates in a CSV file of "date,currency,rate" lines,
+// each giving the units of currency bought by one unit of the base currency.
+func loadExchangeRates(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	rates, err := models.ParseExchangeRates(file, models.BaseCurrency)
+	if err != nil {
+		return err
+	}
+	if err := database.SaveExchangeRates(rates); err != nil {
+		return err
+	}
+	log.Printf("Loaded %d exchange rates from %s", len(rates), path)
+	return nil
+}
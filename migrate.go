@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"tiny-invoicing/database"
+)
+
+const migrateUsage = "usage: tiny-invoicing migrate [up | down [N] | status]"
+
+// migrateUp applies the pending migrations, logging each one.
+func migrateUp() error {
+	list, err := database.Migrations()
+	if err != nil {
+		return err
+	}
+	applied, err := database.MigrateUp(list)
+	for _, m := range applied {
+		log.Printf("Applied migration %s", m)
+	}
+	return err
+}
+
+// runMigrate runs the migrate subcommand: "up" (the default) applies the pending
+// migrations, "down [N]" reverts the last N (default 1) and "status" lists them all.
+func runMigrate(args []string) error {
+	command := "up"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	switch {
+	case command == "up" && len(args) == 0:
+		return migrateUp()
+
+	case command == "down" && len(args) <= 1:
+		steps := 1
+		if len(args) == 1 {
+			n, err := strconv.Atoi(args[0])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of migrations %q\n%s", args[0], migrateUsage)
+			}
+			steps = n
+		}
+		list, err := database.Migrations()
+		if err != nil {
+			return err
+		}
+		reverted, err := database.MigrateDown(list, steps)
+		for _, m := range reverted {
+			log.Printf("Reverted migration %s", m)
+		}
+		return err
+
+	case command == "status" && len(args) == 0:
+		list, err := database.Migrations()
+		if err != nil {
+			return err
+		}
+		status, err := database.GetMigrationStatus(list)
+		if err != nil {
+			return err
+		}
+		for _, m := range status {
+			applied := "pending"
+			if m.AppliedAt != nil {
+				applied = "applied " + m.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%-40s %s\n", m.Migration, applied)
+		}
+		return nil
+	}
+	return errors.New(migrateUsage)
+}
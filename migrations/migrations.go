@@ -0,0 +1,11 @@
+// Package migrations holds the versioned schema changes, one directory per database
+// backend (mysql, sqlite, postgres). NNNN_name.up.sql applies version NNNN and
+// NNNN_name.down.sql, if present, reverts it. Every backend has the same versions.
+// Applied migrations must not be edited: database.MigrateUp refuses to run when the
+// checksum of an applied file changes. Add a new version instead.
+package migrations
+
+import "embed"
+
+//go:embed mysql sqlite postgres
+var Files embed.FS
@@ -0,0 +1,2 @@
+-- Counts the changes to each invoice, for optimistic concurrency control.
+ALTER TABLE invoices ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,13 @@
+-- Responses to requests made with an Idempotency-Key header, replayed when the
+-- request is retried. status_code is NULL while the first request is in flight.
+CREATE TABLE idempotency_keys (
+    scope VARCHAR(255) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_hash CHAR(64) NOT NULL,
+    status_code INT NULL,
+    etag VARCHAR(255) NULL,
+    response_body MEDIUMTEXT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (scope, idempotency_key),
+    INDEX idx_idempotency_keys_created_at (created_at)
+);
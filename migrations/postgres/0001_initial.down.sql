@@ -0,0 +1,19 @@
+DROP TABLE IF EXISTS credit_note_lines;
+DROP TABLE IF EXISTS credit_notes;
+DROP TABLE IF EXISTS estimate_items;
+DROP TABLE IF EXISTS estimates;
+DROP TABLE IF EXISTS recurring_profile_items;
+DROP TABLE IF EXISTS recurring_profiles;
+DROP TABLE IF EXISTS invoice_deliveries;
+DROP TABLE IF EXISTS invoice_templates;
+DROP TABLE IF EXISTS branding;
+DROP TABLE IF EXISTS number_sequence_counters;
+DROP TABLE IF EXISTS number_sequences;
+DROP TABLE IF EXISTS exchange_rates;
+DROP TABLE IF EXISTS payments;
+DROP TABLE IF EXISTS invoice_taxes;
+DROP TABLE IF EXISTS invoice_items;
+DROP TABLE IF EXISTS invoices;
+DROP TABLE IF EXISTS tax_rates;
+DROP TABLE IF EXISTS customers;
+DROP TABLE IF EXISTS users;
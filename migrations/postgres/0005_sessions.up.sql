@@ -0,0 +1,11 @@
+-- Login sessions. The cookie holds a random token; only its SHA-256 hash is stored.
+CREATE TABLE sessions (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    csrf_token VARCHAR(64) NOT NULL,
+    user_id INT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE INDEX idx_sessions_user_id ON sessions (user_id);
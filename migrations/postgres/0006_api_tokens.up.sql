@@ -0,0 +1,13 @@
+-- API tokens for scripts. As with sessions, only the SHA-256 hash of a token is
+-- stored. Scopes are space-separated.
+CREATE TABLE api_tokens (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id INT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    scopes VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    last_used_at TIMESTAMPTZ NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE INDEX idx_api_tokens_user_id ON api_tokens (user_id);
@@ -0,0 +1,268 @@
+-- The tables of mysql/0001_initial.up.sql for the embedded SQLite backend
+-- (DB_DSN=sqlite:<path>). Every backend gets the same migrations: the tables and
+-- columns are the same, only the dialect differs.
+-- DECIMAL columns get NUMERIC affinity, so amounts are rounded again when they are read.
+
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username VARCHAR(255) NOT NULL UNIQUE,
+    password_hash VARCHAR(255) NOT NULL,
+    is_admin BOOLEAN DEFAULT FALSE
+);
+
+CREATE TABLE IF NOT EXISTS customers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255),
+    address VARCHAR(255),
+    currency CHAR(3) NOT NULL DEFAULT 'USD',
+    template VARCHAR(64) NULL
+);
+
+CREATE TABLE IF NOT EXISTS tax_rates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    code VARCHAR(20) NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    rate DECIMAL(9, 4) NOT NULL,
+    compound BOOLEAN NOT NULL DEFAULT FALSE,
+    active BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+CREATE TABLE IF NOT EXISTS invoices (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    customer_id INT NOT NULL,
+    invoice_number VARCHAR(64) NULL UNIQUE,
+    currency CHAR(3) NOT NULL DEFAULT 'USD',
+    issue_date DATE NOT NULL,
+    due_date DATE NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'draft',
+    sent_at DATETIME NULL,
+    paid_at DATETIME NULL,
+    voided_at DATETIME NULL,
+    prices_include_tax BOOLEAN NOT NULL DEFAULT FALSE,
+    discount_percent DECIMAL(9, 4) NOT NULL DEFAULT 0,
+    discount_amount DECIMAL(20, 3) NOT NULL DEFAULT 0,
+    discount DECIMAL(20, 3) NOT NULL DEFAULT 0,
+    discount_total DECIMAL(20, 3) NOT NULL DEFAULT 0,
+    subtotal DECIMAL(20, 3) NOT NULL DEFAULT 0,
+    tax_total DECIMAL(20, 3) NOT NULL DEFAULT 0,
+    total DECIMAL(20, 3) NOT NULL,
+    amount_paid DECIMAL(20, 3) NOT NULL DEFAULT 0,
+    amount_credited DECIMAL(20, 3) NOT NULL DEFAULT 0,
+    base_currency CHAR(3) NULL,
+    exchange_rate DECIMAL(20, 8) NULL,
+    base_total DECIMAL(20, 3) NULL,
+    rounding_mode VARCHAR(16) NOT NULL DEFAULT 'half_up',
+    recurring_profile_id INT NULL,
+    recurrence_date DATETIME NULL,
+    -- The estimate the invoice was converted from; an estimate converts only once.
+    estimate_id INT NULL UNIQUE,
+    FOREIGN KEY (customer_id) REFERENCES customers(id),
+    -- A recurring profile generates at most one invoice per run.
+    CONSTRAINT invoices_recurrence UNIQUE (recurring_profile_id, recurrence_date)
+);
+
+CREATE TABLE IF NOT EXISTS invoice_items (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    invoice_id INT NOT NULL,
+    description VARCHAR(255) NOT NULL,
+    quantity INT NOT NULL,
+    unit_price DECIMAL(20, 3) NOT NULL,
+    discount_percent DECIMAL(9, 4) NOT NULL DEFAULT 0,
+    discount_amount DECIMAL(20, 3) NOT NULL DEFAULT 0,
+    discount DECIMAL(20, 3) NOT NULL DEFAULT 0,
+    invoice_discount DECIMAL(20, 3) NOT NULL DEFAULT 0,
+    tax_codes VARCHAR(255) NOT NULL DEFAULT '',
+    subtotal DECIMAL(20, 3) NOT NULL DEFAULT 0,
+    tax_amount DECIMAL(20, 3) NOT NULL DEFAULT 0,
+    total DECIMAL(20, 3) NOT NULL,
+    FOREIGN KEY (invoice_id) REFERENCES invoices(id)
+);
+
+-- Per-rate tax breakdown, copied from tax_rates when the invoice is created.
+CREATE TABLE IF NOT EXISTS invoice_taxes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    invoice_id INT NOT NULL,
+    code VARCHAR(20) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    rate DECIMAL(9, 4) NOT NULL,
+    compound BOOLEAN NOT NULL DEFAULT FALSE,
+    taxable_amount DECIMAL(20, 3) NOT NULL,
+    tax_amount DECIMAL(20, 3) NOT NULL,
+    FOREIGN KEY (invoice_id) REFERENCES invoices(id)
+);
+
+CREATE TABLE IF NOT EXISTS payments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    invoice_id INT NOT NULL,
+    kind VARCHAR(10) NOT NULL DEFAULT 'payment',
+    amount DECIMAL(20, 3) NOT NULL,
+    paid_on DATE NOT NULL,
+    method VARCHAR(50) NOT NULL,
+    reference VARCHAR(255) NOT NULL DEFAULT '',
+    reversed_at DATETIME NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (invoice_id) REFERENCES invoices(id)
+);
+
+-- Units of currency bought by one unit of base_currency on effective_date.
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    base_currency CHAR(3) NOT NULL,
+    currency CHAR(3) NOT NULL,
+    rate DECIMAL(20, 8) NOT NULL,
+    effective_date DATE NOT NULL,
+    CONSTRAINT exchange_rates_currency_date UNIQUE (base_currency, currency, effective_date)
+);
+
+-- Formats of the human-readable document numbers, e.g. INV-{YYYY}-{seq:05}.
+CREATE TABLE IF NOT EXISTS number_sequences (
+    name VARCHAR(32) PRIMARY KEY,
+    format VARCHAR(40) NOT NULL,
+    reset_period VARCHAR(10) NOT NULL
+);
+
+-- Last number handed out per sequence and period (a year, or '' if the sequence never resets).
+CREATE TABLE IF NOT EXISTS number_sequence_counters (
+    sequence_name VARCHAR(32) NOT NULL,
+    period VARCHAR(4) NOT NULL,
+    last_value BIGINT NOT NULL,
+    PRIMARY KEY (sequence_name, period)
+);
+
+-- Company identity printed on invoices; a single row with id 1.
+CREATE TABLE IF NOT EXISTS branding (
+    id INTEGER PRIMARY KEY,
+    company_name VARCHAR(255) NOT NULL DEFAULT '',
+    company_address TEXT NOT NULL,
+    logo_url MEDIUMTEXT NOT NULL,
+    color CHAR(7) NOT NULL,
+    payment_instructions TEXT NOT NULL,
+    footer VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+-- html/template invoice layouts saved through the API.
+CREATE TABLE IF NOT EXISTS invoice_templates (
+    name VARCHAR(64) PRIMARY KEY,
+    body MEDIUMTEXT NOT NULL
+);
+
+-- Every attempt to email an invoice, successful or not.
+CREATE TABLE IF NOT EXISTS invoice_deliveries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    invoice_id INT NOT NULL,
+    recipient VARCHAR(255) NOT NULL,
+    sent_at DATETIME NOT NULL,
+    message_id VARCHAR(255) NOT NULL,
+    result VARCHAR(10) NOT NULL,
+    error TEXT NULL,
+    FOREIGN KEY (invoice_id) REFERENCES invoices(id)
+);
+
+-- Invoices generated on a schedule; line items are in recurring_profile_items.
+CREATE TABLE IF NOT EXISTS recurring_profiles (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    customer_id INT NOT NULL,
+    currency CHAR(3) NOT NULL,
+    cadence VARCHAR(10) NOT NULL,
+    cron VARCHAR(100) NOT NULL DEFAULT '',
+    start_date DATE NOT NULL,
+    end_date DATE NULL,
+    due_days INT NOT NULL,
+    prices_include_tax BOOLEAN NOT NULL DEFAULT FALSE,
+    discount_percent DECIMAL(9, 4) NOT NULL DEFAULT 0,
+    discount_amount DECIMAL(20, 3) NOT NULL DEFAULT 0,
+    rounding_mode VARCHAR(16) NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run DATETIME NULL,
+    last_run DATETIME NULL,
+    FOREIGN KEY (customer_id) REFERENCES customers(id)
+);
+
+CREATE TABLE IF NOT EXISTS recurring_profile_items (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    profile_id INT NOT NULL,
+    description VARCHAR(255) NOT NULL,
+    quantity INT NOT NULL,
+    unit_price DECIMAL(20, 3) NOT NULL,
+    discount_percent DECIMAL(9, 4) NOT NULL DEFAULT 0,
+    discount_amount DECIMAL(20, 3) NOT NULL DEFAULT 0,
+    tax_codes VARCHAR(255) NOT NULL DEFAULT '',
+    FOREIGN KEY (profile_id) REFERENCES recurring_profiles(id)
+);
+
+-- Corrections of issued invoices, numbered from the credit_note sequence.
+CREATE TABLE IF NOT EXISTS credit_notes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    credit_note_number VARCHAR(64) NOT NULL UNIQUE,
+    invoice_id INT NOT NULL,
+    currency CHAR(3) NOT NULL,
+    issue_date DATE NOT NULL,
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+    subtotal DECIMAL(20, 3) NOT NULL,
+    tax_total DECIMAL(20, 3) NOT NULL,
+    total DECIMAL(20, 3) NOT NULL,
+    base_currency CHAR(3) NULL,
+    exchange_rate DECIMAL(20, 8) NULL,
+    base_total DECIMAL(20, 3) NULL,
+    created_at DATETIME NOT NULL,
+    FOREIGN KEY (invoice_id) REFERENCES invoices(id)
+);
+
+-- The quantity of each invoice line a credit note credits.
+CREATE TABLE IF NOT EXISTS credit_note_lines (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    credit_note_id INT NOT NULL,
+    line_item_id INT NOT NULL,
+    description VARCHAR(255) NOT NULL,
+    quantity INT NOT NULL,
+    subtotal DECIMAL(20, 3) NOT NULL,
+    tax_amount DECIMAL(20, 3) NOT NULL,
+    total DECIMAL(20, 3) NOT NULL,
+    FOREIGN KEY (credit_note_id) REFERENCES credit_notes(id),
+    FOREIGN KEY (line_item_id) REFERENCES invoice_items(id)
+);
+
+-- Quotes sent before work starts; accepted estimates are converted into invoices.
+CREATE TABLE IF NOT EXISTS estimates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    estimate_number VARCHAR(64) NULL UNIQUE,
+    customer_id INT NOT NULL,
+    currency CHAR(3) NOT NULL,
+    issue_date DATE NOT NULL,
+    expiry_date DATE NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'draft',
+    sent_at DATETIME NULL,
+    accepted_at DATETIME NULL,
+    declined_at DATETIME NULL,
+    prices_include_tax BOOLEAN NOT NULL DEFAULT FALSE,
+    discount_percent DECIMAL(9, 4) NOT NULL DEFAULT 0,
+    discount_amount DECIMAL(20, 3) NOT NULL DEFAULT 0,
+    discount DECIMAL(20, 3) NOT NULL DEFAULT 0,
+    discount_total DECIMAL(20, 3) NOT NULL DEFAULT 0,
+    subtotal DECIMAL(20, 3) NOT NULL DEFAULT 0,
+    tax_total DECIMAL(20, 3) NOT NULL DEFAULT 0,
+    total DECIMAL(20, 3) NOT NULL,
+    rounding_mode VARCHAR(16) NOT NULL,
+    FOREIGN KEY (customer_id) REFERENCES customers(id)
+);
+
+CREATE TABLE IF NOT EXISTS estimate_items (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    estimate_id INT NOT NULL,
+    description VARCHAR(255) NOT NULL,
+    quantity INT NOT NULL,
+    unit_price DECIMAL(20, 3) NOT NULL,
+    discount_percent DECIMAL(9, 4) NOT NULL DEFAULT 0,
+    discount_amount DECIMAL(20, 3) NOT NULL DEFAULT 0,
+    discount DECIMAL(20, 3) NOT NULL DEFAULT 0,
+    invoice_discount DECIMAL(20, 3) NOT NULL DEFAULT 0,
+    tax_codes VARCHAR(255) NOT NULL DEFAULT '',
+    subtotal DECIMAL(20, 3) NOT NULL DEFAULT 0,
+    tax_amount DECIMAL(20, 3) NOT NULL DEFAULT 0,
+    total DECIMAL(20, 3) NOT NULL,
+    FOREIGN KEY (estimate_id) REFERENCES estimates(id)
+);
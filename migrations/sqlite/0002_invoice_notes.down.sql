@@ -0,0 +1 @@
+ALTER TABLE invoices DROP COLUMN notes;
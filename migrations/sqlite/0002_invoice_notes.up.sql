@@ -0,0 +1,2 @@
+-- Free-form notes printed on the invoice.
+ALTER TABLE invoices ADD COLUMN notes TEXT NULL;
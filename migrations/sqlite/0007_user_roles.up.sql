@@ -0,0 +1,7 @@
+-- Roles replace the is_admin flag. Existing admins become admins, the first of
+-- them the owner. Other users get no role, and cannot sign in until an admin
+-- assigns them one.
+ALTER TABLE users ADD COLUMN role VARCHAR(20);
+UPDATE users SET role = 'admin' WHERE is_admin;
+UPDATE users SET role = 'owner' WHERE id = (SELECT MIN(id) FROM users WHERE is_admin);
+ALTER TABLE users DROP COLUMN is_admin;
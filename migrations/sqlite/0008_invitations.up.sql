@@ -0,0 +1,14 @@
+-- Invitations to join with a role. The link holds a random token; only its SHA-256
+-- hash is stored. Accepted invitations are kept with the user they created.
+CREATE TABLE invitations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    role VARCHAR(20) NOT NULL,
+    created_by INT NOT NULL,
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    accepted_at DATETIME NULL,
+    user_id INT NULL,
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultBrandColor is the accent colour of documents without configured branding.
+const DefaultBrandColor = "#0d6efd"
+
+var hexColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// maxLogoLength bounds logos given inline as data URLs.
+const maxLogoLength = 256 << 10
+
+// Branding is the company identity printed on invoices. LogoURL is an http(s) URL
+// or a data:image URL; it is shown by HTML templates only.
+type Branding struct {
+	CompanyName         string `json:"company_name"`
+	CompanyAddress      string `json:"company_address"`
+	LogoURL             string `json:"logo_url"`
+	Color               string `json:"color"`
+	PaymentInstructions string `json:"payment_instructions"`
+	Footer              string `json:"footer"`
+}
+
+// Validate checks the logo URL and the accent colour, which defaults to DefaultBrandColor.
+func (b *Branding) Validate() error {
+	b.LogoURL = strings.TrimSpace(b.LogoURL)
+	switch {
+	case b.LogoURL == "":
+	case len(b.LogoURL) > maxLogoLength:
+		return fmt.Errorf("logo_url must be at most %d KB", maxLogoLength>>10)
+	case !strings.HasPrefix(b.LogoURL, "https://") && !strings.HasPrefix(b.LogoURL, "http://") &&
+		!strings.HasPrefix(b.LogoURL, "data:image/"):
+		return fmt.Errorf("logo_url must be an http(s) or data:image URL")
+	}
+
+	b.Color = strings.TrimSpace(b.Color)
+	if b.Color == "" {
+		b.Color = DefaultBrandColor
+	}
+	if !hexColor.MatchString(b.Color) {
+		return fmt.Errorf("color must be a hex colour such as %s", DefaultBrandColor)
+	}
+	return nil
+}
+
+// RGB returns the components of the accent colour. An invalid colour gives
+// DefaultBrandColor's.
+func (b Branding) RGB() (r, g, bl uint8) {
+	color := b.Color
+	if !hexColor.MatchString(color) {
+		color = DefaultBrandColor
+	}
+	v, _ := strconv.ParseUint(color[1:], 16, 32)
+	return uint8(v >> 16), uint8(v >> 8), uint8(v)
+}
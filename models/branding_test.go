@@ -0,0 +1,43 @@
+package models
+
+import "testing"
+
+func TestBranding_Validate(t *testing.T) {
+	b := Branding{}
+	if err := b.Validate(); err != nil || b.Color != DefaultBrandColor {
+		t.Errorf("empty branding: err = %v, color = %q", err, b.Color)
+	}
+
+	tests := []struct {
+		name     string
+		branding Branding
+		ok       bool
+	}{
+		{"hex colour", Branding{Color: "#A1b2C3"}, true},
+		{"named colour", Branding{Color: "red"}, false},
+		{"short hex", Branding{Color: "#abc"}, false},
+		{"https logo", Branding{LogoURL: "https://example.com/logo.png"}, true},
+		{"data logo", Branding{LogoURL: "data:image/png;base64,AAAA"}, true},
+		{"script logo", Branding{LogoURL: "javascript:alert(1)"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.branding.Validate()
+			if (err == nil) != tt.ok {
+				t.Errorf("Validate() error = %v, want ok = %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestBranding_RGB(t *testing.T) {
+	r, g, b := Branding{Color: "#ff8000"}.RGB()
+	if r != 255 || g != 128 || b != 0 {
+		t.Errorf("RGB() = %d, %d, %d, want 255, 128, 0", r, g, b)
+	}
+	// Invalid colours fall back to the default.
+	r, g, b = Branding{Color: "blue"}.RGB()
+	if r != 0x0d || g != 0x6e || b != 0xfd {
+		t.Errorf("RGB() of invalid colour = %d, %d, %d", r, g, b)
+	}
+}
@@ -0,0 +1,155 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidCreditNote is returned for credit notes with missing or malformed fields.
+	ErrInvalidCreditNote = errors.New("invalid credit note")
+	// ErrNotCreditable is returned when the invoice's status does not allow credit notes.
+	ErrNotCreditable = errors.New("invoice cannot be credited")
+	// ErrOverCredit is returned when a line is credited beyond its quantity.
+	ErrOverCredit = errors.New("credit exceeds what remains of the line")
+)
+
+// CreditNote corrects an issued invoice by crediting some or all of its lines.
+// Issued invoices are never edited; the credit note reduces the invoice's balance
+// due instead. Credit notes are numbered from their own sequence when they are
+// created and cannot be changed afterwards.
+type CreditNote struct {
+	ID        int       `json:"id"`
+	Number    string    `json:"credit_note_number"`
+	InvoiceID int       `json:"invoice_id"`
+	Currency  string    `json:"currency"`
+	IssueDate time.Time `json:"issue_date"`
+	Reason    string    `json:"reason"`
+	Subtotal  Money     `json:"subtotal"`
+	TaxTotal  Money     `json:"tax_total"`
+	Total     Money     `json:"total"`
+	// The base-currency equivalent uses the exchange rate snapshotted on the invoice.
+	BaseCurrency string           `json:"base_currency,omitempty"`
+	ExchangeRate Rate             `json:"exchange_rate,omitempty"`
+	BaseTotal    *Money           `json:"base_total,omitempty"`
+	Lines        []CreditNoteLine `json:"lines"`
+	CreatedAt    time.Time        `json:"created_at"`
+}
+
+// CreditNoteLine credits a quantity of one line item of the original invoice, with
+// the matching share of the line's net amount and tax.
+type CreditNoteLine struct {
+	ID          int    `json:"id"`
+	LineItemID  int    `json:"line_item_id"`
+	Description string `json:"description"`
+	Quantity    int    `json:"quantity"`
+	Subtotal    Money  `json:"subtotal"`
+	TaxAmount   Money  `json:"tax_amount"`
+	Total       Money  `json:"total"`
+}
+
+// Credit works out note as a credit against the invoice and applies it to the
+// invoice's balance. credited holds the quantity of each line item already credited
+// by earlier notes. A note without lines credits everything that remains.
+//
+// A line's credit is its share of the line's amounts for the quantity credited. The
+// shares are cut so that crediting every unit, in one note or several, returns
+// exactly the line's amounts.
+func (i *Invoice) Credit(note *CreditNote, credited map[int]int, at time.Time) error {
+	switch i.Status {
+	case StatusSent, StatusPartiallyPaid, StatusPaid, StatusOverdue:
+	default:
+		return fmt.Errorf("%w: invoice is %s", ErrNotCreditable, i.Status)
+	}
+	note.Reason = strings.TrimSpace(note.Reason)
+	if len(note.Reason) > 255 {
+		return fmt.Errorf("%w: reason must be at most 255 characters", ErrInvalidCreditNote)
+	}
+	if note.IssueDate.IsZero() {
+		note.IssueDate = at
+	}
+	note.IssueDate = day(note.IssueDate)
+	if note.IssueDate.Before(day(i.IssueDate)) {
+		return fmt.Errorf("%w: issue_date is before the invoice's", ErrInvalidCreditNote)
+	}
+
+	items := make(map[int]*LineItem, len(i.LineItems))
+	for k := range i.LineItems {
+		items[i.LineItems[k].ID] = &i.LineItems[k]
+	}
+
+	if len(note.Lines) == 0 {
+		for _, item := range i.LineItems {
+			if remaining := item.Quantity - credited[item.ID]; remaining > 0 {
+				note.Lines = append(note.Lines, CreditNoteLine{LineItemID: item.ID, Quantity: remaining})
+			}
+		}
+		if len(note.Lines) == 0 {
+			return fmt.Errorf("%w: invoice is already fully credited", ErrNotCreditable)
+		}
+	}
+
+	currency := i.currency()
+	mode := i.Rounding()
+	note.InvoiceID = i.ID
+	note.Currency = currency
+	note.Subtotal = NewMoney(0, currency)
+	note.TaxTotal = NewMoney(0, currency)
+	seen := make(map[int]bool, len(note.Lines))
+	for k := range note.Lines {
+		line := &note.Lines[k]
+		item, ok := items[line.LineItemID]
+		if !ok {
+			return fmt.Errorf("%w: line item %d is not on the invoice", ErrInvalidCreditNote, line.LineItemID)
+		}
+		if seen[line.LineItemID] {
+			return fmt.Errorf("%w: line item %d is listed twice", ErrInvalidCreditNote, line.LineItemID)
+		}
+		seen[line.LineItemID] = true
+		if line.Quantity <= 0 {
+			return fmt.Errorf("%w: quantity must be positive", ErrInvalidCreditNote)
+		}
+		before := credited[item.ID]
+		if before+line.Quantity > item.Quantity {
+			return fmt.Errorf("%w: line item %d has %d left to credit", ErrOverCredit, item.ID, item.Quantity-before)
+		}
+
+		share := func(m Money) Money {
+			after := int64(before + line.Quantity)
+			return m.MulFrac(after, int64(item.Quantity), mode).Sub(m.MulFrac(int64(before), int64(item.Quantity), mode))
+		}
+		line.Description = item.Description
+		line.Subtotal = share(item.Subtotal)
+		line.TaxAmount = share(item.TaxAmount)
+		var err error
+		if line.Total, err = line.Subtotal.Add(line.TaxAmount); err != nil {
+			return err
+		}
+		if note.Subtotal, err = note.Subtotal.Add(line.Subtotal); err != nil {
+			return err
+		}
+		if note.TaxTotal, err = note.TaxTotal.Add(line.TaxAmount); err != nil {
+			return err
+		}
+	}
+	total, err := note.Subtotal.Add(note.TaxTotal)
+	if err != nil {
+		return err
+	}
+	note.Total = total
+
+	if i.BaseTotal != nil {
+		base := note.Total.Convert(i.BaseCurrency, i.ExchangeRate, mode)
+		note.BaseCurrency = i.BaseCurrency
+		note.ExchangeRate = i.ExchangeRate
+		note.BaseTotal = &base
+	}
+
+	if i.AmountCredited, err = i.AmountCredited.Add(note.Total); err != nil {
+		return err
+	}
+	i.settle(at)
+	return nil
+}
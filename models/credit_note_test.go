@@ -0,0 +1,126 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// creditableInvoice is a sent USD invoice of three items at 10.00 and one at 33.33,
+// all with 20% VAT.
+func creditableInvoice(t *testing.T) *Invoice {
+	t.Helper()
+	invoice := &Invoice{
+		ID:        1,
+		Currency:  "USD",
+		IssueDate: date(2026, 3, 1),
+		Status:    StatusSent,
+		LineItems: []LineItem{
+			{ID: 10, Description: "Widget", Quantity: 3, UnitPrice: NewMoney(1000, "USD"), TaxCodes: []string{"VAT"}},
+			{ID: 11, Description: "Setup", Quantity: 1, UnitPrice: NewMoney(3333, "USD"), TaxCodes: []string{"VAT"}},
+		},
+	}
+	if err := invoice.SetTaxRates([]TaxRate{{Code: "VAT", Name: "VAT", Kind: TaxKindVAT, Rate: NewPercent(2000), Active: true}}); err != nil {
+		t.Fatal(err)
+	}
+	invoice.CalculateTotal()
+	invoice.SnapshotBase("EUR", 50000000)
+	return invoice
+}
+
+func TestInvoice_CreditPartial(t *testing.T) {
+	invoice := creditableInvoice(t)
+	at := date(2026, 3, 10)
+
+	note := &CreditNote{Reason: " Damaged ", Lines: []CreditNoteLine{{LineItemID: 10, Quantity: 1}}}
+	if err := invoice.Credit(note, nil, at); err != nil {
+		t.Fatal(err)
+	}
+	if note.Total.Amount != 1200 || note.TaxTotal.Amount != 200 || note.Reason != "Damaged" {
+		t.Errorf("credit note = %+v, want 12.00 including 2.00 tax", note)
+	}
+	if note.BaseTotal == nil || note.BaseTotal.Amount != 2400 || note.BaseCurrency != "EUR" {
+		t.Errorf("base total = %v, want 24.00 EUR at the invoice's rate", note.BaseTotal)
+	}
+	if !note.IssueDate.Equal(at) || note.Lines[0].Description != "Widget" {
+		t.Errorf("credit note = %+v", note)
+	}
+	if invoice.AmountCredited.Amount != 1200 || invoice.BalanceDue.Amount != invoice.Total.Amount-1200 || invoice.Status != StatusSent {
+		t.Errorf("invoice credited %s, balance %s, status %s", invoice.AmountCredited, invoice.BalanceDue, invoice.Status)
+	}
+}
+
+func TestInvoice_CreditInPartsAddsUp(t *testing.T) {
+	invoice := &Invoice{ID: 1, Currency: "USD", IssueDate: date(2026, 3, 1), Status: StatusSent, LineItems: []LineItem{
+		{ID: 10, Description: "Hours", Quantity: 3, UnitPrice: NewMoney(1000, "USD"), DiscountAmount: NewMoney(1, "USD")},
+	}}
+	invoice.CalculateTotal()
+
+	// 29.99 cannot be split into three equal credits; together they must still match.
+	credited := map[int]int{}
+	for k := 0; k < 3; k++ {
+		note := &CreditNote{Lines: []CreditNoteLine{{LineItemID: 10, Quantity: 1}}}
+		if err := invoice.Credit(note, credited, date(2026, 3, 10)); err != nil {
+			t.Fatal(err)
+		}
+		credited[10]++
+	}
+	if invoice.AmountCredited != invoice.Total || !invoice.BalanceDue.IsZero() {
+		t.Errorf("credited %s of %s, balance %s", invoice.AmountCredited, invoice.Total, invoice.BalanceDue)
+	}
+	if invoice.Status != StatusPaid {
+		t.Errorf("fully credited invoice is %s, want paid", invoice.Status)
+	}
+}
+
+func TestInvoice_CreditFull(t *testing.T) {
+	invoice := creditableInvoice(t)
+
+	// A note without lines credits whatever remains.
+	note := &CreditNote{}
+	if err := invoice.Credit(note, map[int]int{10: 2}, date(2026, 3, 10)); err != nil {
+		t.Fatal(err)
+	}
+	if len(note.Lines) != 2 || note.Lines[0].Quantity != 1 || note.Lines[1].Quantity != 1 {
+		t.Errorf("lines = %+v, want the last widget and the setup", note.Lines)
+	}
+	if err := invoice.Credit(&CreditNote{}, map[int]int{10: 3, 11: 1}, date(2026, 3, 10)); !errors.Is(err, ErrNotCreditable) {
+		t.Errorf("crediting a fully credited invoice: got %v, want ErrNotCreditable", err)
+	}
+}
+
+func TestInvoice_CreditRejected(t *testing.T) {
+	at := date(2026, 3, 10)
+	tests := []struct {
+		name   string
+		status InvoiceStatus
+		note   CreditNote
+		want   error
+	}{
+		{"draft", StatusDraft, CreditNote{}, ErrNotCreditable},
+		{"void", StatusVoid, CreditNote{}, ErrNotCreditable},
+		{"too many", StatusSent, CreditNote{Lines: []CreditNoteLine{{LineItemID: 10, Quantity: 4}}}, ErrOverCredit},
+		{"unknown line", StatusSent, CreditNote{Lines: []CreditNoteLine{{LineItemID: 99, Quantity: 1}}}, ErrInvalidCreditNote},
+		{"zero quantity", StatusSent, CreditNote{Lines: []CreditNoteLine{{LineItemID: 10}}}, ErrInvalidCreditNote},
+		{"listed twice", StatusSent, CreditNote{Lines: []CreditNoteLine{{LineItemID: 10, Quantity: 1}, {LineItemID: 10, Quantity: 1}}}, ErrInvalidCreditNote},
+		{"before invoice", StatusSent, CreditNote{IssueDate: date(2026, 2, 1)}, ErrInvalidCreditNote},
+	}
+	for _, tt := range tests {
+		invoice := creditableInvoice(t)
+		invoice.Status = tt.status
+		if err := invoice.Credit(&tt.note, nil, at); !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+		if !invoice.AmountCredited.IsZero() {
+			t.Errorf("%s: rejected credit changed the invoice", tt.name)
+		}
+	}
+}
+
+func TestTransitionTo_VoidWithCreditNotes(t *testing.T) {
+	invoice := creditableInvoice(t)
+	invoice.AmountCredited = NewMoney(1200, "USD")
+	if err := invoice.TransitionTo(StatusVoid, time.Now()); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("voiding a credited invoice: got %v, want ErrInvalidTransition", err)
+	}
+}
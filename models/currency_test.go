@@ -0,0 +1,63 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseCurrency(t *testing.T) {
+	if code, err := ParseCurrency(" eur "); err != nil || code != "EUR" {
+		t.Errorf("ParseCurrency(\" eur \") = %q (%v), want EUR", code, err)
+	}
+	if _, err := ParseCurrency("XYZ"); err == nil {
+		t.Error("Expected an error for an unknown currency")
+	}
+}
+
+func TestMoney_MinorUnitPrecision(t *testing.T) {
+	tests := []struct {
+		input, currency string
+		minor           int64
+		text            string
+	}{
+		{"1500", "JPY", 1500, "1500"},
+		{"1500.4", "JPY", 1500, "1500"},
+		{"16250000", "IDR", 1625000000, "16250000.00"},
+		{"1.2345", "KWD", 1235, "1.235"},
+		{"12.5", "EUR", 1250, "12.50"},
+	}
+	for _, tt := range tests {
+		m, err := ParseMoney(tt.input, tt.currency, RoundHalfUp)
+		if err != nil {
+			t.Fatalf("ParseMoney(%q, %s) returned error: %v", tt.input, tt.currency, err)
+		}
+		if m.Amount != tt.minor || m.String() != tt.text {
+			t.Errorf("ParseMoney(%q, %s) = %d (%s), want %d (%s)", tt.input, tt.currency, m.Amount, m, tt.minor, tt.text)
+		}
+	}
+}
+
+func TestInvoice_UnmarshalJSON_UsesInvoiceCurrency(t *testing.T) {
+	var invoice Invoice
+	data := []byte(`{"currency": "JPY", "discount_amount": 100, "line_items": [{"quantity": 2, "unit_price": 1500}]}`)
+	if err := json.Unmarshal(data, &invoice); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if invoice.LineItems[0].UnitPrice != NewMoney(1500, "JPY") || invoice.DiscountAmount != NewMoney(100, "JPY") {
+		t.Errorf("Expected amounts in whole yen, got %+v and %+v", invoice.LineItems[0].UnitPrice, invoice.DiscountAmount)
+	}
+
+	invoice.CalculateTotal()
+	if invoice.Total.String() != "2900" {
+		t.Errorf("Expected total 2900, got %s", invoice.Total)
+	}
+
+	// Without a currency in the JSON the receiver's currency applies.
+	preset := Invoice{Currency: "KWD"}
+	if err := json.Unmarshal([]byte(`{"line_items": [{"quantity": 1, "unit_price": 1.125}]}`), &preset); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if preset.Currency != "KWD" || preset.LineItems[0].UnitPrice.Amount != 1125 {
+		t.Errorf("Expected 1125 fils in KWD, got %d in %s", preset.LineItems[0].UnitPrice.Amount, preset.Currency)
+	}
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// DeliveryResult is the outcome of an attempt to email an invoice.
+type DeliveryResult string
+
+const (
+	DeliverySent   DeliveryResult = "sent"
+	DeliveryFailed DeliveryResult = "failed"
+)
+
+// Delivery records one attempt to email an invoice to its customer.
+type Delivery struct {
+	ID        int            `json:"id"`
+	InvoiceID int            `json:"invoice_id"`
+	Recipient string         `json:"to"`
+	SentAt    time.Time      `json:"sent_at"`
+	MessageID string         `json:"message_id"`
+	Result    DeliveryResult `json:"result"`
+	Error     string         `json:"error,omitempty"`
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// ErrInvalidDiscount is returned for negative discounts or discounts larger than
+// the amount they apply to.
+var ErrInvalidDiscount = errors.New("invalid discount")
+
+// discountOf returns the discount on amount for a percentage followed by a fixed
+// amount, never more than amount itself.
+func discountOf(amount Money, percent Percent, fixed Money, mode RoundingMode) Money {
+	discount, err := percent.Of(amount, mode).Add(fixed)
+	if err != nil || discount.Cmp(amount) > 0 {
+		return amount
+	}
+	return discount
+}
+
+// validateDiscount checks one percentage/fixed discount pair against the amount it reduces.
+func validateDiscount(what string, amount Money, percent Percent, fixed Money, mode RoundingMode) error {
+	if percent < 0 || percent > percentScale {
+		return fmt.Errorf("%w: %s percentage must be between 0 and 100", ErrInvalidDiscount, what)
+	}
+	if fixed.IsNegative() {
+		return fmt.Errorf("%w: %s amount must not be negative", ErrInvalidDiscount, what)
+	}
+	if discount, err := percent.Of(amount, mode).Add(fixed); err != nil || discount.Cmp(amount) > 0 {
+		return fmt.Errorf("%w: %s exceeds the amount it applies to", ErrInvalidDiscount, what)
+	}
+	return nil
+}
+
+// ValidateDiscounts reports the first line or invoice discount that is out of range.
+// Line discounts may not exceed their line's amount, and the invoice discount may not
+// exceed the sum of the lines after their own discounts. Lines whose amounts overflow
+// give ErrAmountOutOfRange.
+func (i *Invoice) ValidateDiscounts() error {
+	mode := i.Rounding()
+	base := NewMoney(0, i.currency())
+	for j, item := range i.LineItems {
+		gross, err := item.UnitPrice.Mul(int64(item.Quantity))
+		if err != nil {
+			return fmt.Errorf("line %d: %w", j+1, err)
+		}
+		if err := validateDiscount(fmt.Sprintf("line %d discount", j+1), gross, item.DiscountPercent, item.DiscountAmount, mode); err != nil {
+			return err
+		}
+		if base, err = base.Add(gross.Sub(discountOf(gross, item.DiscountPercent, item.DiscountAmount, mode))); err != nil {
+			return fmt.Errorf("line %d: %w", j+1, err)
+		}
+	}
+	return validateDiscount("invoice discount", base, i.DiscountPercent, i.DiscountAmount, mode)
+}
+
+// allocate splits total across the weights in proportion to them. Shares are rounded
+// down and the leftover minor units go to the largest remainders (earlier lines win
+// ties), so the shares always add up to total exactly.
+func allocate(total Money, weights []Money) []Money {
+	shares := make([]Money, len(weights))
+	sum := big.NewInt(0)
+	for j, weight := range weights {
+		shares[j] = NewMoney(0, total.Currency)
+		if !weight.IsNegative() {
+			sum.Add(sum, big.NewInt(weight.Amount))
+		}
+	}
+	if sum.Sign() == 0 || total.IsZero() {
+		return shares
+	}
+
+	remainders := make([]*big.Int, len(weights))
+	allocated := int64(0)
+	for j, weight := range weights {
+		remainders[j] = big.NewInt(0)
+		if weight.IsNegative() {
+			continue
+		}
+		product := new(big.Int).Mul(big.NewInt(total.Amount), big.NewInt(weight.Amount))
+		quotient, remainder := new(big.Int).QuoRem(product, sum, new(big.Int))
+		shares[j].Amount = quotient.Int64()
+		remainders[j] = remainder
+		allocated += shares[j].Amount
+	}
+
+	order := make([]int, len(weights))
+	for j := range order {
+		order[j] = j
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]].Cmp(remainders[order[b]]) > 0
+	})
+	for k := 0; allocated < total.Amount; k++ {
+		shares[order[k%len(order)]].Amount++
+		allocated++
+	}
+	return shares
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestInvoice_CalculateTotal_LineDiscounts(t *testing.T) {
+	invoice := Invoice{
+		LineItems: []LineItem{
+			// 2 × 50.00 = 100.00, less 10% = 90.00, less 5.00 = 85.00
+			{Quantity: 2, UnitPrice: NewMoney(5000, "USD"), DiscountPercent: NewPercent(1000), DiscountAmount: NewMoney(500, "USD"), TaxCodes: []string{"VAT20"}},
+			{Quantity: 1, UnitPrice: NewMoney(2000, "USD")},
+		},
+	}
+	if err := invoice.SetTaxRates([]TaxRate{vat20}); err != nil {
+		t.Fatalf("SetTaxRates returned error: %v", err)
+	}
+	invoice.CalculateTotal()
+
+	line := invoice.LineItems[0]
+	if line.Discount.String() != "15.00" || line.Subtotal.String() != "85.00" || line.TaxAmount.String() != "17.00" {
+		t.Errorf("Expected discount 15.00, net 85.00 and tax 17.00, got %s, %s and %s", line.Discount, line.Subtotal, line.TaxAmount)
+	}
+	if invoice.DiscountTotal.String() != "15.00" || invoice.Total.String() != "122.00" {
+		t.Errorf("Expected discount total 15.00 and total 122.00, got %s and %s", invoice.DiscountTotal, invoice.Total)
+	}
+}
+
+func TestInvoice_CalculateTotal_InvoiceDiscountBeforeTax(t *testing.T) {
+	invoice := Invoice{
+		DiscountAmount: NewMoney(1000, "USD"),
+		LineItems: []LineItem{
+			{Quantity: 1, UnitPrice: NewMoney(2000, "USD"), TaxCodes: []string{"VAT20"}},
+			{Quantity: 1, UnitPrice: NewMoney(1000, "USD")},
+			{Quantity: 1, UnitPrice: NewMoney(1000, "USD")},
+		},
+	}
+	if err := invoice.SetTaxRates([]TaxRate{vat20}); err != nil {
+		t.Fatalf("SetTaxRates returned error: %v", err)
+	}
+	invoice.CalculateTotal()
+
+	// 10.00 off 40.00 is spread 5.00 / 2.50 / 2.50; VAT is charged on 15.00 only.
+	shares := []string{"5.00", "2.50", "2.50"}
+	for j, want := range shares {
+		if got := invoice.LineItems[j].InvoiceDiscount.String(); got != want {
+			t.Errorf("line %d: expected invoice discount share %s, got %s", j+1, want, got)
+		}
+	}
+	if invoice.Subtotal.String() != "30.00" || invoice.TaxTotal.String() != "3.00" || invoice.Total.String() != "33.00" {
+		t.Errorf("Expected 30.00 + 3.00 = 33.00, got %s + %s = %s", invoice.Subtotal, invoice.TaxTotal, invoice.Total)
+	}
+}
+
+func TestInvoice_CalculateTotal_InvoiceDiscountSharesAddUp(t *testing.T) {
+	invoice := Invoice{
+		DiscountAmount: NewMoney(100, "USD"),
+		LineItems: []LineItem{
+			{Quantity: 1, UnitPrice: NewMoney(1000, "USD")},
+			{Quantity: 1, UnitPrice: NewMoney(1000, "USD")},
+			{Quantity: 1, UnitPrice: NewMoney(1000, "USD")},
+		},
+	}
+	invoice.CalculateTotal()
+
+	// 1.00 over three equal lines: 0.34 / 0.33 / 0.33.
+	if invoice.LineItems[0].InvoiceDiscount.Amount != 34 || invoice.LineItems[1].InvoiceDiscount.Amount != 33 || invoice.LineItems[2].InvoiceDiscount.Amount != 33 {
+		t.Errorf("Unexpected shares: %s, %s, %s", invoice.LineItems[0].InvoiceDiscount, invoice.LineItems[1].InvoiceDiscount, invoice.LineItems[2].InvoiceDiscount)
+	}
+	if invoice.Total.String() != "29.00" {
+		t.Errorf("Expected total 29.00, got %s", invoice.Total)
+	}
+}
+
+func TestInvoice_CalculateTotal_PercentDiscountInclusive(t *testing.T) {
+	invoice := Invoice{
+		PricesIncludeTax: true,
+		DiscountPercent:  NewPercent(5000),
+		LineItems: []LineItem{
+			{Quantity: 1, UnitPrice: NewMoney(12000, "USD"), TaxCodes: []string{"VAT20"}},
+		},
+	}
+	if err := invoice.SetTaxRates([]TaxRate{vat20}); err != nil {
+		t.Fatalf("SetTaxRates returned error: %v", err)
+	}
+	invoice.CalculateTotal()
+
+	// Half of the gross 120.00 leaves 60.00, of which 10.00 is VAT.
+	if invoice.Discount.String() != "60.00" || invoice.Subtotal.String() != "50.00" || invoice.TaxTotal.String() != "10.00" {
+		t.Errorf("Expected discount 60.00, net 50.00 and tax 10.00, got %s, %s and %s", invoice.Discount, invoice.Subtotal, invoice.TaxTotal)
+	}
+}
+
+func TestInvoice_ValidateDiscounts(t *testing.T) {
+	tests := []struct {
+		name    string
+		invoice Invoice
+		valid   bool
+	}{
+		{"no discounts", Invoice{LineItems: []LineItem{{Quantity: 1, UnitPrice: NewMoney(1000, "USD")}}}, true},
+		{"whole line", Invoice{LineItems: []LineItem{{Quantity: 1, UnitPrice: NewMoney(1000, "USD"), DiscountPercent: NewPercent(10000)}}}, true},
+		{"line over 100%", Invoice{LineItems: []LineItem{{Quantity: 1, UnitPrice: NewMoney(1000, "USD"), DiscountPercent: NewPercent(10001)}}}, false},
+		{"negative line amount", Invoice{LineItems: []LineItem{{Quantity: 1, UnitPrice: NewMoney(1000, "USD"), DiscountAmount: NewMoney(-1, "USD")}}}, false},
+		{"line amount too large", Invoice{LineItems: []LineItem{{Quantity: 1, UnitPrice: NewMoney(1000, "USD"), DiscountAmount: NewMoney(1001, "USD")}}}, false},
+		{"invoice amount after line discounts", Invoice{
+			DiscountAmount: NewMoney(600, "USD"),
+			LineItems:      []LineItem{{Quantity: 1, UnitPrice: NewMoney(1000, "USD"), DiscountPercent: NewPercent(5000)}},
+		}, false},
+	}
+
+	for _, tt := range tests {
+		err := tt.invoice.ValidateDiscounts()
+		if tt.valid && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidDiscount) {
+			t.Errorf("%s: expected ErrInvalidDiscount, got %v", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// EstimateStatus is the lifecycle state of an estimate.
+type EstimateStatus string
+
+const (
+	EstimateDraft    EstimateStatus = "draft"
+	EstimateSent     EstimateStatus = "sent"
+	EstimateAccepted EstimateStatus = "accepted"
+	EstimateDeclined EstimateStatus = "declined"
+	// EstimateExpired is never stored. It is derived for sent estimates whose
+	// expiry date has passed.
+	EstimateExpired EstimateStatus = "expired"
+)
+
+// DefaultDueDays is how long after its issue date an invoice converted from an
+// estimate falls due, unless another due date is given.
+const DefaultDueDays = 30
+
+var (
+	// ErrInvalidEstimateTransition is returned when an estimate cannot move to the
+	// requested status.
+	ErrInvalidEstimateTransition = errors.New("invalid estimate status transition")
+	// ErrNotConvertible is returned when an estimate cannot be turned into an invoice.
+	ErrNotConvertible = errors.New("estimate cannot be converted")
+)
+
+// estimateTransitions lists the moves that can be requested. Expired estimates are
+// stored as sent and cannot move on.
+var estimateTransitions = map[EstimateStatus][]EstimateStatus{
+	EstimateDraft:    {EstimateSent},
+	EstimateSent:     {EstimateAccepted, EstimateDeclined},
+	EstimateAccepted: {},
+	EstimateDeclined: {},
+	EstimateExpired:  {},
+}
+
+// ParseEstimateStatus validates a status name.
+func ParseEstimateStatus(s string) (EstimateStatus, error) {
+	status := EstimateStatus(s)
+	if _, ok := estimateTransitions[status]; !ok {
+		return "", fmt.Errorf("unknown estimate status %q", s)
+	}
+	return status, nil
+}
+
+// CanTransitionTo reports whether an estimate in status s may move to next.
+func (s EstimateStatus) CanTransitionTo(next EstimateStatus) bool {
+	for _, allowed := range estimateTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// DeriveEstimateStatus returns the status to report for an estimate stored with the
+// given status and expiry date. Sent estimates expire the day after their expiry date.
+func DeriveEstimateStatus(stored EstimateStatus, expiryDate, now time.Time) EstimateStatus {
+	if stored != EstimateSent {
+		return stored
+	}
+	if !now.UTC().Before(day(expiryDate).AddDate(0, 0, 1)) {
+		return EstimateExpired
+	}
+	return stored
+}
+
+// Estimate is a quote for work not yet done. It has the line items, discounts and
+// taxes of an invoice, and once accepted is converted into one.
+type Estimate struct {
+	ID               int            `json:"id"`
+	Number           string         `json:"estimate_number,omitempty"`
+	CustomerID       int            `json:"customer_id"`
+	Currency         string         `json:"currency"`
+	IssueDate        time.Time      `json:"issue_date"`
+	ExpiryDate       time.Time      `json:"expiry_date"`
+	PricesIncludeTax bool           `json:"prices_include_tax"`
+	DiscountPercent  Percent        `json:"discount_percent"`
+	DiscountAmount   Money          `json:"discount_amount"`
+	Discount         Money          `json:"discount"`
+	DiscountTotal    Money          `json:"discount_total"`
+	Subtotal         Money          `json:"subtotal"`
+	TaxTotal         Money          `json:"tax_total"`
+	Total            Money          `json:"total"`
+	RoundingMode     RoundingMode   `json:"rounding_mode,omitempty"`
+	Status           EstimateStatus `json:"status"`
+	SentAt           *time.Time     `json:"sent_at,omitempty"`
+	AcceptedAt       *time.Time     `json:"accepted_at,omitempty"`
+	DeclinedAt       *time.Time     `json:"declined_at,omitempty"`
+	LineItems        []LineItem     `json:"line_items"`
+	// InvoiceID is the invoice the estimate was converted into, if any.
+	InvoiceID *int `json:"invoice_id,omitempty"`
+}
+
+// UnmarshalJSON decodes an estimate with its amounts read in the estimate's
+// currency, as for Invoice. Status, numbers and totals are left to the server.
+func (e *Estimate) UnmarshalJSON(data []byte) error {
+	// The invoice fields are decoded as an invoice, which settles the currency.
+	invoice := Invoice{Currency: e.Currency}
+	if err := json.Unmarshal(data, &invoice); err != nil {
+		return err
+	}
+	var aux struct {
+		ID         int       `json:"id"`
+		ExpiryDate time.Time `json:"expiry_date"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	*e = Estimate{
+		ID:               aux.ID,
+		CustomerID:       invoice.CustomerID,
+		Currency:         invoice.Currency,
+		IssueDate:        invoice.IssueDate,
+		ExpiryDate:       aux.ExpiryDate,
+		PricesIncludeTax: invoice.PricesIncludeTax,
+		DiscountPercent:  invoice.DiscountPercent,
+		DiscountAmount:   invoice.DiscountAmount,
+		RoundingMode:     invoice.RoundingMode,
+		LineItems:        invoice.LineItems,
+	}
+	return nil
+}
+
+// Validate checks a new estimate and normalises its currency, rounding mode and
+// dates, which are reduced to whole days in UTC.
+func (e *Estimate) Validate() error {
+	if e.CustomerID == 0 {
+		return fmt.Errorf("customer_id is required")
+	}
+	if len(e.LineItems) == 0 {
+		return fmt.Errorf("at least one line item is required")
+	}
+	if err := ValidateQuantities(e.LineItems); err != nil {
+		return err
+	}
+
+	currency, err := ParseCurrency(e.Currency)
+	if err != nil {
+		return err
+	}
+	e.Currency = currency
+	mode, err := ParseRoundingMode(string(e.RoundingMode))
+	if err != nil {
+		return err
+	}
+	e.RoundingMode = mode
+
+	if e.IssueDate.IsZero() || e.ExpiryDate.IsZero() {
+		return fmt.Errorf("issue_date and expiry_date are required")
+	}
+	e.IssueDate, e.ExpiryDate = day(e.IssueDate), day(e.ExpiryDate)
+	if e.ExpiryDate.Before(e.IssueDate) {
+		return fmt.Errorf("expiry_date must not be before issue_date")
+	}
+	return nil
+}
+
+// Calculate works out the estimate's discounts, subtotal, tax and total exactly as
+// CalculateTotal does for an invoice, with the given tax rates.
+func (e *Estimate) Calculate(rates []TaxRate) error {
+	invoice := e.Invoice(e.IssueDate, e.ExpiryDate)
+	if err := invoice.SetTaxRates(rates); err != nil {
+		return fmt.Errorf("invalid tax code: %w", err)
+	}
+	if err := invoice.ValidateDiscounts(); err != nil {
+		return err
+	}
+	if err := invoice.CalculateTotal(); err != nil {
+		return err
+	}
+
+	e.Currency = invoice.Currency
+	e.RoundingMode = invoice.RoundingMode
+	e.Discount = invoice.Discount
+	e.DiscountTotal = invoice.DiscountTotal
+	e.Subtotal = invoice.Subtotal
+	e.TaxTotal = invoice.TaxTotal
+	e.Total = invoice.Total
+	e.LineItems = invoice.LineItems
+	return nil
+}
+
+// TransitionTo moves the estimate to next, stamping the matching transition time.
+// The estimate's Status may be the derived one: an expired estimate can no longer
+// be accepted or declined.
+func (e *Estimate) TransitionTo(next EstimateStatus, at time.Time) error {
+	if !e.Status.CanTransitionTo(next) {
+		return fmt.Errorf("%w: %s to %s", ErrInvalidEstimateTransition, e.Status, next)
+	}
+
+	switch next {
+	case EstimateSent:
+		e.SentAt = &at
+	case EstimateAccepted:
+		e.AcceptedAt = &at
+	case EstimateDeclined:
+		e.DeclinedAt = &at
+	}
+	e.Status = next
+	return nil
+}
+
+// Convertible reports why the estimate cannot be converted into an invoice, or nil
+// if it can: it must be accepted and not converted before.
+func (e *Estimate) Convertible() error {
+	if e.Status != EstimateAccepted {
+		return fmt.Errorf("%w: estimate is %s", ErrNotConvertible, e.Status)
+	}
+	if e.InvoiceID != nil {
+		return fmt.Errorf("%w: already converted into invoice %d", ErrNotConvertible, *e.InvoiceID)
+	}
+	return nil
+}
+
+// Invoice returns a draft invoice with the estimate's customer, currency, discounts
+// and a copy of its line items, issued and due on the given dates and referring back
+// to the estimate. Totals are not yet calculated.
+func (e *Estimate) Invoice(issued, due time.Time) *Invoice {
+	items := make([]LineItem, len(e.LineItems))
+	for k, item := range e.LineItems {
+		items[k] = LineItem{
+			Description:     item.Description,
+			Quantity:        item.Quantity,
+			UnitPrice:       item.UnitPrice,
+			DiscountPercent: item.DiscountPercent,
+			DiscountAmount:  item.DiscountAmount,
+			TaxCodes:        item.TaxCodes,
+		}
+	}
+
+	var estimateID *int
+	if e.ID != 0 {
+		id := e.ID
+		estimateID = &id
+	}
+	return &Invoice{
+		CustomerID:       e.CustomerID,
+		Currency:         e.Currency,
+		IssueDate:        issued,
+		DueDate:          due,
+		PricesIncludeTax: e.PricesIncludeTax,
+		DiscountPercent:  e.DiscountPercent,
+		DiscountAmount:   e.DiscountAmount,
+		RoundingMode:     e.RoundingMode,
+		Status:           StatusDraft,
+		LineItems:        items,
+		EstimateID:       estimateID,
+	}
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDeriveEstimateStatus(t *testing.T) {
+	expiry := date(2026, 3, 31)
+	tests := []struct {
+		stored EstimateStatus
+		now    int
+		want   EstimateStatus
+	}{
+		{EstimateSent, 31, EstimateSent},
+		{EstimateSent, 32, EstimateExpired},
+		{EstimateDraft, 32, EstimateDraft},
+		{EstimateAccepted, 32, EstimateAccepted},
+	}
+	for _, tt := range tests {
+		now := date(2026, 3, tt.now).Add(12 * time.Hour)
+		if got := DeriveEstimateStatus(tt.stored, expiry, now); got != tt.want {
+			t.Errorf("DeriveEstimateStatus(%s, day %d) = %s, want %s", tt.stored, tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestEstimate_TransitionTo(t *testing.T) {
+	at := date(2026, 3, 10)
+	estimate := &Estimate{Status: EstimateDraft}
+	if err := estimate.TransitionTo(EstimateAccepted, at); !errors.Is(err, ErrInvalidEstimateTransition) {
+		t.Errorf("accepting a draft: got %v, want ErrInvalidEstimateTransition", err)
+	}
+	if err := estimate.TransitionTo(EstimateSent, at); err != nil || estimate.SentAt == nil {
+		t.Fatalf("sending a draft: %v", err)
+	}
+	if err := estimate.TransitionTo(EstimateAccepted, at); err != nil || estimate.AcceptedAt == nil {
+		t.Fatalf("accepting a sent estimate: %v", err)
+	}
+	if err := estimate.TransitionTo(EstimateDeclined, at); !errors.Is(err, ErrInvalidEstimateTransition) {
+		t.Errorf("declining an accepted estimate: got %v, want ErrInvalidEstimateTransition", err)
+	}
+
+	expired := &Estimate{Status: EstimateExpired}
+	if err := expired.TransitionTo(EstimateAccepted, at); !errors.Is(err, ErrInvalidEstimateTransition) {
+		t.Errorf("accepting an expired estimate: got %v, want ErrInvalidEstimateTransition", err)
+	}
+}
+
+func TestEstimate_Validate(t *testing.T) {
+	estimate := &Estimate{CustomerID: 1, Currency: "usd", IssueDate: date(2026, 3, 1).Add(5 * time.Hour), ExpiryDate: date(2026, 3, 31),
+		LineItems: []LineItem{{Description: "Design", Quantity: 1, UnitPrice: NewMoney(50000, "USD")}}}
+	if err := estimate.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if estimate.Currency != "USD" || !estimate.IssueDate.Equal(date(2026, 3, 1)) || estimate.RoundingMode == "" {
+		t.Errorf("estimate not normalised: %+v", estimate)
+	}
+
+	estimate.ExpiryDate = date(2026, 2, 1)
+	if err := estimate.Validate(); err == nil {
+		t.Error("expected an error for an expiry date before the issue date")
+	}
+
+	estimate.ExpiryDate = date(2026, 3, 31)
+	estimate.LineItems[0].Quantity = 0
+	if err := estimate.Validate(); err == nil {
+		t.Error("expected an error for a zero quantity")
+	}
+}
+
+func TestEstimate_CalculateAndInvoice(t *testing.T) {
+	estimate := &Estimate{ID: 4, CustomerID: 2, Currency: "USD", IssueDate: date(2026, 3, 1), ExpiryDate: date(2026, 3, 31),
+		DiscountPercent: NewPercent(1000), Status: EstimateAccepted,
+		LineItems: []LineItem{{Description: "Design", Quantity: 2, UnitPrice: NewMoney(10000, "USD"), TaxCodes: []string{"VAT"}}}}
+	rates := []TaxRate{{Code: "VAT", Name: "VAT", Kind: TaxKindVAT, Rate: NewPercent(2000), Active: true}}
+	if err := estimate.Calculate(rates); err != nil {
+		t.Fatal(err)
+	}
+	// 200.00 less 10% is 180.00, plus 20% VAT.
+	if estimate.Subtotal.Amount != 18000 || estimate.TaxTotal.Amount != 3600 || estimate.Total.Amount != 21600 {
+		t.Errorf("estimate totals = %s + %s = %s, want 180.00 + 36.00 = 216.00", estimate.Subtotal, estimate.TaxTotal, estimate.Total)
+	}
+
+	if err := estimate.Convertible(); err != nil {
+		t.Fatalf("accepted estimate not convertible: %v", err)
+	}
+	invoice := estimate.Invoice(date(2026, 4, 1), date(2026, 5, 1))
+	if invoice.EstimateID == nil || *invoice.EstimateID != 4 || invoice.Status != StatusDraft || invoice.CustomerID != 2 {
+		t.Errorf("invoice = %+v, want a draft for customer 2 referring to estimate 4", invoice)
+	}
+	if len(invoice.LineItems) != 1 || !invoice.LineItems[0].Total.IsZero() {
+		t.Errorf("invoice line items = %+v, want an uncalculated copy", invoice.LineItems)
+	}
+
+	invoiceID := 9
+	estimate.InvoiceID = &invoiceID
+	if err := estimate.Convertible(); !errors.Is(err, ErrNotConvertible) {
+		t.Errorf("converting twice: got %v, want ErrNotConvertible", err)
+	}
+	estimate.InvoiceID, estimate.Status = nil, EstimateSent
+	if err := estimate.Convertible(); !errors.Is(err, ErrNotConvertible) {
+		t.Errorf("converting a sent estimate: got %v, want ErrNotConvertible", err)
+	}
+}
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// rateDigits is the number of decimal places kept for exchange rates.
+const rateDigits = 8
+
+// ErrNoExchangeRate is returned when no rate is known for a currency on a date.
+var ErrNoExchangeRate = errors.New("no exchange rate")
+
+// Rate is an exact exchange rate held in units of 10^-8, so 0.9215 is 92150000.
+// It is encoded in JSON as a plain number.
+type Rate int64
+
+// OneRate is the rate between a currency and itself.
+const OneRate Rate = 100000000
+
+// ParseRate parses a positive decimal exchange rate such as "16250.5".
+func ParseRate(s string) (Rate, error) {
+	v, err := parseFixed(s, rateDigits)
+	if err != nil {
+		return 0, fmt.Errorf("invalid exchange rate: %w", err)
+	}
+	if v <= 0 {
+		return 0, fmt.Errorf("invalid exchange rate: %q is not positive", s)
+	}
+	return Rate(v), nil
+}
+
+// String formats the rate without trailing zeros.
+func (r Rate) String() string {
+	return formatFixed(int64(r), rateDigits)
+}
+
+// MarshalJSON encodes the rate as a JSON number.
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a numeric string.
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	return unmarshalFixed(data, func(text string) error {
+		parsed, err := ParseRate(text)
+		*r = parsed
+		return err
+	})
+}
+
+// Scan implements sql.Scanner for DECIMAL columns.
+func (r *Rate) Scan(src interface{}) error {
+	return scanFixed(src, "Rate", func(text string) error {
+		parsed, err := ParseRate(text)
+		*r = parsed
+		return err
+	})
+}
+
+// Value implements driver.Valuer.
+func (r Rate) Value() (driver.Value, error) {
+	return r.String(), nil
+}
+
+// ExchangeRate says how many units of Currency one unit of Base bought on Date,
+// e.g. Base USD, Currency EUR, Rate 0.9215.
+type ExchangeRate struct {
+	Base     string    `json:"base_currency"`
+	Currency string    `json:"currency"`
+	Rate     Rate      `json:"rate"`
+	Date     time.Time `json:"date"`
+}
+
+// Convert expresses m in the currency to, given how many units of m's currency one
+// unit of to buys. The result is rounded to to's minor units with mode.
+func (m Money) Convert(to string, rate Rate, mode RoundingMode) Money {
+	from := m.Currency
+	if from == "" {
+		from = DefaultCurrency
+	}
+	// m.Amount / 10^from × 10^to / (rate / 10^rateDigits)
+	num := new(big.Int).Mul(big.NewInt(m.Amount), pow10(MinorUnits(to)+rateDigits))
+	den := new(big.Int).Mul(pow10(MinorUnits(from)), big.NewInt(int64(rate)))
+	return Money{Amount: mode.round(num, den).Int64(), Currency: to}
+}
+
+// ParseExchangeRates reads exchange rates against base from CSV lines of the form
+// "date,currency,rate", e.g. "2026-01-02,EUR,0.9215". Blank lines, lines starting
+// with # and a "date,currency,rate" header are skipped.
+func ParseExchangeRates(r io.Reader, base string) ([]ExchangeRate, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+
+	var rates []ExchangeRate
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rates, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if first && strings.EqualFold(strings.TrimSpace(record[0]), "date") {
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+
+		date, err := time.Parse("2006-01-02", strings.TrimSpace(record[0]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid date %q", line, record[0])
+		}
+		currency, err := ParseCurrency(record[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rate, err := ParseRate(record[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if currency == base {
+			continue
+		}
+		rates = append(rates, ExchangeRate{Base: base, Currency: currency, Rate: rate, Date: date})
+	}
+}
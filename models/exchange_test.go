@@ -0,0 +1,76 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMoney_Convert(t *testing.T) {
+	tests := []struct {
+		amount Money
+		to     string
+		rate   string
+		want   string
+	}{
+		{NewMoney(10000, "EUR"), "USD", "0.92", "108.70"},
+		{NewMoney(1625000000, "IDR"), "USD", "16250", "1000.00"},
+		{NewMoney(150000, "JPY"), "EUR", "162.5", "923.08"},
+		{NewMoney(1000, "USD"), "JPY", "0.0066", "1515"},
+	}
+	for _, tt := range tests {
+		rate, err := ParseRate(tt.rate)
+		if err != nil {
+			t.Fatalf("ParseRate(%q) returned error: %v", tt.rate, err)
+		}
+		if got := tt.amount.Convert(tt.to, rate, RoundHalfUp); got.String() != tt.want || got.Currency != tt.to {
+			t.Errorf("%s %s at %s = %s %s, want %s %s", tt.amount, tt.amount.Currency, tt.rate, got, got.Currency, tt.want, tt.to)
+		}
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	if rate, err := ParseRate("0.9215"); err != nil || rate.String() != "0.9215" {
+		t.Errorf("ParseRate(\"0.9215\") = %s (%v)", rate, err)
+	}
+	for _, input := range []string{"0", "-1", "abc", "1.000000001"} {
+		if _, err := ParseRate(input); err == nil {
+			t.Errorf("Expected an error for rate %q", input)
+		}
+	}
+}
+
+func TestParseExchangeRates(t *testing.T) {
+	input := `date,currency,rate
+# ECB reference rates
+2026-01-02,EUR,0.9215
+2026-01-02, idr ,16250
+2026-01-02,USD,1
+`
+	rates, err := ParseExchangeRates(strings.NewReader(input), "USD")
+	if err != nil {
+		t.Fatalf("ParseExchangeRates returned error: %v", err)
+	}
+	if len(rates) != 2 {
+		t.Fatalf("Expected 2 rates (the base currency is skipped), got %d", len(rates))
+	}
+	if rates[1].Currency != "IDR" || rates[1].Base != "USD" || rates[1].Rate.String() != "16250" || rates[1].Date.Format("2006-01-02") != "2026-01-02" {
+		t.Errorf("Unexpected rate: %+v", rates[1])
+	}
+
+	_, err = ParseExchangeRates(strings.NewReader("2026-01-02,EUR,0.9\n2026-01-03,ZZZ,1\n"), "USD")
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected an error naming line 2, got %v", err)
+	}
+}
+
+func TestInvoice_SnapshotBase(t *testing.T) {
+	invoice := Invoice{Currency: "EUR", Total: NewMoney(12345, "EUR")}
+	invoice.SnapshotBase("USD", Rate(92150000))
+
+	if invoice.BaseCurrency != "USD" || invoice.ExchangeRate.String() != "0.9215" {
+		t.Errorf("Unexpected snapshot: %s at %s", invoice.BaseCurrency, invoice.ExchangeRate)
+	}
+	if invoice.BaseTotal == nil || invoice.BaseTotal.String() != "133.97" {
+		t.Errorf("Expected base total 133.97, got %v", invoice.BaseTotal)
+	}
+}
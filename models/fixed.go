@@ -0,0 +1,71 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// parseFixed parses a decimal string into an integer scaled by 10^digits,
+// rejecting values with more decimal places than that.
+func parseFixed(s string, digits int) (int64, error) {
+	s = strings.TrimSpace(s)
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || s == "" {
+		return 0, fmt.Errorf("%q is not a number", s)
+	}
+	r.Mul(r, new(big.Rat).SetInt(pow10(digits)))
+	if !r.IsInt() || !r.Num().IsInt64() {
+		return 0, fmt.Errorf("%q has more than %d decimal places or is out of range", s, digits)
+	}
+	return r.Num().Int64(), nil
+}
+
+// formatFixed formats an integer scaled by 10^digits without trailing zeros.
+func formatFixed(v int64, digits int) string {
+	text := strconv.FormatInt(v, 10)
+	sign := ""
+	if strings.HasPrefix(text, "-") {
+		sign, text = "-", text[1:]
+	}
+	if len(text) <= digits {
+		text = strings.Repeat("0", digits-len(text)+1) + text
+	}
+	cut := len(text) - digits
+	whole, frac := text[:cut], strings.TrimRight(text[cut:], "0")
+	if frac == "" {
+		return sign + whole
+	}
+	return sign + whole + "." + frac
+}
+
+// unmarshalFixed passes a JSON number or numeric string to parse; null is ignored.
+func unmarshalFixed(data []byte, parse func(string) error) error {
+	if string(data) == "null" {
+		return nil
+	}
+	text := string(data)
+	if strings.HasPrefix(text, `"`) {
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+	}
+	return parse(text)
+}
+
+// scanFixed passes a DECIMAL column value to parse as text.
+func scanFixed(src interface{}, name string, parse func(string) error) error {
+	switch v := src.(type) {
+	case []byte:
+		return parse(string(v))
+	case string:
+		return parse(v)
+	case int64:
+		return parse(strconv.FormatInt(v, 10))
+	case float64:
+		return parse(strconv.FormatFloat(v, 'f', -1, 64))
+	}
+	return fmt.Errorf("cannot scan %T into %s", src, name)
+}
@@ -1,34 +1,284 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // Invoice represents an invoice in the system.
 type Invoice struct {
-	ID         int        `json:"id"`
-	CustomerID int        `json:"customer_id"`
-	IssueDate  time.Time  `json:"issue_date"`
-	DueDate    time.Time  `json:"due_date"`
-	Total      float64    `json:"total"`
-	Status     string     `json:"status"` // Kita tetap simpan ini di struct untuk UI, tapi di DB akan dipetakan
-	LineItems  []LineItem `json:"line_items"`
+	ID               int           `json:"id"`
+	Number           string        `json:"invoice_number,omitempty"`
+	CustomerID       int           `json:"customer_id"`
+	Currency         string        `json:"currency"`
+	IssueDate        time.Time     `json:"issue_date"`
+	DueDate          time.Time     `json:"due_date"`
+	PricesIncludeTax bool          `json:"prices_include_tax"`
+	DiscountPercent  Percent       `json:"discount_percent"`
+	DiscountAmount   Money         `json:"discount_amount"`
+	Discount         Money         `json:"discount"`
+	DiscountTotal    Money         `json:"discount_total"`
+	Subtotal         Money         `json:"subtotal"`
+	TaxTotal         Money         `json:"tax_total"`
+	Total            Money         `json:"total"`
+	AmountPaid       Money         `json:"amount_paid"`
+	AmountCredited   Money         `json:"amount_credited"`
+	BalanceDue       Money         `json:"balance_due"`
+	BaseCurrency     string        `json:"base_currency,omitempty"`
+	ExchangeRate     Rate          `json:"exchange_rate,omitempty"`
+	BaseTotal        *Money        `json:"base_total,omitempty"`
+	Taxes            []TaxSummary  `json:"taxes"`
+	RoundingMode     RoundingMode  `json:"rounding_mode,omitempty"`
+	Status           InvoiceStatus `json:"status"`
+	SentAt           *time.Time    `json:"sent_at,omitempty"`
+	PaidAt           *time.Time    `json:"paid_at,omitempty"`
+	VoidedAt         *time.Time    `json:"voided_at,omitempty"`
+	LineItems        []LineItem    `json:"line_items"`
+	// Notes are printed on the invoice below the totals.
+	Notes string `json:"notes,omitempty"`
+	// RecurringProfileID and RecurrenceDate identify the run of a recurring profile
+	// that generated the invoice.
+	RecurringProfileID *int       `json:"recurring_profile_id,omitempty"`
+	RecurrenceDate     *time.Time `json:"recurrence_date,omitempty"`
+	// EstimateID is the estimate the invoice was converted from.
+	EstimateID *int `json:"estimate_id,omitempty"`
+	// Version starts at 1 and goes up with every change to the invoice.
+	Version int `json:"version"`
+
+	// taxRates holds the rates set by SetTaxRates for CalculateTotal.
+	taxRates map[string]TaxRate
 }
 
 // LineItem represents a single line item on an invoice.
 type LineItem struct {
-	ID          int     `json:"id"`
-	InvoiceID   int     `json:"invoice_id"`
-	Description string  `json:"description"`
-	Quantity    int     `json:"quantity"`
-	UnitPrice   float64 `json:"unit_price"`
-	Total       float64 `json:"total"`
+	ID              int      `json:"id"`
+	InvoiceID       int      `json:"invoice_id"`
+	Description     string   `json:"description"`
+	Quantity        int      `json:"quantity"`
+	UnitPrice       Money    `json:"unit_price"`
+	DiscountPercent Percent  `json:"discount_percent"`
+	DiscountAmount  Money    `json:"discount_amount"`
+	Discount        Money    `json:"discount"`
+	InvoiceDiscount Money    `json:"invoice_discount"`
+	TaxCodes        []string `json:"tax_codes,omitempty"`
+	Subtotal        Money    `json:"subtotal"`
+	TaxAmount       Money    `json:"tax_amount"`
+	Total           Money    `json:"total"`
+}
+
+// invoiceFields has Invoice's fields without its UnmarshalJSON method.
+type invoiceFields Invoice
+
+// UnmarshalJSON decodes an invoice with every amount read in the invoice's currency,
+// so that e.g. "1500" means 1500 yen rather than 1500.00. The currency comes from the
+// JSON "currency" field, else from the receiver's Currency, else DefaultCurrency.
+// The currency is not validated here; see ParseCurrency.
+func (i *Invoice) UnmarshalJSON(data []byte) error {
+	var head struct {
+		Currency string `json:"currency"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return err
+	}
+	currency := i.Currency
+	if head.Currency != "" {
+		currency = head.Currency
+	}
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+
+	fields := invoiceFields{
+		Currency:       currency,
+		DiscountAmount: NewMoney(0, currency),
+	}
+	aux := struct {
+		*invoiceFields
+		LineItems []json.RawMessage `json:"line_items"`
+	}{invoiceFields: &fields}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	fields.LineItems = nil
+	for _, raw := range aux.LineItems {
+		item := LineItem{
+			UnitPrice:      NewMoney(0, currency),
+			DiscountAmount: NewMoney(0, currency),
+		}
+		if err := json.Unmarshal(raw, &item); err != nil {
+			return err
+		}
+		fields.LineItems = append(fields.LineItems, item)
+	}
+
+	*i = Invoice(fields)
+	return nil
+}
+
+// currency returns the invoice's currency, falling back to DefaultCurrency.
+func (i *Invoice) currency() string {
+	if i.Currency == "" {
+		return DefaultCurrency
+	}
+	return i.Currency
+}
+
+// Title returns the invoice number, or "#<id>" for drafts, which are not numbered
+// until they are sent.
+func (i *Invoice) Title() string {
+	if i.Number != "" {
+		return i.Number
+	}
+	return "#" + strconv.Itoa(i.ID)
 }
 
-// CalculateTotal calculates the total amount of the invoice based on its line items.
-func (i *Invoice) CalculateTotal() {
-	var grandTotal float64
+// SnapshotBase records the invoice total in the base currency, given how many units
+// of the invoice's currency one unit of base buys. Reports use the snapshot so that
+// later rate changes do not alter past figures.
+func (i *Invoice) SnapshotBase(base string, rate Rate) {
+	total := i.Total.Convert(base, rate, i.Rounding())
+	i.BaseCurrency = base
+	i.ExchangeRate = rate
+	i.BaseTotal = &total
+}
+
+// Rounding returns the invoice's rounding mode, falling back to DefaultRoundingMode.
+func (i *Invoice) Rounding() RoundingMode {
+	if i.RoundingMode == "" {
+		return DefaultRoundingMode
+	}
+	return i.RoundingMode
+}
+
+// ValidateQuantities reports the first line item whose quantity is not positive.
+func ValidateQuantities(items []LineItem) error {
+	for j, item := range items {
+		if item.Quantity <= 0 {
+			return fmt.Errorf("line %d quantity must be positive", j+1)
+		}
+	}
+	return nil
+}
+
+// CalculateTotal calculates the discounts, subtotal, tax and total of the invoice from
+// its line items, in this order:
+//
+//  1. Each line's price is quantity × unit price, less the line's percentage discount
+//     and then its fixed discount.
+//  2. The invoice's percentage and then fixed discount are taken from the sum of the
+//     discounted lines and spread over the lines in proportion to their amounts.
+//  3. Tax is charged on what remains of each line. When PricesIncludeTax is set that
+//     amount already contains tax, and the net amount is backed out of it; otherwise it
+//     is the net amount and tax is added on top.
+//
+// Discounts are therefore always taken before tax. Tax is rounded per line with the
+// invoice's rounding mode, and the invoice totals and per-rate summary are exact
+// sums of the rounded line amounts. Rates come from SetTaxRates. Amounts too large to
+// hold give ErrAmountOutOfRange.
+func (i *Invoice) CalculateTotal() error {
+	i.RoundingMode = i.Rounding()
+	mode := i.RoundingMode
+	i.Currency = i.currency()
+
+	discounted := make([]Money, len(i.LineItems))
+	base := NewMoney(0, i.Currency)
+	lineDiscounts := NewMoney(0, i.Currency)
+	for j := range i.LineItems {
+		item := &i.LineItems[j]
+		gross, err := item.UnitPrice.Mul(int64(item.Quantity))
+		if err != nil {
+			return err
+		}
+		item.Discount = discountOf(gross, item.DiscountPercent, item.DiscountAmount, mode)
+		discounted[j] = gross.Sub(item.Discount)
+		if base, err = base.Add(discounted[j]); err != nil {
+			return err
+		}
+		if lineDiscounts, err = lineDiscounts.Add(item.Discount); err != nil {
+			return err
+		}
+	}
+	i.Discount = discountOf(base, i.DiscountPercent, i.DiscountAmount, mode)
+	discountTotal, err := lineDiscounts.Add(i.Discount)
+	if err != nil {
+		return err
+	}
+	i.DiscountTotal = discountTotal
+	shares := allocate(i.Discount, discounted)
+
+	subtotal := NewMoney(0, i.Currency)
+	taxTotal := NewMoney(0, i.Currency)
+	var summary []TaxSummary
+	index := map[string]int{}
+
 	for j := range i.LineItems {
-		i.LineItems[j].Total = float64(i.LineItems[j].Quantity) * i.LineItems[j].UnitPrice
-		grandTotal += i.LineItems[j].Total
+		item := &i.LineItems[j]
+		item.InvoiceDiscount = shares[j]
+
+		var rates []TaxRate
+		for _, code := range item.TaxCodes {
+			if rate, ok := i.taxRates[code]; ok {
+				rates = append(rates, rate)
+			}
+		}
+
+		net, taxes, err := taxLine(discounted[j].Sub(shares[j]), rates, i.PricesIncludeTax, mode)
+		if err != nil {
+			return err
+		}
+		item.Subtotal = net
+		item.TaxAmount = NewMoney(0, net.Currency)
+		for _, tax := range taxes {
+			if item.TaxAmount, err = item.TaxAmount.Add(tax.amount); err != nil {
+				return err
+			}
+
+			k, ok := index[tax.rate.Code]
+			if !ok {
+				k = len(summary)
+				index[tax.rate.Code] = k
+				summary = append(summary, TaxSummary{
+					Code:          tax.rate.Code,
+					Name:          tax.rate.Name,
+					Kind:          tax.rate.Kind,
+					Rate:          tax.rate.Rate,
+					Compound:      tax.rate.Compound,
+					TaxableAmount: NewMoney(0, net.Currency),
+					TaxAmount:     NewMoney(0, net.Currency),
+				})
+			}
+			if summary[k].TaxableAmount, err = summary[k].TaxableAmount.Add(tax.taxable); err != nil {
+				return err
+			}
+			if summary[k].TaxAmount, err = summary[k].TaxAmount.Add(tax.amount); err != nil {
+				return err
+			}
+		}
+		if item.Total, err = item.Subtotal.Add(item.TaxAmount); err != nil {
+			return err
+		}
+
+		if subtotal, err = subtotal.Add(item.Subtotal); err != nil {
+			return err
+		}
+		if taxTotal, err = taxTotal.Add(item.TaxAmount); err != nil {
+			return err
+		}
+	}
+
+	total, err := subtotal.Add(taxTotal)
+	if err != nil {
+		return err
 	}
-	i.Total = grandTotal
+	i.Subtotal = subtotal
+	i.TaxTotal = taxTotal
+	i.Total = total
+	i.Taxes = summary
+	i.UpdateBalance()
+	return nil
 }
@@ -10,10 +10,10 @@ func TestInvoiceStruct(t *testing.T) {
 	dueDate := issueDate.Add(24 * 14 * time.Hour)
 	invoice := Invoice{
 		ID:         1,
-		ClientID:   1,
+		CustomerID: 1,
 		IssueDate:  issueDate,
 		DueDate:    dueDate,
-		Total:      100.00,
+		Total:      NewMoney(10000, "USD"),
 		Status:     "draft",
 		LineItems: []LineItem{
 			{
@@ -21,7 +21,7 @@ func TestInvoiceStruct(t *testing.T) {
 				InvoiceID:   1,
 				Description: "Test Item",
 				Quantity:    1,
-				UnitPrice:   100.00,
+				UnitPrice:   NewMoney(10000, "USD"),
 			},
 		},
 	}
@@ -37,24 +37,41 @@ func TestInvoiceStruct(t *testing.T) {
 func TestInvoice_CalculateTotal(t *testing.T) {
 	invoice := Invoice{
 		LineItems: []LineItem{
-			{Quantity: 2, UnitPrice: 10.50},
-			{Quantity: 1, UnitPrice: 5.00},
-			{Quantity: 3, UnitPrice: 20.00},
+			{Quantity: 2, UnitPrice: NewMoney(1050, "USD")},
+			{Quantity: 1, UnitPrice: NewMoney(500, "USD")},
+			{Quantity: 3, UnitPrice: NewMoney(2000, "USD")},
 		},
 	}
 	invoice.CalculateTotal()
-	expected := 86.0
+	expected := NewMoney(8600, "USD")
 	if invoice.Total != expected {
-		t.Errorf("Expected Total to be %f, but got %f", expected, invoice.Total)
+		t.Errorf("Expected Total to be %s, but got %s", expected, invoice.Total)
 	}
 
-	if invoice.LineItems[0].Total != 21.0 {
-		t.Errorf("Expected first line item total to be 21.0, but got %f", invoice.LineItems[0].Total)
+	if invoice.LineItems[0].Total.String() != "21.00" {
+		t.Errorf("Expected first line item total to be 21.00, but got %s", invoice.LineItems[0].Total)
 	}
-	if invoice.LineItems[1].Total != 5.0 {
-		t.Errorf("Expected second line item total to be 5.0, but got %f", invoice.LineItems[1].Total)
+	if invoice.LineItems[1].Total.String() != "5.00" {
+		t.Errorf("Expected second line item total to be 5.00, but got %s", invoice.LineItems[1].Total)
 	}
-	if invoice.LineItems[2].Total != 60.0 {
-		t.Errorf("Expected third line item total to be 60.0, but got %f", invoice.LineItems[2].Total)
+	if invoice.LineItems[2].Total.String() != "60.00" {
+		t.Errorf("Expected third line item total to be 60.00, but got %s", invoice.LineItems[2].Total)
+	}
+	if invoice.RoundingMode != DefaultRoundingMode {
+		t.Errorf("Expected rounding mode to be pinned to %s, but got %s", DefaultRoundingMode, invoice.RoundingMode)
+	}
+}
+
+func TestInvoice_CalculateTotal_NoFloatDrift(t *testing.T) {
+	// 0.1 + 0.2 style drift used to leave long invoices a cent off.
+	items := make([]LineItem, 1000)
+	for j := range items {
+		items[j] = LineItem{Quantity: 1, UnitPrice: NewMoney(10, "USD")}
+	}
+	invoice := Invoice{LineItems: items}
+	invoice.CalculateTotal()
+
+	if invoice.Total.String() != "100.00" {
+		t.Errorf("Expected Total to be 100.00, but got %s", invoice.Total)
 	}
 }
@@ -0,0 +1,266 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is the currency assumed for amounts that do not carry one.
+const DefaultCurrency = "USD"
+
+// ErrAmountOutOfRange is returned when a sum or product of amounts does not fit in
+// the 64-bit integer that holds Money.
+var ErrAmountOutOfRange = errors.New("amount is out of range")
+
+// RoundingMode selects how amounts that fall between two minor units are rounded.
+type RoundingMode string
+
+const (
+	// RoundHalfUp rounds halves away from zero (1.005 -> 1.01).
+	RoundHalfUp RoundingMode = "half_up"
+	// RoundHalfEven rounds halves to the nearest even digit, also known as banker's rounding (1.005 -> 1.00).
+	RoundHalfEven RoundingMode = "half_even"
+)
+
+// DefaultRoundingMode is used when an invoice does not specify its own rounding mode.
+var DefaultRoundingMode = RoundHalfUp
+
+// ParseRoundingMode validates a rounding mode name. An empty string yields DefaultRoundingMode.
+func ParseRoundingMode(s string) (RoundingMode, error) {
+	switch RoundingMode(s) {
+	case "":
+		return DefaultRoundingMode, nil
+	case RoundHalfUp, RoundHalfEven:
+		return RoundingMode(s), nil
+	}
+	return "", fmt.Errorf("unknown rounding mode %q", s)
+}
+
+// round divides num by den and rounds the quotient to an integer according to the mode.
+func (r RoundingMode) round(num, den *big.Int) *big.Int {
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Sign() == 0 {
+		return quo
+	}
+
+	// Compare twice the remainder with the divisor to find out which side of the half we are on.
+	twice := new(big.Int).Abs(rem)
+	twice.Lsh(twice, 1)
+	cmp := twice.Cmp(new(big.Int).Abs(den))
+
+	awayFromZero := cmp > 0
+	if cmp == 0 {
+		switch r {
+		case RoundHalfEven:
+			awayFromZero = quo.Bit(0) == 1
+		default:
+			awayFromZero = true
+		}
+	}
+
+	if awayFromZero {
+		if (num.Sign() < 0) != (den.Sign() < 0) {
+			quo.Sub(quo, big.NewInt(1))
+		} else {
+			quo.Add(quo, big.NewInt(1))
+		}
+	}
+	return quo
+}
+
+// Money is an exact monetary amount held as an integer number of minor units of its
+// currency (cents for USD, yen for JPY, fils for KWD). It is encoded in JSON as a plain
+// decimal number such as 21.50 and stored in DECIMAL columns; the currency travels
+// separately, e.g. in Invoice.Currency.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// NewMoney returns an amount of minor units in the given currency.
+func NewMoney(minor int64, currency string) Money {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	return Money{Amount: minor, Currency: currency}
+}
+
+// ParseMoney parses a decimal string such as "10.5" or "-3.25" into Money.
+// Digits beyond the currency's precision are rounded using mode.
+func ParseMoney(s, currency string, mode RoundingMode) (Money, error) {
+	s = strings.TrimSpace(s)
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || s == "" {
+		return Money{}, fmt.Errorf("invalid amount %q", s)
+	}
+
+	scaled := new(big.Int).Mul(r.Num(), pow10(MinorUnits(currency)))
+	minor := mode.round(scaled, r.Denom())
+	if !minor.IsInt64() {
+		return Money{}, fmt.Errorf("amount %q is out of range", s)
+	}
+	return NewMoney(minor.Int64(), currency), nil
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// IsZero reports whether the amount is zero.
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// IsNegative reports whether the amount is below zero.
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// Cmp compares two amounts of the same currency, returning -1, 0 or +1.
+func (m Money) Cmp(o Money) int {
+	m.sameCurrency(o)
+	switch {
+	case m.Amount < o.Amount:
+		return -1
+	case m.Amount > o.Amount:
+		return 1
+	}
+	return 0
+}
+
+// Add returns m + o, or ErrAmountOutOfRange if the sum overflows. Both amounts must
+// share a currency.
+func (m Money) Add(o Money) (Money, error) {
+	sum := m.Amount + o.Amount
+	if (sum > m.Amount) != (o.Amount > 0) {
+		return Money{}, ErrAmountOutOfRange
+	}
+	return Money{Amount: sum, Currency: m.sameCurrency(o)}, nil
+}
+
+// Sub returns m - o. Both amounts must share a currency.
+func (m Money) Sub(o Money) Money {
+	return Money{Amount: m.Amount - o.Amount, Currency: m.sameCurrency(o)}
+}
+
+// Neg returns -m.
+func (m Money) Neg() Money {
+	return Money{Amount: -m.Amount, Currency: m.Currency}
+}
+
+// Mul returns m multiplied by an integer factor such as a quantity, or
+// ErrAmountOutOfRange if the product overflows.
+func (m Money) Mul(n int64) (Money, error) {
+	product := new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(n))
+	if !product.IsInt64() {
+		return Money{}, ErrAmountOutOfRange
+	}
+	return Money{Amount: product.Int64(), Currency: m.Currency}, nil
+}
+
+// MulFrac returns m * num / den rounded to whole minor units using mode.
+// It is the building block for percentages and pro-rata allocations.
+func (m Money) MulFrac(num, den int64, mode RoundingMode) Money {
+	product := new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(num))
+	return Money{Amount: mode.round(product, big.NewInt(den)).Int64(), Currency: m.Currency}
+}
+
+// sameCurrency returns the shared currency of m and o. A blank currency adopts the other side's.
+// Mixing two different currencies is a programming error and panics.
+func (m Money) sameCurrency(o Money) string {
+	switch {
+	case m.Currency == o.Currency, o.Currency == "":
+		return m.Currency
+	case m.Currency == "":
+		return o.Currency
+	}
+	panic(fmt.Sprintf("models: currency mismatch %s vs %s", m.Currency, o.Currency))
+}
+
+// String formats the amount as a plain decimal with the currency's number of
+// decimal places, e.g. "1234.50" for USD and "1234" for JPY.
+func (m Money) String() string {
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign = "-"
+	}
+	places := MinorUnits(m.Currency)
+	digits := strconv.FormatUint(absUint(amount), 10)
+	if places == 0 {
+		return sign + digits
+	}
+	if len(digits) <= places {
+		digits = strings.Repeat("0", places-len(digits)+1) + digits
+	}
+	cut := len(digits) - places
+	return sign + digits[:cut] + "." + digits[cut:]
+}
+
+func absUint(n int64) uint64 {
+	if n < 0 {
+		return uint64(-(n + 1)) + 1
+	}
+	return uint64(n)
+}
+
+// MarshalJSON encodes the amount as a JSON number with a fixed number of decimals.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a numeric string. Excess precision is
+// rounded with DefaultRoundingMode; the receiver's currency is kept if already set.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	text := string(data)
+	if strings.HasPrefix(text, `"`) {
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+	}
+	parsed, err := ParseMoney(text, m.Currency, DefaultRoundingMode)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Scan implements sql.Scanner for DECIMAL columns.
+func (m *Money) Scan(src interface{}) error {
+	var text string
+	switch v := src.(type) {
+	case nil:
+		*m = NewMoney(0, m.Currency)
+		return nil
+	case []byte:
+		text = string(v)
+	case string:
+		text = v
+	case int64:
+		text = strconv.FormatInt(v, 10)
+	case float64:
+		text = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+	parsed, err := ParseMoney(text, m.Currency, DefaultRoundingMode)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Value implements driver.Valuer so amounts are written as exact decimal strings.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
@@ -0,0 +1,132 @@
+package models
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		input string
+		mode  RoundingMode
+		want  string
+	}{
+		{"10", RoundHalfUp, "10.00"},
+		{"10.5", RoundHalfUp, "10.50"},
+		{"0.05", RoundHalfUp, "0.05"},
+		{"-3.25", RoundHalfUp, "-3.25"},
+		{"1.005", RoundHalfUp, "1.01"},
+		{"1.005", RoundHalfEven, "1.00"},
+		{"1.015", RoundHalfEven, "1.02"},
+		{"-1.005", RoundHalfUp, "-1.01"},
+		{"-1.005", RoundHalfEven, "-1.00"},
+		{"0.30000000000000004", RoundHalfUp, "0.30"},
+		{"1e2", RoundHalfUp, "100.00"},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.input, "USD", tt.mode)
+		if err != nil {
+			t.Errorf("ParseMoney(%q) returned error: %v", tt.input, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("ParseMoney(%q, %s) = %s, want %s", tt.input, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestParseMoney_Invalid(t *testing.T) {
+	for _, input := range []string{"", "abc", "1.2.3", "99999999999999999999999"} {
+		if _, err := ParseMoney(input, "USD", RoundHalfUp); err == nil {
+			t.Errorf("ParseMoney(%q) expected an error", input)
+		}
+	}
+}
+
+func TestParseRoundingMode(t *testing.T) {
+	if mode, err := ParseRoundingMode(""); err != nil || mode != DefaultRoundingMode {
+		t.Errorf("Expected empty mode to default to %s, got %s (%v)", DefaultRoundingMode, mode, err)
+	}
+	if mode, err := ParseRoundingMode("half_even"); err != nil || mode != RoundHalfEven {
+		t.Errorf("Expected half_even, got %s (%v)", mode, err)
+	}
+	if _, err := ParseRoundingMode("up"); err == nil {
+		t.Error("Expected an error for unknown rounding mode")
+	}
+}
+
+func TestMoney_MulFrac(t *testing.T) {
+	price := NewMoney(1001, "USD") // 10.01
+
+	// 10.01 / 2 = 5.005
+	if got := price.MulFrac(1, 2, RoundHalfUp); got.String() != "5.01" {
+		t.Errorf("half-up: got %s, want 5.01", got)
+	}
+	if got := price.MulFrac(1, 2, RoundHalfEven); got.String() != "5.00" {
+		t.Errorf("half-even: got %s, want 5.00", got)
+	}
+}
+
+func TestMoney_Overflow(t *testing.T) {
+	big := NewMoney(math.MaxInt64/2+1, "USD")
+	if _, err := big.Add(big); err != ErrAmountOutOfRange {
+		t.Errorf("Add: got %v, want ErrAmountOutOfRange", err)
+	}
+	if _, err := big.Neg().Add(big.Neg().Sub(NewMoney(1, "USD"))); err != ErrAmountOutOfRange {
+		t.Errorf("Add of negatives: got %v, want ErrAmountOutOfRange", err)
+	}
+	if sum, err := big.Add(big.Neg()); err != nil || !sum.IsZero() {
+		t.Errorf("Add = %s, %v; want 0.00", sum, err)
+	}
+
+	// 10^12 units at 1,000,000.00 is 10^20 cents.
+	if _, err := NewMoney(100000000, "USD").Mul(1000000000000); err != ErrAmountOutOfRange {
+		t.Errorf("Mul: got %v, want ErrAmountOutOfRange", err)
+	}
+	if product, err := NewMoney(-250, "USD").Mul(3); err != nil || product.String() != "-7.50" {
+		t.Errorf("Mul = %s, %v; want -7.50", product, err)
+	}
+}
+
+func TestMoney_JSON(t *testing.T) {
+	var item LineItem
+	if err := json.Unmarshal([]byte(`{"quantity": 3, "unit_price": 19.99}`), &item); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if item.UnitPrice.Amount != 1999 || item.UnitPrice.Currency != DefaultCurrency {
+		t.Errorf("Expected 1999 minor units in %s, got %+v", DefaultCurrency, item.UnitPrice)
+	}
+
+	if err := json.Unmarshal([]byte(`{"unit_price": "7.5"}`), &item); err != nil {
+		t.Fatalf("Unmarshal of string amount returned error: %v", err)
+	}
+	if item.UnitPrice.Amount != 750 {
+		t.Errorf("Expected 750 minor units, got %d", item.UnitPrice.Amount)
+	}
+
+	out, err := json.Marshal(NewMoney(-5, "USD"))
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if string(out) != "-0.05" {
+		t.Errorf("Expected -0.05, got %s", out)
+	}
+}
+
+func TestMoney_Scan(t *testing.T) {
+	sources := []interface{}{[]byte("25.10"), "25.10", 25.1, int64(25)}
+	wants := []int64{2510, 2510, 2510, 2500}
+
+	for i, src := range sources {
+		var m Money
+		if err := m.Scan(src); err != nil {
+			t.Errorf("Scan(%v) returned error: %v", src, err)
+			continue
+		}
+		if m.Amount != wants[i] {
+			t.Errorf("Scan(%v) = %d, want %d", src, m.Amount, wants[i])
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SequenceReset says when a numbering sequence starts again from 1.
+type SequenceReset string
+
+const (
+	ResetYearly SequenceReset = "yearly"
+	ResetNever  SequenceReset = "never"
+)
+
+// Names of the numbering sequences.
+const (
+	SequenceInvoice    = "invoice"
+	SequenceCreditNote = "credit_note"
+	SequenceEstimate   = "estimate"
+)
+
+// DefaultInvoiceNumberFormat numbers invoices INV-2026-00001, INV-2026-00002, ...
+const DefaultInvoiceNumberFormat = "INV-{YYYY}-{seq:05}"
+
+// DefaultCreditNoteNumberFormat numbers credit notes CN-2026-00001, CN-2026-00002, ...
+const DefaultCreditNoteNumberFormat = "CN-{YYYY}-{seq:05}"
+
+// DefaultEstimateNumberFormat numbers estimates EST-2026-00001, EST-2026-00002, ...
+const DefaultEstimateNumberFormat = "EST-{YYYY}-{seq:05}"
+
+// numberToken matches the placeholders of a number format.
+var numberToken = regexp.MustCompile(`\{(YYYY|YY|MM|seq(?::0?([1-9][0-9]?))?)\}`)
+
+// NumberSequence is a configurable sequence of document numbers, such as invoice
+// numbers. Format may contain {YYYY}, {YY} and {MM} for the document date and
+// {seq} or {seq:05} for the sequence value, zero-padded to the given width.
+type NumberSequence struct {
+	Name   string        `json:"name"`
+	Format string        `json:"format"`
+	Reset  SequenceReset `json:"reset"`
+}
+
+// Validate checks the format and reset rule. A format must contain {seq} exactly
+// once, and a yearly sequence must show the year so its numbers never repeat.
+func (s *NumberSequence) Validate() error {
+	s.Format = strings.TrimSpace(s.Format)
+	if s.Format == "" || len(s.Format) > 40 {
+		return fmt.Errorf("format must be between 1 and 40 characters")
+	}
+	if s.Reset != ResetYearly && s.Reset != ResetNever {
+		return fmt.Errorf("reset must be yearly or never")
+	}
+
+	seq, year := 0, false
+	for _, match := range numberToken.FindAllStringSubmatch(s.Format, -1) {
+		switch {
+		case strings.HasPrefix(match[1], "seq"):
+			seq++
+		case match[1] == "YYYY" || match[1] == "YY":
+			year = true
+		}
+	}
+	if leftover := numberToken.ReplaceAllString(s.Format, ""); strings.ContainsAny(leftover, "{}") {
+		return fmt.Errorf("format contains an unknown placeholder")
+	}
+	if seq != 1 {
+		return fmt.Errorf("format must contain {seq} exactly once")
+	}
+	if s.Reset == ResetYearly && !year {
+		return fmt.Errorf("a yearly sequence must include {YYYY} or {YY} in its format")
+	}
+	return nil
+}
+
+// Period returns the counter that a document dated date draws from: its year for
+// yearly sequences, and a single shared counter otherwise.
+func (s *NumberSequence) Period(date time.Time) string {
+	if s.Reset == ResetYearly {
+		return strconv.Itoa(date.Year())
+	}
+	return ""
+}
+
+// Number formats the seq-th number of the sequence for a document dated date.
+func (s *NumberSequence) Number(date time.Time, seq int64) string {
+	return numberToken.ReplaceAllStringFunc(s.Format, func(token string) string {
+		match := numberToken.FindStringSubmatch(token)
+		switch match[1] {
+		case "YYYY":
+			return fmt.Sprintf("%04d", date.Year())
+		case "YY":
+			return fmt.Sprintf("%02d", date.Year()%100)
+		case "MM":
+			return fmt.Sprintf("%02d", int(date.Month()))
+		}
+		width, _ := strconv.Atoi(match[2])
+		return fmt.Sprintf("%0*d", width, seq)
+	})
+}
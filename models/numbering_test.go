@@ -0,0 +1,59 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNumberSequence_Number(t *testing.T) {
+	date := time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		format string
+		seq    int64
+		want   string
+	}{
+		{"INV-{YYYY}-{seq:05}", 42, "INV-2026-00042"},
+		{"{YY}{MM}/{seq:3}", 7, "2603/007"},
+		{"F{seq}", 123456, "F123456"},
+		{"INV-{YYYY}-{seq:02}", 1234, "INV-2026-1234"},
+	}
+	for _, tt := range tests {
+		sequence := NumberSequence{Format: tt.format, Reset: ResetNever}
+		if got := sequence.Number(date, tt.seq); got != tt.want {
+			t.Errorf("Number(%q, %d) = %q, want %q", tt.format, tt.seq, got, tt.want)
+		}
+	}
+}
+
+func TestNumberSequence_Validate(t *testing.T) {
+	tests := []struct {
+		sequence NumberSequence
+		valid    bool
+	}{
+		{NumberSequence{Format: DefaultInvoiceNumberFormat, Reset: ResetYearly}, true},
+		{NumberSequence{Format: "F{seq:06}", Reset: ResetNever}, true},
+		{NumberSequence{Format: "F{seq:06}", Reset: ResetYearly}, false},
+		{NumberSequence{Format: "INV-{YYYY}", Reset: ResetYearly}, false},
+		{NumberSequence{Format: "{seq}-{seq}", Reset: ResetNever}, false},
+		{NumberSequence{Format: "{DD}-{seq}", Reset: ResetNever}, false},
+		{NumberSequence{Format: "{seq}", Reset: "monthly"}, false},
+	}
+	for _, tt := range tests {
+		err := tt.sequence.Validate()
+		if tt.valid && err != nil {
+			t.Errorf("%q (%s): unexpected error %v", tt.sequence.Format, tt.sequence.Reset, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("%q (%s): expected an error", tt.sequence.Format, tt.sequence.Reset)
+		}
+	}
+}
+
+func TestNumberSequence_Period(t *testing.T) {
+	date := time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)
+	yearly := NumberSequence{Reset: ResetYearly}
+	never := NumberSequence{Reset: ResetNever}
+	if yearly.Period(date) != "2026" || never.Period(date) != "" {
+		t.Errorf("unexpected periods %q and %q", yearly.Period(date), never.Period(date))
+	}
+}
@@ -0,0 +1,157 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PaymentKind distinguishes money received from money returned to the customer.
+type PaymentKind string
+
+const (
+	PaymentKindPayment PaymentKind = "payment"
+	PaymentKindRefund  PaymentKind = "refund"
+)
+
+var (
+	// ErrInvalidPayment is returned for payments with missing or malformed fields.
+	ErrInvalidPayment = errors.New("invalid payment")
+	// ErrNotPayable is returned when the invoice's status does not accept payments.
+	ErrNotPayable = errors.New("invoice does not accept payments")
+	// ErrOverpayment is returned when a payment is larger than the balance due.
+	ErrOverpayment = errors.New("payment exceeds balance due")
+	// ErrRefundExceedsPaid is returned when a refund is larger than the amount paid.
+	ErrRefundExceedsPaid = errors.New("refund exceeds amount paid")
+	// ErrAlreadyReversed is returned when reversing a payment twice.
+	ErrAlreadyReversed = errors.New("payment already reversed")
+)
+
+// Payment is an entry in an invoice's payment ledger. Entries are never deleted;
+// a mistaken or bounced payment is reversed instead, which keeps the audit trail.
+type Payment struct {
+	ID         int         `json:"id"`
+	InvoiceID  int         `json:"invoice_id"`
+	Kind       PaymentKind `json:"kind"`
+	Amount     Money       `json:"amount"`
+	Date       time.Time   `json:"date"`
+	Method     string      `json:"method"`
+	Reference  string      `json:"reference"`
+	ReversedAt *time.Time  `json:"reversed_at,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+// Validate checks the payment's own fields, defaulting Kind to a regular payment.
+func (p *Payment) Validate() error {
+	if p.Kind == "" {
+		p.Kind = PaymentKindPayment
+	}
+	if p.Kind != PaymentKindPayment && p.Kind != PaymentKindRefund {
+		return fmt.Errorf("%w: unknown kind %q", ErrInvalidPayment, p.Kind)
+	}
+	if p.Amount.IsZero() || p.Amount.IsNegative() {
+		return fmt.Errorf("%w: amount must be positive", ErrInvalidPayment)
+	}
+	if p.Date.IsZero() {
+		return fmt.Errorf("%w: date is required", ErrInvalidPayment)
+	}
+	p.Method = strings.TrimSpace(p.Method)
+	if p.Method == "" {
+		return fmt.Errorf("%w: method is required", ErrInvalidPayment)
+	}
+	return nil
+}
+
+// Signed returns the payment's effect on the amount paid: positive for payments,
+// negative for refunds and zero once reversed.
+func (p *Payment) Signed() Money {
+	switch {
+	case p.ReversedAt != nil:
+		return NewMoney(0, p.Amount.Currency)
+	case p.Kind == PaymentKindRefund:
+		return p.Amount.Neg()
+	}
+	return p.Amount
+}
+
+// UpdateBalance recomputes BalanceDue from Total, AmountPaid and AmountCredited.
+// It is negative when the customer has paid for something that was later credited.
+func (i *Invoice) UpdateBalance() {
+	i.BalanceDue = i.Total.Sub(i.AmountCredited).Sub(i.AmountPaid)
+}
+
+// ApplyPayment records a new ledger entry against the invoice, updating the amount
+// paid and moving the invoice between sent, partially paid and paid.
+// The invoice's Status must hold its stored (not derived) status.
+func (i *Invoice) ApplyPayment(p *Payment, at time.Time) error {
+	i.UpdateBalance()
+
+	switch p.Kind {
+	case PaymentKindRefund:
+		if i.Status != StatusSent && i.Status != StatusPartiallyPaid && i.Status != StatusPaid {
+			return fmt.Errorf("%w: invoice is %s", ErrNotPayable, i.Status)
+		}
+		if p.Amount.Cmp(i.AmountPaid) > 0 {
+			return ErrRefundExceedsPaid
+		}
+	default:
+		if i.Status != StatusSent && i.Status != StatusPartiallyPaid {
+			return fmt.Errorf("%w: invoice is %s", ErrNotPayable, i.Status)
+		}
+		if p.Amount.Cmp(i.BalanceDue) > 0 {
+			return ErrOverpayment
+		}
+	}
+
+	paid, err := i.AmountPaid.Add(p.Signed())
+	if err != nil {
+		return err
+	}
+	i.AmountPaid = paid
+	i.settle(at)
+	return nil
+}
+
+// ReversePayment cancels an earlier ledger entry. Reversing a payment must not
+// leave the invoice with more refunded than paid; reverse the refund first.
+func (i *Invoice) ReversePayment(p *Payment, at time.Time) error {
+	if p.ReversedAt != nil {
+		return ErrAlreadyReversed
+	}
+	if i.Status == StatusVoid || i.Status == StatusDraft {
+		return fmt.Errorf("%w: invoice is %s", ErrNotPayable, i.Status)
+	}
+
+	paid := i.AmountPaid.Sub(p.Signed())
+	if paid.IsNegative() {
+		return ErrRefundExceedsPaid
+	}
+	if paid.Cmp(i.Total.Sub(i.AmountCredited)) > 0 {
+		return ErrOverpayment
+	}
+
+	p.ReversedAt = &at
+	i.AmountPaid = paid
+	i.settle(at)
+	return nil
+}
+
+// settle derives the payment-driven status from the amount paid.
+func (i *Invoice) settle(at time.Time) {
+	i.UpdateBalance()
+
+	switch {
+	case i.BalanceDue.IsZero() || i.BalanceDue.IsNegative():
+		if i.Status != StatusPaid {
+			i.PaidAt = &at
+		}
+		i.Status = StatusPaid
+	case i.AmountPaid.IsZero():
+		i.Status = StatusSent
+		i.PaidAt = nil
+	default:
+		i.Status = StatusPartiallyPaid
+		i.PaidAt = nil
+	}
+}
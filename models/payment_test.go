@@ -0,0 +1,122 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func sentInvoice(total int64) Invoice {
+	return Invoice{
+		Status:     StatusSent,
+		Total:      NewMoney(total, "USD"),
+		AmountPaid: NewMoney(0, "USD"),
+	}
+}
+
+func payment(kind PaymentKind, amount int64) *Payment {
+	return &Payment{Kind: kind, Amount: NewMoney(amount, "USD"), Date: time.Now(), Method: "bank_transfer"}
+}
+
+func TestInvoice_ApplyPayment_PartialThenFull(t *testing.T) {
+	at := time.Now()
+	invoice := sentInvoice(10000)
+
+	if err := invoice.ApplyPayment(payment(PaymentKindPayment, 4000), at); err != nil {
+		t.Fatalf("ApplyPayment returned error: %v", err)
+	}
+	if invoice.Status != StatusPartiallyPaid || invoice.BalanceDue.String() != "60.00" {
+		t.Errorf("Expected partially_paid with 60.00 due, got %s with %s", invoice.Status, invoice.BalanceDue)
+	}
+
+	if err := invoice.ApplyPayment(payment(PaymentKindPayment, 6000), at); err != nil {
+		t.Fatalf("ApplyPayment returned error: %v", err)
+	}
+	if invoice.Status != StatusPaid || invoice.PaidAt == nil || !invoice.BalanceDue.IsZero() {
+		t.Errorf("Expected paid with nothing due, got %s with %s", invoice.Status, invoice.BalanceDue)
+	}
+
+	if err := invoice.ApplyPayment(payment(PaymentKindPayment, 1), at); !errors.Is(err, ErrNotPayable) {
+		t.Errorf("Expected ErrNotPayable on a paid invoice, got %v", err)
+	}
+}
+
+func TestInvoice_ApplyPayment_Rejections(t *testing.T) {
+	at := time.Now()
+
+	invoice := sentInvoice(10000)
+	if err := invoice.ApplyPayment(payment(PaymentKindPayment, 10001), at); !errors.Is(err, ErrOverpayment) {
+		t.Errorf("Expected ErrOverpayment, got %v", err)
+	}
+	if err := invoice.ApplyPayment(payment(PaymentKindRefund, 1), at); !errors.Is(err, ErrRefundExceedsPaid) {
+		t.Errorf("Expected ErrRefundExceedsPaid, got %v", err)
+	}
+
+	draft := Invoice{Status: StatusDraft, Total: NewMoney(10000, "USD")}
+	if err := draft.ApplyPayment(payment(PaymentKindPayment, 100), at); !errors.Is(err, ErrNotPayable) {
+		t.Errorf("Expected ErrNotPayable on a draft, got %v", err)
+	}
+}
+
+func TestInvoice_Refund_ReopensInvoice(t *testing.T) {
+	at := time.Now()
+	invoice := sentInvoice(10000)
+	invoice.ApplyPayment(payment(PaymentKindPayment, 10000), at)
+
+	if err := invoice.ApplyPayment(payment(PaymentKindRefund, 2500), at); err != nil {
+		t.Fatalf("ApplyPayment(refund) returned error: %v", err)
+	}
+	if invoice.Status != StatusPartiallyPaid || invoice.AmountPaid.String() != "75.00" || invoice.PaidAt != nil {
+		t.Errorf("Expected partially_paid with 75.00 paid, got %s with %s", invoice.Status, invoice.AmountPaid)
+	}
+}
+
+func TestInvoice_ReversePayment(t *testing.T) {
+	at := time.Now()
+	invoice := sentInvoice(10000)
+	first := payment(PaymentKindPayment, 10000)
+	invoice.ApplyPayment(first, at)
+	refund := payment(PaymentKindRefund, 3000)
+	invoice.ApplyPayment(refund, at)
+
+	// Reversing the original payment would leave more refunded than paid.
+	if err := invoice.ReversePayment(first, at); !errors.Is(err, ErrRefundExceedsPaid) {
+		t.Errorf("Expected ErrRefundExceedsPaid, got %v", err)
+	}
+
+	if err := invoice.ReversePayment(refund, at); err != nil {
+		t.Fatalf("ReversePayment(refund) returned error: %v", err)
+	}
+	if invoice.Status != StatusPaid {
+		t.Errorf("Expected paid after reversing the refund, got %s", invoice.Status)
+	}
+
+	if err := invoice.ReversePayment(first, at); err != nil {
+		t.Fatalf("ReversePayment returned error: %v", err)
+	}
+	if invoice.Status != StatusSent || !invoice.AmountPaid.IsZero() || first.ReversedAt == nil {
+		t.Errorf("Expected sent with nothing paid, got %s with %s", invoice.Status, invoice.AmountPaid)
+	}
+
+	if err := invoice.ReversePayment(first, at); !errors.Is(err, ErrAlreadyReversed) {
+		t.Errorf("Expected ErrAlreadyReversed, got %v", err)
+	}
+}
+
+func TestPayment_Validate(t *testing.T) {
+	p := payment("", 100)
+	if err := p.Validate(); err != nil || p.Kind != PaymentKindPayment {
+		t.Errorf("Expected valid payment defaulting to kind payment, got %s (%v)", p.Kind, err)
+	}
+
+	for _, bad := range []*Payment{
+		payment("gift", 100),
+		payment(PaymentKindPayment, 0),
+		payment(PaymentKindPayment, -5),
+		{Kind: PaymentKindPayment, Amount: NewMoney(100, "USD"), Date: time.Now()},
+	} {
+		if err := bad.Validate(); !errors.Is(err, ErrInvalidPayment) {
+			t.Errorf("Expected ErrInvalidPayment for %+v, got %v", bad, err)
+		}
+	}
+}
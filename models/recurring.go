@@ -0,0 +1,255 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Cadence says how often a recurring profile produces an invoice.
+type Cadence string
+
+const (
+	CadenceWeekly    Cadence = "weekly"
+	CadenceMonthly   Cadence = "monthly"
+	CadenceQuarterly Cadence = "quarterly"
+	CadenceYearly    Cadence = "yearly"
+	// CadenceCron follows the profile's cron expression.
+	CadenceCron Cadence = "cron"
+)
+
+// maxDueDays bounds the payment term of a recurring profile.
+const maxDueDays = 365
+
+// RecurringProfile is a template from which an invoice is generated on a schedule,
+// e.g. a monthly retainer. Calendar cadences fall on the start date and every week,
+// month, quarter or year after it; a start date on the 31st falls on the last day of
+// shorter months. Cron schedules run at the times of their expression from the start
+// date on. No invoices are generated after the end date, if there is one.
+type RecurringProfile struct {
+	ID               int          `json:"id"`
+	Name             string       `json:"name"`
+	CustomerID       int          `json:"customer_id"`
+	Currency         string       `json:"currency"`
+	Cadence          Cadence      `json:"cadence"`
+	Cron             string       `json:"cron,omitempty"`
+	StartDate        time.Time    `json:"start_date"`
+	EndDate          *time.Time   `json:"end_date,omitempty"`
+	DueDays          int          `json:"due_days"`
+	PricesIncludeTax bool         `json:"prices_include_tax"`
+	DiscountPercent  Percent      `json:"discount_percent"`
+	DiscountAmount   Money        `json:"discount_amount"`
+	RoundingMode     RoundingMode `json:"rounding_mode,omitempty"`
+	LineItems        []LineItem   `json:"line_items"`
+	Active           bool         `json:"active"`
+	// NextRun is when the next invoice is due to be generated; nil once the
+	// schedule has ended.
+	NextRun *time.Time `json:"next_run"`
+	LastRun *time.Time `json:"last_run,omitempty"`
+}
+
+// UnmarshalJSON decodes a profile with its amounts read in the profile's currency,
+// as for Invoice. "active" keeps the receiver's value when it is absent.
+func (p *RecurringProfile) UnmarshalJSON(data []byte) error {
+	// The invoice fields are decoded as an invoice, which settles the currency.
+	invoice := Invoice{Currency: p.Currency}
+	if err := json.Unmarshal(data, &invoice); err != nil {
+		return err
+	}
+	var aux struct {
+		ID        int        `json:"id"`
+		Name      string     `json:"name"`
+		Cadence   Cadence    `json:"cadence"`
+		Cron      string     `json:"cron"`
+		StartDate time.Time  `json:"start_date"`
+		EndDate   *time.Time `json:"end_date"`
+		DueDays   int        `json:"due_days"`
+		Active    *bool      `json:"active"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	active := p.Active
+	if aux.Active != nil {
+		active = *aux.Active
+	}
+	*p = RecurringProfile{
+		ID:               aux.ID,
+		Name:             aux.Name,
+		CustomerID:       invoice.CustomerID,
+		Currency:         invoice.Currency,
+		Cadence:          aux.Cadence,
+		Cron:             aux.Cron,
+		StartDate:        aux.StartDate,
+		EndDate:          aux.EndDate,
+		DueDays:          aux.DueDays,
+		PricesIncludeTax: invoice.PricesIncludeTax,
+		DiscountPercent:  invoice.DiscountPercent,
+		DiscountAmount:   invoice.DiscountAmount,
+		RoundingMode:     invoice.RoundingMode,
+		LineItems:        invoice.LineItems,
+		Active:           active,
+	}
+	return nil
+}
+
+// Validate checks the profile and normalises its currency, rounding mode and dates,
+// which are reduced to whole days in UTC. Whether its tax codes and discounts make
+// a valid invoice is checked on the result of Invoice.
+func (p *RecurringProfile) Validate() error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" || len(p.Name) > 255 {
+		return fmt.Errorf("name must be between 1 and 255 characters")
+	}
+	if p.CustomerID == 0 {
+		return fmt.Errorf("customer_id is required")
+	}
+	if len(p.LineItems) == 0 {
+		return fmt.Errorf("at least one line item is required")
+	}
+	if err := ValidateQuantities(p.LineItems); err != nil {
+		return err
+	}
+
+	currency, err := ParseCurrency(p.Currency)
+	if err != nil {
+		return err
+	}
+	p.Currency = currency
+	mode, err := ParseRoundingMode(string(p.RoundingMode))
+	if err != nil {
+		return err
+	}
+	p.RoundingMode = mode
+
+	switch p.Cadence {
+	case CadenceWeekly, CadenceMonthly, CadenceQuarterly, CadenceYearly:
+		p.Cron = ""
+	case CadenceCron:
+		if _, err := ParseCron(p.Cron); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("cadence must be weekly, monthly, quarterly, yearly or cron")
+	}
+
+	if p.StartDate.IsZero() {
+		return fmt.Errorf("start_date is required")
+	}
+	p.StartDate = day(p.StartDate)
+	if p.EndDate != nil {
+		end := day(*p.EndDate)
+		if end.Before(p.StartDate) {
+			return fmt.Errorf("end_date must not be before start_date")
+		}
+		p.EndDate = &end
+	}
+	if p.DueDays < 0 || p.DueDays > maxDueDays {
+		return fmt.Errorf("due_days must be between 0 and %d", maxDueDays)
+	}
+	return nil
+}
+
+// day returns the start of t's day in UTC.
+func day(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// Schedule sets NextRun to the first run on or after the start of now's day that is
+// later than LastRun. Runs before the profile was created, or while it was paused,
+// are not made up for.
+func (p *RecurringProfile) Schedule(now time.Time) {
+	after := day(now).Add(-time.Nanosecond)
+	if p.LastRun != nil && p.LastRun.After(after) {
+		after = *p.LastRun
+	}
+	p.NextRun = p.RunAfter(after)
+}
+
+// RunAfter returns the first run of the schedule later than t, or nil if the
+// schedule ends before then.
+func (p *RecurringProfile) RunAfter(t time.Time) *time.Time {
+	var next time.Time
+	if p.Cadence == CadenceCron {
+		schedule, err := ParseCron(p.Cron)
+		if err != nil {
+			return nil
+		}
+		if start := p.StartDate.Add(-time.Nanosecond); t.Before(start) {
+			t = start
+		}
+		next = schedule.Next(t)
+		if next.IsZero() {
+			return nil
+		}
+	} else {
+		n := 0
+		for !p.occurrence(n).After(t) {
+			n++
+		}
+		next = p.occurrence(n)
+	}
+
+	if p.EndDate != nil && !next.Before(p.EndDate.AddDate(0, 0, 1)) {
+		return nil
+	}
+	return &next
+}
+
+// occurrence returns the nth run of a calendar cadence, counting the start date as 0.
+func (p *RecurringProfile) occurrence(n int) time.Time {
+	months := 0
+	switch p.Cadence {
+	case CadenceWeekly:
+		return p.StartDate.AddDate(0, 0, 7*n)
+	case CadenceMonthly:
+		months = n
+	case CadenceQuarterly:
+		months = 3 * n
+	case CadenceYearly:
+		months = 12 * n
+	}
+	// Count from the start date each time, so that a run clamped to the end of a
+	// short month does not move later runs.
+	y, m, d := p.StartDate.Date()
+	first := time.Date(y, m+time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+	last := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(d, last)-1)
+}
+
+// Invoice returns the draft invoice for the run at the given time: issued that day,
+// due DueDays later, with a copy of the profile's line items. Totals are not yet
+// calculated.
+func (p *RecurringProfile) Invoice(run time.Time) *Invoice {
+	items := make([]LineItem, len(p.LineItems))
+	for k, item := range p.LineItems {
+		items[k] = LineItem{
+			Description:     item.Description,
+			Quantity:        item.Quantity,
+			UnitPrice:       item.UnitPrice,
+			DiscountPercent: item.DiscountPercent,
+			DiscountAmount:  item.DiscountAmount,
+			TaxCodes:        item.TaxCodes,
+		}
+	}
+
+	profileID := p.ID
+	issued := day(run)
+	return &Invoice{
+		CustomerID:         p.CustomerID,
+		Currency:           p.Currency,
+		IssueDate:          issued,
+		DueDate:            issued.AddDate(0, 0, p.DueDays),
+		PricesIncludeTax:   p.PricesIncludeTax,
+		DiscountPercent:    p.DiscountPercent,
+		DiscountAmount:     p.DiscountAmount,
+		RoundingMode:       p.RoundingMode,
+		Status:             StatusDraft,
+		LineItems:          items,
+		RecurringProfileID: &profileID,
+		RecurrenceDate:     &run,
+	}
+}
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		expr  string
+		after time.Time
+		want  time.Time
+	}{
+		// 09:00 on the first of every month.
+		{"0 9 1 * *", date(2026, 1, 15), time.Date(2026, 2, 1, 9, 0, 0, 0, time.UTC)},
+		// Every 15 minutes.
+		{"*/15 * * * *", time.Date(2026, 1, 1, 10, 7, 0, 0, time.UTC), time.Date(2026, 1, 1, 10, 15, 0, 0, time.UTC)},
+		// Weekdays at 08:30; 2026-01-03 is a Saturday.
+		{"30 8 * * 1-5", date(2026, 1, 3), time.Date(2026, 1, 5, 8, 30, 0, 0, time.UTC)},
+		// Sunday written as 7.
+		{"0 0 * * 7", date(2026, 1, 5), date(2026, 1, 11)},
+		// Either day field matches when both are restricted: the 13th or a Friday.
+		{"0 0 13 * 5", date(2026, 1, 1), date(2026, 1, 2)},
+		// Quarterly, on the first day of January, April, July and October.
+		{"0 0 1 1,4,7,10 *", date(2026, 1, 1), date(2026, 4, 1)},
+		// Strictly after: a time that matches gives the following run.
+		{"0 0 1 * *", date(2026, 3, 1), date(2026, 4, 1)},
+		// Never matches.
+		{"0 0 30 2 *", date(2026, 1, 1), time.Time{}},
+	}
+	for _, tt := range tests {
+		schedule, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Errorf("ParseCron(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := schedule.Next(tt.after); !got.Equal(tt.want) {
+			t.Errorf("%q.Next(%v) = %v, want %v", tt.expr, tt.after, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestRecurringProfile_RunAfter(t *testing.T) {
+	end := date(2026, 6, 30)
+	monthly := RecurringProfile{Cadence: CadenceMonthly, StartDate: date(2026, 1, 31), EndDate: &end}
+
+	// Runs on the 31st fall on the last day of shorter months without drifting.
+	var runs []time.Time
+	for run := monthly.RunAfter(date(2025, 12, 31)); run != nil; run = monthly.RunAfter(*run) {
+		runs = append(runs, *run)
+	}
+	want := []time.Time{date(2026, 1, 31), date(2026, 2, 28), date(2026, 3, 31), date(2026, 4, 30), date(2026, 5, 31), date(2026, 6, 30)}
+	if len(runs) != len(want) {
+		t.Fatalf("got runs %v, want %v", runs, want)
+	}
+	for k := range want {
+		if !runs[k].Equal(want[k]) {
+			t.Errorf("run %d = %v, want %v", k, runs[k], want[k])
+		}
+	}
+
+	quarterly := RecurringProfile{Cadence: CadenceQuarterly, StartDate: date(2026, 1, 15)}
+	if run := quarterly.RunAfter(date(2026, 1, 15)); run == nil || !run.Equal(date(2026, 4, 15)) {
+		t.Errorf("quarterly run after the start = %v, want 2026-04-15", run)
+	}
+
+	cron := RecurringProfile{Cadence: CadenceCron, Cron: "0 9 * * 1", StartDate: date(2026, 3, 1)}
+	if run := cron.RunAfter(date(2026, 1, 1)); run == nil || !run.Equal(time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("cron run before the start date = %v, want the first Monday after it", run)
+	}
+}
+
+func TestRecurringProfile_Schedule(t *testing.T) {
+	profile := RecurringProfile{Cadence: CadenceMonthly, StartDate: date(2025, 11, 10)}
+
+	// A new profile starts from today, not from runs in the past.
+	profile.Schedule(time.Date(2026, 2, 10, 15, 0, 0, 0, time.UTC))
+	if profile.NextRun == nil || !profile.NextRun.Equal(date(2026, 2, 10)) {
+		t.Errorf("NextRun = %v, want 2026-02-10", profile.NextRun)
+	}
+
+	// After a run, the next one follows it.
+	last := date(2026, 2, 10)
+	profile.LastRun = &last
+	profile.Schedule(time.Date(2026, 2, 10, 16, 0, 0, 0, time.UTC))
+	if profile.NextRun == nil || !profile.NextRun.Equal(date(2026, 3, 10)) {
+		t.Errorf("NextRun = %v, want 2026-03-10", profile.NextRun)
+	}
+}
+
+func TestRecurringProfile_UnmarshalJSON(t *testing.T) {
+	profile := RecurringProfile{Currency: "JPY", Active: true}
+	data := `{"name": "Retainer", "customer_id": 3, "cadence": "monthly", "start_date": "2026-01-01T00:00:00Z", "due_days": 14,
+		"line_items": [{"description": "Support", "quantity": 1, "unit_price": "1500"}]}`
+	if err := json.Unmarshal([]byte(data), &profile); err != nil {
+		t.Fatal(err)
+	}
+	if profile.Name != "Retainer" || profile.CustomerID != 3 || profile.DueDays != 14 || !profile.Active {
+		t.Errorf("decoded profile = %+v", profile)
+	}
+	if price := profile.LineItems[0].UnitPrice; price.Amount != 1500 || price.Currency != "JPY" {
+		t.Errorf("unit price = %+v, want 1500 JPY", price)
+	}
+}
+
+func TestRecurringProfile_Invoice(t *testing.T) {
+	profile := RecurringProfile{
+		ID:         4,
+		CustomerID: 3,
+		Currency:   "EUR",
+		DueDays:    14,
+		LineItems:  []LineItem{{ID: 9, Description: "Support", Quantity: 1, UnitPrice: NewMoney(50000, "EUR"), Total: NewMoney(1, "EUR")}},
+	}
+	run := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	invoice := profile.Invoice(run)
+
+	if !invoice.IssueDate.Equal(date(2026, 5, 1)) || !invoice.DueDate.Equal(date(2026, 5, 15)) {
+		t.Errorf("dates = %v / %v, want 2026-05-01 / 2026-05-15", invoice.IssueDate, invoice.DueDate)
+	}
+	if invoice.Status != StatusDraft || *invoice.RecurringProfileID != 4 || !invoice.RecurrenceDate.Equal(run) {
+		t.Errorf("invoice = %+v", invoice)
+	}
+	if item := invoice.LineItems[0]; item.ID != 0 || !item.Total.IsZero() || item.UnitPrice.Amount != 50000 {
+		t.Errorf("line item = %+v, want a fresh copy of the template", item)
+	}
+}
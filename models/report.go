@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// CurrencySales sums the issued invoices and credit notes of one currency over a
+// report period. Net is what was invoiced less what was credited.
+type CurrencySales struct {
+	Currency     string `json:"currency"`
+	Invoices     int    `json:"invoices"`
+	Total        Money  `json:"total"`
+	BaseTotal    Money  `json:"base_total"`
+	CreditNotes  int    `json:"credit_notes"`
+	Credited     Money  `json:"credited"`
+	BaseCredited Money  `json:"base_credited"`
+	Net          Money  `json:"net"`
+	BaseNet      Money  `json:"base_net"`
+}
+
+// SalesReport sums issued invoices and credit notes by currency. Base-currency
+// figures use the exchange rate snapshotted when each invoice was sent; a credit
+// note uses its invoice's rate.
+type SalesReport struct {
+	From         time.Time       `json:"from"`
+	To           time.Time       `json:"to"`
+	BaseCurrency string          `json:"base_currency"`
+	Currencies   []CurrencySales `json:"currencies"`
+	BaseTotal    Money           `json:"base_total"`
+	BaseCredited Money           `json:"base_credited"`
+	BaseNet      Money           `json:"base_net"`
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Role decides what a user may do.
+type Role string
+
+const (
+	// RoleOwner may do everything, including managing other owners.
+	RoleOwner Role = "owner"
+	// RoleAdmin may do everything except manage owners.
+	RoleAdmin Role = "admin"
+	// RoleAccountant keeps the books: invoices, payments, credit notes, estimates,
+	// recurring profiles and customers, but not settings or users.
+	RoleAccountant Role = "accountant"
+	// RoleViewer may only look, e.g. a bookkeeper reconciling the accounts.
+	RoleViewer Role = "viewer"
+	// RoleNone is the role of users who have not been assigned one, such as the
+	// non-admins of installations from before roles. They cannot sign in.
+	RoleNone Role = ""
+)
+
+// Permission is something a handler requires of the signed-in user. Permissions
+// double as the scopes of API tokens.
+type Permission string
+
+const (
+	PermissionRead           Permission = "read"
+	PermissionWriteInvoices  Permission = "invoices:write"
+	PermissionWriteCustomers Permission = "customers:write"
+	PermissionWriteSettings  Permission = "settings:write"
+	PermissionManageUsers    Permission = "users:write"
+	// PermissionAccount covers a user's own sessions and API tokens. Every role has
+	// it, but it cannot be granted to API tokens.
+	PermissionAccount Permission = "account"
+)
+
+// ErrLastOwner is returned when the only owner would be demoted.
+var ErrLastOwner = errors.New("the last owner cannot be demoted")
+
+// permissions is the permission matrix of the roles.
+var permissions = map[Role][]Permission{
+	RoleOwner:      {PermissionRead, PermissionWriteInvoices, PermissionWriteCustomers, PermissionWriteSettings, PermissionManageUsers, PermissionAccount},
+	RoleAdmin:      {PermissionRead, PermissionWriteInvoices, PermissionWriteCustomers, PermissionWriteSettings, PermissionManageUsers, PermissionAccount},
+	RoleAccountant: {PermissionRead, PermissionWriteInvoices, PermissionWriteCustomers, PermissionAccount},
+	RoleViewer:     {PermissionRead, PermissionAccount},
+}
+
+// ParseRole validates a role name.
+func ParseRole(s string) (Role, error) {
+	role := Role(s)
+	if _, ok := permissions[role]; !ok {
+		return "", fmt.Errorf("unknown role %q", s)
+	}
+	return role, nil
+}
+
+// Can reports whether users with role r have permission p.
+func (r Role) Can(p Permission) bool {
+	for _, granted := range permissions[r] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAssign reports whether users with role r may give another user the role
+// target, or change the role of a user who has it. Only owners manage owners.
+func (r Role) CanAssign(target Role) bool {
+	if !r.Can(PermissionManageUsers) {
+		return false
+	}
+	return r == RoleOwner || target != RoleOwner
+}
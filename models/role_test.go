@@ -0,0 +1,62 @@
+package models
+
+import "testing"
+
+func TestRole_Can(t *testing.T) {
+	tests := []struct {
+		role       Role
+		permission Permission
+		want       bool
+	}{
+		{RoleOwner, PermissionManageUsers, true},
+		{RoleAdmin, PermissionManageUsers, true},
+		{RoleAdmin, PermissionWriteSettings, true},
+		{RoleAccountant, PermissionWriteInvoices, true},
+		{RoleAccountant, PermissionWriteCustomers, true},
+		{RoleAccountant, PermissionWriteSettings, false},
+		{RoleAccountant, PermissionManageUsers, false},
+		{RoleViewer, PermissionRead, true},
+		{RoleViewer, PermissionAccount, true},
+		{RoleViewer, PermissionWriteInvoices, false},
+		{RoleViewer, PermissionWriteCustomers, false},
+		{Role("root"), PermissionRead, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.role.Can(tt.permission); got != tt.want {
+			t.Errorf("%s can %s: got %v, want %v", tt.role, tt.permission, got, tt.want)
+		}
+	}
+}
+
+func TestRole_CanAssign(t *testing.T) {
+	tests := []struct {
+		role, target Role
+		want         bool
+	}{
+		{RoleOwner, RoleOwner, true},
+		{RoleOwner, RoleViewer, true},
+		{RoleAdmin, RoleAdmin, true},
+		{RoleAdmin, RoleAccountant, true},
+		{RoleAdmin, RoleOwner, false},
+		{RoleAccountant, RoleViewer, false},
+		{RoleViewer, RoleViewer, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.role.CanAssign(tt.target); got != tt.want {
+			t.Errorf("%s assigns %s: got %v, want %v", tt.role, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	for _, name := range []string{"owner", "admin", "accountant", "viewer"} {
+		if role, err := ParseRole(name); err != nil || string(role) != name {
+			t.Errorf("ParseRole(%q) = %q, %v", name, role, err)
+		}
+	}
+	if _, err := ParseRole("superuser"); err == nil {
+		t.Error("ParseRole(\"superuser\") succeeded, want an error")
+	}
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// InvoiceStatus is the lifecycle state of an invoice.
+type InvoiceStatus string
+
+const (
+	StatusDraft         InvoiceStatus = "draft"
+	StatusSent          InvoiceStatus = "sent"
+	StatusPartiallyPaid InvoiceStatus = "partially_paid"
+	StatusPaid          InvoiceStatus = "paid"
+	StatusVoid          InvoiceStatus = "void"
+	// StatusOverdue is never stored. It is derived for sent or partially paid
+	// invoices whose due date has passed.
+	StatusOverdue InvoiceStatus = "overdue"
+)
+
+// ErrInvalidTransition is returned when an invoice cannot move to the requested status.
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// ErrInvoiceLocked is returned when an invoice that has been finalised is edited.
+var ErrInvoiceLocked = errors.New("only draft invoices can be edited")
+
+// transitions lists the moves that can be requested directly. Moves between sent,
+// partially paid and paid are driven by the payment ledger (see ApplyPayment).
+var transitions = map[InvoiceStatus][]InvoiceStatus{
+	StatusDraft:         {StatusSent, StatusVoid},
+	StatusSent:          {StatusVoid},
+	StatusPartiallyPaid: {StatusVoid},
+	StatusOverdue:       {StatusVoid},
+	StatusPaid:          {},
+	StatusVoid:          {},
+}
+
+// ParseInvoiceStatus validates a status name.
+func ParseInvoiceStatus(s string) (InvoiceStatus, error) {
+	status := InvoiceStatus(s)
+	if _, ok := transitions[status]; !ok {
+		return "", fmt.Errorf("unknown invoice status %q", s)
+	}
+	return status, nil
+}
+
+// CanTransitionTo reports whether an invoice in status s may move to next.
+func (s InvoiceStatus) CanTransitionTo(next InvoiceStatus) bool {
+	for _, allowed := range transitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Editable reports whether an invoice in status s may still be edited. Invoices are
+// locked once they leave draft: sent invoices have been numbered and delivered, and
+// voided ones are kept as they were.
+func (s InvoiceStatus) Editable() bool {
+	return s == StatusDraft
+}
+
+// DeriveStatus returns the status to report for an invoice stored with the given
+// status and due date. Outstanding invoices become overdue the day after they fall due.
+func DeriveStatus(stored InvoiceStatus, dueDate, now time.Time) InvoiceStatus {
+	if stored != StatusSent && stored != StatusPartiallyPaid {
+		return stored
+	}
+	due := time.Date(dueDate.Year(), dueDate.Month(), dueDate.Day(), 0, 0, 0, 0, time.UTC)
+	if !now.UTC().Before(due.AddDate(0, 0, 1)) {
+		return StatusOverdue
+	}
+	return stored
+}
+
+// TransitionTo moves the invoice to next, stamping the matching transition time.
+// The invoice's Status must hold its stored (not derived) status.
+func (i *Invoice) TransitionTo(next InvoiceStatus, at time.Time) error {
+	if next == StatusPaid || next == StatusPartiallyPaid {
+		return fmt.Errorf("%w: %s is set by recording payments", ErrInvalidTransition, next)
+	}
+	if !i.Status.CanTransitionTo(next) {
+		return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, i.Status, next)
+	}
+	if next == StatusVoid && !i.AmountPaid.IsZero() {
+		return fmt.Errorf("%w: refund payments before voiding", ErrInvalidTransition)
+	}
+	if next == StatusVoid && !i.AmountCredited.IsZero() {
+		return fmt.Errorf("%w: invoice has credit notes", ErrInvalidTransition)
+	}
+
+	switch next {
+	case StatusSent:
+		i.SentAt = &at
+	case StatusVoid:
+		i.VoidedAt = &at
+	}
+	i.Status = next
+	return nil
+}
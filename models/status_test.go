@@ -0,0 +1,108 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestInvoiceStatus_CanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from, to InvoiceStatus
+		want     bool
+	}{
+		{StatusDraft, StatusSent, true},
+		{StatusDraft, StatusVoid, true},
+		{StatusDraft, StatusPaid, false},
+		{StatusSent, StatusVoid, true},
+		{StatusSent, StatusPaid, false},
+		{StatusSent, StatusDraft, false},
+		{StatusPartiallyPaid, StatusVoid, true},
+		{StatusPartiallyPaid, StatusSent, false},
+		{StatusOverdue, StatusVoid, true},
+		{StatusPaid, StatusVoid, false},
+		{StatusVoid, StatusSent, false},
+		{StatusSent, StatusOverdue, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+			t.Errorf("%s -> %s: got %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestInvoiceStatus_Editable(t *testing.T) {
+	for _, status := range []InvoiceStatus{StatusDraft, StatusSent, StatusPartiallyPaid, StatusPaid, StatusVoid, StatusOverdue} {
+		if got, want := status.Editable(), status == StatusDraft; got != want {
+			t.Errorf("%s: Editable() = %v, want %v", status, got, want)
+		}
+	}
+}
+
+func TestParseInvoiceStatus(t *testing.T) {
+	if status, err := ParseInvoiceStatus("partially_paid"); err != nil || status != StatusPartiallyPaid {
+		t.Errorf("Expected partially_paid, got %s (%v)", status, err)
+	}
+	if _, err := ParseInvoiceStatus("archived"); err == nil {
+		t.Error("Expected an error for unknown status")
+	}
+}
+
+func TestDeriveStatus(t *testing.T) {
+	due := time.Date(2026, 1, 14, 0, 0, 0, 0, time.UTC)
+	onDueDate := time.Date(2026, 1, 14, 23, 59, 0, 0, time.UTC)
+	dayAfter := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	if got := DeriveStatus(StatusSent, due, onDueDate); got != StatusSent {
+		t.Errorf("Expected sent on the due date, got %s", got)
+	}
+	if got := DeriveStatus(StatusSent, due, dayAfter); got != StatusOverdue {
+		t.Errorf("Expected overdue after the due date, got %s", got)
+	}
+	if got := DeriveStatus(StatusPartiallyPaid, due, dayAfter); got != StatusOverdue {
+		t.Errorf("Expected partially paid invoice to become overdue, got %s", got)
+	}
+	for _, status := range []InvoiceStatus{StatusDraft, StatusPaid, StatusVoid} {
+		if got := DeriveStatus(status, due, dayAfter); got != status {
+			t.Errorf("Expected %s to stay %s, got %s", status, status, got)
+		}
+	}
+}
+
+func TestInvoice_TransitionTo(t *testing.T) {
+	at := time.Date(2026, 2, 1, 10, 0, 0, 0, time.UTC)
+	invoice := Invoice{Status: StatusDraft}
+
+	if err := invoice.TransitionTo(StatusSent, at); err != nil {
+		t.Fatalf("TransitionTo(sent) returned error: %v", err)
+	}
+	if invoice.Status != StatusSent || invoice.SentAt == nil || !invoice.SentAt.Equal(at) {
+		t.Errorf("Expected sent status stamped at %v, got %s %v", at, invoice.Status, invoice.SentAt)
+	}
+
+	if err := invoice.TransitionTo(StatusDraft, at); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected ErrInvalidTransition, got %v", err)
+	}
+
+	if err := invoice.TransitionTo(StatusVoid, at); err != nil {
+		t.Fatalf("TransitionTo(void) returned error: %v", err)
+	}
+	if invoice.VoidedAt == nil {
+		t.Error("Expected voided_at to be set")
+	}
+}
+
+func TestInvoice_TransitionTo_PaymentDriven(t *testing.T) {
+	at := time.Now()
+	invoice := Invoice{Status: StatusSent}
+
+	if err := invoice.TransitionTo(StatusPaid, at); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected paid to be rejected as a manual transition, got %v", err)
+	}
+
+	invoice.AmountPaid = NewMoney(500, "USD")
+	if err := invoice.TransitionTo(StatusVoid, at); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected voiding an invoice with payments to be rejected, got %v", err)
+	}
+}
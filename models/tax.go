@@ -0,0 +1,246 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// percentDigits is the number of decimal places kept for percentages (8.875% is exact).
+const percentDigits = 4
+
+// percentScale is the Percent value of 100%.
+const percentScale = 100 * 10000
+
+// Percent is an exact percentage held in ten-thousandths of a percent, so 20% is 200000.
+// It is encoded in JSON as a plain number of percent, e.g. 8.875.
+type Percent int64
+
+// NewPercent returns a Percent from a whole number of basis points (1% = 100).
+func NewPercent(basisPoints int64) Percent {
+	return Percent(basisPoints * 100)
+}
+
+// ParsePercent parses a decimal number of percent such as "20" or "8.875".
+func ParsePercent(s string) (Percent, error) {
+	v, err := parseFixed(s, percentDigits)
+	if err != nil {
+		return 0, fmt.Errorf("invalid percentage: %w", err)
+	}
+	return Percent(v), nil
+}
+
+// String formats the percentage without trailing zeros, e.g. "8.875".
+func (p Percent) String() string {
+	return formatFixed(int64(p), percentDigits)
+}
+
+// Of returns the percentage of an amount, rounded to whole minor units.
+func (p Percent) Of(m Money, mode RoundingMode) Money {
+	return m.MulFrac(int64(p), percentScale, mode)
+}
+
+// MarshalJSON encodes the percentage as a JSON number.
+func (p Percent) MarshalJSON() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a numeric string.
+func (p *Percent) UnmarshalJSON(data []byte) error {
+	return unmarshalFixed(data, func(text string) error {
+		parsed, err := ParsePercent(text)
+		*p = parsed
+		return err
+	})
+}
+
+// Scan implements sql.Scanner for DECIMAL columns.
+func (p *Percent) Scan(src interface{}) error {
+	return scanFixed(src, "Percent", func(text string) error {
+		parsed, err := ParsePercent(text)
+		*p = parsed
+		return err
+	})
+}
+
+// Value implements driver.Valuer.
+func (p Percent) Value() (driver.Value, error) {
+	return p.String(), nil
+}
+
+// TaxKind names the family of a tax rate.
+type TaxKind string
+
+const (
+	TaxKindVAT      TaxKind = "vat"
+	TaxKindGST      TaxKind = "gst"
+	TaxKindSalesTax TaxKind = "sales_tax"
+)
+
+// TaxRate is a configurable tax that line items refer to by Code.
+// A compound tax is charged on the net amount plus the line's other taxes
+// (e.g. Quebec QST on top of GST); a simple tax is charged on the net amount only.
+type TaxRate struct {
+	ID       int     `json:"id"`
+	Code     string  `json:"code"`
+	Name     string  `json:"name"`
+	Kind     TaxKind `json:"kind"`
+	Rate     Percent `json:"rate"`
+	Compound bool    `json:"compound"`
+	Active   bool    `json:"active"`
+}
+
+// Validate normalises the tax rate and reports the first problem found.
+func (t *TaxRate) Validate() error {
+	t.Code = strings.ToUpper(strings.TrimSpace(t.Code))
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Code == "" || t.Name == "" {
+		return fmt.Errorf("code and name are required")
+	}
+	if len(t.Code) > 20 || strings.ContainsAny(t.Code, ", ") {
+		return fmt.Errorf("code must be at most 20 characters without spaces or commas")
+	}
+	switch t.Kind {
+	case TaxKindVAT, TaxKindGST, TaxKindSalesTax:
+	default:
+		return fmt.Errorf("kind must be one of vat, gst or sales_tax")
+	}
+	if t.Rate < 0 || t.Rate > percentScale {
+		return fmt.Errorf("rate must be between 0 and 100")
+	}
+	return nil
+}
+
+// TaxSummary is the per-rate breakdown of an invoice's tax. It is stored with the
+// invoice so that later changes to a TaxRate never alter issued invoices.
+type TaxSummary struct {
+	Code          string  `json:"code"`
+	Name          string  `json:"name"`
+	Kind          TaxKind `json:"kind"`
+	Rate          Percent `json:"rate"`
+	Compound      bool    `json:"compound"`
+	TaxableAmount Money   `json:"taxable_amount"`
+	TaxAmount     Money   `json:"tax_amount"`
+}
+
+// TaxCodes returns the distinct tax codes used by the invoice's line items.
+func (i *Invoice) TaxCodes() []string {
+	seen := map[string]bool{}
+	var codes []string
+	for _, item := range i.LineItems {
+		for _, code := range item.TaxCodes {
+			if !seen[code] {
+				seen[code] = true
+				codes = append(codes, code)
+			}
+		}
+	}
+	return codes
+}
+
+// SetTaxRates provides the rates CalculateTotal applies to the line items' tax codes.
+// It returns an error naming the first code that has no active rate.
+func (i *Invoice) SetTaxRates(rates []TaxRate) error {
+	i.taxRates = make(map[string]TaxRate, len(rates))
+	for _, rate := range rates {
+		if rate.Active {
+			i.taxRates[rate.Code] = rate
+		}
+	}
+	for j := range i.LineItems {
+		item := &i.LineItems[j]
+		for k, code := range item.TaxCodes {
+			code = strings.ToUpper(strings.TrimSpace(code))
+			item.TaxCodes[k] = code
+			if _, ok := i.taxRates[code]; !ok {
+				return fmt.Errorf("unknown tax code %q", code)
+			}
+		}
+	}
+	return nil
+}
+
+// lineTax is the tax charged by one rate on one line.
+type lineTax struct {
+	rate    TaxRate
+	taxable Money
+	amount  Money
+}
+
+// taxLine computes the net amount and taxes of a line whose price (gross when
+// inclusive is set) is already known. Simple taxes are charged on the net amount,
+// compound taxes on the net amount plus every tax applied before them.
+func taxLine(price Money, rates []TaxRate, inclusive bool, mode RoundingMode) (Money, []lineTax, error) {
+	// Simple taxes first, then compound taxes in the order they were listed.
+	ordered := make([]TaxRate, 0, len(rates))
+	for _, rate := range rates {
+		if !rate.Compound {
+			ordered = append(ordered, rate)
+		}
+	}
+	for _, rate := range rates {
+		if rate.Compound {
+			ordered = append(ordered, rate)
+		}
+	}
+
+	net := price
+	if inclusive && len(ordered) > 0 {
+		// gross = net * (1 + sum of simple rates) * product of (1 + compound rate)
+		factor := new(big.Rat).SetInt64(1)
+		simple := new(big.Rat)
+		for _, rate := range ordered {
+			r := big.NewRat(int64(rate.Rate), percentScale)
+			if rate.Compound {
+				factor.Mul(factor, new(big.Rat).Add(big.NewRat(1, 1), r))
+			} else {
+				simple.Add(simple, r)
+			}
+		}
+		factor.Mul(factor, simple.Add(simple, big.NewRat(1, 1)))
+
+		quotient := new(big.Rat).Quo(new(big.Rat).SetInt64(price.Amount), factor)
+		net = Money{Amount: mode.round(quotient.Num(), quotient.Denom()).Int64(), Currency: price.Currency}
+	}
+
+	taxes := make([]lineTax, 0, len(ordered))
+	base := net
+	simpleTotal := NewMoney(0, net.Currency)
+	for _, rate := range ordered {
+		if rate.Compound {
+			continue
+		}
+		amount := rate.Rate.Of(net, mode)
+		taxes = append(taxes, lineTax{rate: rate, taxable: net, amount: amount})
+		var err error
+		if simpleTotal, err = simpleTotal.Add(amount); err != nil {
+			return Money{}, nil, err
+		}
+	}
+	base, err := base.Add(simpleTotal)
+	if err != nil {
+		return Money{}, nil, err
+	}
+	for _, rate := range ordered {
+		if !rate.Compound {
+			continue
+		}
+		amount := rate.Rate.Of(base, mode)
+		taxes = append(taxes, lineTax{rate: rate, taxable: base, amount: amount})
+		if base, err = base.Add(amount); err != nil {
+			return Money{}, nil, err
+		}
+	}
+
+	// With inclusive pricing the gross price is fixed; absorb rounding differences
+	// in the last tax so that net + taxes always equals the price charged.
+	if inclusive && len(taxes) > 0 {
+		diff := price.Sub(base)
+		last := &taxes[len(taxes)-1]
+		if last.amount, err = last.amount.Add(diff); err != nil {
+			return Money{}, nil, err
+		}
+	}
+	return net, taxes, nil
+}
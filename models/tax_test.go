@@ -0,0 +1,134 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+var (
+	vat20 = TaxRate{Code: "VAT20", Name: "VAT", Kind: TaxKindVAT, Rate: NewPercent(2000), Active: true}
+	gst5  = TaxRate{Code: "GST", Name: "GST", Kind: TaxKindGST, Rate: NewPercent(500), Active: true}
+	qst   = TaxRate{Code: "QST", Name: "QST", Kind: TaxKindSalesTax, Rate: 99750, Compound: true, Active: true}
+)
+
+func TestParsePercent(t *testing.T) {
+	tests := map[string]Percent{"20": 200000, "8.875": 88750, "0": 0, "9.975": 99750}
+	for input, want := range tests {
+		got, err := ParsePercent(input)
+		if err != nil || got != want {
+			t.Errorf("ParsePercent(%q) = %d (%v), want %d", input, got, err, want)
+		}
+		if got.String() != input {
+			t.Errorf("Percent(%d).String() = %q, want %q", got, got.String(), input)
+		}
+	}
+
+	if _, err := ParsePercent("1.23456"); err == nil {
+		t.Error("Expected an error for more than four decimal places")
+	}
+
+	var rate TaxRate
+	if err := json.Unmarshal([]byte(`{"rate": 7.5}`), &rate); err != nil || rate.Rate != 75000 {
+		t.Errorf("Expected rate 7.5 to decode to 75000, got %d (%v)", rate.Rate, err)
+	}
+}
+
+func TestInvoice_CalculateTotal_TaxExclusive(t *testing.T) {
+	invoice := Invoice{
+		LineItems: []LineItem{
+			{Quantity: 2, UnitPrice: NewMoney(5000, "USD"), TaxCodes: []string{"VAT20"}},
+			{Quantity: 1, UnitPrice: NewMoney(1999, "USD")},
+		},
+	}
+	if err := invoice.SetTaxRates([]TaxRate{vat20}); err != nil {
+		t.Fatalf("SetTaxRates returned error: %v", err)
+	}
+	invoice.CalculateTotal()
+
+	if invoice.Subtotal.String() != "119.99" || invoice.TaxTotal.String() != "20.00" || invoice.Total.String() != "139.99" {
+		t.Errorf("Expected 119.99 + 20.00 = 139.99, got %s + %s = %s", invoice.Subtotal, invoice.TaxTotal, invoice.Total)
+	}
+	if invoice.LineItems[0].Total.String() != "120.00" {
+		t.Errorf("Expected taxed line total 120.00, got %s", invoice.LineItems[0].Total)
+	}
+	if len(invoice.Taxes) != 1 || invoice.Taxes[0].TaxableAmount.String() != "100.00" || invoice.Taxes[0].TaxAmount.String() != "20.00" {
+		t.Errorf("Unexpected tax summary: %+v", invoice.Taxes)
+	}
+}
+
+func TestInvoice_CalculateTotal_TaxInclusive(t *testing.T) {
+	invoice := Invoice{
+		PricesIncludeTax: true,
+		LineItems: []LineItem{
+			{Quantity: 1, UnitPrice: NewMoney(12000, "USD"), TaxCodes: []string{"VAT20"}},
+			// 10.00 gross / 1.2 = 8.333.. net; net and tax must still add up to 10.00.
+			{Quantity: 1, UnitPrice: NewMoney(1000, "USD"), TaxCodes: []string{"VAT20"}},
+		},
+	}
+	if err := invoice.SetTaxRates([]TaxRate{vat20}); err != nil {
+		t.Fatalf("SetTaxRates returned error: %v", err)
+	}
+	invoice.CalculateTotal()
+
+	if invoice.LineItems[0].Subtotal.String() != "100.00" || invoice.LineItems[0].TaxAmount.String() != "20.00" {
+		t.Errorf("Expected 100.00 net + 20.00 tax, got %s + %s", invoice.LineItems[0].Subtotal, invoice.LineItems[0].TaxAmount)
+	}
+	if invoice.LineItems[1].Subtotal.String() != "8.33" || invoice.LineItems[1].TaxAmount.String() != "1.67" {
+		t.Errorf("Expected 8.33 net + 1.67 tax, got %s + %s", invoice.LineItems[1].Subtotal, invoice.LineItems[1].TaxAmount)
+	}
+	if invoice.Total.String() != "130.00" {
+		t.Errorf("Expected the inclusive total to equal the prices charged (130.00), got %s", invoice.Total)
+	}
+}
+
+func TestInvoice_CalculateTotal_CompoundTax(t *testing.T) {
+	invoice := Invoice{
+		LineItems: []LineItem{
+			// Compound codes may be listed first; they are still applied after simple taxes.
+			{Quantity: 1, UnitPrice: NewMoney(10000, "USD"), TaxCodes: []string{"QST", "GST"}},
+		},
+	}
+	if err := invoice.SetTaxRates([]TaxRate{gst5, qst}); err != nil {
+		t.Fatalf("SetTaxRates returned error: %v", err)
+	}
+	invoice.CalculateTotal()
+
+	// GST: 100.00 * 5% = 5.00; QST: 105.00 * 9.975% = 10.47375 -> 10.47
+	if invoice.TaxTotal.String() != "15.47" || invoice.Total.String() != "115.47" {
+		t.Errorf("Expected 15.47 tax and 115.47 total, got %s and %s", invoice.TaxTotal, invoice.Total)
+	}
+	if len(invoice.Taxes) != 2 || invoice.Taxes[1].Code != "QST" || invoice.Taxes[1].TaxableAmount.String() != "105.00" {
+		t.Errorf("Unexpected tax summary: %+v", invoice.Taxes)
+	}
+}
+
+func TestInvoice_SetTaxRates_UnknownCode(t *testing.T) {
+	inactive := vat20
+	inactive.Active = false
+	invoice := Invoice{LineItems: []LineItem{{Quantity: 1, UnitPrice: NewMoney(100, "USD"), TaxCodes: []string{"vat20"}}}}
+
+	if err := invoice.SetTaxRates([]TaxRate{inactive}); err == nil {
+		t.Error("Expected an error for an inactive tax rate")
+	}
+	if err := invoice.SetTaxRates([]TaxRate{vat20}); err != nil {
+		t.Errorf("Expected lower-case codes to be normalised, got %v", err)
+	}
+}
+
+func TestTaxRate_Validate(t *testing.T) {
+	rate := TaxRate{Code: " vat20 ", Name: "VAT", Kind: TaxKindVAT, Rate: NewPercent(2000)}
+	if err := rate.Validate(); err != nil || rate.Code != "VAT20" {
+		t.Errorf("Expected valid rate with code VAT20, got %q (%v)", rate.Code, err)
+	}
+
+	for _, bad := range []TaxRate{
+		{Code: "X", Name: "X", Kind: "levy"},
+		{Code: "X", Name: "X", Kind: TaxKindVAT, Rate: NewPercent(10001)},
+		{Code: "A,B", Name: "X", Kind: TaxKindVAT},
+		{Name: "X", Kind: TaxKindVAT},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Expected an error for %+v", bad)
+		}
+	}
+}
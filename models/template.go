@@ -0,0 +1,39 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// DefaultTemplate is the name of the built-in invoice template.
+const DefaultTemplate = "default"
+
+// maxTemplateLength bounds the size of a stored template.
+const maxTemplateLength = 256 << 10
+
+// templateName restricts names to what is safe as a file name.
+var templateName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// InvoiceTemplate is an html/template invoice layout stored in the database.
+type InvoiceTemplate struct {
+	Name string `json:"name"`
+	Body string `json:"body"`
+}
+
+// ValidTemplateName reports whether name can name a template: lowercase letters,
+// digits, "-" and "_", at most 64 characters.
+func ValidTemplateName(name string) bool {
+	return templateName.MatchString(name)
+}
+
+// Validate checks the name and size of the template. Whether the body is a valid
+// template is checked by the templates package.
+func (t *InvoiceTemplate) Validate() error {
+	if !ValidTemplateName(t.Name) {
+		return fmt.Errorf("name must be 1 to 64 lowercase letters, digits, '-' or '_'")
+	}
+	if t.Body == "" || len(t.Body) > maxTemplateLength {
+		return fmt.Errorf("body must be between 1 byte and %d KB", maxTemplateLength>>10)
+	}
+	return nil
+}
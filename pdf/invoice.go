@@ -0,0 +1,286 @@
+package pdf
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"tiny-invoicing/database"
+	"tiny-invoicing/models"
+)
+
+const (
+	margin       = 50.0
+	contentWidth = PageWidth - 2*margin
+	footerY      = PageHeight - 30
+	bottom       = PageHeight - 60 // content stops above the footer
+)
+
+// Right edges of the line item columns; the description fills the rest.
+var (
+	colQuantity  = margin + 300.0
+	colUnitPrice = margin + 370.0
+	colDiscount  = margin + 430.0
+	colAmount    = margin + contentWidth
+)
+
+// invoiceLayout draws an invoice, starting new pages as the content grows.
+type invoiceLayout struct {
+	doc      *Document
+	invoice  *models.Invoice
+	branding models.Branding
+	accent   Color
+	y        float64
+}
+
+// Invoice renders invoice as a PDF for customer, in the colours and with the
+// company details and payment instructions of branding.
+func Invoice(w io.Writer, invoice *models.Invoice, customer *database.Customer, branding models.Branding) error {
+	r, g, b := branding.RGB()
+	l := &invoiceLayout{
+		doc:      New("Invoice " + invoice.Title()),
+		invoice:  invoice,
+		branding: branding,
+		accent:   Color{r, g, b},
+	}
+
+	l.newPage()
+	l.header(customer)
+	l.lineItems()
+	l.totals()
+	l.section("NOTES", l.invoice.Notes)
+	l.section("PAYMENT INSTRUCTIONS", l.branding.PaymentInstructions)
+
+	_, err := l.doc.WriteTo(w)
+	return err
+}
+
+// newPage starts a page with the accent bar and footer, keeping the current font
+// and colour for whatever is drawn next.
+func (l *invoiceLayout) newPage() {
+	d := l.doc
+	bold, size, color := d.bold, d.size, d.color
+	defer func() {
+		d.SetFont(bold, size)
+		d.SetColor(color)
+	}()
+
+	d.AddPage()
+	d.SetColor(l.accent)
+	d.Rect(0, 0, PageWidth, 8)
+
+	d.SetColor(Gray)
+	d.SetFont(false, 8)
+	if l.branding.Footer != "" {
+		d.Text(margin, footerY, l.branding.Footer)
+	}
+	d.TextRight(margin+contentWidth, footerY, "Page "+strconv.Itoa(d.PageCount()))
+	l.y = 40
+}
+
+// space makes sure the next height points fit on the page.
+func (l *invoiceLayout) space(height float64) {
+	if l.y+height > bottom {
+		l.newPage()
+	}
+}
+
+func (l *invoiceLayout) header(customer *database.Customer) {
+	d := l.doc
+	top := l.y + 20
+
+	// Company on the left, document details on the right.
+	y := top
+	d.SetColor(Black)
+	d.SetFont(true, 16)
+	d.Text(margin, y, l.branding.CompanyName)
+	d.SetColor(Gray)
+	d.SetFont(false, 9)
+	for _, line := range d.Wrap(l.branding.CompanyAddress, 250) {
+		y += 12
+		d.Text(margin, y, line)
+	}
+	left := y
+
+	right := margin + contentWidth
+	y = top
+	d.SetColor(l.accent)
+	d.SetFont(true, 20)
+	d.TextRight(right, y, "INVOICE")
+	y += 6
+	details := [][2]string{
+		{"Invoice no.", l.invoice.Title()},
+		{"Issue date", l.invoice.IssueDate.Format("2 January 2006")},
+		{"Due date", l.invoice.DueDate.Format("2 January 2006")},
+		{"Currency", l.invoice.Currency},
+	}
+	if l.invoice.Status == models.StatusDraft || l.invoice.Status == models.StatusVoid {
+		details = append(details, [2]string{"Status", strings.ToUpper(string(l.invoice.Status))})
+	}
+	for _, detail := range details {
+		y += 14
+		d.SetColor(Gray)
+		d.SetFont(false, 9)
+		d.TextRight(right-110, y, detail[0])
+		d.SetColor(Black)
+		d.SetFont(true, 9)
+		d.TextRight(right, y, detail[1])
+	}
+	l.y = max(left, y) + 35
+
+	// Bill to.
+	d.SetColor(l.accent)
+	d.SetFont(true, 9)
+	d.Text(margin, l.y, "BILL TO")
+	d.SetColor(Black)
+	d.SetFont(true, 11)
+	l.y += 15
+	d.Text(margin, l.y, customer.Name)
+	d.SetFont(false, 9)
+	lines := d.Wrap(customer.Address, 250)
+	if customer.Email != "" {
+		lines = append(lines, customer.Email)
+	}
+	for _, line := range lines {
+		l.y += 12
+		d.Text(margin, l.y, line)
+	}
+	l.y += 30
+}
+
+func (l *invoiceLayout) tableHeader() {
+	d := l.doc
+	d.SetColor(l.accent)
+	d.Rect(margin, l.y, contentWidth, 20)
+	d.SetColor(White)
+	d.SetFont(true, 9)
+	baseline := l.y + 13
+	d.Text(margin+6, baseline, "Description")
+	d.TextRight(colQuantity, baseline, "Qty")
+	d.TextRight(colUnitPrice, baseline, "Unit price")
+	d.TextRight(colDiscount, baseline, "Discount")
+	amount := "Amount"
+	if l.invoice.PricesIncludeTax {
+		amount = "Amount incl. tax"
+	}
+	d.TextRight(colAmount-6, baseline, amount)
+	l.y += 20
+}
+
+func (l *invoiceLayout) lineItems() {
+	d := l.doc
+	l.space(60)
+	l.tableHeader()
+
+	for _, item := range l.invoice.LineItems {
+		d.SetFont(false, 9)
+		lines := d.Wrap(item.Description, colQuantity-margin-50)
+		height := float64(len(lines))*12 + 10
+		if l.y+height > bottom {
+			l.newPage()
+			l.tableHeader()
+		}
+
+		// Line and invoice discounts are shown together; the amount is what remains.
+		// Both are parts of the line's price, so their sum cannot overflow.
+		discount, _ := item.Discount.Add(item.InvoiceDiscount)
+		amount := item.Subtotal
+		if l.invoice.PricesIncludeTax {
+			amount = item.Total
+		}
+
+		baseline := l.y + 15
+		d.SetColor(Black)
+		for i, line := range lines {
+			d.Text(margin+6, baseline+float64(i)*12, line)
+		}
+		d.TextRight(colQuantity, baseline, strconv.Itoa(item.Quantity))
+		d.TextRight(colUnitPrice, baseline, item.UnitPrice.String())
+		if !discount.IsZero() {
+			d.TextRight(colDiscount, baseline, "-"+discount.String())
+		}
+		d.TextRight(colAmount-6, baseline, amount.String())
+
+		l.y += height
+		d.SetColor(Color{222, 226, 230})
+		d.Line(margin, l.y, margin+contentWidth, l.y, 0.5)
+	}
+	l.y += 10
+}
+
+func (l *invoiceLayout) totals() {
+	d := l.doc
+	currency := l.invoice.Currency
+
+	rows := [][2]string{{"Subtotal", l.invoice.Subtotal.String()}}
+	for _, tax := range l.invoice.Taxes {
+		rows = append(rows, [2]string{tax.Name + " (" + tax.Rate.String() + "%)", tax.TaxAmount.String()})
+	}
+	l.space(float64(len(rows))*16 + 70)
+
+	right := margin + contentWidth - 6
+	label := right - 110
+	for _, row := range rows {
+		l.y += 16
+		d.SetColor(Gray)
+		d.SetFont(false, 9)
+		d.TextRight(label, l.y, row[0])
+		d.SetColor(Black)
+		d.TextRight(right, l.y, row[1])
+	}
+
+	l.y += 10
+	d.SetColor(Black)
+	d.Line(label-120, l.y, right, l.y, 0.75)
+	l.y += 18
+	d.SetFont(true, 11)
+	d.TextRight(label, l.y, "Total "+currency)
+	d.TextRight(right, l.y, l.invoice.Total.String())
+
+	if !l.invoice.AmountCredited.IsZero() {
+		l.y += 16
+		d.SetFont(false, 9)
+		d.SetColor(Gray)
+		d.TextRight(label, l.y, "Credited")
+		d.SetColor(Black)
+		d.TextRight(right, l.y, "-"+l.invoice.AmountCredited.String())
+	}
+	if !l.invoice.AmountPaid.IsZero() {
+		l.y += 16
+		d.SetFont(false, 9)
+		d.SetColor(Gray)
+		d.TextRight(label, l.y, "Paid")
+		d.SetColor(Black)
+		d.TextRight(right, l.y, "-"+l.invoice.AmountPaid.String())
+	}
+	l.y += 22
+	d.SetColor(l.accent)
+	d.SetFont(true, 12)
+	d.TextRight(label, l.y, "Balance due "+currency)
+	d.TextRight(right, l.y, l.invoice.BalanceDue.String())
+	l.y += 30
+}
+
+// section draws a titled block of wrapped text, such as the invoice notes, and
+// nothing when text is blank.
+func (l *invoiceLayout) section(title, text string) {
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	d := l.doc
+	d.SetFont(false, 9)
+	lines := d.Wrap(text, contentWidth)
+	l.space(20)
+
+	d.SetColor(l.accent)
+	d.SetFont(true, 9)
+	d.Text(margin, l.y, title)
+	d.SetColor(Black)
+	d.SetFont(false, 9)
+	for _, line := range lines {
+		l.y += 12
+		l.space(0)
+		d.Text(margin, l.y, line)
+	}
+	l.y += 30
+}
@@ -0,0 +1,208 @@
+// Package pdf writes simple PDF documents: pages of text, lines and filled
+// rectangles set in the standard Helvetica fonts, which every PDF reader provides.
+// It needs no external tools or font files.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// A4 page size in points (1/72 inch).
+const (
+	PageWidth  = 595.28
+	PageHeight = 841.89
+)
+
+// Color is an RGB colour.
+type Color struct {
+	R, G, B uint8
+}
+
+var (
+	Black = Color{0, 0, 0}
+	White = Color{255, 255, 255}
+	Gray  = Color{108, 117, 125}
+)
+
+// Document is a PDF document being built page by page. Coordinates are in points
+// from the top-left corner of the page, and text is positioned by its baseline.
+type Document struct {
+	title string
+	pages []*bytes.Buffer
+	page  *bytes.Buffer
+	bold  bool
+	size  float64
+	color Color
+}
+
+// New starts an empty document with the given title.
+func New(title string) *Document {
+	return &Document{title: title, size: 10}
+}
+
+// AddPage starts a new page; drawing happens on the latest page.
+func (d *Document) AddPage() {
+	d.page = &bytes.Buffer{}
+	d.pages = append(d.pages, d.page)
+}
+
+// PageCount returns the number of pages added so far.
+func (d *Document) PageCount() int {
+	return len(d.pages)
+}
+
+// SetFont selects Helvetica, or Helvetica-Bold, at the given size in points.
+func (d *Document) SetFont(bold bool, size float64) {
+	d.bold = bold
+	d.size = size
+}
+
+// SetColor sets the colour used for text, lines and rectangles.
+func (d *Document) SetColor(c Color) {
+	d.color = c
+}
+
+// TextWidth returns the width of s in the current font.
+func (d *Document) TextWidth(s string) float64 {
+	return float64(width(encode(s), d.bold)) * d.size / 1000
+}
+
+// Text draws s with its baseline starting at (x, y).
+func (d *Document) Text(x, y float64, s string) {
+	font := "F1"
+	if d.bold {
+		font = "F2"
+	}
+	fmt.Fprintf(d.page, "BT %s rg /%s %s Tf %s %s Td (%s) Tj ET\n",
+		d.fill(), font, num(d.size), num(x), num(PageHeight-y), escape(encode(s)))
+}
+
+// TextRight draws s so that it ends at x.
+func (d *Document) TextRight(x, y float64, s string) {
+	d.Text(x-d.TextWidth(s), y, s)
+}
+
+// Rect fills a rectangle whose top-left corner is (x, y).
+func (d *Document) Rect(x, y, w, h float64) {
+	fmt.Fprintf(d.page, "%s rg %s %s %s %s re f\n",
+		d.fill(), num(x), num(PageHeight-y-h), num(w), num(h))
+}
+
+// Line draws a line of the given thickness.
+func (d *Document) Line(x1, y1, x2, y2, thickness float64) {
+	c := d.color
+	fmt.Fprintf(d.page, "%s %s %s RG %s w %s %s m %s %s l S\n",
+		num(float64(c.R)/255), num(float64(c.G)/255), num(float64(c.B)/255), num(thickness),
+		num(x1), num(PageHeight-y1), num(x2), num(PageHeight-y2))
+}
+
+// Wrap splits s into lines no wider than maxWidth in the current font. Line breaks
+// in s are kept; words longer than a line are put on a line of their own.
+func (d *Document) Wrap(s string, maxWidth float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			if line != "" && d.TextWidth(line+" "+word) > maxWidth {
+				lines = append(lines, line)
+				line = ""
+			}
+			if line != "" {
+				line += " "
+			}
+			line += word
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func (d *Document) fill() string {
+	c := d.color
+	return num(float64(c.R)/255) + " " + num(float64(c.G)/255) + " " + num(float64(c.B)/255)
+}
+
+// WriteTo writes the document as a PDF file.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string, stream []byte) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\n", len(offsets), body)
+		if stream != nil {
+			buf.WriteString("stream\n")
+			buf.Write(stream)
+			buf.WriteString("\nendstream\n")
+		}
+		buf.WriteString("endobj\n")
+	}
+
+	// Objects 1-5 are fixed; each page then takes a page object and a content stream.
+	const firstPage = 6
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>", nil)
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)), nil)
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>", nil)
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>", nil)
+	object(fmt.Sprintf("<< /Title (%s) /Producer (tiny-invoicing) >>", escape(encode(d.title))), nil)
+
+	for i, page := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			num(PageWidth), num(PageHeight), firstPage+2*i+1), nil)
+
+		var content bytes.Buffer
+		zw := zlib.NewWriter(&content)
+		if _, err := zw.Write(page.Bytes()); err != nil {
+			return 0, err
+		}
+		if err := zw.Close(); err != nil {
+			return 0, err
+		}
+		object(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>", content.Len()), content.Bytes())
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return buf.WriteTo(w)
+}
+
+// num formats a coordinate with at most two decimals.
+func num(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" || s == "" {
+		return "0"
+	}
+	return s
+}
+
+// escape quotes text for a PDF string literal.
+func escape(text []byte) string {
+	var b strings.Builder
+	for _, c := range text {
+		if c == '\\' || c == '(' || c == ')' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,152 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"tiny-invoicing/database"
+	"tiny-invoicing/models"
+)
+
+// pageContents checks the structure of a PDF file and returns the decompressed
+// content stream of each page.
+func pageContents(t *testing.T, data []byte) []string {
+	t.Helper()
+
+	if !bytes.HasPrefix(data, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(data, []byte("%%EOF\n")) {
+		t.Fatalf("not a PDF file: %q...", data[:min(len(data), 20)])
+	}
+
+	// Every cross-reference entry must point at the start of its object.
+	start := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(data)
+	if start == nil {
+		t.Fatal("missing startxref")
+	}
+	xref, _ := strconv.Atoi(string(start[1]))
+	if !bytes.HasPrefix(data[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point at the xref table", xref)
+	}
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(data[xref:], -1)
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		if want := fmt.Sprintf("%d 0 obj\n", i+1); !bytes.HasPrefix(data[offset:], []byte(want)) {
+			t.Errorf("xref entry %d points at %q", i+1, data[offset:offset+10])
+		}
+	}
+
+	var pages []string
+	streams := regexp.MustCompile(`(?s)/Length (\d+) /Filter /FlateDecode >>\nstream\n`)
+	for _, loc := range streams.FindAllSubmatchIndex(data, -1) {
+		length, _ := strconv.Atoi(string(data[loc[2]:loc[3]]))
+		zr, err := zlib.NewReader(bytes.NewReader(data[loc[1] : loc[1]+length]))
+		if err != nil {
+			t.Fatalf("content stream: %v", err)
+		}
+		content, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatalf("content stream: %v", err)
+		}
+		pages = append(pages, string(content))
+	}
+	if count := regexp.MustCompile(`/Count (\d+)`).FindSubmatch(data); count == nil || string(count[1]) != strconv.Itoa(len(pages)) {
+		t.Errorf("page count does not match the %d content streams", len(pages))
+	}
+	return pages
+}
+
+func TestDocument_Text(t *testing.T) {
+	doc := New("Test")
+	doc.AddPage()
+	doc.SetFont(true, 12)
+	doc.Text(50, 100, `Total (incl. tax) \ 10 €`)
+
+	var buf bytes.Buffer
+	if _, err := doc.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	pages := pageContents(t, buf.Bytes())
+	if len(pages) != 1 {
+		t.Fatalf("got %d pages, want 1", len(pages))
+	}
+
+	// Text is positioned from the bottom of the page, with special characters escaped.
+	want := "/F2 12 Tf 50 741.89 Td (Total \\(incl. tax\\) \\\\ 10 \x80) Tj"
+	if !strings.Contains(pages[0], want) {
+		t.Errorf("page content %q does not contain %q", pages[0], want)
+	}
+}
+
+func TestDocument_TextWidth(t *testing.T) {
+	doc := New("")
+	doc.SetFont(false, 10)
+	// "Hi" is 722 + 222 thousandths of the font size in Helvetica.
+	if got := doc.TextWidth("Hi"); got != 9.44 {
+		t.Errorf("TextWidth(Hi) = %v, want 9.44", got)
+	}
+}
+
+func TestDocument_Wrap(t *testing.T) {
+	doc := New("")
+	doc.SetFont(false, 10)
+	got := doc.Wrap("one two three four\nfive", doc.TextWidth("one two three"))
+	want := []string{"one two three", "four", "five"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Wrap = %q, want %q", got, want)
+	}
+}
+
+func TestInvoice(t *testing.T) {
+	date := time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)
+	invoice := &models.Invoice{
+		ID:        7,
+		Number:    "INV-2026-00042",
+		Currency:  "EUR",
+		IssueDate: date,
+		DueDate:   date.AddDate(0, 0, 30),
+		Status:    models.StatusSent,
+		Notes:     "Purchase order 4471",
+	}
+	for i := 0; i < 60; i++ {
+		invoice.LineItems = append(invoice.LineItems, models.LineItem{
+			Description: fmt.Sprintf("Consulting, week %d", i+1),
+			Quantity:    1,
+			UnitPrice:   models.NewMoney(150000, "EUR"),
+		})
+	}
+	invoice.CalculateTotal()
+
+	customer := &database.Customer{Name: "Acme GmbH", Address: "Hauptstraße 1\n10115 Berlin", Email: "billing@acme.example"}
+	branding := models.Branding{
+		CompanyName:         "Tiny Invoicing Ltd",
+		Color:               "#ff0000",
+		PaymentInstructions: "Pay to IBAN DE00 1234",
+		Footer:              "Registered in England",
+	}
+
+	var buf bytes.Buffer
+	if err := Invoice(&buf, invoice, customer, branding); err != nil {
+		t.Fatal(err)
+	}
+	pages := pageContents(t, buf.Bytes())
+	if len(pages) < 2 {
+		t.Fatalf("60 line items fit on %d page, want more than one", len(pages))
+	}
+
+	all := strings.Join(pages, "")
+	for _, want := range []string{"(INV-2026-00042)", "(Acme GmbH)", "(Hauptstra\xdfe 1)", "(Consulting, week 60)",
+		"(Balance due EUR)", "(90000.00)", "(Purchase order 4471)", "(Pay to IBAN DE00 1234)", "(Registered in England)", "1 0 0 rg"} {
+		if !strings.Contains(all, want) {
+			t.Errorf("invoice PDF does not contain %q", want)
+		}
+	}
+	if !strings.Contains(pages[1], "(Description)") {
+		t.Error("table header is not repeated on the second page")
+	}
+}
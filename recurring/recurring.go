@@ -0,0 +1,139 @@
+// Package recurring generates invoices from recurring profiles when their runs
+// fall due. Each run becomes a draft invoice created through the same store method
+// as invoices posted to the API. Runs missed while the server was down are caught
+// up on the next pass.
+package recurring
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"tiny-invoicing/database"
+	"tiny-invoicing/models"
+)
+
+// DefaultInterval is how often the scheduler looks for due profiles.
+const DefaultInterval = time.Minute
+
+// Store is what the scheduler needs from persistence.
+type Store interface {
+	GetDueRecurringProfiles(now time.Time) ([]models.RecurringProfile, error)
+	AdvanceRecurringProfile(id int, run time.Time, next *time.Time) error
+	GetTaxRatesByCode(codes []string) ([]models.TaxRate, error)
+	CreateInvoice(invoice *models.Invoice) (int64, error)
+}
+
+// Scheduler generates the invoices of due recurring profiles.
+//
+// Generation is idempotent: an invoice records the profile and run it was made for,
+// and the store refuses a second invoice for the same run with
+// database.ErrRecurrenceExists. A run whose invoice was created just before a crash
+// is therefore skipped after the restart rather than invoiced twice, and several
+// servers may run schedulers against the same database.
+type Scheduler struct {
+	Store Store
+	// Interval between passes; DefaultInterval if zero.
+	Interval time.Duration
+	// Now returns the current time; time.Now if nil.
+	Now func() time.Time
+}
+
+// Run makes a pass straight away and then every Interval until ctx is done. Errors
+// are logged, and the runs concerned are retried on the next pass.
+func (s *Scheduler) Run(ctx context.Context) {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if created, err := s.RunOnce(); err != nil {
+			log.Printf("Recurring invoices: %v", err)
+		} else if created > 0 {
+			log.Printf("Recurring invoices: generated %d invoice(s)", created)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce generates the invoices of every run that is due and returns how many were
+// created. A profile that fails is left for the next pass without holding up the
+// others; the first error is returned.
+func (s *Scheduler) RunOnce() (int, error) {
+	now := time.Now()
+	if s.Now != nil {
+		now = s.Now()
+	}
+
+	profiles, err := s.Store.GetDueRecurringProfiles(now)
+	if err != nil {
+		return 0, fmt.Errorf("loading due profiles: %w", err)
+	}
+
+	created := 0
+	var firstErr error
+	for k := range profiles {
+		n, err := s.generate(&profiles[k], now)
+		created += n
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("profile %d: %w", profiles[k].ID, err)
+		}
+	}
+	return created, firstErr
+}
+
+// generate creates the invoices for the runs of profile up to now, oldest first, and
+// returns how many were created.
+func (s *Scheduler) generate(profile *models.RecurringProfile, now time.Time) (int, error) {
+	created := 0
+	for run := profile.NextRun; run != nil && !run.After(now); {
+		invoice := profile.Invoice(*run)
+
+		rates, err := s.Store.GetTaxRatesByCode(invoice.TaxCodes())
+		if err != nil {
+			return created, err
+		}
+		if err := invoice.SetTaxRates(rates); err != nil {
+			return created, fmt.Errorf("invalid tax code: %w", err)
+		}
+		if err := invoice.ValidateDiscounts(); err != nil {
+			return created, err
+		}
+		if err := invoice.CalculateTotal(); err != nil {
+			return created, err
+		}
+
+		_, err = s.Store.CreateInvoice(invoice)
+		switch {
+		case err == nil:
+			created++
+		case errors.Is(err, database.ErrRecurrenceExists):
+			// Created by an earlier pass that stopped before advancing the profile.
+		default:
+			return created, err
+		}
+
+		next := profile.RunAfter(*run)
+		if err := s.Store.AdvanceRecurringProfile(profile.ID, *run, next); err != nil {
+			if err == sql.ErrNoRows {
+				// The profile was edited or advanced elsewhere; the next pass
+				// picks up its new schedule.
+				return created, nil
+			}
+			return created, err
+		}
+		run = next
+	}
+	return created, nil
+}
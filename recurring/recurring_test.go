@@ -0,0 +1,154 @@
+package recurring
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"tiny-invoicing/database"
+	"tiny-invoicing/models"
+)
+
+// memoryStore keeps profiles and invoices in memory, refusing a second invoice for
+// the same run as the database does.
+type memoryStore struct {
+	profiles map[int]*models.RecurringProfile
+	invoices []*models.Invoice
+	// advanceErr, when set, makes AdvanceRecurringProfile fail, as if the process
+	// stopped right after creating an invoice.
+	advanceErr error
+}
+
+func (s *memoryStore) GetDueRecurringProfiles(now time.Time) ([]models.RecurringProfile, error) {
+	var due []models.RecurringProfile
+	for _, profile := range s.profiles {
+		if profile.Active && profile.NextRun != nil && !profile.NextRun.After(now) {
+			due = append(due, *profile)
+		}
+	}
+	return due, nil
+}
+
+func (s *memoryStore) AdvanceRecurringProfile(id int, run time.Time, next *time.Time) error {
+	if s.advanceErr != nil {
+		return s.advanceErr
+	}
+	profile := s.profiles[id]
+	if profile.NextRun == nil || !profile.NextRun.Equal(run) {
+		return sql.ErrNoRows
+	}
+	profile.LastRun, profile.NextRun = &run, next
+	return nil
+}
+
+func (s *memoryStore) GetTaxRatesByCode(codes []string) ([]models.TaxRate, error) {
+	var rates []models.TaxRate
+	for _, code := range codes {
+		if code == "VAT" {
+			rates = append(rates, models.TaxRate{Code: "VAT", Name: "VAT", Kind: models.TaxKindVAT, Rate: models.NewPercent(2000), Active: true})
+		}
+	}
+	return rates, nil
+}
+
+func (s *memoryStore) CreateInvoice(invoice *models.Invoice) (int64, error) {
+	for _, existing := range s.invoices {
+		if *existing.RecurringProfileID == *invoice.RecurringProfileID && existing.RecurrenceDate.Equal(*invoice.RecurrenceDate) {
+			return 0, database.ErrRecurrenceExists
+		}
+	}
+	s.invoices = append(s.invoices, invoice)
+	return int64(len(s.invoices)), nil
+}
+
+func day(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func newStore(next time.Time) *memoryStore {
+	return &memoryStore{profiles: map[int]*models.RecurringProfile{
+		1: {
+			ID:         1,
+			CustomerID: 2,
+			Currency:   "USD",
+			Cadence:    models.CadenceMonthly,
+			StartDate:  day(2026, 1, 1),
+			DueDays:    30,
+			Active:     true,
+			NextRun:    &next,
+			LineItems: []models.LineItem{
+				{Description: "Retainer", Quantity: 1, UnitPrice: models.NewMoney(100000, "USD"), TaxCodes: []string{"VAT"}},
+			},
+		},
+	}}
+}
+
+func TestScheduler_CatchesUpMissedRuns(t *testing.T) {
+	store := newStore(day(2026, 1, 1))
+	scheduler := &Scheduler{Store: store, Now: func() time.Time { return day(2026, 3, 15) }}
+
+	created, err := scheduler.RunOnce()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created != 3 || len(store.invoices) != 3 {
+		t.Fatalf("created %d invoices, want one each for January, February and March", created)
+	}
+
+	march := store.invoices[2]
+	if !march.IssueDate.Equal(day(2026, 3, 1)) || !march.DueDate.Equal(day(2026, 3, 31)) {
+		t.Errorf("March invoice dated %v, due %v", march.IssueDate, march.DueDate)
+	}
+	if march.Total.Amount != 120000 || march.Status != models.StatusDraft {
+		t.Errorf("March invoice total %s, status %s; want 1200.00 draft", march.Total, march.Status)
+	}
+	if next := store.profiles[1].NextRun; next == nil || !next.Equal(day(2026, 4, 1)) {
+		t.Errorf("next run = %v, want 2026-04-01", next)
+	}
+
+	// Nothing more is due until April.
+	if created, err := scheduler.RunOnce(); err != nil || created != 0 {
+		t.Errorf("second pass created %d invoices (%v), want none", created, err)
+	}
+}
+
+func TestScheduler_IdempotentAfterCrash(t *testing.T) {
+	store := newStore(day(2026, 2, 1))
+	scheduler := &Scheduler{Store: store, Now: func() time.Time { return day(2026, 2, 1).Add(time.Hour) }}
+
+	// The invoice is created, but the profile is not advanced.
+	store.advanceErr = sql.ErrConnDone
+	if _, err := scheduler.RunOnce(); err == nil {
+		t.Fatal("expected the failed advance to be reported")
+	}
+	if len(store.invoices) != 1 {
+		t.Fatalf("got %d invoices, want 1", len(store.invoices))
+	}
+
+	// After a restart the run is found already invoiced and the profile moves on.
+	store.advanceErr = nil
+	created, err := (&Scheduler{Store: store, Now: scheduler.Now}).RunOnce()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created != 0 || len(store.invoices) != 1 {
+		t.Errorf("created %d more invoices, want none", created)
+	}
+	if next := store.profiles[1].NextRun; next == nil || !next.Equal(day(2026, 3, 1)) {
+		t.Errorf("next run = %v, want 2026-03-01", next)
+	}
+}
+
+func TestScheduler_StopsAtEndDate(t *testing.T) {
+	store := newStore(day(2026, 1, 1))
+	end := day(2026, 2, 15)
+	store.profiles[1].EndDate = &end
+	scheduler := &Scheduler{Store: store, Now: func() time.Time { return day(2026, 6, 1) }}
+
+	if created, err := scheduler.RunOnce(); err != nil || created != 2 {
+		t.Fatalf("created %d invoices (%v), want 2", created, err)
+	}
+	if next := store.profiles[1].NextRun; next != nil {
+		t.Errorf("next run = %v, want none after the end date", next)
+	}
+}
@@ -0,0 +1,175 @@
+// Package templates renders invoices as HTML from html/template layouts. Layouts
+// are looked up by name in the database, then in a directory on disk, and the
+// built-in "default" layout is always available.
+package templates
+
+import (
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"tiny-invoicing/database"
+	"tiny-invoicing/models"
+)
+
+//go:embed default.html
+var defaultLayout string
+
+// ErrUnknownTemplate is returned when no template has the requested name.
+var ErrUnknownTemplate = errors.New("unknown template")
+
+// Store loads templates saved in the database.
+type Store interface {
+	GetInvoiceTemplate(name string) (*models.InvoiceTemplate, error)
+}
+
+// Data is what an invoice template is executed with.
+type Data struct {
+	Invoice  *models.Invoice
+	Customer *database.Customer
+	Branding models.Branding
+	// Logo is Branding.LogoURL, which has been validated and is safe to use as an
+	// image source.
+	Logo template.URL
+}
+
+// NewData prepares the data for rendering invoice for customer.
+func NewData(invoice *models.Invoice, customer *database.Customer, branding models.Branding) Data {
+	if branding.Color == "" {
+		branding.Color = models.DefaultBrandColor
+	}
+	return Data{
+		Invoice:  invoice,
+		Customer: customer,
+		Branding: branding,
+		Logo:     template.URL(branding.LogoURL),
+	}
+}
+
+// funcs are available to every template.
+var funcs = template.FuncMap{
+	// date formats a date as "2 January 2006".
+	"date": func(t time.Time) string {
+		return t.Format("2 January 2006")
+	},
+	// lines splits multi-line text such as addresses.
+	"lines": func(s string) []string {
+		return strings.Split(strings.ReplaceAll(strings.TrimSpace(s), "\r\n", "\n"), "\n")
+	},
+}
+
+// Renderer finds templates by name and renders invoices with them.
+type Renderer struct {
+	// Store holds templates saved through the API; it may be nil.
+	Store Store
+	// Dir holds templates as <name>.html files; it may be empty. Files are read on
+	// every render, so edits apply without a restart.
+	Dir string
+}
+
+// Render executes the template called name with data and writes the HTML to w.
+// It returns ErrUnknownTemplate if the name is not found anywhere.
+func (r *Renderer) Render(w io.Writer, name string, data Data) error {
+	body, err := r.lookup(name)
+	if err != nil {
+		return err
+	}
+	tmpl, err := parse(name, body)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, data)
+}
+
+// Exists reports whether a template called name can be found.
+func (r *Renderer) Exists(name string) (bool, error) {
+	_, err := r.lookup(name)
+	if errors.Is(err, ErrUnknownTemplate) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// lookup returns the source of the template called name: a saved template first,
+// then a file in Dir, then the built-in default.
+func (r *Renderer) lookup(name string) (string, error) {
+	if !models.ValidTemplateName(name) {
+		return "", ErrUnknownTemplate
+	}
+
+	if r.Store != nil {
+		saved, err := r.Store.GetInvoiceTemplate(name)
+		if err == nil {
+			return saved.Body, nil
+		}
+		if err != sql.ErrNoRows {
+			return "", err
+		}
+	}
+
+	if r.Dir != "" {
+		body, err := os.ReadFile(filepath.Join(r.Dir, name+".html"))
+		if err == nil {
+			return string(body), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+	}
+
+	if name == models.DefaultTemplate {
+		return defaultLayout, nil
+	}
+	return "", ErrUnknownTemplate
+}
+
+func parse(name, body string) (*template.Template, error) {
+	return template.New(name).Funcs(funcs).Parse(body)
+}
+
+// Check parses body and renders it with a sample invoice, so that mistakes such as
+// misspelt fields are reported when a template is saved rather than when it is used.
+func Check(body string) error {
+	tmpl, err := parse("check", body)
+	if err != nil {
+		return err
+	}
+	if err := tmpl.Execute(io.Discard, sampleData()); err != nil {
+		return fmt.Errorf("template fails on a sample invoice: %w", err)
+	}
+	return nil
+}
+
+// sampleData is a small, fully populated invoice for Check.
+func sampleData() Data {
+	date := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+	paidAt := date.AddDate(0, 0, 3)
+	invoice := &models.Invoice{
+		ID:         1,
+		Number:     "INV-2026-00001",
+		CustomerID: 1,
+		Currency:   models.DefaultCurrency,
+		IssueDate:  date,
+		DueDate:    date.AddDate(0, 0, 30),
+		Status:     models.StatusPaid,
+		SentAt:     &date,
+		PaidAt:     &paidAt,
+		LineItems: []models.LineItem{
+			{Description: "Sample item", Quantity: 2, UnitPrice: models.NewMoney(5000, models.DefaultCurrency), TaxCodes: []string{"VAT"}},
+		},
+	}
+	invoice.SetTaxRates([]models.TaxRate{{Code: "VAT", Name: "VAT", Kind: models.TaxKindVAT, Rate: models.NewPercent(2000), Active: true}})
+	invoice.CalculateTotal()
+	invoice.AmountPaid = invoice.Total
+	invoice.UpdateBalance()
+
+	customer := &database.Customer{ID: 1, Name: "Sample Customer", Email: "billing@example.com", Address: "1 Main St\nSpringfield", Currency: models.DefaultCurrency}
+	return NewData(invoice, customer, models.Branding{CompanyName: "Sample Company", CompanyAddress: "2 High St", PaymentInstructions: "Pay by bank transfer.", Footer: "Thank you"})
+}
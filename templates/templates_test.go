@@ -0,0 +1,116 @@
+package templates
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tiny-invoicing/models"
+)
+
+type mockStore map[string]string
+
+func (m mockStore) GetInvoiceTemplate(name string) (*models.InvoiceTemplate, error) {
+	body, ok := m[name]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &models.InvoiceTemplate{Name: name, Body: body}, nil
+}
+
+func render(t *testing.T, r *Renderer, name string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := r.Render(&buf, name, sampleData()); err != nil {
+		t.Fatalf("Render(%q): %v", name, err)
+	}
+	return buf.String()
+}
+
+func TestRenderer_Lookup(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "compact.html"), []byte("disk {{.Invoice.Title}}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "shared.html"), []byte("disk shared"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r := &Renderer{Store: mockStore{"shared": "saved shared"}, Dir: dir}
+
+	if got := render(t, r, "compact"); got != "disk INV-2026-00001" {
+		t.Errorf("compact = %q, want the file on disk", got)
+	}
+	// Saved templates take precedence over files of the same name.
+	if got := render(t, r, "shared"); got != "saved shared" {
+		t.Errorf("shared = %q, want the saved template", got)
+	}
+	if got := render(t, r, "default"); !strings.Contains(got, "<h1>INVOICE</h1>") {
+		t.Errorf("default did not render the built-in template: %q", got)
+	}
+
+	for _, name := range []string{"missing", "../compact", ""} {
+		if err := r.Render(&bytes.Buffer{}, name, sampleData()); !errors.Is(err, ErrUnknownTemplate) {
+			t.Errorf("Render(%q) error = %v, want ErrUnknownTemplate", name, err)
+		}
+	}
+}
+
+func TestRenderer_DefaultTemplate(t *testing.T) {
+	data := sampleData()
+	data.Customer.Name = `<script>alert("x")</script>`
+	data.Branding.Color = "#198754"
+	data.Logo = "data:image/png;base64,iVBORw0KGgo="
+	data.Invoice.Notes = "PO 4471\nThanks!"
+
+	var buf bytes.Buffer
+	if err := (&Renderer{}).Render(&buf, models.DefaultTemplate, data); err != nil {
+		t.Fatal(err)
+	}
+	html := buf.String()
+
+	for _, want := range []string{
+		"background: #198754",
+		`src="data:image/png;base64,iVBORw0KGgo="`,
+		"&lt;script&gt;",
+		`<td class="muted">VAT (20%)</td><td>20.00</td>`,
+		"Balance due USD</td><td>0.00</td>",
+		"Pay by bank transfer.",
+		"<h2>NOTES</h2>\n<div>PO 4471<br>Thanks!<br></div>",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("default template output does not contain %q", want)
+		}
+	}
+	if strings.Contains(html, "<script>") {
+		t.Error("customer name was not escaped")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		ok   bool
+	}{
+		{"valid", "{{.Invoice.Title}} {{date .Invoice.DueDate}} {{.Branding.CompanyName}}", true},
+		{"syntax error", "{{if .Invoice.Title}}", false},
+		{"unknown field", "{{.Invoice.Nmber}}", false},
+		{"unknown function", "{{money .Invoice.Total}}", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Check(tt.body)
+			if tt.ok && err != nil {
+				t.Errorf("Check() error = %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Error("Check() accepted an invalid template")
+			}
+		})
+	}
+}